@@ -0,0 +1,1001 @@
+// Code generated by go run ./gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type APIError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Field   string          `json:"field,omitempty"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+type Backup struct {
+	ID        string   `json:"id"`
+	ProjectID string   `json:"project_id"`
+	FileName  string   `json:"file_name"`
+	SizeBytes int64    `json:"size_bytes"`
+	Volumes   []string `json:"volumes"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+type BundleDomain struct {
+	Hostname      string `json:"hostname"`
+	Service       string `json:"service,omitempty"`
+	ContainerPort int    `json:"container_port,omitempty"`
+}
+
+type BundleHealthCheck struct {
+	Type           string `json:"type"`
+	Path           string `json:"path,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	Service        string `json:"service,omitempty"`
+	Port           int    `json:"port,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+type BundlePort struct {
+	Service       string `json:"service,omitempty"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+type BundleProject struct {
+	Name              string                `json:"name"`
+	GitURL            string                `json:"git_url"`
+	GitRef            string                `json:"git_ref,omitempty"`
+	RepoSubdir        string                `json:"repo_subdir,omitempty"`
+	DeployType        string                `json:"deploy_type,omitempty"`
+	ComposeFile       string                `json:"compose_file,omitempty"`
+	ComposeService    string                `json:"compose_service,omitempty"`
+	DockerfilePath    string                `json:"dockerfile_path,omitempty"`
+	DockerfileContent string                `json:"dockerfile_content,omitempty"`
+	ComposeContent    string                `json:"compose_content,omitempty"`
+	ExposeMode        string                `json:"expose_mode,omitempty"`
+	BindIP            string                `json:"bind_ip,omitempty"`
+	Ports             []BundlePort          `json:"ports,omitempty"`
+	HealthCheck       *BundleHealthCheck    `json:"health_check,omitempty"`
+	DeployStrategy    string                `json:"deploy_strategy,omitempty"`
+	AutoRollback      bool                  `json:"auto_rollback,omitempty"`
+	SparseCheckout    bool                  `json:"sparse_checkout,omitempty"`
+	ResourceLimits    *BundleResourceLimits `json:"resource_limits,omitempty"`
+	Volumes           []BundleVolume        `json:"volumes,omitempty"`
+	Domains           []BundleDomain        `json:"domains,omitempty"`
+	BuildArgs         map[string]string     `json:"build_args,omitempty"`
+	Env               []string              `json:"env,omitempty"`
+}
+
+type BundleResourceLimits struct {
+	MemoryMB     int64 `json:"memory_mb,omitempty"`
+	MemorySwapMB int64 `json:"memory_swap_mb,omitempty"`
+	CPUShares    int64 `json:"cpu_shares,omitempty"`
+	CPUQuota     int64 `json:"cpu_quota,omitempty"`
+	PidsLimit    int64 `json:"pids_limit,omitempty"`
+}
+
+type BundleVolume struct {
+	Name          string `json:"name"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only,omitempty"`
+}
+
+type CertificateInfo struct {
+	Hostname  string   `json:"hostname"`
+	Source    string   `json:"source"`
+	Issuer    string   `json:"issuer"`
+	DNSNames  []string `json:"dns_names"`
+	NotBefore int64    `json:"not_before"`
+	NotAfter  int64    `json:"not_after"`
+}
+
+type CloneProjectRequest struct {
+	Name     string  `json:"name"`
+	GitRef   *string `json:"git_ref,omitempty"`
+	HostPort int     `json:"host_port"`
+	Deploy   bool    `json:"deploy"`
+}
+
+type CreateGitCredentialRequest struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	Secret     string `json:"secret"`
+	KnownHosts string `json:"known_hosts"`
+}
+
+type CreateProjectDomainRequest struct {
+	Hostname      string `json:"hostname"`
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+}
+
+type CreateProjectFromDraftRequest struct {
+	DraftID           string            `json:"draft_id"`
+	DockerfileContent string            `json:"dockerfile_content"`
+	ComposeContent    string            `json:"compose_content"`
+	ComposeService    string            `json:"compose_service"`
+	GitRef            string            `json:"git_ref"`
+	RepoSubdir        string            `json:"repo_subdir"`
+	SparseCheckout    bool              `json:"sparse_checkout"`
+	HostPort          int               `json:"host_port"`
+	ExposeMode        string            `json:"expose_mode"`
+	BindIP            string            `json:"bind_ip"`
+	Deploy            bool              `json:"deploy"`
+	Env               map[string]string `json:"env,omitempty"`
+}
+
+type CreateProjectRequest struct {
+	Name            string               `json:"name"`
+	GitURL          string               `json:"git_url"`
+	GitRef          string               `json:"git_ref"`
+	RepoSubdir      string               `json:"repo_subdir"`
+	DeployType      string               `json:"deploy_type"`
+	ComposeFile     string               `json:"compose_file"`
+	ComposeService  string               `json:"compose_service"`
+	DockerfilePath  string               `json:"dockerfile_path"`
+	HostPort        int                  `json:"host_port"`
+	ContainerPort   int                  `json:"container_port"`
+	ExposeMode      string               `json:"expose_mode"`
+	BindIP          string               `json:"bind_ip"`
+	Deploy          bool                 `json:"deploy"`
+	GitCredentialID string               `json:"git_credential_id"`
+	SparseCheckout  bool                 `json:"sparse_checkout"`
+	Ports           []ProjectPortRequest `json:"ports"`
+}
+
+type CreateProjectVolumeRequest struct {
+	Name          string `json:"name"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+}
+
+type DBBackup struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type DetectProjectRequest struct {
+	Name            string `json:"name"`
+	GitURL          string `json:"git_url"`
+	GitCredentialID string `json:"git_credential_id"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type GitCredential struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	PublicKey  string `json:"public_key,omitempty"`
+	KnownHosts string `json:"known_hosts"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+type GitOpsPlan struct {
+	Commit      string       `json:"commit"`
+	Items       []ImportItem `json:"items"`
+	Destructive bool         `json:"destructive"`
+}
+
+type GitOpsStatus struct {
+	Enabled          bool        `json:"enabled"`
+	Repo             string      `json:"repo,omitempty"`
+	Ref              string      `json:"ref,omitempty"`
+	Path             string      `json:"path,omitempty"`
+	AllowDestructive bool        `json:"allow_destructive"`
+	Adopt            bool        `json:"adopt"`
+	LastSyncAt       int64       `json:"last_sync_at,omitempty"`
+	LastCommit       string      `json:"last_commit,omitempty"`
+	LastError        string      `json:"last_error,omitempty"`
+	LastPlan         *GitOpsPlan `json:"last_plan,omitempty"`
+}
+
+type HealthCheck struct {
+	Type           string `json:"type"`
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expected_status"`
+	Service        string `json:"service"`
+	Port           int    `json:"port"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+type HealthCheckRequest struct {
+	Type           string `json:"type"`
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expected_status"`
+	Service        string `json:"service"`
+	Port           int    `json:"port"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+type ImportItem struct {
+	Name      string   `json:"name"`
+	Action    string   `json:"action"`
+	ProjectID string   `json:"project_id,omitempty"`
+	Changes   []string `json:"changes,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Env       []string `json:"env,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type Job struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"project_id"`
+	Type        string `json:"type"`
+	Target      string `json:"target,omitempty"`
+	Status      string `json:"status"`
+	CurrentStep string `json:"current_step"`
+	Log         string `json:"log"`
+	Error       string `json:"error"`
+	RequestedAt int64  `json:"requested_at"`
+	StartedAt   *int64 `json:"started_at,omitempty"`
+	FinishedAt  *int64 `json:"finished_at,omitempty"`
+}
+
+type Preview struct {
+	ProjectID  string `json:"project_id"`
+	TemplateID string `json:"template_id"`
+	PrNumber   int    `json:"pr_number"`
+	HeadRef    string `json:"head_ref"`
+	HeadSha    string `json:"head_sha"`
+	ExpiresAt  int64  `json:"expires_at"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+type Project struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	GitURL            string            `json:"git_url"`
+	GitRef            string            `json:"git_ref"`
+	RepoSubdir        string            `json:"repo_subdir"`
+	DeployType        string            `json:"deploy_type"`
+	ComposeFile       string            `json:"compose_file"`
+	ComposeService    string            `json:"compose_service"`
+	DockerfilePath    string            `json:"dockerfile_path"`
+	DockerfileContent string            `json:"dockerfile_content,omitempty"`
+	ComposeContent    string            `json:"compose_content,omitempty"`
+	HostPort          int               `json:"host_port"`
+	ContainerPort     int               `json:"container_port"`
+	ExposeMode        string            `json:"expose_mode"`
+	BindIP            string            `json:"bind_ip"`
+	LastStatus        string            `json:"last_status"`
+	LastStatusAt      *int64            `json:"last_status_at,omitempty"`
+	DeletedAt         *int64            `json:"deleted_at,omitempty"`
+	CreatedAt         int64             `json:"created_at"`
+	UpdatedAt         int64             `json:"updated_at"`
+	Ports             []ProjectPort     `json:"ports"`
+	HealthCheck       HealthCheck       `json:"health_check"`
+	DeployStrategy    string            `json:"deploy_strategy"`
+	AutoRollback      bool              `json:"auto_rollback"`
+	ResourceLimits    ResourceLimits    `json:"resource_limits"`
+	ManagedBy         string            `json:"managed_by"`
+	BuildArgs         map[string]string `json:"build_args,omitempty"`
+	GitCredentialID   string            `json:"git_credential_id,omitempty"`
+	SparseCheckout    bool              `json:"sparse_checkout"`
+}
+
+type ProjectBundle struct {
+	Version    int             `json:"version"`
+	ExportedAt int64           `json:"exported_at,omitempty"`
+	Projects   []BundleProject `json:"projects"`
+}
+
+type ProjectDomain struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Hostname      string `json:"hostname"`
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+type ProjectEnvVar struct {
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type ProjectPort struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Service       string `json:"service"`
+	HostIP        string `json:"host_ip"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+type ProjectPortRequest struct {
+	Service       string `json:"service"`
+	HostIP        string `json:"host_ip"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+type ProjectVolume struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Name          string `json:"name"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+type RepoConfig struct {
+	DeployType     string            `json:"deploy_type,omitempty"`
+	DockerfilePath string            `json:"dockerfile_path,omitempty"`
+	ComposeFile    string            `json:"compose_file,omitempty"`
+	ComposeService string            `json:"compose_service,omitempty"`
+	Ports          []RepoPort        `json:"ports,omitempty"`
+	HealthCheck    *RepoHealthCheck  `json:"health_check,omitempty"`
+	Env            []string          `json:"env,omitempty"`
+	BuildArgs      map[string]string `json:"build_args,omitempty"`
+}
+
+type RepoHealthCheck struct {
+	Type           string `json:"type"`
+	Path           string `json:"path,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty"`
+	Service        string `json:"service,omitempty"`
+	Port           int    `json:"port,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+type RepoPort struct {
+	Service       string `json:"service,omitempty"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+type ResourceLimits struct {
+	MemoryMB     int64 `json:"memory_mb"`
+	MemorySwapMB int64 `json:"memory_swap_mb"`
+	CPUShares    int64 `json:"cpu_shares"`
+	CPUQuota     int64 `json:"cpu_quota"`
+	PidsLimit    int64 `json:"pids_limit"`
+}
+
+type ResourceLimitsRequest struct {
+	MemoryMB     int64 `json:"memory_mb"`
+	MemorySwapMB int64 `json:"memory_swap_mb"`
+	CPUShares    int64 `json:"cpu_shares"`
+	CPUQuota     int64 `json:"cpu_quota"`
+	PidsLimit    int64 `json:"pids_limit"`
+}
+
+type SetProjectEnvRequest struct {
+	Value string `json:"value"`
+}
+
+type UpdateAutoRollbackRequest struct {
+	AutoRollback *bool `json:"auto_rollback"`
+}
+
+type UpdateDeployStrategyRequest struct {
+	DeployStrategy string `json:"deploy_strategy"`
+}
+
+type UpdateGitCredentialRequest struct {
+	Name       *string `json:"name,omitempty"`
+	Username   *string `json:"username,omitempty"`
+	Secret     *string `json:"secret,omitempty"`
+	KnownHosts *string `json:"known_hosts,omitempty"`
+}
+
+type UpdateProjectConfigRequest struct {
+	DockerfileContent string `json:"dockerfile_content"`
+	ComposeContent    string `json:"compose_content"`
+}
+
+type UpdateProjectExposureRequest struct {
+	ExposeMode string `json:"expose_mode"`
+	BindIP     string `json:"bind_ip"`
+}
+
+type UpdateProjectRequest struct {
+	Name            *string              `json:"name,omitempty"`
+	GitURL          *string              `json:"git_url,omitempty"`
+	GitRef          *string              `json:"git_ref,omitempty"`
+	RepoSubdir      *string              `json:"repo_subdir,omitempty"`
+	DeployType      *string              `json:"deploy_type,omitempty"`
+	ComposeFile     *string              `json:"compose_file,omitempty"`
+	ComposeService  *string              `json:"compose_service,omitempty"`
+	DockerfilePath  *string              `json:"dockerfile_path,omitempty"`
+	ExposeMode      *string              `json:"expose_mode,omitempty"`
+	BindIP          *string              `json:"bind_ip,omitempty"`
+	Ports           []ProjectPortRequest `json:"ports,omitempty"`
+	GitCredentialID *string              `json:"git_credential_id,omitempty"`
+	SparseCheckout  *bool                `json:"sparse_checkout,omitempty"`
+}
+
+type UploadCertificateRequest struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+type CloneProjectResponse struct {
+	Job     *Job    `json:"job,omitempty"`
+	Project Project `json:"project"`
+}
+
+type CreateProjectResponse struct {
+	Job     *Job    `json:"job,omitempty"`
+	Project Project `json:"project"`
+}
+
+type CreateProjectFromDraftResponse struct {
+	Job     *Job    `json:"job,omitempty"`
+	Project Project `json:"project"`
+}
+
+// DeleteProjectParams holds the query parameters of DeleteProject.
+type DeleteProjectParams struct {
+	// Also delete the project's volumes.
+	Purge bool
+}
+
+type DetectProjectResponse struct {
+	ComposeContent    string      `json:"compose_content"`
+	ComposePath       string      `json:"compose_path"`
+	ComposeService    string      `json:"compose_service"`
+	Config            *RepoConfig `json:"config,omitempty"`
+	ConfigPath        string      `json:"config_path"`
+	DeployType        string      `json:"deploy_type"`
+	DockerfileContent string      `json:"dockerfile_content"`
+	DockerfilePath    string      `json:"dockerfile_path"`
+	DraftID           string      `json:"draft_id"`
+	Services          []string    `json:"services"`
+}
+
+// ExportProjectsParams holds the query parameters of ExportProjects.
+type ExportProjectsParams struct {
+	// Output format: json (default) or yaml.
+	Format string
+}
+
+// GetProjectLogsParams holds the query parameters of GetProjectLogs.
+type GetProjectLogsParams struct {
+	// Lines to show from the end, default 200.
+	Tail int
+	// Keep streaming new lines.
+	Follow bool
+}
+
+// ImportProjectsParams holds the query parameters of ImportProjects.
+type ImportProjectsParams struct {
+	// Only return the import plan.
+	DryRun bool
+}
+
+type ImportProjectsResponse struct {
+	DryRun bool         `json:"dry_run"`
+	Items  []ImportItem `json:"items"`
+}
+
+type ListProjectDomainsResponse struct {
+	DefaultHostname string          `json:"default_hostname"`
+	Domains         []ProjectDomain `json:"domains"`
+}
+
+// SyncGitOpsParams holds the query parameters of SyncGitOps.
+type SyncGitOpsParams struct {
+	// Allow deleting projects missing from the manifest.
+	AllowDestructive bool
+}
+
+type UpdateProjectResponse struct {
+	Project          Project `json:"project"`
+	RequiresRedeploy bool    `json:"requires_redeploy"`
+}
+
+type UpdateProjectExposureResponse struct {
+	BindIP     string `json:"bind_ip"`
+	ExposeMode string `json:"expose_mode"`
+}
+
+// CloneProject calls POST /projects/{id}/clone: copy a project into a new one.
+func (c *Client) CloneProject(ctx context.Context, id string, req CloneProjectRequest) (CloneProjectResponse, error) {
+	var out CloneProjectResponse
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/clone", nil, req, &out)
+	return out, err
+}
+
+// CreateDBBackup calls POST /admin/db-backups: back up the database now.
+func (c *Client) CreateDBBackup(ctx context.Context) (DBBackup, error) {
+	var out struct {
+		Backup DBBackup `json:"backup"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/db-backups", nil, nil, &out)
+	return out.Backup, err
+}
+
+// CreateGitCredential calls POST /git-credentials: create a deploy key, token or password credential.
+func (c *Client) CreateGitCredential(ctx context.Context, req CreateGitCredentialRequest) (GitCredential, error) {
+	var out struct {
+		Credential GitCredential `json:"credential"`
+	}
+	err := c.do(ctx, http.MethodPost, "/git-credentials", nil, req, &out)
+	return out.Credential, err
+}
+
+// CreateProject calls POST /projects: create a project and optionally deploy it.
+func (c *Client) CreateProject(ctx context.Context, req CreateProjectRequest) (CreateProjectResponse, error) {
+	var out CreateProjectResponse
+	err := c.do(ctx, http.MethodPost, "/projects", nil, req, &out)
+	return out, err
+}
+
+// CreateProjectBackup calls POST /projects/{id}/backups: start a volume backup.
+func (c *Client) CreateProjectBackup(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/backups", nil, nil, &out)
+	return out.Job, err
+}
+
+// CreateProjectDomain calls POST /projects/{id}/domains: add a domain.
+func (c *Client) CreateProjectDomain(ctx context.Context, id string, req CreateProjectDomainRequest) (ProjectDomain, error) {
+	var out struct {
+		Domain ProjectDomain `json:"domain"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/domains", nil, req, &out)
+	return out.Domain, err
+}
+
+// CreateProjectFromDraft calls POST /projects/from-draft: create a project from a detection draft.
+func (c *Client) CreateProjectFromDraft(ctx context.Context, req CreateProjectFromDraftRequest) (CreateProjectFromDraftResponse, error) {
+	var out CreateProjectFromDraftResponse
+	err := c.do(ctx, http.MethodPost, "/projects/from-draft", nil, req, &out)
+	return out, err
+}
+
+// CreateProjectPort calls POST /projects/{id}/ports: add a port mapping.
+func (c *Client) CreateProjectPort(ctx context.Context, id string, req ProjectPortRequest) (ProjectPort, error) {
+	var out struct {
+		Port ProjectPort `json:"port"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/ports", nil, req, &out)
+	return out.Port, err
+}
+
+// CreateProjectVolume calls POST /projects/{id}/volumes: add a volume.
+func (c *Client) CreateProjectVolume(ctx context.Context, id string, req CreateProjectVolumeRequest) (ProjectVolume, error) {
+	var out struct {
+		Volume ProjectVolume `json:"volume"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/volumes", nil, req, &out)
+	return out.Volume, err
+}
+
+// DeleteDomainCertificate calls DELETE /projects/{id}/domains/{domainId}/certificate: remove the custom certificate.
+func (c *Client) DeleteDomainCertificate(ctx context.Context, id string, domainID string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/domains/"+url.PathEscape(domainID)+"/certificate", nil, nil, nil)
+}
+
+// DeleteGitCredential calls DELETE /git-credentials/{id}: delete a git credential no project uses.
+func (c *Client) DeleteGitCredential(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/git-credentials/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// DeleteProject calls DELETE /projects/{id}: start deleting a project.
+func (c *Client) DeleteProject(ctx context.Context, id string, params DeleteProjectParams) (Job, error) {
+	q := url.Values{}
+	if params.Purge {
+		q.Set("purge", "true")
+	}
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id), q, nil, &out)
+	return out.Job, err
+}
+
+// DeleteProjectBackup calls DELETE /projects/{id}/backups/{backupId}: delete a backup.
+func (c *Client) DeleteProjectBackup(ctx context.Context, id string, backupID string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/backups/"+url.PathEscape(backupID), nil, nil, nil)
+}
+
+// DeleteProjectDomain calls DELETE /projects/{id}/domains/{domainId}: remove a domain.
+func (c *Client) DeleteProjectDomain(ctx context.Context, id string, domainID string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/domains/"+url.PathEscape(domainID), nil, nil, nil)
+}
+
+// DeleteProjectEnv calls DELETE /projects/{id}/env/{name}: remove an environment variable.
+func (c *Client) DeleteProjectEnv(ctx context.Context, id string, name string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/env/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// DeleteProjectPort calls DELETE /projects/{id}/ports/{portId}: remove a port mapping.
+func (c *Client) DeleteProjectPort(ctx context.Context, id string, portID string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/ports/"+url.PathEscape(portID), nil, nil, nil)
+}
+
+// DeleteProjectVolume calls DELETE /projects/{id}/volumes/{volumeId}: remove a volume.
+func (c *Client) DeleteProjectVolume(ctx context.Context, id string, volumeID string) error {
+	return c.do(ctx, http.MethodDelete, "/projects/"+url.PathEscape(id)+"/volumes/"+url.PathEscape(volumeID), nil, nil, nil)
+}
+
+// DeployProject calls POST /projects/{id}/deploy: start a deploy.
+func (c *Client) DeployProject(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/deploy", nil, nil, &out)
+	return out.Job, err
+}
+
+// DetectProject calls POST /projects/detect: clone a repository and detect how to deploy it.
+func (c *Client) DetectProject(ctx context.Context, req DetectProjectRequest) (DetectProjectResponse, error) {
+	var out DetectProjectResponse
+	err := c.do(ctx, http.MethodPost, "/projects/detect", nil, req, &out)
+	return out, err
+}
+
+// DownloadDBBackup calls GET /admin/db-backups/{name}/download: download a database backup.
+func (c *Client) DownloadDBBackup(ctx context.Context, name string) (io.ReadCloser, error) {
+	return c.stream(ctx, http.MethodGet, "/admin/db-backups/"+url.PathEscape(name)+"/download", nil, nil)
+}
+
+// DownloadProjectBackup calls GET /projects/{id}/backups/{backupId}/download: download a backup archive.
+func (c *Client) DownloadProjectBackup(ctx context.Context, id string, backupID string) (io.ReadCloser, error) {
+	return c.stream(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/backups/"+url.PathEscape(backupID)+"/download", nil, nil)
+}
+
+// ExportProjects calls GET /projects/export: export all project definitions.
+func (c *Client) ExportProjects(ctx context.Context, params ExportProjectsParams) (ProjectBundle, error) {
+	q := url.Values{}
+	if params.Format != "" {
+		q.Set("format", params.Format)
+	}
+	var out ProjectBundle
+	err := c.do(ctx, http.MethodGet, "/projects/export", q, nil, &out)
+	return out, err
+}
+
+// GetDomainCertificate calls GET /projects/{id}/domains/{domainId}/certificate: get the certificate of a domain.
+func (c *Client) GetDomainCertificate(ctx context.Context, id string, domainID string) (*CertificateInfo, error) {
+	var out struct {
+		Certificate *CertificateInfo `json:"certificate"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/domains/"+url.PathEscape(domainID)+"/certificate", nil, nil, &out)
+	return out.Certificate, err
+}
+
+// GetGitOpsStatus calls GET /gitops: get the GitOps status.
+func (c *Client) GetGitOpsStatus(ctx context.Context) (GitOpsStatus, error) {
+	var out struct {
+		Gitops GitOpsStatus `json:"gitops"`
+	}
+	err := c.do(ctx, http.MethodGet, "/gitops", nil, nil, &out)
+	return out.Gitops, err
+}
+
+// GetJob calls GET /jobs/{id}: get a job.
+func (c *Client) GetJob(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, nil, &out)
+	return out.Job, err
+}
+
+// GetOpenAPI calls GET /openapi.json: return this OpenAPI document.
+func (c *Client) GetOpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &out)
+	return out, err
+}
+
+// GetProject calls GET /projects/{id}: get a project.
+func (c *Client) GetProject(ctx context.Context, id string) (Project, error) {
+	var out struct {
+		Project Project `json:"project"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id), nil, nil, &out)
+	return out.Project, err
+}
+
+// GetProjectLatestJob calls GET /projects/{id}/jobs/latest: get the latest job of a project.
+func (c *Client) GetProjectLatestJob(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/jobs/latest", nil, nil, &out)
+	return out.Job, err
+}
+
+// GetProjectLogs calls GET /projects/{id}/logs: read container logs.
+func (c *Client) GetProjectLogs(ctx context.Context, id string, params GetProjectLogsParams) (io.ReadCloser, error) {
+	q := url.Values{}
+	if params.Tail != 0 {
+		q.Set("tail", strconv.Itoa(params.Tail))
+	}
+	if params.Follow {
+		q.Set("follow", "true")
+	}
+	return c.stream(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/logs", q, nil)
+}
+
+// Health calls GET /health: check that the server is up.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil, nil)
+}
+
+// ImportProjects calls POST /projects/import: import project definitions.
+func (c *Client) ImportProjects(ctx context.Context, req ProjectBundle, params ImportProjectsParams) (ImportProjectsResponse, error) {
+	q := url.Values{}
+	if params.DryRun {
+		q.Set("dry_run", "true")
+	}
+	var out ImportProjectsResponse
+	err := c.do(ctx, http.MethodPost, "/projects/import", q, req, &out)
+	return out, err
+}
+
+// ListDBBackups calls GET /admin/db-backups: list database backups.
+func (c *Client) ListDBBackups(ctx context.Context) ([]DBBackup, error) {
+	var out struct {
+		Backups []DBBackup `json:"backups"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin/db-backups", nil, nil, &out)
+	return out.Backups, err
+}
+
+// ListGitCredentials calls GET /git-credentials: list git credentials without their secrets.
+func (c *Client) ListGitCredentials(ctx context.Context) ([]GitCredential, error) {
+	var out struct {
+		Credentials []GitCredential `json:"credentials"`
+	}
+	err := c.do(ctx, http.MethodGet, "/git-credentials", nil, nil, &out)
+	return out.Credentials, err
+}
+
+// ListProjectBackups calls GET /projects/{id}/backups: list volume backups.
+func (c *Client) ListProjectBackups(ctx context.Context, id string) ([]Backup, error) {
+	var out struct {
+		Backups []Backup `json:"backups"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/backups", nil, nil, &out)
+	return out.Backups, err
+}
+
+// ListProjectDomains calls GET /projects/{id}/domains: list domains.
+func (c *Client) ListProjectDomains(ctx context.Context, id string) (ListProjectDomainsResponse, error) {
+	var out ListProjectDomainsResponse
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/domains", nil, nil, &out)
+	return out, err
+}
+
+// ListProjectEnv calls GET /projects/{id}/env: list environment variables.
+func (c *Client) ListProjectEnv(ctx context.Context, id string) ([]ProjectEnvVar, error) {
+	var out struct {
+		Env []ProjectEnvVar `json:"env"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/env", nil, nil, &out)
+	return out.Env, err
+}
+
+// ListProjectPorts calls GET /projects/{id}/ports: list port mappings.
+func (c *Client) ListProjectPorts(ctx context.Context, id string) ([]ProjectPort, error) {
+	var out struct {
+		Ports []ProjectPort `json:"ports"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/ports", nil, nil, &out)
+	return out.Ports, err
+}
+
+// ListProjectPreviews calls GET /projects/{id}/previews: list the pull request previews of a template project.
+func (c *Client) ListProjectPreviews(ctx context.Context, id string) ([]Preview, error) {
+	var out struct {
+		Previews []Preview `json:"previews"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/previews", nil, nil, &out)
+	return out.Previews, err
+}
+
+// ListProjectVolumes calls GET /projects/{id}/volumes: list volumes.
+func (c *Client) ListProjectVolumes(ctx context.Context, id string) ([]ProjectVolume, error) {
+	var out struct {
+		Volumes []ProjectVolume `json:"volumes"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(id)+"/volumes", nil, nil, &out)
+	return out.Volumes, err
+}
+
+// ListProjects calls GET /projects: list projects.
+func (c *Client) ListProjects(ctx context.Context) ([]Project, error) {
+	var out struct {
+		Projects []Project `json:"projects"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects", nil, nil, &out)
+	return out.Projects, err
+}
+
+// PauseProject calls POST /projects/{id}/pause: pause the containers.
+func (c *Client) PauseProject(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/pause", nil, nil, &out)
+	return out.Job, err
+}
+
+// PlanGitOps calls POST /gitops/plan: plan a GitOps sync without applying it.
+func (c *Client) PlanGitOps(ctx context.Context) (GitOpsPlan, error) {
+	var out struct {
+		Plan GitOpsPlan `json:"plan"`
+	}
+	err := c.do(ctx, http.MethodPost, "/gitops/plan", nil, nil, &out)
+	return out.Plan, err
+}
+
+// PreviewWebhook calls POST /previews/github/{id}: receive a GitHub pull_request webhook for a template project.
+func (c *Client) PreviewWebhook(ctx context.Context, id string, req json.RawMessage) (Preview, error) {
+	var out struct {
+		Preview Preview `json:"preview"`
+	}
+	err := c.do(ctx, http.MethodPost, "/previews/github/"+url.PathEscape(id), nil, req, &out)
+	return out.Preview, err
+}
+
+// RestoreProjectBackup calls POST /projects/{id}/backups/{backupId}/restore: start restoring a backup.
+func (c *Client) RestoreProjectBackup(ctx context.Context, id string, backupID string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/backups/"+url.PathEscape(backupID)+"/restore", nil, nil, &out)
+	return out.Job, err
+}
+
+// SetProjectEnv calls PUT /projects/{id}/env/{name}: set an environment variable.
+func (c *Client) SetProjectEnv(ctx context.Context, id string, name string, req SetProjectEnvRequest) (ProjectEnvVar, error) {
+	var out struct {
+		Env ProjectEnvVar `json:"env"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/env/"+url.PathEscape(name), nil, req, &out)
+	return out.Env, err
+}
+
+// StartProject calls POST /projects/{id}/start: start the containers.
+func (c *Client) StartProject(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/start", nil, nil, &out)
+	return out.Job, err
+}
+
+// StopProject calls POST /projects/{id}/stop: stop the containers.
+func (c *Client) StopProject(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/stop", nil, nil, &out)
+	return out.Job, err
+}
+
+// SyncGitOps calls POST /gitops/sync: sync projects with the manifest repository.
+func (c *Client) SyncGitOps(ctx context.Context, params SyncGitOpsParams) (GitOpsPlan, error) {
+	q := url.Values{}
+	if params.AllowDestructive {
+		q.Set("allow_destructive", "true")
+	}
+	var out struct {
+		Plan GitOpsPlan `json:"plan"`
+	}
+	err := c.do(ctx, http.MethodPost, "/gitops/sync", q, nil, &out)
+	return out.Plan, err
+}
+
+// UnpauseProject calls POST /projects/{id}/unpause: unpause the containers.
+func (c *Client) UnpauseProject(ctx context.Context, id string) (Job, error) {
+	var out struct {
+		Job Job `json:"job"`
+	}
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(id)+"/unpause", nil, nil, &out)
+	return out.Job, err
+}
+
+// UpdateGitCredential calls PATCH /git-credentials/{id}: change a git credential.
+func (c *Client) UpdateGitCredential(ctx context.Context, id string, req UpdateGitCredentialRequest) (GitCredential, error) {
+	var out struct {
+		Credential GitCredential `json:"credential"`
+	}
+	err := c.do(ctx, http.MethodPatch, "/git-credentials/"+url.PathEscape(id), nil, req, &out)
+	return out.Credential, err
+}
+
+// UpdateProject calls PATCH /projects/{id}: change project settings.
+func (c *Client) UpdateProject(ctx context.Context, id string, req UpdateProjectRequest) (UpdateProjectResponse, error) {
+	var out UpdateProjectResponse
+	err := c.do(ctx, http.MethodPatch, "/projects/"+url.PathEscape(id), nil, req, &out)
+	return out, err
+}
+
+// UpdateProjectAutoRollback calls PUT /projects/{id}/auto-rollback: turn automatic rollback on or off.
+func (c *Client) UpdateProjectAutoRollback(ctx context.Context, id string, req UpdateAutoRollbackRequest) (bool, error) {
+	var out struct {
+		AutoRollback bool `json:"auto_rollback"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/auto-rollback", nil, req, &out)
+	return out.AutoRollback, err
+}
+
+// UpdateProjectConfig calls PUT /projects/{id}/config: replace the Dockerfile and compose content.
+func (c *Client) UpdateProjectConfig(ctx context.Context, id string, req UpdateProjectConfigRequest) error {
+	return c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/config", nil, req, nil)
+}
+
+// UpdateProjectDeployStrategy calls PUT /projects/{id}/deploy-strategy: change the deploy strategy.
+func (c *Client) UpdateProjectDeployStrategy(ctx context.Context, id string, req UpdateDeployStrategyRequest) (string, error) {
+	var out struct {
+		DeployStrategy string `json:"deploy_strategy"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/deploy-strategy", nil, req, &out)
+	return out.DeployStrategy, err
+}
+
+// UpdateProjectExposure calls PUT /projects/{id}/exposure: change where published ports listen.
+func (c *Client) UpdateProjectExposure(ctx context.Context, id string, req UpdateProjectExposureRequest) (UpdateProjectExposureResponse, error) {
+	var out UpdateProjectExposureResponse
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/exposure", nil, req, &out)
+	return out, err
+}
+
+// UpdateProjectHealthCheck calls PUT /projects/{id}/health-check: change the health check.
+func (c *Client) UpdateProjectHealthCheck(ctx context.Context, id string, req HealthCheckRequest) (HealthCheck, error) {
+	var out struct {
+		HealthCheck HealthCheck `json:"health_check"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/health-check", nil, req, &out)
+	return out.HealthCheck, err
+}
+
+// UpdateProjectPort calls PUT /projects/{id}/ports/{portId}: change a port mapping.
+func (c *Client) UpdateProjectPort(ctx context.Context, id string, portID string, req ProjectPortRequest) (ProjectPort, error) {
+	var out struct {
+		Port ProjectPort `json:"port"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/ports/"+url.PathEscape(portID), nil, req, &out)
+	return out.Port, err
+}
+
+// UpdateProjectResources calls PUT /projects/{id}/resources: change container resource limits.
+func (c *Client) UpdateProjectResources(ctx context.Context, id string, req ResourceLimitsRequest) (ResourceLimits, error) {
+	var out struct {
+		ResourceLimits ResourceLimits `json:"resource_limits"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/resources", nil, req, &out)
+	return out.ResourceLimits, err
+}
+
+// UploadDomainCertificate calls PUT /projects/{id}/domains/{domainId}/certificate: upload a custom certificate.
+func (c *Client) UploadDomainCertificate(ctx context.Context, id string, domainID string, req UploadCertificateRequest) (CertificateInfo, error) {
+	var out struct {
+		Certificate CertificateInfo `json:"certificate"`
+	}
+	err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(id)+"/domains/"+url.PathEscape(domainID)+"/certificate", nil, req, &out)
+	return out.Certificate, err
+}
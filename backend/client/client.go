@@ -0,0 +1,123 @@
+// Package client is a typed Go client for the last-deploy HTTP API.
+//
+// The types and methods in api_gen.go are generated from the server's
+// OpenAPI document (GET /api/openapi.json). Run "go generate ./client"
+// after changing the API.
+package client
+
+//go:generate go run ./gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls a last-deploy server.
+type Client struct {
+	// BaseURL is the server root, e.g. http://127.0.0.1:8080.
+	BaseURL string
+	// Token is sent as a bearer token when set.
+	Token string
+	// HTTPClient defaults to http.DefaultClient. Streaming calls such as
+	// GetProjectLogs with Follow run until the context is canceled, so
+	// avoid a client-wide timeout when using them.
+	HTTPClient *http.Client
+}
+
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// Error is a non-2xx response from the server.
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error code, such as "project_not_found".
+	Code    string
+	Message string
+	// Field names the request field the error refers to, if any.
+	Field string
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("last-deploy: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	rc, err := c.stream(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, rc)
+		return err
+	}
+	if err := json.NewDecoder(rc).Decode(out); err != nil {
+		return fmt.Errorf("last-deploy: decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// stream sends a request and returns the body of a 2xx response.
+func (c *Client) stream(ctx context.Context, method, path string, query url.Values, body any) (io.ReadCloser, error) {
+	u := strings.TrimRight(c.BaseURL, "/") + "/api" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	e := &Error{StatusCode: resp.StatusCode, Body: data}
+	var msg ErrorResponse
+	if json.Unmarshal(data, &msg) == nil && msg.Error.Code != "" {
+		e.Code, e.Message, e.Field = msg.Error.Code, msg.Error.Message, msg.Error.Field
+	} else if e.Message = strings.TrimSpace(string(data)); e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, e
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"last-deploy/internal/api"
+	"last-deploy/internal/openapi"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	want, err := openapi.GenerateClient(api.OpenAPI(), "client", "go run ./gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("api_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("api_gen.go is stale; run go generate ./client")
+	}
+}
+
+func TestClient(t *testing.T) {
+	var gotAuth, gotQuery string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotQuery = r.URL.RawQuery
+		gotBody, _ = io.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/projects/p%2F1", "GET /api/projects/p/1":
+			json.NewEncoder(w).Encode(map[string]any{"project": map[string]any{"id": "p/1", "name": "web", "host_port": 8080}})
+		case "DELETE /api/projects/p1":
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]any{"job": map[string]any{"id": "j1", "type": "purge"}})
+		case "PUT /api/projects/p1/env/FOO":
+			json.NewEncoder(w).Encode(map[string]any{"env": map[string]any{"name": "FOO", "value": "bar"}})
+		case "GET /api/projects/p1/logs":
+			io.WriteString(w, "line 1\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "not_found", "message": "Not found"}})
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := New(srv.URL+"/", "secret")
+
+	p, err := c.GetProject(ctx, "p/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "web" || p.HostPort != 8080 || gotAuth != "Bearer secret" {
+		t.Fatalf("project = %+v, auth = %q", p, gotAuth)
+	}
+
+	job, err := c.DeleteProject(ctx, "p1", DeleteProjectParams{Purge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "j1" || gotQuery != "purge=true" {
+		t.Fatalf("job = %+v, query = %q", job, gotQuery)
+	}
+
+	v, err := c.SetProjectEnv(ctx, "p1", "FOO", SetProjectEnvRequest{Value: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value != "bar" || string(gotBody) != `{"value":"bar"}` {
+		t.Fatalf("env = %+v, body = %s", v, gotBody)
+	}
+
+	rc, err := c.GetProjectLogs(ctx, "p1", GetProjectLogsParams{Tail: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(logs) != "line 1\n" || gotQuery != "tail=10" {
+		t.Fatalf("logs = %q, query = %q", logs, gotQuery)
+	}
+
+	_, err = c.GetJob(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Fatalf("err = %v, want not found", err)
+	}
+	if e := err.(*Error); e.Code != "not_found" || e.Message != "Not found" {
+		t.Fatalf("code = %q, message = %q", e.Code, e.Message)
+	}
+}
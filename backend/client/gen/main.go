@@ -0,0 +1,25 @@
+// Command gen writes api_gen.go of package client from the server's
+// OpenAPI document. It runs from "go generate ./client".
+package main
+
+import (
+	"log"
+	"os"
+
+	"last-deploy/internal/api"
+	"last-deploy/internal/openapi"
+)
+
+// generator is recorded in the header of the generated file; keep it in
+// sync with client_test.go.
+const generator = "go run ./gen"
+
+func main() {
+	src, err := openapi.GenerateClient(api.OpenAPI(), "client", generator)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("api_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
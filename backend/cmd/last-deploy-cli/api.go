@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiClient talks to the last-deploy HTTP API.
+type apiClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// apiError is a non-2xx response decoded from the server's error envelope.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+func isNotFound(err error) bool {
+	var ae *apiError
+	return errors.As(err, &ae) && ae.Status == http.StatusNotFound
+}
+
+// open sends a request and returns the response of a 2xx status; the caller
+// closes the body.
+func (c *apiClient) open(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.base, "/")+"/api"+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var e struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	ae := &apiError{Status: resp.StatusCode}
+	if json.Unmarshal(data, &e) == nil && e.Error.Code != "" {
+		ae.Code, ae.Message = e.Error.Code, e.Error.Message
+	} else if ae.Message = strings.TrimSpace(string(data)); ae.Message == "" {
+		ae.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, ae
+}
+
+// do sends a request, decodes a JSON response into out when it is not nil
+// and returns the raw body.
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) (json.RawMessage, error) {
+	resp, err := c.open(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("decode %s %s: %w", method, path, err)
+		}
+	}
+	return data, nil
+}
+
+type project struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	GitURL            string `json:"git_url"`
+	DeployType        string `json:"deploy_type"`
+	DockerfileContent string `json:"dockerfile_content"`
+	ComposeContent    string `json:"compose_content"`
+	HostPort          int    `json:"host_port"`
+	LastStatus        string `json:"last_status"`
+}
+
+type job struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"project_id"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	CurrentStep string `json:"current_step"`
+	Log         string `json:"log"`
+	Error       string `json:"error"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// resolveProject accepts a project id or a unique project name.
+func (c *apiClient) resolveProject(ctx context.Context, ref string) (project, error) {
+	var res struct {
+		Project project `json:"project"`
+	}
+	_, err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(ref), nil, &res)
+	if err == nil {
+		return res.Project, nil
+	}
+	if !isNotFound(err) {
+		return project{}, err
+	}
+
+	var list struct {
+		Projects []project `json:"projects"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/projects", nil, &list); err != nil {
+		return project{}, err
+	}
+	var matches []project
+	for _, p := range list.Projects {
+		if p.Name == ref {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return project{}, fmt.Errorf("project %q not found", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return project{}, fmt.Errorf("%d projects are named %q, use the id", len(matches), ref)
+	}
+}
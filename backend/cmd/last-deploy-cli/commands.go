@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// watchInterval is how often a watched job is polled.
+var watchInterval = time.Second
+
+func (c *cli) printJSON(raw json.RawMessage) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := c.stdout.Write(buf.Bytes())
+	return err
+}
+
+func (c *cli) projectsList(ctx context.Context, args []string) error {
+	fs := c.flagSet("projects ls")
+	if _, err := parseFlags(fs, args, 0, 0, ""); err != nil {
+		return err
+	}
+	var res struct {
+		Projects []project `json:"projects"`
+	}
+	raw, err := c.api.do(ctx, http.MethodGet, "/projects", nil, &res)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tSTATUS\tPORT\tGIT URL")
+	for _, p := range res.Projects {
+		port := "-"
+		if p.HostPort > 0 {
+			port = strconv.Itoa(p.HostPort)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.Name, p.DeployType, p.LastStatus, port, p.GitURL)
+	}
+	return tw.Flush()
+}
+
+func (c *cli) projectsCreate(ctx context.Context, args []string) error {
+	fs := c.flagSet("projects create")
+	var req struct {
+		Name           string `json:"name"`
+		GitURL         string `json:"git_url"`
+		GitRef         string `json:"git_ref,omitempty"`
+		RepoSubdir     string `json:"repo_subdir,omitempty"`
+		DeployType     string `json:"deploy_type,omitempty"`
+		ComposeFile    string `json:"compose_file,omitempty"`
+		ComposeService string `json:"compose_service,omitempty"`
+		DockerfilePath string `json:"dockerfile_path,omitempty"`
+		HostPort       int    `json:"host_port,omitempty"`
+		ContainerPort  int    `json:"container_port,omitempty"`
+		ExposeMode     string `json:"expose_mode,omitempty"`
+		BindIP         string `json:"bind_ip,omitempty"`
+		Deploy         bool   `json:"deploy"`
+	}
+	fs.StringVar(&req.Name, "name", "", "project name (required)")
+	fs.StringVar(&req.GitURL, "git-url", "", "git repository URL (required)")
+	fs.StringVar(&req.GitRef, "git-ref", "", "branch, tag or commit")
+	fs.StringVar(&req.RepoSubdir, "subdir", "", "subdirectory of the repository to deploy")
+	fs.StringVar(&req.DeployType, "deploy-type", "", "auto, dockerfile or compose")
+	fs.StringVar(&req.ComposeFile, "compose-file", "", "compose file path")
+	fs.StringVar(&req.ComposeService, "compose-service", "", "compose services to run, comma separated")
+	fs.StringVar(&req.DockerfilePath, "dockerfile", "", "Dockerfile path")
+	fs.IntVar(&req.HostPort, "host-port", 0, "host port, allocated when 0")
+	fs.IntVar(&req.ContainerPort, "container-port", 0, "container port")
+	fs.StringVar(&req.ExposeMode, "expose", "", "loopback, all, ip or none")
+	fs.StringVar(&req.BindIP, "bind-ip", "", "host address for -expose ip")
+	fs.BoolVar(&req.Deploy, "deploy", false, "deploy right away")
+	watch := fs.Bool("watch", false, "with -deploy, wait for the deploy and fail if it fails")
+	if _, err := parseFlags(fs, args, 0, 0, "-name NAME -git-url URL [flags]"); err != nil {
+		return err
+	}
+	if req.Name == "" || req.GitURL == "" {
+		return usageError{"projects create: -name and -git-url are required"}
+	}
+
+	var res struct {
+		Project project `json:"project"`
+		Job     *job    `json:"job"`
+	}
+	raw, err := c.api.do(ctx, http.MethodPost, "/projects", req, &res)
+	if err != nil {
+		return err
+	}
+	if c.json && !*watch {
+		return c.printJSON(raw)
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "created project %s (%s)\n", res.Project.Name, res.Project.ID)
+	}
+	if res.Job == nil {
+		if c.json {
+			return c.printJSON(raw)
+		}
+		return nil
+	}
+	return c.afterJob(ctx, *res.Job, *watch)
+}
+
+func (c *cli) projectsRemove(ctx context.Context, args []string) error {
+	fs := c.flagSet("projects rm")
+	purge := fs.Bool("purge", false, "also delete the project's volumes")
+	watch := fs.Bool("watch", false, "wait for the removal and fail if it fails")
+	pos, err := parseFlags(fs, args, 1, 1, "[-purge] [-watch] <project>")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	path := "/projects/" + url.PathEscape(p.ID)
+	if *purge {
+		path += "?purge=true"
+	}
+	return c.startJob(ctx, http.MethodDelete, path, *watch)
+}
+
+// projectAction runs deploy, start, stop, pause or unpause.
+func (c *cli) projectAction(ctx context.Context, action string, args []string) error {
+	fs := c.flagSet(action)
+	watch := fs.Bool("watch", false, "wait for the job and fail if it fails")
+	pos, err := parseFlags(fs, args, 1, 1, "[-watch] <project>")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.startJob(ctx, http.MethodPost, "/projects/"+url.PathEscape(p.ID)+"/"+action, *watch)
+}
+
+func (c *cli) startJob(ctx context.Context, method, path string, watch bool) error {
+	var res struct {
+		Job job `json:"job"`
+	}
+	raw, err := c.api.do(ctx, method, path, nil, &res)
+	if err != nil {
+		return err
+	}
+	if c.json && !watch {
+		return c.printJSON(raw)
+	}
+	return c.afterJob(ctx, res.Job, watch)
+}
+
+func (c *cli) afterJob(ctx context.Context, j job, watch bool) error {
+	if !watch {
+		fmt.Fprintf(c.stdout, "queued %s job %s\n", j.Type, j.ID)
+		return nil
+	}
+	return c.watchJob(ctx, j.ID)
+}
+
+func (c *cli) jobsWatch(ctx context.Context, args []string) error {
+	fs := c.flagSet("jobs watch")
+	pos, err := parseFlags(fs, args, 1, 1, "<job-id>")
+	if err != nil {
+		return err
+	}
+	return c.watchJob(ctx, pos[0])
+}
+
+// watchJob polls a job until it finishes, printing its log as it grows.
+// A failed job is returned as jobFailedError.
+func (c *cli) watchJob(ctx context.Context, id string) error {
+	printed := 0
+	for {
+		var res struct {
+			Job job `json:"job"`
+		}
+		raw, err := c.api.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &res)
+		if err != nil {
+			return err
+		}
+		j := res.Job
+		if !c.json {
+			if len(j.Log) < printed {
+				// The log never shrinks; start over if it did.
+				printed = 0
+			}
+			io.WriteString(c.stdout, j.Log[printed:])
+			printed = len(j.Log)
+		}
+
+		switch j.Status {
+		case "succeeded", "failed":
+			if c.json {
+				if err := c.printJSON(raw); err != nil {
+					return err
+				}
+			} else {
+				fmt.Fprintf(c.stdout, "job %s %s\n", j.ID, j.Status)
+			}
+			if j.Status == "failed" {
+				return jobFailedError{j}
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+func (c *cli) logs(ctx context.Context, args []string) error {
+	fs := c.flagSet("logs")
+	follow := fs.Bool("f", false, "follow the logs")
+	tail := fs.Int("tail", 200, "number of lines to show from the end")
+	pos, err := parseFlags(fs, args, 1, 1, "[-f] [-tail N] <project>")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"tail": {strconv.Itoa(*tail)}}
+	if *follow {
+		q.Set("follow", "true")
+	}
+	resp, err := c.api.open(ctx, http.MethodGet, "/projects/"+url.PathEscape(p.ID)+"/logs?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(c.stdout, resp.Body)
+	if ctx.Err() != nil {
+		// Interrupted while following.
+		return nil
+	}
+	return err
+}
+
+func (c *cli) envList(ctx context.Context, args []string) error {
+	fs := c.flagSet("env ls")
+	pos, err := parseFlags(fs, args, 1, 1, "<project>")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Env []envVar `json:"env"`
+	}
+	raw, err := c.api.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(p.ID)+"/env", nil, &res)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	for _, v := range res.Env {
+		fmt.Fprintf(c.stdout, "%s=%s\n", v.Name, v.Value)
+	}
+	return nil
+}
+
+func (c *cli) envSet(ctx context.Context, args []string) error {
+	fs := c.flagSet("env set")
+	pos, err := parseFlags(fs, args, 2, -1, "<project> NAME=value...")
+	if err != nil {
+		return err
+	}
+	vars := make([]envVar, 0, len(pos)-1)
+	for _, kv := range pos[1:] {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return usageError{fmt.Sprintf("env set: %q is not NAME=value", kv)}
+		}
+		vars = append(vars, envVar{Name: name, Value: value})
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		path := "/projects/" + url.PathEscape(p.ID) + "/env/" + url.PathEscape(v.Name)
+		if _, err := c.api.do(ctx, http.MethodPut, path, map[string]string{"value": v.Value}, nil); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "set %d variable(s) on %s; redeploy to apply\n", len(vars), p.Name)
+	}
+	return nil
+}
+
+func (c *cli) envUnset(ctx context.Context, args []string) error {
+	fs := c.flagSet("env unset")
+	pos, err := parseFlags(fs, args, 2, -1, "<project> NAME...")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	for _, name := range pos[1:] {
+		path := "/projects/" + url.PathEscape(p.ID) + "/env/" + url.PathEscape(name)
+		if _, err := c.api.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "unset %d variable(s) on %s; redeploy to apply\n", len(pos)-1, p.Name)
+	}
+	return nil
+}
+
+// configEdit opens the project's Dockerfile or compose content in $EDITOR
+// and saves it when it changed.
+func (c *cli) configEdit(ctx context.Context, args []string) error {
+	fs := c.flagSet("config edit")
+	file := fs.String("file", "", "dockerfile or compose; defaults to the one the project deploys with")
+	deploy := fs.Bool("deploy", false, "deploy after saving and wait for the result")
+	pos, err := parseFlags(fs, args, 1, 1, "[-file dockerfile|compose] [-deploy] <project>")
+	if err != nil {
+		return err
+	}
+	p, err := c.api.resolveProject(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+
+	kind := *file
+	if kind == "" {
+		kind = "dockerfile"
+		if p.DeployType == "compose" {
+			kind = "compose"
+		}
+	}
+	var content *string
+	var pattern string
+	switch kind {
+	case "dockerfile":
+		content, pattern = &p.DockerfileContent, "Dockerfile-*"
+	case "compose":
+		content, pattern = &p.ComposeContent, "compose-*.yml"
+	default:
+		return usageError{"config edit: -file must be dockerfile or compose"}
+	}
+
+	edited, err := editText(ctx, *content, pattern, c.stdout, c.stderr)
+	if err != nil {
+		return err
+	}
+	if edited == *content {
+		fmt.Fprintln(c.stderr, "no changes")
+		return nil
+	}
+	*content = edited
+
+	body := map[string]string{
+		"dockerfile_content": p.DockerfileContent,
+		"compose_content":    p.ComposeContent,
+	}
+	if _, err := c.api.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(p.ID)+"/config", body, nil); err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "saved %s of %s\n", kind, p.Name)
+	}
+	if !*deploy {
+		return nil
+	}
+	return c.startJob(ctx, http.MethodPost, "/projects/"+url.PathEscape(p.ID)+"/deploy", true)
+}
+
+// editText writes text to a temporary file, runs $VISUAL or $EDITOR (vi by
+// default) on it and returns the result.
+func editText(ctx context.Context, text, pattern string, stdout, stderr io.Writer) (string, error) {
+	f, err := os.CreateTemp("", "last-deploy-"+pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = envOr("EDITOR", "vi")
+	}
+	// The editor may carry arguments, e.g. "code --wait".
+	parts := strings.Fields(editor)
+	cmd := exec.CommandContext(ctx, parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor, err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
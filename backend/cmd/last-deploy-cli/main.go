@@ -0,0 +1,195 @@
+// Command last-deploy-cli drives a last-deploy server from the shell or CI.
+//
+// The server address and API token come from -server/-token or the
+// LAST_DEPLOY_SERVER/LAST_DEPLOY_TOKEN environment variables. -json prints
+// raw API responses instead of tables.
+//
+// Exit codes: 0 on success, 1 when a request fails, 2 on bad usage and 3
+// when a watched job fails.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	exitError     = 1
+	exitUsage     = 2
+	exitJobFailed = 3
+)
+
+const usage = `usage: last-deploy-cli [-server URL] [-token TOKEN] [-json] <command> [args]
+
+commands:
+  projects ls
+  projects create -name NAME -git-url URL [flags]
+  projects rm [-purge] [-watch] <project>
+  deploy [-watch] <project>
+  start|stop|pause|unpause [-watch] <project>
+  logs [-f] [-tail N] <project>
+  jobs watch <job-id>
+  env ls <project>
+  env set <project> NAME=value...
+  env unset <project> NAME...
+  config edit [-file dockerfile|compose] [-deploy] <project>
+
+<project> is a project id or a unique project name.
+Run "last-deploy-cli <command> -h" for the flags of a command.
+`
+
+// usageError is bad command line usage; it exits with exitUsage.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+// jobFailedError is a watched job that ended as failed; it exits with
+// exitJobFailed.
+type jobFailedError struct{ job job }
+
+func (e jobFailedError) Error() string {
+	return fmt.Sprintf("job %s (%s) failed: %s", e.job.ID, e.job.Type, e.job.Error)
+}
+
+type cli struct {
+	api    *apiClient
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(exitCode(err, os.Stderr))
+}
+
+func exitCode(err error, stderr io.Writer) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintln(stderr, "error:", err)
+	var ue usageError
+	var je jobFailedError
+	switch {
+	case errors.As(err, &ue):
+		return exitUsage
+	case errors.As(err, &je):
+		return exitJobFailed
+	default:
+		return exitError
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("last-deploy-cli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	server := fs.String("server", envOr("LAST_DEPLOY_SERVER", "http://127.0.0.1:8080"), "server URL")
+	token := fs.String("token", os.Getenv("LAST_DEPLOY_TOKEN"), "API token")
+	jsonOut := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageError{"missing command"}
+	}
+
+	c := &cli{
+		// No client timeout: logs -f and jobs watch run until done or interrupted.
+		api:    &apiClient{base: *server, token: *token, http: &http.Client{}},
+		json:   *jsonOut,
+		stdout: stdout,
+		stderr: stderr,
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "projects":
+		return c.sub(ctx, "projects", rest, map[string]func(context.Context, []string) error{
+			"ls":     c.projectsList,
+			"create": c.projectsCreate,
+			"rm":     c.projectsRemove,
+		})
+	case "deploy", "start", "stop", "pause", "unpause":
+		return c.projectAction(ctx, cmd, rest)
+	case "logs":
+		return c.logs(ctx, rest)
+	case "jobs":
+		return c.sub(ctx, "jobs", rest, map[string]func(context.Context, []string) error{
+			"watch": c.jobsWatch,
+		})
+	case "env":
+		return c.sub(ctx, "env", rest, map[string]func(context.Context, []string) error{
+			"ls":    c.envList,
+			"set":   c.envSet,
+			"unset": c.envUnset,
+		})
+	case "config":
+		return c.sub(ctx, "config", rest, map[string]func(context.Context, []string) error{
+			"edit": c.configEdit,
+		})
+	default:
+		fs.Usage()
+		return usageError{fmt.Sprintf("unknown command %q", cmd)}
+	}
+}
+
+func (c *cli) sub(ctx context.Context, name string, args []string, cmds map[string]func(context.Context, []string) error) error {
+	if len(args) == 0 {
+		return usageError{fmt.Sprintf("%s: missing subcommand", name)}
+	}
+	fn, ok := cmds[args[0]]
+	if !ok {
+		return usageError{fmt.Sprintf("%s: unknown subcommand %q", name, args[0])}
+	}
+	return fn(ctx, args[1:])
+}
+
+// parseFlags parses flags given before, between or after the positional
+// arguments and checks their count.
+func parseFlags(fs *flag.FlagSet, args []string, minArgs, maxArgs int, argsUsage string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err.Error()}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) < minArgs || (maxArgs >= 0 && len(positional) > maxArgs) {
+		return nil, usageError{fmt.Sprintf("usage: last-deploy-cli %s %s", fs.Name(), argsUsage)}
+	}
+	return positional, nil
+}
+
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
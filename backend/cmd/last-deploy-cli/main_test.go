@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func fakeServer(t *testing.T, token string, jobStatus string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "p1" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "project_not_found", "message": "Project not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"project": project{ID: "p1", Name: "web"}})
+	})
+	mux.HandleFunc("GET /api/projects", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"projects": []project{
+			{ID: "p1", Name: "web"},
+			{ID: "p2", Name: "dup"},
+			{ID: "p3", Name: "dup"},
+		}})
+	})
+	mux.HandleFunc("POST /api/projects/{id}/deploy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"job": job{ID: "j1", Type: "deploy", Status: "queued"}})
+	})
+	mux.HandleFunc("GET /api/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"job": job{ID: "j1", Type: "deploy", Status: jobStatus, Log: "building\n", Error: "boom"}})
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": "unauthorized", "message": "Unauthorized"}})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunExitCodes(t *testing.T) {
+	cases := []struct {
+		name      string
+		token     string
+		jobStatus string
+		args      []string
+		wantCode  int
+		wantOut   string
+	}{
+		{name: "watch succeeded", jobStatus: "succeeded", args: []string{"deploy", "-watch", "web"}, wantCode: 0, wantOut: "building\njob j1 succeeded\n"},
+		{name: "watch failed", jobStatus: "failed", args: []string{"deploy", "web", "-watch"}, wantCode: exitJobFailed},
+		{name: "no watch", jobStatus: "failed", args: []string{"deploy", "p1"}, wantCode: 0, wantOut: "queued deploy job j1\n"},
+		{name: "jobs watch", jobStatus: "failed", args: []string{"jobs", "watch", "j1"}, wantCode: exitJobFailed},
+		{name: "token", token: "secret", jobStatus: "succeeded", args: []string{"-token", "secret", "deploy", "web"}, wantCode: 0},
+		{name: "missing token", token: "secret", jobStatus: "succeeded", args: []string{"deploy", "web"}, wantCode: exitError},
+		{name: "unknown project", jobStatus: "succeeded", args: []string{"deploy", "api"}, wantCode: exitError},
+		{name: "ambiguous name", jobStatus: "succeeded", args: []string{"deploy", "dup"}, wantCode: exitError},
+		{name: "unknown command", args: []string{"nope"}, wantCode: exitUsage},
+		{name: "missing project", args: []string{"deploy"}, wantCode: exitUsage},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LAST_DEPLOY_TOKEN", "")
+			srv := fakeServer(t, tc.token, tc.jobStatus)
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-server", srv.URL}, tc.args...)
+			code := exitCode(run(context.Background(), args, &stdout, &stderr), &stderr)
+			if code != tc.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tc.wantCode, stderr.String())
+			}
+			if tc.wantOut != "" && stdout.String() != tc.wantOut {
+				t.Fatalf("stdout = %q, want %q", stdout.String(), tc.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	srv := fakeServer(t, "", "succeeded")
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-server", srv.URL, "-json", "projects", "ls"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Projects []project `json:"projects"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout.String())
+	}
+	if len(res.Projects) != 3 || !strings.Contains(stdout.String(), "\n  ") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
@@ -3,21 +3,32 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"last-deploy/internal/api"
+	"last-deploy/internal/certs"
 	"last-deploy/internal/config"
+	"last-deploy/internal/dbbackup"
+	"last-deploy/internal/gitauth"
+	"last-deploy/internal/gitops"
 	"last-deploy/internal/jobs"
+	"last-deploy/internal/preview"
+	"last-deploy/internal/proxy"
 	"last-deploy/internal/store"
 	"last-deploy/internal/workspace"
 )
 
 func main() {
+	restoreDB := flag.String("restore-db", "", "replace the database with this backup file before starting")
+	flag.Parse()
+
 	cfg := config.Load()
 
 	if err := workspace.EnsureDataDirs(cfg); err != nil {
@@ -27,6 +38,15 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	if *restoreDB != "" {
+		if err := store.RestoreFile(ctx, *restoreDB, cfg.DBPath()); err != nil {
+			log.Fatalf("restore db: %v", err)
+		}
+		log.Printf("restored db from %s, previous db kept as %s.before-restore", *restoreDB, cfg.DBPath())
+		log.Printf("git credentials in the restored db need the secret key they were encrypted with; if the key changed since the backup, restore it from %s",
+			filepath.Join(cfg.DBBackupsDir(), dbbackup.KeyName))
+	}
+
 	st, err := store.Open(ctx, cfg.DBPath())
 	if err != nil {
 		log.Fatalf("open db: %v", err)
@@ -40,10 +60,42 @@ func main() {
 		log.Printf("enqueue persisted jobs: %v", err)
 	}
 
+	if err := jobs.JoinSharedNetwork(ctx, st); err != nil {
+		log.Printf("join shared network: %v", err)
+	}
+
+	px := proxy.New(st, cfg.BaseDomain)
+	cm, err := certs.New(cfg, px.HostPolicy)
+	if err != nil {
+		log.Fatalf("init certificates: %v", err)
+	}
+
+	key, err := gitauth.LoadKey(cfg)
+	if err != nil {
+		log.Fatalf("load secret key: %v", err)
+	}
+	box, err := gitauth.NewBox(key)
+	if err != nil {
+		log.Fatalf("load secret key: %v", err)
+	}
+	ga := gitauth.New(st, box)
+
+	pv := preview.New(cfg, st, px)
 	worker := jobs.NewWorker(st, queue, cfg)
+	worker.SetGitAuth(ga)
+	worker.SetCertificates(cm)
+	worker.OnFinished(func(string) { px.Trigger() })
+	worker.OnFinished(pv.JobFinished)
+	worker.SetTrafficSwitch(px)
 	go worker.Run(ctx)
 
-	r := api.NewRouter(st, queue, cfg)
+	dbb := dbbackup.New(st, cfg)
+	go dbb.Run(ctx)
+
+	gr := gitops.New(cfg, st)
+	r := api.NewRouter(st, queue, cfg, px, cm, dbb, gr, pv, ga)
+	go gr.Run(ctx)
+	go pv.Run(ctx)
 
 	srv := &http.Server{
 		Addr:              cfg.Addr,
@@ -51,11 +103,53 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
+	var proxySrvs []*http.Server
+	if cfg.ProxyAddr != "" || cfg.ProxyTLSAddr != "" {
+		go px.Run(ctx)
+	}
+	if cfg.ProxyAddr != "" {
+		// 启用 HTTPS 时由 HTTP 入口响应 ACME HTTP-01 验证
+		var handler http.Handler = px
+		if cfg.ProxyTLSAddr != "" {
+			handler = cm.HTTPHandler(px)
+		}
+		proxySrv := &http.Server{
+			Addr:              cfg.ProxyAddr,
+			Handler:           handler,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		proxySrvs = append(proxySrvs, proxySrv)
+		go func() {
+			log.Printf("proxy listening on http://%s", cfg.ProxyAddr)
+			if err := proxySrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("proxy listen: %v", err)
+			}
+		}()
+	}
+	if cfg.ProxyTLSAddr != "" {
+		tlsSrv := &http.Server{
+			Addr:              cfg.ProxyTLSAddr,
+			Handler:           px,
+			TLSConfig:         cm.TLSConfig(),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		proxySrvs = append(proxySrvs, tlsSrv)
+		go func() {
+			log.Printf("proxy listening on https://%s", cfg.ProxyTLSAddr)
+			if err := tlsSrv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("proxy tls listen: %v", err)
+			}
+		}()
+	}
+
 	go func() {
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		_ = srv.Shutdown(shutdownCtx)
+		for _, ps := range proxySrvs {
+			_ = ps.Shutdown(shutdownCtx)
+		}
 	}()
 
 	log.Printf("listening on http://%s", cfg.Addr)
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/dbbackup"
+)
+
+func (s *Server) listDBBackups(c *gin.Context) {
+	backups, err := s.dbBackups.List()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+// createDBBackup 立即备份部署器自身的数据库，数据库较小因此同步执行
+func (s *Server) createDBBackup(c *gin.Context) {
+	snap, err := s.dbBackups.Create(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"backup": snap})
+}
+
+func (s *Server) downloadDBBackup(c *gin.Context) {
+	name := c.Param("name")
+	path, err := s.dbBackups.Path(name)
+	if err != nil {
+		if errors.Is(err, dbbackup.ErrNotFound) {
+			err = newError(http.StatusNotFound, codeDBBackupNotFound)
+		}
+		writeError(c, err)
+		return
+	}
+	c.FileAttachment(path, name)
+}
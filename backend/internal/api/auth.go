@@ -0,0 +1,26 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireToken 配置了 API token 时要求请求携带 Authorization: Bearer <token>
+func requireToken(tokens []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if ok {
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(t)) == 1 {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="last-deploy"`)
+		c.AbortWithStatusJSON(errorStatus(c, newError(http.StatusUnauthorized, codeUnauthorized)))
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/proxy"
+	"last-deploy/internal/store"
+	"last-deploy/internal/workspace"
+)
+
+func (s *Server) listProjectBackups(c *gin.Context) {
+	projectID := c.Param("id")
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	backups, err := s.st.ListBackups(c.Request.Context(), projectID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+// createProjectBackup 创建备份任务，备份 ID 与任务 ID 相同
+func (s *Server) createProjectBackup(c *gin.Context) { s.enqueueJob(c, store.JobTypeBackup) }
+
+func (s *Server) downloadProjectBackup(c *gin.Context) {
+	b, ok := s.loadProjectBackup(c)
+	if !ok {
+		return
+	}
+	path, err := workspace.BackupPath(s.cfg, b)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		writeError(c, newError(http.StatusNotFound, codeBackupFileMissing))
+		return
+	}
+
+	name := b.ID
+	if project, err := s.st.GetProject(c.Request.Context(), b.ProjectID); err == nil {
+		if slug := proxy.Slug(project.Name); slug != "" {
+			name = slug + "-" + b.ID
+		}
+	}
+	c.FileAttachment(path, name+".tar.gz")
+}
+
+func (s *Server) deleteProjectBackup(c *gin.Context) {
+	b, ok := s.loadProjectBackup(c)
+	if !ok {
+		return
+	}
+	if err := workspace.RemoveBackup(c.Request.Context(), s.cfg, s.st, b); err != nil {
+		writeLookupError(c, err, codeBackupNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// restoreProjectBackup 创建恢复任务：停止项目、恢复数据卷后重新启动
+func (s *Server) restoreProjectBackup(c *gin.Context) {
+	b, ok := s.loadProjectBackup(c)
+	if !ok {
+		return
+	}
+	job, err := s.queueJob(c.Request.Context(), store.Job{
+		ProjectID: b.ProjectID,
+		Type:      store.JobTypeRestore,
+		Target:    b.ID,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (s *Server) loadProjectBackup(c *gin.Context) (store.Backup, bool) {
+	projectID := c.Param("id")
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return store.Backup{}, false
+	}
+	b, err := s.st.GetBackup(c.Request.Context(), projectID, c.Param("backupId"))
+	if err != nil {
+		writeLookupError(c, err, codeBackupNotFound)
+		return store.Backup{}, false
+	}
+	return b, true
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/certs"
+	"last-deploy/internal/store"
+)
+
+var hostnameRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type createProjectDomainRequest struct {
+	Hostname      string `json:"hostname"`
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+}
+
+func (s *Server) listProjectDomains(c *gin.Context) {
+	projectID := c.Param("id")
+	project, err := s.st.GetProject(c.Request.Context(), projectID)
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	domains, err := s.st.ListProjectDomains(c.Request.Context(), projectID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"default_hostname": s.proxy.DefaultHostname(project),
+		"domains":          domains,
+	})
+}
+
+func (s *Server) createProjectDomain(c *gin.Context) {
+	projectID := c.Param("id")
+	var req createProjectDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	hostname := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Hostname)), ".")
+	if len(hostname) > 253 || !hostnameRe.MatchString(hostname) {
+		badRequest(c, invalidField("hostname"))
+		return
+	}
+	service := strings.TrimSpace(req.Service)
+	if service != "" && !composeServiceRe.MatchString(service) {
+		badRequest(c, invalidComposeService("service", service))
+		return
+	}
+	if req.ContainerPort < 0 || req.ContainerPort > 65535 {
+		badRequest(c, invalidField("container_port"))
+		return
+	}
+
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	created, err := s.st.CreateProjectDomain(c.Request.Context(), store.ProjectDomain{
+		ID:            id,
+		ProjectID:     projectID,
+		Hostname:      hostname,
+		Service:       service,
+		ContainerPort: req.ContainerPort,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	s.proxy.Trigger()
+	c.JSON(http.StatusCreated, gin.H{"domain": created})
+}
+
+func (s *Server) deleteProjectDomain(c *gin.Context) {
+	domain, ok := s.loadProjectDomain(c)
+	if !ok {
+		return
+	}
+	if err := s.st.DeleteProjectDomain(c.Request.Context(), domain.ProjectID, domain.ID); err != nil {
+		writeLookupError(c, err, codeDomainNotFound)
+		return
+	}
+	// 域名删除后上传的证书一并清理
+	_ = s.certs.DeleteCustom(domain.Hostname)
+
+	s.proxy.Trigger()
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type uploadCertificateRequest struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// getDomainCertificate 返回域名当前的证书；ACME 尚未签发时 certificate 为 null
+func (s *Server) getDomainCertificate(c *gin.Context) {
+	domain, ok := s.loadProjectDomain(c)
+	if !ok {
+		return
+	}
+	info, err := s.certs.Info(c.Request.Context(), domain.Hostname)
+	if err != nil {
+		if errors.Is(err, certs.ErrNoCertificate) {
+			c.JSON(http.StatusOK, gin.H{"certificate": nil})
+			return
+		}
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"certificate": info})
+}
+
+// uploadDomainCertificate 上传自定义证书（PEM），优先于 ACME 证书使用
+func (s *Server) uploadDomainCertificate(c *gin.Context) {
+	var req uploadCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if strings.TrimSpace(req.Certificate) == "" {
+		badRequest(c, missingField("certificate"))
+		return
+	}
+	if strings.TrimSpace(req.PrivateKey) == "" {
+		badRequest(c, missingField("private_key"))
+		return
+	}
+
+	domain, ok := s.loadProjectDomain(c)
+	if !ok {
+		return
+	}
+	info, err := s.certs.SaveCustom(domain.Hostname, []byte(req.Certificate), []byte(req.PrivateKey))
+	if err != nil {
+		badRequest(c, newError(http.StatusBadRequest, codeInvalidCertificate).withDetail("%s", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"certificate": info})
+}
+
+// deleteDomainCertificate 删除自定义证书，之后回退到 ACME 自动签发
+func (s *Server) deleteDomainCertificate(c *gin.Context) {
+	domain, ok := s.loadProjectDomain(c)
+	if !ok {
+		return
+	}
+	if err := s.certs.DeleteCustom(domain.Hostname); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) loadProjectDomain(c *gin.Context) (store.ProjectDomain, bool) {
+	domain, err := s.st.GetProjectDomain(c.Request.Context(), c.Param("id"), c.Param("domainId"))
+	if err != nil {
+		writeLookupError(c, err, codeDomainNotFound)
+		return store.ProjectDomain{}, false
+	}
+	return domain, true
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/store"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type setProjectEnvRequest struct {
+	Value string `json:"value"`
+}
+
+func (s *Server) listProjectEnv(c *gin.Context) {
+	projectID := c.Param("id")
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	vars, err := s.st.ListProjectEnv(c.Request.Context(), projectID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"env": vars})
+}
+
+// setProjectEnv 设置或覆盖一个环境变量，下次部署时生效
+func (s *Server) setProjectEnv(c *gin.Context) {
+	projectID, name := c.Param("id"), c.Param("name")
+	var req setProjectEnvRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if !envNameRe.MatchString(name) {
+		badRequest(c, invalidField("name"))
+		return
+	}
+
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	v, err := s.st.SetProjectEnv(c.Request.Context(), store.ProjectEnvVar{ProjectID: projectID, Name: name, Value: req.Value})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"env": v})
+}
+
+func (s *Server) deleteProjectEnv(c *gin.Context) {
+	if err := s.st.DeleteProjectEnv(c.Request.Context(), c.Param("id"), c.Param("name")); err != nil {
+		writeLookupError(c, err, codeEnvNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
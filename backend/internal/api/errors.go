@@ -0,0 +1,305 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/portalloc"
+	"last-deploy/internal/store"
+)
+
+// 错误码。客户端按 code 判断错误类型，message 只用于展示，可能随语言变化
+const (
+	codeInvalidRequest            = "invalid_request"
+	codeMissingField              = "missing_field"
+	codeInvalidField              = "invalid_field"
+	codeInvalidComposeService     = "invalid_compose_service"
+	codeInvalidRepoConfig         = "invalid_repo_config"
+	codeInvalidBundle             = "invalid_bundle"
+	codeInvalidCertificate        = "invalid_certificate"
+	codeBundleTooLarge            = "bundle_too_large"
+	codeCloneFailed               = "clone_failed"
+	codeDetectFailed              = "detect_failed"
+	codePortNotDetected           = "port_not_detected"
+	codeComposeVolumesUnsupported = "compose_volumes_unsupported"
+	codeUnauthorized              = "unauthorized"
+	codeNotFound                  = "not_found"
+	codeProjectNotFound           = "project_not_found"
+	codeDraftNotFound             = "draft_not_found"
+	codeJobNotFound               = "job_not_found"
+	codePortNotFound              = "port_not_found"
+	codeDomainNotFound            = "domain_not_found"
+	codeVolumeNotFound            = "volume_not_found"
+	codeBackupNotFound            = "backup_not_found"
+	codeBackupFileMissing         = "backup_file_missing"
+	codeEnvNotFound               = "env_not_found"
+	codeDBBackupNotFound          = "db_backup_not_found"
+	codeNoContainers              = "no_containers"
+	codePortInUse                 = "port_in_use"
+	codeNoFreePort                = "no_free_port"
+	codeHostnameInUse             = "hostname_in_use"
+	codeVolumeInUse               = "volume_in_use"
+	codeImportConflict            = "import_conflict"
+	codeGitOpsDisabled            = "gitops_disabled"
+	codeGitOpsDestructive         = "gitops_destructive"
+	codeGitOpsFailed              = "gitops_failed"
+	codePreviewsDisabled          = "previews_disabled"
+	codeInvalidSignature          = "invalid_signature"
+	codePreviewRepoMismatch       = "preview_repo_mismatch"
+	codePreviewTemplate           = "preview_template"
+	codeGitCredentialNotFound     = "git_credential_not_found"
+	codeGitCredentialNameInUse    = "git_credential_name_in_use"
+	codeGitCredentialInUse        = "git_credential_in_use"
+	codeDockerUnavailable         = "docker_unavailable"
+	codeInternal                  = "internal_error"
+)
+
+// errorMessages 按语言保存错误码对应的提示，{field} 和 {detail} 在输出时替换，
+// 模板中没有 {detail} 时附加在末尾。
+// 新增错误码时需同时补充各语言的文案
+var errorMessages = map[string]map[string]string{
+	"en": {
+		codeInvalidRequest:            "Invalid request: {detail}",
+		codeMissingField:              "{field} is required",
+		codeInvalidField:              "Invalid {field}",
+		codeInvalidComposeService:     "Invalid compose service: {detail}",
+		codeInvalidRepoConfig:         "Invalid {field}: {detail}",
+		codeInvalidBundle:             "Invalid bundle: {detail}",
+		codeInvalidCertificate:        "Invalid certificate: {detail}",
+		codeBundleTooLarge:            "Bundle is too large",
+		codeCloneFailed:               "Clone failed: {detail}",
+		codeDetectFailed:              "Detection failed: {detail}",
+		codePortNotDetected:           "Could not find a port; check EXPOSE in the Dockerfile or ports in the compose file",
+		codeComposeVolumesUnsupported: "Volumes of compose projects are declared in the compose file",
+		codeUnauthorized:              "Unauthorized",
+		codeNotFound:                  "Not found",
+		codeProjectNotFound:           "Project not found",
+		codeDraftNotFound:             "Draft not found or expired",
+		codeJobNotFound:               "Job not found",
+		codePortNotFound:              "Port mapping not found",
+		codeDomainNotFound:            "Domain not found",
+		codeVolumeNotFound:            "Volume not found",
+		codeBackupNotFound:            "Backup not found",
+		codeBackupFileMissing:         "Backup file is missing",
+		codeEnvNotFound:               "Environment variable not found",
+		codeDBBackupNotFound:          "Database backup not found",
+		codeNoContainers:              "Project has no containers",
+		codePortInUse:                 "Host port is already in use",
+		codeNoFreePort:                "No free host port in the configured range",
+		codeHostnameInUse:             "Hostname is already in use by another project",
+		codeVolumeInUse:               "Volume name or container path is already used by this project",
+		codeImportConflict:            "Some projects have conflicts, nothing was imported",
+		codeGitOpsDisabled:            "GitOps is not configured",
+		codeGitOpsDestructive:         "The manifest removes projects and destructive changes are not allowed",
+		codeGitOpsFailed:              "GitOps failed: {detail}",
+		codePreviewsDisabled:          "Preview environments are not configured",
+		codeInvalidSignature:          "Webhook signature is missing or invalid",
+		codePreviewRepoMismatch:       "The webhook repository does not match the template project",
+		codePreviewTemplate:           "A preview project cannot be used as a template",
+		codeGitCredentialNotFound:     "Git credential not found",
+		codeGitCredentialNameInUse:    "Another git credential already has this name",
+		codeGitCredentialInUse:        "The git credential is still used by projects",
+		codeDockerUnavailable:         "Docker is unavailable",
+		codeInternal:                  "Internal server error",
+	},
+	"zh": {
+		codeInvalidRequest:            "请求无效：{detail}",
+		codeMissingField:              "缺少必填字段 {field}",
+		codeInvalidField:              "{field} 的值无效",
+		codeInvalidComposeService:     "无效的 compose 服务名：{detail}",
+		codeInvalidRepoConfig:         "{field} 无效：{detail}",
+		codeInvalidBundle:             "导入文件无效：{detail}",
+		codeInvalidCertificate:        "证书无效：{detail}",
+		codeBundleTooLarge:            "导入文件过大",
+		codeCloneFailed:               "克隆仓库失败：{detail}",
+		codeDetectFailed:              "识别部署方式失败：{detail}",
+		codePortNotDetected:           "无法从配置中解析端口信息，请检查 Dockerfile 的 EXPOSE 指令或 docker-compose.yml 的 ports 配置",
+		codeComposeVolumesUnsupported: "compose 项目的数据卷需在 compose 文件中声明",
+		codeUnauthorized:              "未授权",
+		codeNotFound:                  "未找到",
+		codeProjectNotFound:           "项目不存在",
+		codeDraftNotFound:             "草稿不存在或已过期",
+		codeJobNotFound:               "任务不存在",
+		codePortNotFound:              "端口映射不存在",
+		codeDomainNotFound:            "域名不存在",
+		codeVolumeNotFound:            "数据卷不存在",
+		codeBackupNotFound:            "备份不存在",
+		codeBackupFileMissing:         "备份文件已丢失",
+		codeEnvNotFound:               "环境变量不存在",
+		codeDBBackupNotFound:          "数据库备份不存在",
+		codeNoContainers:              "项目没有容器",
+		codePortInUse:                 "主机端口已被占用",
+		codeNoFreePort:                "端口范围内没有可用的主机端口",
+		codeHostnameInUse:             "域名已被其他项目使用",
+		codeVolumeInUse:               "数据卷名称或容器路径已被该项目使用",
+		codeImportConflict:            "部分项目存在冲突，未导入任何内容",
+		codeGitOpsDisabled:            "未配置 GitOps",
+		codeGitOpsDestructive:         "清单会删除项目，但未允许破坏性变更",
+		codeGitOpsFailed:              "GitOps 失败：{detail}",
+		codePreviewsDisabled:          "未配置预览环境",
+		codeInvalidSignature:          "Webhook 签名缺失或无效",
+		codePreviewRepoMismatch:       "Webhook 的仓库与模板项目不一致",
+		codePreviewTemplate:           "预览项目不能作为模板",
+		codeGitCredentialNotFound:     "Git 凭据不存在",
+		codeGitCredentialNameInUse:    "已有同名的 Git 凭据",
+		codeGitCredentialInUse:        "仍有项目在使用该 Git 凭据",
+		codeDockerUnavailable:         "无法连接 Docker",
+		codeInternal:                  "服务器内部错误",
+	},
+}
+
+// apiError 是统一的错误响应，序列化为 {"error": {...}}
+type apiError struct {
+	status int
+	// detail 是填入提示中的原始信息，通常来自下层错误
+	detail string
+
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+func newError(status int, code string) *apiError {
+	return &apiError{status: status, Code: code}
+}
+
+func (e *apiError) withField(field string) *apiError {
+	e.Field = field
+	return e
+}
+
+func (e *apiError) withDetail(format string, args ...any) *apiError {
+	e.detail = fmt.Sprintf(format, args...)
+	return e
+}
+
+func (e *apiError) withDetails(details any) *apiError {
+	e.Details = details
+	return e
+}
+
+func (e *apiError) Error() string {
+	return e.message("en")
+}
+
+func (e *apiError) message(lang string) string {
+	tmpl, ok := errorMessages[lang][e.Code]
+	if !ok {
+		tmpl = errorMessages["en"][e.Code]
+	}
+	if tmpl == "" {
+		tmpl = e.Code
+	}
+	if e.detail != "" && !strings.Contains(tmpl, "{detail}") {
+		tmpl += ": {detail}"
+	}
+	return strings.NewReplacer("{field}", e.Field, "{detail}", e.detail).Replace(tmpl)
+}
+
+func missingField(field string) *apiError {
+	return newError(http.StatusBadRequest, codeMissingField).withField(field)
+}
+
+func invalidField(field string) *apiError {
+	return newError(http.StatusBadRequest, codeInvalidField).withField(field)
+}
+
+func invalidComposeService(field, service string) *apiError {
+	return newError(http.StatusBadRequest, codeInvalidComposeService).withField(field).withDetail("%s", service)
+}
+
+// invalidRepoConfig 表示仓库中的 last-deploy.yaml 有误，field 为配置文件路径
+func invalidRepoConfig(path string, err error) *apiError {
+	return newError(http.StatusBadRequest, codeInvalidRepoConfig).withField(path).withDetail("%s", err)
+}
+
+// invalidRequest 将校验错误转换为 400；已是 apiError 时保持原样
+func invalidRequest(err error) *apiError {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae
+	}
+	return newError(http.StatusBadRequest, codeInvalidRequest).withDetail("%s", err.Error())
+}
+
+// toAPIError 把 store/engine 等下层错误映射为对应的状态码和错误码，
+// notFoundCode 用于 store.ErrNotFound。未知错误记录日志后返回 500，不把原始信息暴露给客户端
+func toAPIError(err error, notFoundCode string) *apiError {
+	var (
+		ae       *apiError
+		conflict *portalloc.ConflictError
+	)
+	switch {
+	case errors.As(err, &ae):
+		return ae
+	case errors.Is(err, store.ErrNotFound):
+		return newError(http.StatusNotFound, notFoundCode)
+	case errors.As(err, &conflict):
+		return newError(http.StatusConflict, codePortInUse).withField("host_port").withDetails(gin.H{
+			"port":        conflict.Port,
+			"protocol":    conflict.Protocol,
+			"suggestions": conflict.Suggestions,
+		})
+	case errors.Is(err, store.ErrPortConflict):
+		return newError(http.StatusConflict, codePortInUse).withField("host_port")
+	case errors.Is(err, portalloc.ErrNoFreePort):
+		return newError(http.StatusConflict, codeNoFreePort)
+	case errors.Is(err, store.ErrDomainConflict):
+		return newError(http.StatusConflict, codeHostnameInUse).withField("hostname")
+	case errors.Is(err, store.ErrVolumeConflict):
+		return newError(http.StatusConflict, codeVolumeInUse)
+	case errors.Is(err, engine.ErrNoContainers):
+		return newError(http.StatusNotFound, codeNoContainers)
+	case engine.IsUnavailable(err):
+		log.Printf("api: %v", err)
+		return newError(http.StatusServiceUnavailable, codeDockerUnavailable)
+	default:
+		log.Printf("api: %v", err)
+		return newError(http.StatusInternalServerError, codeInternal)
+	}
+}
+
+// writeError 按请求的 Accept-Language 输出错误响应
+func writeError(c *gin.Context, err error) {
+	c.JSON(errorStatus(c, err))
+}
+
+// writeLookupError 同 writeError，但 store.ErrNotFound 输出 notFoundCode；
+// 只用于可能找不到资源的调用
+func writeLookupError(c *gin.Context, err error, notFoundCode string) {
+	c.JSON(lookupErrorStatus(c, err, notFoundCode))
+}
+
+func errorStatus(c *gin.Context, err error) (int, gin.H) {
+	return lookupErrorStatus(c, err, codeNotFound)
+}
+
+func lookupErrorStatus(c *gin.Context, err error, notFoundCode string) (int, gin.H) {
+	ae := toAPIError(err, notFoundCode)
+	ae.Message = ae.message(requestLang(c))
+	return ae.status, gin.H{"error": ae}
+}
+
+// badRequest 输出校验错误
+func badRequest(c *gin.Context, err error) {
+	writeError(c, invalidRequest(err))
+}
+
+// requestLang 从 Accept-Language 选出支持的语言，默认英文
+func requestLang(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := errorMessages[lang]; ok {
+			return lang
+		}
+	}
+	return "en"
+}
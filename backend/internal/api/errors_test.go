@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/portalloc"
+	"last-deploy/internal/store"
+)
+
+func TestToAPIError(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{name: "not found", err: fmt.Errorf("get: %w", store.ErrNotFound), wantStatus: http.StatusNotFound, wantCode: codeProjectNotFound},
+		{name: "port conflict", err: store.ErrPortConflict, wantStatus: http.StatusConflict, wantCode: codePortInUse, wantField: "host_port"},
+		{name: "allocator conflict", err: &portalloc.ConflictError{Port: 8080, Protocol: "tcp"}, wantStatus: http.StatusConflict, wantCode: codePortInUse, wantField: "host_port"},
+		{name: "no free port", err: portalloc.ErrNoFreePort, wantStatus: http.StatusConflict, wantCode: codeNoFreePort},
+		{name: "hostname", err: store.ErrDomainConflict, wantStatus: http.StatusConflict, wantCode: codeHostnameInUse, wantField: "hostname"},
+		{name: "validation", err: invalidComposeService("compose_service", "a b"), wantStatus: http.StatusBadRequest, wantCode: codeInvalidComposeService, wantField: "compose_service"},
+		{name: "unknown", err: errors.New("sqlite: disk I/O error"), wantStatus: http.StatusInternalServerError, wantCode: codeInternal},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ae := toAPIError(tc.err, codeProjectNotFound)
+			if ae.status != tc.wantStatus || ae.Code != tc.wantCode || ae.Field != tc.wantField {
+				t.Fatalf("got %d %s %q, want %d %s %q", ae.status, ae.Code, ae.Field, tc.wantStatus, tc.wantCode, tc.wantField)
+			}
+		})
+	}
+}
+
+func TestWriteErrorLocalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		lang        string
+		wantMessage string
+	}{
+		{lang: "", wantMessage: "Invalid compose service: a b"},
+		{lang: "zh-CN,zh;q=0.9,en;q=0.8", wantMessage: "无效的 compose 服务名：a b"},
+		{lang: "fr-FR, en;q=0.5", wantMessage: "Invalid compose service: a b"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/projects", nil)
+		c.Request.Header.Set("Accept-Language", tc.lang)
+		badRequest(c, invalidComposeService("compose_service", "a b"))
+
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Field   string `json:"field"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || body.Error.Code != codeInvalidComposeService || body.Error.Field != "compose_service" {
+			t.Fatalf("%q: unexpected response %d %s", tc.lang, w.Code, w.Body.String())
+		}
+		if body.Error.Message != tc.wantMessage {
+			t.Errorf("%q: message = %q, want %q", tc.lang, body.Error.Message, tc.wantMessage)
+		}
+	}
+}
+
+// TestErrorMessagesComplete 确保每种语言都覆盖了全部错误码
+func TestErrorMessagesComplete(t *testing.T) {
+	for lang, msgs := range errorMessages {
+		for code := range errorMessages["en"] {
+			if msgs[code] == "" {
+				t.Errorf("%s: missing message for %s", lang, code)
+			}
+		}
+		if len(msgs) != len(errorMessages["en"]) {
+			t.Errorf("%s: %d messages, en has %d", lang, len(msgs), len(errorMessages["en"]))
+		}
+	}
+}
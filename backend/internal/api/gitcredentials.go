@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/gitauth"
+	"last-deploy/internal/store"
+)
+
+type createGitCredentialRequest struct {
+	Name string `json:"name"`
+	// Type 为 ssh、token 或 basic
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	// Secret 为令牌或密码；ssh 类型可导入已有私钥，留空则由服务端生成 ed25519 部署密钥
+	Secret     string `json:"secret"`
+	KnownHosts string `json:"known_hosts"`
+}
+
+type updateGitCredentialRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Username *string `json:"username,omitempty"`
+	// Secret 提供时替换原有密钥；ssh 类型传空字符串会重新生成密钥
+	Secret     *string `json:"secret,omitempty"`
+	KnownHosts *string `json:"known_hosts,omitempty"`
+}
+
+// listGitCredentials 返回全部凭据，不含私钥、令牌和密码
+func (s *Server) listGitCredentials(c *gin.Context) {
+	creds, err := s.st.ListGitCredentials(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credentials": creds})
+}
+
+// createGitCredential 创建凭据；ssh 类型返回的 public_key 需添加为仓库的部署密钥
+func (s *Server) createGitCredential(c *gin.Context) {
+	var req createGitCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		badRequest(c, missingField("name"))
+		return
+	}
+	credType := strings.ToLower(strings.TrimSpace(req.Type))
+	switch credType {
+	case store.GitCredentialSSH, store.GitCredentialToken:
+	case store.GitCredentialBasic:
+		if strings.TrimSpace(req.Username) == "" {
+			badRequest(c, missingField("username"))
+			return
+		}
+	case "":
+		badRequest(c, missingField("type"))
+		return
+	default:
+		badRequest(c, invalidField("type"))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	cred, err := s.gitAuth.Create(c.Request.Context(), store.GitCredential{
+		ID:         id,
+		Name:       name,
+		Type:       credType,
+		Username:   strings.TrimSpace(req.Username),
+		KnownHosts: strings.TrimSpace(req.KnownHosts),
+	}, strings.TrimSpace(req.Secret))
+	if err != nil {
+		writeError(c, gitCredentialError(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"credential": cred})
+}
+
+func (s *Server) updateGitCredential(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req updateGitCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	cred, err := s.st.GetGitCredential(ctx, c.Param("id"))
+	if err != nil {
+		writeLookupError(c, err, codeGitCredentialNotFound)
+		return
+	}
+	if req.Name != nil {
+		if cred.Name = strings.TrimSpace(*req.Name); cred.Name == "" {
+			badRequest(c, missingField("name"))
+			return
+		}
+	}
+	if req.Username != nil {
+		cred.Username = strings.TrimSpace(*req.Username)
+	}
+	if req.KnownHosts != nil {
+		cred.KnownHosts = strings.TrimSpace(*req.KnownHosts)
+	}
+	if req.Secret != nil {
+		secret := strings.TrimSpace(*req.Secret)
+		req.Secret = &secret
+	}
+	cred, err = s.gitAuth.Update(ctx, cred, req.Secret)
+	if err != nil {
+		writeLookupError(c, gitCredentialError(err), codeGitCredentialNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credential": cred})
+}
+
+// deleteGitCredential 仍有项目使用时返回 409
+func (s *Server) deleteGitCredential(c *gin.Context) {
+	if err := s.st.DeleteGitCredential(c.Request.Context(), c.Param("id")); err != nil {
+		writeLookupError(c, gitCredentialError(err), codeGitCredentialNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// checkGitCredential 确认项目引用的凭据存在，id 为空表示匿名拉取
+func (s *Server) checkGitCredential(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, err := s.st.GetGitCredential(ctx, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return invalidField("git_credential_id")
+		}
+		return err
+	}
+	return nil
+}
+
+func gitCredentialError(err error) error {
+	switch {
+	case errors.Is(err, gitauth.ErrInvalidKey), errors.Is(err, gitauth.ErrMissingSecret):
+		return invalidField("secret").withDetail("%s", err)
+	case errors.Is(err, gitauth.ErrInvalidKnownHosts):
+		return invalidField("known_hosts").withDetail("%s", err)
+	case errors.Is(err, gitauth.ErrMissingKnownHosts):
+		return missingField("known_hosts")
+	case errors.Is(err, store.ErrCredentialConflict):
+		return newError(http.StatusConflict, codeGitCredentialNameInUse).withField("name")
+	case errors.Is(err, store.ErrCredentialInUse):
+		return newError(http.StatusConflict, codeGitCredentialInUse)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/gitops"
+)
+
+func (s *Server) getGitOpsStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"gitops": s.gitops.Status()})
+}
+
+// planGitOps 拉取清单仓库并返回同步计划，不做任何修改
+func (s *Server) planGitOps(c *gin.Context) {
+	plan, err := s.gitops.Plan(c.Request.Context())
+	if err != nil {
+		writeError(c, gitOpsError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plan": plan})
+}
+
+// syncGitOps 立即同步；?allow_destructive=true 时允许删除已移出清单的项目
+func (s *Server) syncGitOps(c *gin.Context) {
+	allow, _ := strconv.ParseBool(c.Query("allow_destructive"))
+	plan, err := s.gitops.Sync(c.Request.Context(), allow)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"plan": plan})
+	case errors.Is(err, gitops.ErrDisabled), errors.Is(err, gitops.ErrDestructive), plan.Commit == "":
+		// plan.Commit 为空说明清单未能读取
+		status, body := errorStatus(c, gitOpsError(err))
+		if errors.Is(err, gitops.ErrDestructive) {
+			body["plan"] = plan
+		}
+		c.JSON(status, body)
+	default:
+		// 部分项目同步失败，详情见各项的 error
+		_, body := errorStatus(c, newError(http.StatusMultiStatus, codeGitOpsFailed).withDetail("%s", err))
+		body["plan"] = plan
+		c.JSON(http.StatusMultiStatus, body)
+	}
+}
+
+// gitOpsError 将 GitOps 的错误映射为错误码；其余错误多为拉取清单仓库失败，返回 502
+func gitOpsError(err error) *apiError {
+	switch {
+	case errors.Is(err, gitops.ErrDisabled):
+		return newError(http.StatusBadRequest, codeGitOpsDisabled)
+	case errors.Is(err, gitops.ErrDestructive):
+		return newError(http.StatusConflict, codeGitOpsDestructive)
+	default:
+		return newError(http.StatusBadGateway, codeGitOpsFailed).withDetail("%s", err)
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/store"
+)
+
+type healthCheckRequest struct {
+	Type           string `json:"type"`
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expected_status"`
+	Service        string `json:"service"`
+	Port           int    `json:"port"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// toHealthCheck 校验健康检查配置，type 为空表示不检查
+func (r healthCheckRequest) toHealthCheck() (store.HealthCheck, error) {
+	hc := store.HealthCheck{
+		Type:           strings.ToLower(strings.TrimSpace(r.Type)),
+		Service:        strings.TrimSpace(r.Service),
+		Port:           r.Port,
+		TimeoutSeconds: r.TimeoutSeconds,
+	}
+	switch hc.Type {
+	case store.HealthCheckNone, "none":
+		return store.HealthCheck{}, nil
+	case store.HealthCheckHTTP:
+		hc.Path = strings.TrimSpace(r.Path)
+		if hc.Path == "" {
+			hc.Path = "/"
+		}
+		if !strings.HasPrefix(hc.Path, "/") {
+			return store.HealthCheck{}, invalidField("path").withDetail("must start with /")
+		}
+		if r.ExpectedStatus != 0 && (r.ExpectedStatus < 100 || r.ExpectedStatus > 599) {
+			return store.HealthCheck{}, invalidField("expected_status")
+		}
+		hc.ExpectedStatus = r.ExpectedStatus
+	case store.HealthCheckTCP, store.HealthCheckDocker:
+	default:
+		return store.HealthCheck{}, invalidField("type")
+	}
+
+	if hc.Port < 0 || hc.Port > 65535 {
+		return store.HealthCheck{}, invalidField("port")
+	}
+	if hc.Service != "" && !composeServiceRe.MatchString(hc.Service) {
+		return store.HealthCheck{}, invalidComposeService("service", hc.Service)
+	}
+	if hc.TimeoutSeconds == 0 {
+		hc.TimeoutSeconds = 60
+	}
+	if hc.TimeoutSeconds < 1 || hc.TimeoutSeconds > 3600 {
+		return store.HealthCheck{}, invalidField("timeout_seconds").withDetail("must be between 1 and 3600")
+	}
+	return hc, nil
+}
+
+// updateProjectHealthCheck 修改健康检查配置，下次部署时生效
+func (s *Server) updateProjectHealthCheck(c *gin.Context) {
+	var req healthCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	hc, err := req.toHealthCheck()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	if err := s.st.SetProjectHealthCheck(c.Request.Context(), c.Param("id"), hc); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"health_check": hc})
+}
+
+type updateDeployStrategyRequest struct {
+	DeployStrategy string `json:"deploy_strategy"`
+}
+
+// updateProjectDeployStrategy 切换 Dockerfile 项目的部署方式（recreate / blue_green）
+func (s *Server) updateProjectDeployStrategy(c *gin.Context) {
+	var req updateDeployStrategyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	strategy := strings.ToLower(strings.TrimSpace(req.DeployStrategy))
+	switch strategy {
+	case "":
+		strategy = store.DeployStrategyRecreate
+	case store.DeployStrategyRecreate, store.DeployStrategyBlueGreen:
+	default:
+		badRequest(c, invalidField("deploy_strategy"))
+		return
+	}
+
+	if err := s.st.SetProjectDeployStrategy(c.Request.Context(), c.Param("id"), strategy); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deploy_strategy": strategy})
+}
+
+type updateAutoRollbackRequest struct {
+	AutoRollback *bool `json:"auto_rollback"`
+}
+
+// updateProjectAutoRollback 开关部署失败后的自动回滚
+func (s *Server) updateProjectAutoRollback(c *gin.Context) {
+	var req updateAutoRollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.AutoRollback == nil {
+		badRequest(c, missingField("auto_rollback"))
+		return
+	}
+
+	if err := s.st.SetProjectAutoRollback(c.Request.Context(), c.Param("id"), *req.AutoRollback); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"auto_rollback": *req.AutoRollback})
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/engine"
+)
+
+const (
+	defaultLogTail = 200
+	maxLogTail     = 10000
+)
+
+// getProjectLogs 返回项目容器的日志；?follow=true 时持续输出直到客户端断开或容器退出
+func (s *Server) getProjectLogs(c *gin.Context) {
+	projectID := c.Param("id")
+	tail := defaultLogTail
+	if v := c.Query("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxLogTail {
+			badRequest(c, invalidField("tail"))
+			return
+		}
+		tail = n
+	}
+	follow, _ := strconv.ParseBool(c.Query("follow"))
+
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	dk, err := engine.NewDocker()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	defer dk.Close()
+
+	if !follow {
+		logs, err := dk.ProjectLogs(c.Request.Context(), projectID, tail)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.String(http.StatusOK, logs)
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	if err := dk.FollowProjectLogs(c.Request.Context(), projectID, tail, c.Writer); err != nil {
+		// 响应头已发送，只能把错误附在输出末尾
+		_, _ = c.Writer.WriteString("error: " + err.Error() + "\n")
+	}
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/bundle"
+	"last-deploy/internal/certs"
+	"last-deploy/internal/dbbackup"
+	"last-deploy/internal/detector"
+	"last-deploy/internal/gitops"
+	"last-deploy/internal/openapi"
+	"last-deploy/internal/store"
+)
+
+// apiRoute 描述一个 API 路由，用于生成 OpenAPI 文档。
+// handler 必须与 router.go 中注册的处理函数同名，由测试保证两者一致
+type apiRoute struct {
+	method  string
+	path    string // gin 格式，不含 /api 前缀
+	handler string
+	tag     string
+	summary string
+	query   []openapi.Parameter
+	body    any // 请求体的 Go 值，nil 表示无请求体
+	status  int
+	resp    any    // 响应的 Go 值或 openapi.Object
+	media   string // 非 JSON 响应的类型
+	yaml    bool   // 请求体/响应也接受 YAML
+	public  bool   // 不需要 API token
+}
+
+var (
+	okResponse  = openapi.Object{"ok": true}
+	jobResponse = openapi.Object{"job": store.Job{}}
+)
+
+var apiRoutes = []apiRoute{
+	{method: "GET", path: "/health", handler: "health", tag: "system", summary: "Check that the server is up", resp: okResponse, public: true},
+	{method: "GET", path: "/openapi.json", handler: "getOpenAPI", tag: "system", summary: "Return this OpenAPI document", resp: &openapi.Schema{Type: "object"}, public: true},
+
+	{method: "GET", path: "/projects", handler: "listProjects", tag: "projects", summary: "List projects",
+		resp: openapi.Object{"projects": []store.Project{}}},
+	{method: "POST", path: "/projects", handler: "createProject", tag: "projects", summary: "Create a project and optionally deploy it",
+		body: createProjectRequest{}, status: http.StatusCreated,
+		resp: openapi.Object{"project": store.Project{}, "job": (*store.Job)(nil)}},
+	{method: "POST", path: "/projects/detect", handler: "detectProject", tag: "projects", summary: "Clone a repository and detect how to deploy it",
+		body: detectProjectRequest{},
+		resp: openapi.Object{
+			"draft_id":           "",
+			"deploy_type":        "",
+			"dockerfile_path":    "",
+			"dockerfile_content": "",
+			"compose_path":       "",
+			"compose_content":    "",
+			"services":           []string{},
+			"compose_service":    "",
+			"config_path":        "",
+			"config":             (*detector.RepoConfig)(nil),
+		}},
+	{method: "POST", path: "/projects/from-draft", handler: "createProjectFromDraft", tag: "projects", summary: "Create a project from a detection draft",
+		body: createProjectFromDraftRequest{}, status: http.StatusCreated,
+		resp: openapi.Object{"project": store.Project{}, "job": (*store.Job)(nil)}},
+	{method: "GET", path: "/projects/export", handler: "exportProjects", tag: "projects", summary: "Export all project definitions",
+		query: []openapi.Parameter{queryParam("format", "string", "Output format: json (default) or yaml.")},
+		resp:  bundle.Bundle{}, yaml: true},
+	{method: "POST", path: "/projects/import", handler: "importProjects", tag: "projects", summary: "Import project definitions",
+		query: []openapi.Parameter{queryParam("dry_run", "boolean", "Only return the import plan.")},
+		body:  bundle.Bundle{}, yaml: true,
+		resp: openapi.Object{"dry_run": false, "items": []bundle.Item{}}},
+	{method: "GET", path: "/projects/:id", handler: "getProject", tag: "projects", summary: "Get a project",
+		resp: openapi.Object{"project": store.Project{}}},
+	{method: "PATCH", path: "/projects/:id", handler: "updateProject", tag: "projects", summary: "Change project settings",
+		body: updateProjectRequest{}, resp: openapi.Object{"project": store.Project{}, "requires_redeploy": false}},
+	{method: "POST", path: "/projects/:id/clone", handler: "cloneProject", tag: "projects", summary: "Copy a project into a new one",
+		body: cloneProjectRequest{}, status: http.StatusCreated, resp: openapi.Object{"project": store.Project{}, "job": (*store.Job)(nil)}},
+	{method: "GET", path: "/projects/:id/previews", handler: "listProjectPreviews", tag: "previews", summary: "List the pull request previews of a template project",
+		resp: openapi.Object{"previews": []store.Preview{}}},
+	{method: "POST", path: "/previews/github/:id", handler: "previewWebhook", tag: "previews", summary: "Receive a GitHub pull_request webhook for a template project",
+		body: &openapi.Schema{Type: "object"}, resp: openapi.Object{"preview": store.Preview{}}, public: true},
+	{method: "PUT", path: "/projects/:id/config", handler: "updateProjectConfig", tag: "projects", summary: "Replace the Dockerfile and compose content",
+		body: updateProjectConfigRequest{}, resp: okResponse},
+	{method: "PUT", path: "/projects/:id/exposure", handler: "updateProjectExposure", tag: "projects", summary: "Change where published ports listen",
+		body: updateProjectExposureRequest{}, resp: openapi.Object{"expose_mode": "", "bind_ip": ""}},
+	{method: "PUT", path: "/projects/:id/health-check", handler: "updateProjectHealthCheck", tag: "projects", summary: "Change the health check",
+		body: healthCheckRequest{}, resp: openapi.Object{"health_check": store.HealthCheck{}}},
+	{method: "PUT", path: "/projects/:id/deploy-strategy", handler: "updateProjectDeployStrategy", tag: "projects", summary: "Change the deploy strategy",
+		body: updateDeployStrategyRequest{}, resp: openapi.Object{"deploy_strategy": ""}},
+	{method: "PUT", path: "/projects/:id/auto-rollback", handler: "updateProjectAutoRollback", tag: "projects", summary: "Turn automatic rollback on or off",
+		body: updateAutoRollbackRequest{}, resp: openapi.Object{"auto_rollback": false}},
+	{method: "PUT", path: "/projects/:id/resources", handler: "updateProjectResources", tag: "projects", summary: "Change container resource limits",
+		body: resourceLimitsRequest{}, resp: openapi.Object{"resource_limits": store.ResourceLimits{}}},
+
+	{method: "GET", path: "/projects/:id/ports", handler: "listProjectPorts", tag: "ports", summary: "List port mappings",
+		resp: openapi.Object{"ports": []store.ProjectPort{}}},
+	{method: "POST", path: "/projects/:id/ports", handler: "createProjectPort", tag: "ports", summary: "Add a port mapping",
+		body: projectPortRequest{}, status: http.StatusCreated, resp: openapi.Object{"port": store.ProjectPort{}}},
+	{method: "PUT", path: "/projects/:id/ports/:portId", handler: "updateProjectPort", tag: "ports", summary: "Change a port mapping",
+		body: projectPortRequest{}, resp: openapi.Object{"port": store.ProjectPort{}}},
+	{method: "DELETE", path: "/projects/:id/ports/:portId", handler: "deleteProjectPort", tag: "ports", summary: "Remove a port mapping",
+		resp: okResponse},
+
+	{method: "GET", path: "/projects/:id/domains", handler: "listProjectDomains", tag: "domains", summary: "List domains",
+		resp: openapi.Object{"default_hostname": "", "domains": []store.ProjectDomain{}}},
+	{method: "POST", path: "/projects/:id/domains", handler: "createProjectDomain", tag: "domains", summary: "Add a domain",
+		body: createProjectDomainRequest{}, status: http.StatusCreated, resp: openapi.Object{"domain": store.ProjectDomain{}}},
+	{method: "DELETE", path: "/projects/:id/domains/:domainId", handler: "deleteProjectDomain", tag: "domains", summary: "Remove a domain",
+		resp: okResponse},
+	{method: "GET", path: "/projects/:id/domains/:domainId/certificate", handler: "getDomainCertificate", tag: "domains", summary: "Get the certificate of a domain",
+		resp: openapi.Object{"certificate": (*certs.Info)(nil)}},
+	{method: "PUT", path: "/projects/:id/domains/:domainId/certificate", handler: "uploadDomainCertificate", tag: "domains", summary: "Upload a custom certificate",
+		body: uploadCertificateRequest{}, resp: openapi.Object{"certificate": certs.Info{}}},
+	{method: "DELETE", path: "/projects/:id/domains/:domainId/certificate", handler: "deleteDomainCertificate", tag: "domains", summary: "Remove the custom certificate",
+		resp: okResponse},
+
+	{method: "GET", path: "/projects/:id/volumes", handler: "listProjectVolumes", tag: "volumes", summary: "List volumes",
+		resp: openapi.Object{"volumes": []store.ProjectVolume{}}},
+	{method: "POST", path: "/projects/:id/volumes", handler: "createProjectVolume", tag: "volumes", summary: "Add a volume",
+		body: createProjectVolumeRequest{}, status: http.StatusCreated, resp: openapi.Object{"volume": store.ProjectVolume{}}},
+	{method: "DELETE", path: "/projects/:id/volumes/:volumeId", handler: "deleteProjectVolume", tag: "volumes", summary: "Remove a volume",
+		resp: okResponse},
+
+	{method: "GET", path: "/projects/:id/backups", handler: "listProjectBackups", tag: "backups", summary: "List volume backups",
+		resp: openapi.Object{"backups": []store.Backup{}}},
+	{method: "POST", path: "/projects/:id/backups", handler: "createProjectBackup", tag: "backups", summary: "Start a volume backup",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "GET", path: "/projects/:id/backups/:backupId/download", handler: "downloadProjectBackup", tag: "backups", summary: "Download a backup archive",
+		media: "application/gzip"},
+	{method: "DELETE", path: "/projects/:id/backups/:backupId", handler: "deleteProjectBackup", tag: "backups", summary: "Delete a backup",
+		resp: okResponse},
+	{method: "POST", path: "/projects/:id/backups/:backupId/restore", handler: "restoreProjectBackup", tag: "backups", summary: "Start restoring a backup",
+		status: http.StatusAccepted, resp: jobResponse},
+
+	{method: "GET", path: "/projects/:id/env", handler: "listProjectEnv", tag: "env", summary: "List environment variables",
+		resp: openapi.Object{"env": []store.ProjectEnvVar{}}},
+	{method: "PUT", path: "/projects/:id/env/:name", handler: "setProjectEnv", tag: "env", summary: "Set an environment variable",
+		body: setProjectEnvRequest{}, resp: openapi.Object{"env": store.ProjectEnvVar{}}},
+	{method: "DELETE", path: "/projects/:id/env/:name", handler: "deleteProjectEnv", tag: "env", summary: "Remove an environment variable",
+		resp: okResponse},
+
+	{method: "GET", path: "/projects/:id/logs", handler: "getProjectLogs", tag: "projects", summary: "Read container logs",
+		query: []openapi.Parameter{
+			queryParam("tail", "integer", "Lines to show from the end, default "+strconv.Itoa(defaultLogTail)+"."),
+			queryParam("follow", "boolean", "Keep streaming new lines."),
+		},
+		media: "text/plain"},
+	{method: "GET", path: "/projects/:id/jobs/latest", handler: "getProjectLatestJob", tag: "jobs", summary: "Get the latest job of a project",
+		resp: jobResponse},
+	{method: "POST", path: "/projects/:id/deploy", handler: "deployProject", tag: "projects", summary: "Start a deploy",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "POST", path: "/projects/:id/start", handler: "startProject", tag: "projects", summary: "Start the containers",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "POST", path: "/projects/:id/stop", handler: "stopProject", tag: "projects", summary: "Stop the containers",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "POST", path: "/projects/:id/pause", handler: "pauseProject", tag: "projects", summary: "Pause the containers",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "POST", path: "/projects/:id/unpause", handler: "unpauseProject", tag: "projects", summary: "Unpause the containers",
+		status: http.StatusAccepted, resp: jobResponse},
+	{method: "DELETE", path: "/projects/:id", handler: "deleteProject", tag: "projects", summary: "Start deleting a project",
+		query:  []openapi.Parameter{queryParam("purge", "boolean", "Also delete the project's volumes.")},
+		status: http.StatusAccepted, resp: jobResponse},
+
+	{method: "GET", path: "/jobs/:id", handler: "getJob", tag: "jobs", summary: "Get a job",
+		resp: jobResponse},
+
+	{method: "GET", path: "/admin/db-backups", handler: "listDBBackups", tag: "admin", summary: "List database backups",
+		resp: openapi.Object{"backups": []dbbackup.Snapshot{}}},
+	{method: "POST", path: "/admin/db-backups", handler: "createDBBackup", tag: "admin", summary: "Back up the database now",
+		status: http.StatusCreated, resp: openapi.Object{"backup": dbbackup.Snapshot{}}},
+	{method: "GET", path: "/admin/db-backups/:name/download", handler: "downloadDBBackup", tag: "admin", summary: "Download a database backup",
+		media: "application/octet-stream"},
+
+	{method: "GET", path: "/gitops", handler: "getGitOpsStatus", tag: "gitops", summary: "Get the GitOps status",
+		resp: openapi.Object{"gitops": gitops.Status{}}},
+	{method: "POST", path: "/gitops/plan", handler: "planGitOps", tag: "gitops", summary: "Plan a GitOps sync without applying it",
+		resp: openapi.Object{"plan": gitops.Plan{}}},
+	{method: "POST", path: "/gitops/sync", handler: "syncGitOps", tag: "gitops", summary: "Sync projects with the manifest repository",
+		query: []openapi.Parameter{queryParam("allow_destructive", "boolean", "Allow deleting projects missing from the manifest.")},
+		resp:  openapi.Object{"plan": gitops.Plan{}}},
+
+	{method: "GET", path: "/git-credentials", handler: "listGitCredentials", tag: "git-credentials", summary: "List git credentials without their secrets",
+		resp: openapi.Object{"credentials": []store.GitCredential{}}},
+	{method: "POST", path: "/git-credentials", handler: "createGitCredential", tag: "git-credentials", summary: "Create a deploy key, token or password credential",
+		body: createGitCredentialRequest{}, status: http.StatusCreated, resp: openapi.Object{"credential": store.GitCredential{}}},
+	{method: "PATCH", path: "/git-credentials/:id", handler: "updateGitCredential", tag: "git-credentials", summary: "Change a git credential",
+		body: updateGitCredentialRequest{}, resp: openapi.Object{"credential": store.GitCredential{}}},
+	{method: "DELETE", path: "/git-credentials/:id", handler: "deleteGitCredential", tag: "git-credentials", summary: "Delete a git credential no project uses",
+		resp: okResponse},
+}
+
+// schemaNames 为与 store 同名的类型指定文档中的名称，与前端 types.ts 保持一致
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeFor[bundle.Bundle]():         "ProjectBundle",
+	reflect.TypeFor[bundle.Project]():        "BundleProject",
+	reflect.TypeFor[bundle.Port]():           "BundlePort",
+	reflect.TypeFor[bundle.HealthCheck]():    "BundleHealthCheck",
+	reflect.TypeFor[bundle.ResourceLimits](): "BundleResourceLimits",
+	reflect.TypeFor[bundle.Volume]():         "BundleVolume",
+	reflect.TypeFor[bundle.Domain]():         "BundleDomain",
+	reflect.TypeFor[bundle.Item]():           "ImportItem",
+	reflect.TypeFor[apiError]():              "APIError",
+	reflect.TypeFor[certs.Info]():            "CertificateInfo",
+	reflect.TypeFor[dbbackup.Snapshot]():     "DBBackup",
+	reflect.TypeFor[gitops.Plan]():           "GitOpsPlan",
+	reflect.TypeFor[gitops.Status]():         "GitOpsStatus",
+}
+
+var ginParamRe = regexp.MustCompile(`:([A-Za-z]+)`)
+
+var openAPIDoc = sync.OnceValue(buildOpenAPI)
+
+// OpenAPI 返回 API 的 OpenAPI 3 文档，由 apiRoutes 和处理函数使用的 Go 类型生成
+func OpenAPI() *openapi.Document {
+	return openAPIDoc()
+}
+
+func buildOpenAPI() *openapi.Document {
+	schemas := openapi.NewSchemas()
+	for t, name := range schemaNames {
+		schemas.Name(t, name)
+	}
+	errResp := openapi.Response{
+		Description: "Error",
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: schemas.For(errorResponse{})}},
+	}
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Last Deploy API",
+			Description: "Requests need an Authorization: Bearer header when the server sets LAST_DEPLOY_API_TOKENS.",
+			Version:     "1",
+		},
+		Servers:  []openapi.Server{{URL: "/api"}},
+		Security: []openapi.SecurityRequirement{{"bearer": {}}},
+		Paths:    map[string]openapi.PathItem{},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]openapi.SecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}},
+		},
+	}
+	for _, rt := range apiRoutes {
+		op := &openapi.Operation{
+			OperationID: rt.handler,
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Responses:   map[string]openapi.Response{"default": errResp},
+		}
+		for _, m := range ginParamRe.FindAllStringSubmatch(rt.path, -1) {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: m[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, rt.query...)
+
+		if rt.body != nil {
+			content := map[string]openapi.MediaType{"application/json": {Schema: schemas.For(rt.body)}}
+			if rt.yaml {
+				content["application/yaml"] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+			}
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: content}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := openapi.Response{Description: http.StatusText(status), Content: map[string]openapi.MediaType{}}
+		if rt.media != "" {
+			format := "binary"
+			if strings.HasPrefix(rt.media, "text/") {
+				format = ""
+			}
+			resp.Content[rt.media] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: format}}
+		} else {
+			resp.Content["application/json"] = openapi.MediaType{Schema: schemas.For(rt.resp)}
+			if rt.yaml {
+				resp.Content["application/yaml"] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+
+		if rt.public {
+			op.Security = &[]openapi.SecurityRequirement{}
+		}
+
+		path := ginParamRe.ReplaceAllString(rt.path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = openapi.PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = op
+	}
+	doc.Components.Schemas = schemas.Components()
+	return doc
+}
+
+// errorResponse 是所有错误响应的格式
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+func getOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPI())
+}
+
+func health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
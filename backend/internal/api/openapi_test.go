@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/gitops"
+	"last-deploy/internal/openapi"
+	"last-deploy/internal/preview"
+)
+
+// TestOpenAPIMatchesRouter 遍历路由表，确保文档与注册的路由一一对应
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Config{}
+	r := NewRouter(nil, nil, cfg, nil, nil, nil, gitops.New(cfg, nil), preview.New(cfg, nil, nil), nil)
+	doc := OpenAPI()
+
+	seen := map[string]bool{}
+	for _, rt := range r.Routes() {
+		path, ok := strings.CutPrefix(rt.Path, "/api")
+		if !ok {
+			continue
+		}
+		path = ginParamRe.ReplaceAllString(path, "{$1}")
+		key := rt.Method + " " + path
+		seen[key] = true
+
+		op := doc.Paths[path][strings.ToLower(rt.Method)]
+		if op == nil {
+			t.Errorf("%s is routed but missing from the OpenAPI document", key)
+			continue
+		}
+		handler := rt.Handler[strings.LastIndexByte(rt.Handler, '.')+1:]
+		handler = strings.TrimSuffix(handler, "-fm")
+		if op.OperationID != handler {
+			t.Errorf("%s: operationId %s, handler %s", key, op.OperationID, handler)
+		}
+	}
+	for _, op := range doc.Operations() {
+		if key := op.Method + " " + op.Path; !seen[key] {
+			t.Errorf("%s is documented but not routed", key)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	doc := OpenAPI()
+	var walk func(where string, s *openapi.Schema)
+	walk = func(where string, s *openapi.Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			if doc.Components.Schemas[s.RefName()] == nil {
+				t.Errorf("%s: unresolved %s", where, s.Ref)
+			}
+			return
+		}
+		for _, sub := range s.AllOf {
+			walk(where, sub)
+		}
+		walk(where, s.Items)
+		walk(where, s.AdditionalProperties)
+		for name, p := range s.Properties {
+			walk(where+"."+name, p)
+		}
+	}
+	for name, s := range doc.Components.Schemas {
+		walk(name, s)
+	}
+	for _, op := range doc.Operations() {
+		if op.RequestBody != nil {
+			for _, mt := range op.RequestBody.Content {
+				walk(op.OperationID, mt.Schema)
+			}
+		}
+		for _, resp := range op.Responses {
+			for _, mt := range resp.Content {
+				walk(op.OperationID, mt.Schema)
+			}
+		}
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Config{APITokens: []string{"secret"}}
+	r := NewRouter(nil, nil, cfg, nil, nil, nil, gitops.New(cfg, nil), preview.New(cfg, nil, nil), nil)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 without a token", w.Code)
+	}
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version || doc.Paths["/projects/{id}"] == nil {
+		t.Fatalf("unexpected document: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/projects", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401 without a token", w.Code)
+	}
+}
@@ -103,6 +103,89 @@ services:
 			expectedHost:      0,
 			expectedContainer: 0,
 		},
+		{
+			name: "long syntax",
+			content: `services:
+  web:
+    image: nginx
+    ports:
+      - target: 80
+        published: 8081
+        protocol: tcp`,
+			serviceName:       "web",
+			expectedHost:      8081,
+			expectedContainer: 80,
+		},
+		{
+			name: "ip bound mapping",
+			content: `services:
+  web:
+    image: nginx
+    ports:
+      - "127.0.0.1:8082:80"`,
+			serviceName:       "web",
+			expectedHost:      8082,
+			expectedContainer: 80,
+		},
+		{
+			name: "env var interpolation with default",
+			content: `services:
+  app:
+    image: node
+    ports:
+      - "${PORT:-3000}:3000"`,
+			serviceName:       "app",
+			expectedHost:      3000,
+			expectedContainer: 3000,
+		},
+		{
+			name: "selected service is not the first",
+			content: `services:
+  db:
+    image: postgres
+    ports:
+      - "5432:5432"
+  web:
+    image: nginx
+    ports:
+      - "8080:80"`,
+			serviceName:       "web",
+			expectedHost:      8080,
+			expectedContainer: 80,
+		},
+		{
+			name: "no service selected skips services without published ports",
+			content: `services:
+  db:
+    image: postgres
+    expose:
+      - "5432"
+  web:
+    image: nginx
+    ports:
+      - "80"
+      - "8080:80"`,
+			serviceName:       "",
+			expectedHost:      8080,
+			expectedContainer: 80,
+		},
+		{
+			name: "required variable in another service",
+			content: `services:
+  db:
+    image: postgres
+    environment:
+      POSTGRES_PASSWORD: ${DB_PASSWORD:?set a password}
+    ports:
+      - "${DB_PORT?}:5432"
+  web:
+    image: nginx
+    ports:
+      - "8080:80"`,
+			serviceName:       "web",
+			expectedHost:      8080,
+			expectedContainer: 80,
+		},
 	}
 
 	for _, tt := range tests {
@@ -115,3 +198,19 @@ services:
 		})
 	}
 }
+
+func TestParseComposeProjectPorts_RequiredVariable(t *testing.T) {
+	content := `services:
+  worker:
+    image: worker
+    command: ["run", "${QUEUE:?}"]
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+      - "${ADMIN_PORT:?}:81"`
+	ports := parseComposeProjectPorts(content, "")
+	if len(ports) != 1 || ports[0].Service != "web" || ports[0].HostPort != 8080 || ports[0].ContainerPort != 80 {
+		t.Fatalf("parseComposeProjectPorts() = %+v, want only web 8080:80", ports)
+	}
+}
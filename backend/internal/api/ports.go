@@ -0,0 +1,221 @@
+package api
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/store"
+)
+
+type projectPortRequest struct {
+	Service       string `json:"service"`
+	HostIP        string `json:"host_ip"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+// toProjectPort 校验并规范化端口映射请求
+func (r projectPortRequest) toProjectPort() (store.ProjectPort, error) {
+	if r.HostPort < 0 || r.HostPort > 65535 {
+		return store.ProjectPort{}, invalidField("host_port")
+	}
+	if r.ContainerPort <= 0 || r.ContainerPort > 65535 {
+		return store.ProjectPort{}, invalidField("container_port")
+	}
+
+	protocol := strings.ToLower(strings.TrimSpace(r.Protocol))
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	switch protocol {
+	case "tcp", "udp", "sctp":
+	default:
+		return store.ProjectPort{}, invalidField("protocol")
+	}
+
+	hostIP := strings.TrimSpace(r.HostIP)
+	if hostIP != "" {
+		if _, err := netip.ParseAddr(hostIP); err != nil {
+			return store.ProjectPort{}, invalidField("host_ip")
+		}
+	}
+
+	service := strings.TrimSpace(r.Service)
+	if service != "" && !composeServiceRe.MatchString(service) {
+		return store.ProjectPort{}, invalidComposeService("service", service)
+	}
+
+	return store.ProjectPort{
+		Service:       service,
+		HostIP:        hostIP,
+		HostPort:      r.HostPort,
+		ContainerPort: r.ContainerPort,
+		Protocol:      protocol,
+	}, nil
+}
+
+// keepHostPorts 让未指定主机端口的映射沿用同一位置、同一容器端口的当前主机端口
+func keepHostPorts(ports, cur []store.ProjectPort) {
+	for i := range ports {
+		if i < len(cur) && ports[i].HostPort == 0 && ports[i].ContainerPort == cur[i].ContainerPort {
+			ports[i].HostPort = cur[i].HostPort
+		}
+	}
+}
+
+// samePorts 比较两组映射的配置，忽略 ID 和创建时间
+func samePorts(a, b []store.ProjectPort) bool {
+	return slices.EqualFunc(a, b, func(x, y store.ProjectPort) bool {
+		return x.Service == y.Service && x.HostIP == y.HostIP && x.HostPort == y.HostPort &&
+			x.ContainerPort == y.ContainerPort && x.Protocol == y.Protocol
+	})
+}
+
+// normalizeExposure 校验项目的端口暴露方式，mode 为空时使用 loopback
+func normalizeExposure(mode, bindIP string) (string, string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	bindIP = strings.TrimSpace(bindIP)
+	switch mode {
+	case "":
+		mode = store.ExposeLoopback
+	case store.ExposeLoopback, store.ExposeAll, store.ExposeNone:
+	case store.ExposeIP:
+		addr, err := netip.ParseAddr(bindIP)
+		if err != nil {
+			return "", "", invalidField("bind_ip")
+		}
+		return mode, addr.String(), nil
+	default:
+		return "", "", invalidField("expose_mode")
+	}
+	return mode, "", nil
+}
+
+type updateProjectExposureRequest struct {
+	ExposeMode string `json:"expose_mode"`
+	BindIP     string `json:"bind_ip"`
+}
+
+// updateProjectExposure 修改端口绑定地址，下次部署/启动时生效
+func (s *Server) updateProjectExposure(c *gin.Context) {
+	id := c.Param("id")
+	var req updateProjectExposureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	mode, bindIP, err := normalizeExposure(req.ExposeMode, req.BindIP)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	if err := s.st.SetProjectExposure(c.Request.Context(), id, mode, bindIP); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"expose_mode": mode, "bind_ip": bindIP})
+}
+
+func (s *Server) listProjectPorts(c *gin.Context) {
+	projectID := c.Param("id")
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	ports, err := s.st.ListProjectPorts(c.Request.Context(), projectID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ports": ports})
+}
+
+func (s *Server) createProjectPort(c *gin.Context) {
+	projectID := c.Param("id")
+	var req projectPortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	port, err := req.toProjectPort()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	port.ID = id
+	port.ProjectID = projectID
+
+	// host_port 为 0 时自动分配空闲端口
+	assigned, err := s.ports.Assign(c.Request.Context(), projectID, []store.ProjectPort{port})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	created, err := s.st.CreateProjectPort(c.Request.Context(), assigned[0])
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"port": created})
+}
+
+func (s *Server) updateProjectPort(c *gin.Context) {
+	projectID := c.Param("id")
+	var req projectPortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	port, err := req.toProjectPort()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	port.ID = c.Param("portId")
+	port.ProjectID = projectID
+
+	assigned, err := s.ports.Assign(c.Request.Context(), projectID, []store.ProjectPort{port})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if err := s.st.UpdateProjectPort(c.Request.Context(), assigned[0]); err != nil {
+		writeLookupError(c, err, codePortNotFound)
+		return
+	}
+
+	updated, err := s.st.GetProjectPort(c.Request.Context(), projectID, port.ID)
+	if err != nil {
+		writeLookupError(c, err, codePortNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"port": updated})
+}
+
+func (s *Server) deleteProjectPort(c *gin.Context) {
+	if err := s.st.DeleteProjectPort(c.Request.Context(), c.Param("id"), c.Param("portId")); err != nil {
+		writeLookupError(c, err, codePortNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/preview"
+)
+
+// maxWebhookBody 限制 webhook 请求体大小，GitHub 的 payload 上限为 25MB
+const maxWebhookBody = 25 << 20
+
+// previewWebhook 接收 GitHub 的 pull_request webhook，为 :id 模板项目的每个
+// PR 创建、更新或删除预览项目。不经过 API token 校验，改用 X-Hub-Signature-256
+func (s *Server) previewWebhook(c *gin.Context) {
+	if !s.previews.Enabled() {
+		writeError(c, newError(http.StatusNotFound, codePreviewsDisabled))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if !s.previews.VerifySignature(body, c.GetHeader("X-Hub-Signature-256")) {
+		writeError(c, newError(http.StatusUnauthorized, codeInvalidSignature))
+		return
+	}
+
+	switch c.GetHeader("X-GitHub-Event") {
+	case "ping":
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	case "pull_request":
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"ignored": true})
+		return
+	}
+
+	ev, err := preview.ParseEvent(body)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	pv, err := s.previews.Handle(c.Request.Context(), c.Param("id"), ev)
+	if err != nil {
+		writeLookupError(c, previewError(err), codeProjectNotFound)
+		return
+	}
+	if pv.ProjectID == "" {
+		c.JSON(http.StatusAccepted, gin.H{"ignored": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preview": pv})
+}
+
+// listProjectPreviews 返回以该项目为模板的预览环境
+func (s *Server) listProjectPreviews(c *gin.Context) {
+	ctx := c.Request.Context()
+	p, err := s.st.GetProject(ctx, c.Param("id"))
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	previews, err := s.st.ListPreviews(ctx, p.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"previews": previews})
+}
+
+func previewError(err error) error {
+	switch {
+	case errors.Is(err, preview.ErrDisabled):
+		return newError(http.StatusNotFound, codePreviewsDisabled)
+	case errors.Is(err, preview.ErrRepoMismatch):
+		return newError(http.StatusBadRequest, codePreviewRepoMismatch)
+	case errors.Is(err, preview.ErrNotTemplate):
+		return newError(http.StatusBadRequest, codePreviewTemplate)
+	default:
+		return err
+	}
+}
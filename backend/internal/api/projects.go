@@ -5,10 +5,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,13 +34,23 @@ type createProjectRequest struct {
 	DockerfilePath string `json:"dockerfile_path"`
 	HostPort       int    `json:"host_port"`
 	ContainerPort  int    `json:"container_port"`
+	ExposeMode     string `json:"expose_mode"`
+	BindIP         string `json:"bind_ip"`
 	Deploy         bool   `json:"deploy"`
+
+	// GitCredentialID 可选，拉取私有仓库时使用的凭据
+	GitCredentialID string `json:"git_credential_id"`
+	// SparseCheckout 为 true 时只检出 repo_subdir
+	SparseCheckout bool `json:"sparse_checkout"`
+
+	// Ports 可选，指定后替代 host_port/container_port 作为完整的端口映射列表
+	Ports []projectPortRequest `json:"ports"`
 }
 
 func (s *Server) listProjects(c *gin.Context) {
 	projects, err := s.st.ListProjects(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"projects": projects})
@@ -47,23 +59,42 @@ func (s *Server) listProjects(c *gin.Context) {
 func (s *Server) createProject(c *gin.Context) {
 	var req createProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 	if strings.TrimSpace(req.Name) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		badRequest(c, missingField("name"))
 		return
 	}
 	if strings.TrimSpace(req.GitURL) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "git_url is required"})
+		badRequest(c, missingField("git_url"))
 		return
 	}
-	if req.HostPort <= 0 || req.HostPort > 65535 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid host_port"})
-		return
+	var ports []store.ProjectPort
+	for _, pr := range req.Ports {
+		port, err := pr.toProjectPort()
+		if err != nil {
+			badRequest(c, err)
+			return
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) == 0 {
+		// host_port 为 0 时自动分配
+		if req.HostPort < 0 || req.HostPort > 65535 {
+			badRequest(c, invalidField("host_port"))
+			return
+		}
+		if req.ContainerPort <= 0 || req.ContainerPort > 65535 {
+			badRequest(c, invalidField("container_port"))
+			return
+		}
+		ports = []store.ProjectPort{{HostPort: req.HostPort, ContainerPort: req.ContainerPort, Protocol: "tcp"}}
 	}
-	if req.ContainerPort <= 0 || req.ContainerPort > 65535 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid container_port"})
+
+	exposeMode, bindIP, err := normalizeExposure(req.ExposeMode, req.BindIP)
+	if err != nil {
+		badRequest(c, err)
 		return
 	}
 
@@ -74,7 +105,12 @@ func (s *Server) createProject(c *gin.Context) {
 	switch deployType {
 	case "auto", "dockerfile", "compose":
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid deploy_type"})
+		badRequest(c, invalidField("deploy_type"))
+		return
+	}
+
+	if err := s.checkGitCredential(c.Request.Context(), req.GitCredentialID); err != nil {
+		writeError(c, err)
 		return
 	}
 
@@ -84,15 +120,21 @@ func (s *Server) createProject(c *gin.Context) {
 		for _, svc := range strings.Split(composeService, ",") {
 			svc = strings.TrimSpace(svc)
 			if svc != "" && !composeServiceRe.MatchString(svc) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid compose_service: " + svc})
+				badRequest(c, invalidComposeService("compose_service", svc))
 				return
 			}
 		}
 	}
 
+	ports, err = s.ports.Assign(c.Request.Context(), "", ports)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
 	id, err := newID()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -107,14 +149,18 @@ func (s *Server) createProject(c *gin.Context) {
 		ComposeFile:    req.ComposeFile,
 		ComposeService: composeService,
 		DockerfilePath: req.DockerfilePath,
-		HostPort:       req.HostPort,
-		ContainerPort:  req.ContainerPort,
+		Ports:          ports,
+		ExposeMode:     exposeMode,
+		BindIP:         bindIP,
 		LastStatus:     store.ProjectStatusUnknown,
 		CreatedAt:      now,
 		UpdatedAt:      now,
+
+		GitCredentialID: req.GitCredentialID,
+		SparseCheckout:  req.SparseCheckout,
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -125,7 +171,7 @@ func (s *Server) createProject(c *gin.Context) {
 
 	job, err := s.createJob(c.Request.Context(), project.ID, store.JobTypeDeploy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"project": project, "job": job})
@@ -135,25 +181,322 @@ func (s *Server) getProject(c *gin.Context) {
 	id := c.Param("id")
 	p, err := s.st.GetProject(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"project": p})
+}
+
+// updateProjectRequest 用于修改项目设置，未提供的字段保持不变；
+// ports 提供时替换全部端口映射
+type updateProjectRequest struct {
+	Name           *string               `json:"name,omitempty"`
+	GitURL         *string               `json:"git_url,omitempty"`
+	GitRef         *string               `json:"git_ref,omitempty"`
+	RepoSubdir     *string               `json:"repo_subdir,omitempty"`
+	DeployType     *string               `json:"deploy_type,omitempty"`
+	ComposeFile    *string               `json:"compose_file,omitempty"`
+	ComposeService *string               `json:"compose_service,omitempty"`
+	DockerfilePath *string               `json:"dockerfile_path,omitempty"`
+	ExposeMode     *string               `json:"expose_mode,omitempty"`
+	BindIP         *string               `json:"bind_ip,omitempty"`
+	Ports          *[]projectPortRequest `json:"ports,omitempty"`
+
+	// GitCredentialID 为空字符串时改为匿名拉取
+	GitCredentialID *string `json:"git_credential_id,omitempty"`
+	SparseCheckout  *bool   `json:"sparse_checkout,omitempty"`
+}
+
+// toSettings 校验修改内容并与当前项目比对，只保留有变化的字段。
+// redeploy 表示变化要在重新部署后才生效；端口需分配后再比对，不计入其中
+func (r updateProjectRequest) toSettings(cur store.Project) (u store.ProjectSettings, redeploy bool, err error) {
+	// set 在值变化时记录修改
+	set := func(dst **string, v, old string, needsRedeploy bool) {
+		if v != old {
+			*dst = &v
+			redeploy = redeploy || needsRedeploy
+		}
+	}
+
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" {
+			return u, false, missingField("name")
+		}
+		// 名称只影响默认域名，无需重新部署
+		set(&u.Name, name, cur.Name, false)
+	}
+	if r.GitURL != nil {
+		gitURL := strings.TrimSpace(*r.GitURL)
+		if gitURL == "" {
+			return u, false, missingField("git_url")
+		}
+		set(&u.GitURL, gitURL, cur.GitURL, true)
+	}
+	if r.GitRef != nil {
+		ref, err := normalizeGitRef(*r.GitRef)
+		if err != nil {
+			return u, false, err
+		}
+		set(&u.GitRef, ref, cur.GitRef, true)
+	}
+	if r.GitCredentialID != nil {
+		set(&u.GitCredentialID, strings.TrimSpace(*r.GitCredentialID), cur.GitCredentialID, true)
+	}
+	if r.SparseCheckout != nil && *r.SparseCheckout != cur.SparseCheckout {
+		u.SparseCheckout, redeploy = r.SparseCheckout, true
+	}
+	for _, f := range []struct {
+		name     string
+		value    *string
+		dst      **string
+		old, def string
+	}{
+		{"repo_subdir", r.RepoSubdir, &u.RepoSubdir, cur.RepoSubdir, ""},
+		{"compose_file", r.ComposeFile, &u.ComposeFile, cur.ComposeFile, ""},
+		{"dockerfile_path", r.DockerfilePath, &u.DockerfilePath, cur.DockerfilePath, "Dockerfile"},
+	} {
+		if f.value == nil {
+			continue
+		}
+		v, err := repoPath(f.name, *f.value)
+		if err != nil {
+			return u, false, err
+		}
+		if v == "" {
+			v = f.def
+		}
+		set(f.dst, v, f.old, true)
+	}
+	if r.DeployType != nil {
+		deployType := strings.ToLower(strings.TrimSpace(*r.DeployType))
+		switch deployType {
+		case "":
+			deployType = "auto"
+		case "auto", "dockerfile", "compose":
+		default:
+			return u, false, invalidField("deploy_type")
+		}
+		set(&u.DeployType, deployType, cur.DeployType, true)
+	}
+	if r.ComposeService != nil {
+		composeService := strings.TrimSpace(*r.ComposeService)
+		for _, svc := range strings.Split(composeService, ",") {
+			svc = strings.TrimSpace(svc)
+			if svc != "" && !composeServiceRe.MatchString(svc) {
+				return u, false, invalidComposeService("compose_service", svc)
+			}
+		}
+		set(&u.ComposeService, composeService, cur.ComposeService, true)
+	}
+	if r.ExposeMode != nil || r.BindIP != nil {
+		mode, bindIP := cur.ExposeMode, cur.BindIP
+		if r.ExposeMode != nil {
+			mode = *r.ExposeMode
+		}
+		if r.BindIP != nil {
+			bindIP = *r.BindIP
+		}
+		mode, bindIP, err = normalizeExposure(mode, bindIP)
+		if err != nil {
+			return u, false, err
+		}
+		set(&u.ExposeMode, mode, cur.ExposeMode, true)
+		set(&u.BindIP, bindIP, cur.BindIP, true)
+	}
+	if r.Ports != nil {
+		if len(*r.Ports) == 0 {
+			return u, false, missingField("ports")
+		}
+		u.Ports = make([]store.ProjectPort, 0, len(*r.Ports))
+		for i, pr := range *r.Ports {
+			port, err := pr.toProjectPort()
+			if err != nil {
+				var ae *apiError
+				if errors.As(err, &ae) && ae.Field != "" {
+					ae.Field = fmt.Sprintf("ports[%d].%s", i, ae.Field)
+				}
+				return u, false, err
+			}
+			u.Ports = append(u.Ports, port)
+		}
+	}
+	return u, redeploy, nil
+}
+
+// normalizeGitRef 校验分支、标签或提交，空值表示仓库的默认分支
+func normalizeGitRef(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.ContainsAny(ref, " \t\n") || strings.HasPrefix(ref, "-") {
+		return "", invalidField("git_ref")
+	}
+	return ref, nil
+}
+
+// repoPath 校验仓库内的相对路径，返回以 / 分隔的规范形式
+func repoPath(field, p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return "", nil
+	}
+	p = filepath.ToSlash(filepath.Clean(p))
+	if !filepath.IsLocal(p) || strings.ContainsAny(p, "\n\x00") {
+		return "", invalidField(field).withDetail("must be a relative path inside the repository")
+	}
+	if p == "." {
+		return "", nil
+	}
+	return p, nil
+}
+
+// updateProject 修改项目设置，所有字段在一个事务中更新；
+// 返回的 requires_redeploy 表示修改需重新部署后才对运行中的容器生效
+func (s *Server) updateProject(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	var req updateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	project, err := s.st.GetProject(ctx, id)
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	u, redeploy, err := req.toSettings(project)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if u.GitCredentialID != nil {
+		if err := s.checkGitCredential(ctx, *u.GitCredentialID); err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+	if u.Ports != nil {
+		keepHostPorts(u.Ports, project.Ports)
+		ports, err := s.ports.Assign(ctx, id, u.Ports)
+		if err != nil {
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		u.Ports = nil
+		if !samePorts(ports, project.Ports) {
+			u.Ports = ports
+			redeploy = true
+		}
+	}
+
+	if err := s.st.UpdateProjectSettings(ctx, id, u); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"project": p})
+	if u.Name != nil {
+		// 默认域名由项目名生成
+		s.proxy.Trigger()
+	}
+
+	updated, err := s.st.GetProject(ctx, id)
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"project": updated, "requires_redeploy": redeploy})
+}
+
+type cloneProjectRequest struct {
+	Name string `json:"name"`
+	// GitRef 不提供时沿用源项目的分支
+	GitRef *string `json:"git_ref,omitempty"`
+	// HostPort 为新项目第一个端口映射的主机端口，为 0 时自动分配
+	HostPort int  `json:"host_port"`
+	Deploy   bool `json:"deploy"`
+}
+
+// cloneProject 复制项目的定义、配置内容、环境变量和数据卷定义为新项目。
+// 其余端口映射的主机端口重新分配；数据卷内容、域名和备份不复制
+func (s *Server) cloneProject(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req cloneProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		badRequest(c, missingField("name"))
+		return
+	}
+	if req.HostPort < 0 || req.HostPort > 65535 {
+		badRequest(c, invalidField("host_port"))
+		return
+	}
+
+	src, err := s.st.GetProject(ctx, c.Param("id"))
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	gitRef := src.GitRef
+	if req.GitRef != nil {
+		if gitRef, err = normalizeGitRef(*req.GitRef); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+
+	p := src
+	p.Name, p.GitRef = name, gitRef
+	p.Ports = make([]store.ProjectPort, len(src.Ports))
+	for i, port := range src.Ports {
+		port.ID, port.HostPort = "", 0
+		p.Ports[i] = port
+	}
+	if len(p.Ports) > 0 {
+		p.Ports[0].HostPort = req.HostPort
+	}
+	p.ManagedBy = ""
+	project, err := s.CloneProject(ctx, src.ID, p, store.CloneOptions{})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if !req.Deploy {
+		c.JSON(http.StatusCreated, gin.H{"project": project})
+		return
+	}
+	job, err := s.createJob(ctx, project.ID, store.JobTypeDeploy)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"project": project, "job": job})
+}
+
+// CloneProject 以 p 为定义创建 srcID 的副本，分配新 ID 和 p.Ports 中为 0 的主机端口。
+// 也供预览环境使用
+func (s *Server) CloneProject(ctx context.Context, srcID string, p store.Project, opts store.CloneOptions) (store.Project, error) {
+	ports, err := s.ports.Assign(ctx, "", p.Ports)
+	if err != nil {
+		return store.Project{}, err
+	}
+	id, err := newID()
+	if err != nil {
+		return store.Project{}, err
+	}
+	p.ID, p.Ports, p.DeletedAt = id, ports, nil
+	return s.st.CloneProject(ctx, srcID, p, opts)
 }
 
 func (s *Server) getProjectLatestJob(c *gin.Context) {
 	id := c.Param("id")
 	job, err := s.st.GetLatestJobByProject(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLookupError(c, err, codeJobNotFound)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"job": job})
@@ -164,38 +507,49 @@ func (s *Server) startProject(c *gin.Context)   { s.enqueueJob(c, store.JobTypeS
 func (s *Server) stopProject(c *gin.Context)    { s.enqueueJob(c, store.JobTypeStop) }
 func (s *Server) pauseProject(c *gin.Context)   { s.enqueueJob(c, store.JobTypePause) }
 func (s *Server) unpauseProject(c *gin.Context) { s.enqueueJob(c, store.JobTypeUnpause) }
-func (s *Server) deleteProject(c *gin.Context)  { s.enqueueJob(c, store.JobTypeDelete) }
+
+// deleteProject 删除项目；?purge=true 时连同数据卷一起删除
+func (s *Server) deleteProject(c *gin.Context) {
+	if purge, _ := strconv.ParseBool(c.Query("purge")); purge {
+		s.enqueueJob(c, store.JobTypePurge)
+		return
+	}
+	s.enqueueJob(c, store.JobTypeDelete)
+}
 
 func (s *Server) enqueueJob(c *gin.Context, jobType string) {
 	projectID := c.Param("id")
 	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLookupError(c, err, codeProjectNotFound)
 		return
 	}
 
 	job, err := s.createJob(c.Request.Context(), projectID, jobType)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	c.JSON(http.StatusAccepted, gin.H{"job": job})
 }
 
+// EnqueueJob 供 GitOps 等后台流程创建任务
+func (s *Server) EnqueueJob(ctx context.Context, projectID, jobType string) (store.Job, error) {
+	return s.createJob(ctx, projectID, jobType)
+}
+
 func (s *Server) createJob(ctx context.Context, projectID, jobType string) (store.Job, error) {
+	return s.queueJob(ctx, store.Job{ProjectID: projectID, Type: jobType})
+}
+
+// queueJob 保存任务并放入队列，用于需要额外字段（如 Target）的任务
+func (s *Server) queueJob(ctx context.Context, job store.Job) (store.Job, error) {
 	id, err := newID()
 	if err != nil {
 		return store.Job{}, err
 	}
-	job, err := s.st.CreateJob(ctx, store.Job{
-		ID:        id,
-		ProjectID: projectID,
-		Type:      jobType,
-		Status:    store.JobStatusQueued,
-	})
+	job.ID = id
+	job.Status = store.JobStatusQueued
+	job, err = s.st.CreateJob(ctx, job)
 	if err != nil {
 		return store.Job{}, err
 	}
@@ -212,62 +566,85 @@ func newID() (string, error) {
 }
 
 type detectProjectRequest struct {
-	Name   string `json:"name"`
-	GitURL string `json:"git_url"`
+	Name            string `json:"name"`
+	GitURL          string `json:"git_url"`
+	GitCredentialID string `json:"git_credential_id"`
 }
 
 func (s *Server) detectProject(c *gin.Context) {
 	var req detectProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 	if strings.TrimSpace(req.Name) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		badRequest(c, missingField("name"))
 		return
 	}
 	if strings.TrimSpace(req.GitURL) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "git_url is required"})
+		badRequest(c, missingField("git_url"))
 		return
 	}
 
 	id, err := newID()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
+		return
+	}
+
+	if err := s.checkGitCredential(c.Request.Context(), req.GitCredentialID); err != nil {
+		writeError(c, err)
+		return
+	}
+	auth, err := s.gitAuth.AuthMethod(c.Request.Context(), req.GitCredentialID)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 
 	repoDir := filepath.Join(os.TempDir(), "last-deploy-drafts", id)
-	if err := engine.CloneRepo(c.Request.Context(), req.GitURL, "", repoDir); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "clone failed: " + err.Error()})
+	if err := engine.CloneRepo(c.Request.Context(), req.GitURL, "", repoDir, engine.CloneOptions{Auth: auth, Depth: 1}); err != nil {
+		badRequest(c, newError(http.StatusBadRequest, codeCloneFailed).withDetail("%s", err))
 		return
 	}
 
 	result, err := detector.Detect(repoDir)
 	if err != nil {
 		_ = os.RemoveAll(repoDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "detect failed: " + err.Error()})
+		writeError(c, newError(http.StatusInternalServerError, codeDetectFailed).withDetail("%s", err))
 		return
 	}
+	composeService := ""
+	if result.Config != nil {
+		// 仓库中的 last-deploy.yaml 有误时尽早提示，而不是等到创建项目
+		if _, _, err := repoConfigSettings(result.Config); err != nil {
+			_ = os.RemoveAll(repoDir)
+			badRequest(c, invalidRepoConfig(result.ConfigPath, err))
+			return
+		}
+		composeService = result.Config.ComposeService
+	}
 
 	now := time.Now().Unix()
 	draft := store.ProjectDraft{
 		ID:                id,
 		Name:              req.Name,
 		GitURL:            req.GitURL,
+		GitCredentialID:   req.GitCredentialID,
 		DeployType:        result.DeployType,
 		DockerfilePath:    result.DockerfilePath,
 		DockerfileContent: result.DockerfileContent,
 		ComposePath:       result.ComposePath,
 		ComposeContent:    result.ComposeContent,
 		Services:          result.Services,
+		RepoConfig:        result.ConfigContent,
 		RepoDir:           repoDir,
 		CreatedAt:         now,
 		ExpiresAt:         now + 30*60, // 30 minutes
 	}
 	if _, err := s.st.CreateProjectDraft(c.Request.Context(), draft); err != nil {
 		_ = os.RemoveAll(repoDir)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -279,9 +656,33 @@ func (s *Server) detectProject(c *gin.Context) {
 		"compose_path":       result.ComposePath,
 		"compose_content":    result.ComposeContent,
 		"services":           result.Services,
+		"compose_service":    composeService,
+		"config_path":        result.ConfigPath,
+		"config":             result.Config,
 	})
 }
 
+// repoConfigSettings 使用与各个接口相同的规则校验 last-deploy.yaml 中的端口和健康检查
+func repoConfigSettings(cfg *detector.RepoConfig) ([]store.ProjectPort, store.HealthCheck, error) {
+	var ports []store.ProjectPort
+	for i, port := range cfg.Ports {
+		pp, err := projectPortRequest(port).toProjectPort()
+		if err != nil {
+			return nil, store.HealthCheck{}, fmt.Errorf("ports[%d]: %w", i, err)
+		}
+		ports = append(ports, pp)
+	}
+	var hc store.HealthCheck
+	if cfg.HealthCheck != nil {
+		var err error
+		hc, err = healthCheckRequest(*cfg.HealthCheck).toHealthCheck()
+		if err != nil {
+			return nil, store.HealthCheck{}, fmt.Errorf("health_check: %w", err)
+		}
+	}
+	return ports, hc, nil
+}
+
 type createProjectFromDraftRequest struct {
 	DraftID           string `json:"draft_id"`
 	DockerfileContent string `json:"dockerfile_content"`
@@ -289,7 +690,14 @@ type createProjectFromDraftRequest struct {
 	ComposeService    string `json:"compose_service"`
 	GitRef            string `json:"git_ref"`
 	RepoSubdir        string `json:"repo_subdir"`
-	Deploy            bool   `json:"deploy"`
+	SparseCheckout    bool   `json:"sparse_checkout"`
+	// HostPort 可选，仅 dockerfile 部署使用；为 0 时优先使用容器端口，被占用则自动分配
+	HostPort   int    `json:"host_port"`
+	ExposeMode string `json:"expose_mode"`
+	BindIP     string `json:"bind_ip"`
+	Deploy     bool   `json:"deploy"`
+	// Env 为环境变量的值；只创建给出了非空值的变量，last-deploy.yaml 声明的其余变量之后再填写
+	Env map[string]string `json:"env,omitempty"`
 }
 
 type updateProjectConfigRequest struct {
@@ -301,17 +709,13 @@ func (s *Server) updateProjectConfig(c *gin.Context) {
 	id := c.Param("id")
 	var req updateProjectConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
 	project, err := s.st.GetProject(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeLookupError(c, err, codeProjectNotFound)
 		return
 	}
 
@@ -321,19 +725,29 @@ func (s *Server) updateProjectConfig(c *gin.Context) {
 		hostPort, containerPort = parseComposePort(req.ComposeContent, project.ComposeService)
 	} else if req.DockerfileContent != "" {
 		containerPort = parseDockerfilePort(req.DockerfileContent)
-		hostPort = containerPort
+		// 保留已分配的主机端口，没有时再分配
+		hostPort = project.HostPort
+	}
+
+	if containerPort > 0 {
+		assigned, err := s.ports.Assign(c.Request.Context(), id, []store.ProjectPort{{HostPort: hostPort, ContainerPort: containerPort, Protocol: "tcp"}})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		hostPort = assigned[0].HostPort
 	}
 
 	// 如果解析到了端口，同步更新
 	if hostPort > 0 && containerPort > 0 {
 		if err := s.st.UpdateProjectConfigWithPorts(c.Request.Context(), id, req.DockerfileContent, req.ComposeContent, hostPort, containerPort); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeLookupError(c, err, codeProjectNotFound)
 			return
 		}
 	} else {
 		// 没有解析到端口，只更新配置内容
 		if err := s.st.UpdateProjectConfig(c.Request.Context(), id, req.DockerfileContent, req.ComposeContent); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeLookupError(c, err, codeProjectNotFound)
 			return
 		}
 	}
@@ -344,21 +758,43 @@ func (s *Server) updateProjectConfig(c *gin.Context) {
 func (s *Server) createProjectFromDraft(c *gin.Context) {
 	var req createProjectFromDraftRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 	if strings.TrimSpace(req.DraftID) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "draft_id is required"})
+		badRequest(c, missingField("draft_id"))
 		return
 	}
 
 	draft, err := s.st.GetProjectDraft(c.Request.Context(), req.DraftID)
 	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "draft not found"})
+		writeLookupError(c, err, codeDraftNotFound)
+		return
+	}
+
+	exposeMode, bindIP, err := normalizeExposure(req.ExposeMode, req.BindIP)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	for name := range req.Env {
+		if !envNameRe.MatchString(name) {
+			badRequest(c, invalidField("env").withDetail("invalid name %q", name))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	// 仓库中的 last-deploy.yaml 提供服务、端口、健康检查、环境变量名和构建参数的默认值
+	repoConfig := &detector.RepoConfig{}
+	if draft.RepoConfig != "" {
+		if repoConfig, err = detector.ParseRepoConfig([]byte(draft.RepoConfig)); err != nil {
+			badRequest(c, invalidRepoConfig("last-deploy.yaml", err))
+			return
+		}
+	}
+	configPorts, healthCheck, err := repoConfigSettings(repoConfig)
+	if err != nil {
+		badRequest(c, invalidRepoConfig("last-deploy.yaml", err))
 		return
 	}
 
@@ -392,8 +828,11 @@ func (s *Server) createProjectFromDraft(c *gin.Context) {
 			composeFile = "docker-compose.yml"
 		}
 		dockerfilePath = ""
-		// 使用请求中的 service，否则使用第一个 service
+		// 使用请求中的 service，其次是 last-deploy.yaml 声明的，否则使用第一个 service
 		composeService = strings.TrimSpace(req.ComposeService)
+		if composeService == "" {
+			composeService = repoConfig.ComposeService
+		}
 		if composeService == "" && len(draft.Services) > 0 {
 			composeService = draft.Services[0]
 		}
@@ -406,53 +845,84 @@ func (s *Server) createProjectFromDraft(c *gin.Context) {
 		}
 	}
 
-	// 解析端口信息
-	var hostPort, containerPort int
-	if deployType == "compose" {
-		// 从 compose 内容中解析端口
-		hostPort, containerPort = parseComposePort(composeContent, composeService)
-	} else {
-		// 从 dockerfile 内容中解析 EXPOSE 端口
-		containerPort = parseDockerfilePort(dockerfileContent)
-		if containerPort > 0 {
-			// 使用容器端口作为主机端口（简化处理）
-			hostPort = containerPort
+	// 解析端口信息，last-deploy.yaml 声明了端口时直接使用
+	var ports []store.ProjectPort
+	if len(configPorts) > 0 {
+		if req.HostPort < 0 || req.HostPort > 65535 {
+			badRequest(c, invalidField("host_port"))
+			return
+		}
+		ports = configPorts
+		if req.HostPort > 0 {
+			ports[0].HostPort = req.HostPort
 		}
+	} else if deployType == "compose" {
+		// 从 compose 内容中解析端口，所选服务发布的端口全部登记到项目
+		ports = parseComposeProjectPorts(composeContent, composeService)
+	} else if containerPort := parseDockerfilePort(dockerfileContent); containerPort > 0 {
+		// 从 dockerfile 内容中解析 EXPOSE 端口，主机端口未指定时自动分配
+		if req.HostPort < 0 || req.HostPort > 65535 {
+			badRequest(c, invalidField("host_port"))
+			return
+		}
+		ports = []store.ProjectPort{{HostPort: req.HostPort, ContainerPort: containerPort, Protocol: "tcp"}}
 	}
 
 	// 如果解析失败，返回错误
-	if hostPort <= 0 || containerPort <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无法从配置中解析端口信息，请检查 Dockerfile 的 EXPOSE 指令或 docker-compose.yml 的 ports 配置"})
+	if len(ports) == 0 {
+		badRequest(c, newError(http.StatusBadRequest, codePortNotDetected))
+		return
+	}
+
+	// compose 文件中的端口为显式指定，被占用时返回 409 及可用端口建议
+	ports, err = s.ports.Assign(c.Request.Context(), "", ports)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 
 	id, err := newID()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
+	// 只保存用户填写了值的环境变量；last-deploy.yaml 声明但未填写的变量不创建，
+	// 避免部署时以空值覆盖镜像或 compose 文件中的默认值
+	var env []store.ProjectEnvVar
+	for name, value := range req.Env {
+		if value != "" {
+			env = append(env, store.ProjectEnvVar{Name: name, Value: value})
+		}
+	}
+
+	// 项目、健康检查、构建参数和环境变量在同一个事务中写入
 	now := time.Now().Unix()
-	project, err := s.st.CreateProject(c.Request.Context(), store.Project{
+	project, err := s.st.CreateProjectWith(c.Request.Context(), store.Project{
 		ID:                id,
 		Name:              draft.Name,
 		GitURL:            draft.GitURL,
+		GitCredentialID:   draft.GitCredentialID,
 		GitRef:            req.GitRef,
 		RepoSubdir:        req.RepoSubdir,
+		SparseCheckout:    req.SparseCheckout,
 		DeployType:        deployType,
 		ComposeFile:       composeFile,
 		ComposeService:    composeService,
 		DockerfilePath:    dockerfilePath,
 		DockerfileContent: dockerfileContent,
 		ComposeContent:    composeContent,
-		HostPort:          hostPort,
-		ContainerPort:     containerPort,
+		Ports:             ports,
+		ExposeMode:        exposeMode,
+		BindIP:            bindIP,
+		HealthCheck:       healthCheck,
+		BuildArgs:         repoConfig.BuildArgs,
 		LastStatus:        store.ProjectStatusUnknown,
 		CreatedAt:         now,
 		UpdatedAt:         now,
-	})
+	}, store.ProjectAdditions{Env: env})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -467,7 +937,7 @@ func (s *Server) createProjectFromDraft(c *gin.Context) {
 
 	job, err := s.createJob(c.Request.Context(), project.ID, store.JobTypeDeploy)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"project": project, "job": job})
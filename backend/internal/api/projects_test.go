@@ -0,0 +1,116 @@
+package api
+
+import (
+	"testing"
+
+	"last-deploy/internal/store"
+)
+
+func TestUpdateProjectRequestToSettings(t *testing.T) {
+	cur := store.Project{
+		Name:           "web",
+		GitURL:         "https://example.com/web.git",
+		DeployType:     "auto",
+		DockerfilePath: "Dockerfile",
+		ExposeMode:     store.ExposeLoopback,
+	}
+	str := func(s string) *string { return &s }
+
+	cases := []struct {
+		name         string
+		req          updateProjectRequest
+		wantRedeploy bool
+		wantCode     string
+		wantField    string
+		check        func(t *testing.T, u store.ProjectSettings)
+	}{
+		{
+			name: "rename only",
+			req:  updateProjectRequest{Name: str(" api ")},
+			check: func(t *testing.T, u store.ProjectSettings) {
+				if u.Name == nil || *u.Name != "api" || u.GitURL != nil {
+					t.Errorf("settings = %+v", u)
+				}
+			},
+		},
+		{
+			name: "unchanged values are dropped",
+			req:  updateProjectRequest{Name: str("web"), DeployType: str("AUTO"), DockerfilePath: str("")},
+			check: func(t *testing.T, u store.ProjectSettings) {
+				if u.Name != nil || u.DeployType != nil || u.DockerfilePath != nil {
+					t.Errorf("settings = %+v", u)
+				}
+			},
+		},
+		{
+			name:         "source changes need a redeploy",
+			req:          updateProjectRequest{GitRef: str("v2"), RepoSubdir: str("./services/api/")},
+			wantRedeploy: true,
+			check: func(t *testing.T, u store.ProjectSettings) {
+				if *u.GitRef != "v2" || *u.RepoSubdir != "services/api" {
+					t.Errorf("settings = %+v", u)
+				}
+			},
+		},
+		{
+			name:         "credential",
+			req:          updateProjectRequest{GitCredentialID: str(" c1 ")},
+			wantRedeploy: true,
+			check: func(t *testing.T, u store.ProjectSettings) {
+				if u.GitCredentialID == nil || *u.GitCredentialID != "c1" {
+					t.Errorf("settings = %+v", u)
+				}
+			},
+		},
+		{
+			name:         "sparse checkout",
+			req:          updateProjectRequest{SparseCheckout: func() *bool { b := true; return &b }()},
+			wantRedeploy: true,
+			check: func(t *testing.T, u store.ProjectSettings) {
+				if u.SparseCheckout == nil || !*u.SparseCheckout {
+					t.Errorf("settings = %+v", u)
+				}
+			},
+		},
+		{
+			name:         "exposure",
+			req:          updateProjectRequest{ExposeMode: str("ip"), BindIP: str("10.0.0.2")},
+			wantRedeploy: true,
+		},
+		{name: "empty name", req: updateProjectRequest{Name: str(" ")}, wantCode: codeMissingField, wantField: "name"},
+		{name: "escaping subdir", req: updateProjectRequest{RepoSubdir: str("../other")}, wantCode: codeInvalidField, wantField: "repo_subdir"},
+		{name: "absolute dockerfile", req: updateProjectRequest{DockerfilePath: str("/etc/passwd")}, wantCode: codeInvalidField, wantField: "dockerfile_path"},
+		{name: "option-like ref", req: updateProjectRequest{GitRef: str("--upload-pack=x")}, wantCode: codeInvalidField, wantField: "git_ref"},
+		{name: "deploy type", req: updateProjectRequest{DeployType: str("helm")}, wantCode: codeInvalidField, wantField: "deploy_type"},
+		{name: "compose service", req: updateProjectRequest{ComposeService: str("web,bad name")}, wantCode: codeInvalidComposeService, wantField: "compose_service"},
+		{name: "bind ip without ip mode", req: updateProjectRequest{ExposeMode: str("ip")}, wantCode: codeInvalidField, wantField: "bind_ip"},
+		{name: "no ports", req: updateProjectRequest{Ports: &[]projectPortRequest{}}, wantCode: codeMissingField, wantField: "ports"},
+		{
+			name:      "bad port",
+			req:       updateProjectRequest{Ports: &[]projectPortRequest{{ContainerPort: 80}, {ContainerPort: 70000}}},
+			wantCode:  codeInvalidField,
+			wantField: "ports[1].container_port",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, redeploy, err := tc.req.toSettings(cur)
+			if tc.wantCode != "" {
+				ae := toAPIError(err, codeNotFound)
+				if ae.Code != tc.wantCode || ae.Field != tc.wantField {
+					t.Fatalf("err = %v (%s %q), want %s %q", err, ae.Code, ae.Field, tc.wantCode, tc.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if redeploy != tc.wantRedeploy {
+				t.Errorf("redeploy = %v, want %v", redeploy, tc.wantRedeploy)
+			}
+			if tc.check != nil {
+				tc.check(t, u)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/store"
+)
+
+type resourceLimitsRequest struct {
+	MemoryMB     int64 `json:"memory_mb"`
+	MemorySwapMB int64 `json:"memory_swap_mb"`
+	CPUShares    int64 `json:"cpu_shares"`
+	CPUQuota     int64 `json:"cpu_quota"`
+	PidsLimit    int64 `json:"pids_limit"`
+}
+
+// toResourceLimits 校验资源限制，0 表示使用服务端默认值，-1 表示不限制
+func (r resourceLimitsRequest) toResourceLimits() (store.ResourceLimits, error) {
+	switch {
+	case r.MemoryMB < -1 || (r.MemoryMB > 0 && r.MemoryMB < 6):
+		return store.ResourceLimits{}, invalidField("memory_mb").withDetail("must be -1 or at least 6")
+	case r.MemorySwapMB < -1:
+		return store.ResourceLimits{}, invalidField("memory_swap_mb")
+	case r.MemorySwapMB > 0 && r.MemorySwapMB < r.MemoryMB:
+		return store.ResourceLimits{}, invalidField("memory_swap_mb").withDetail("must not be less than memory_mb")
+	case r.MemorySwapMB > 0 && r.MemoryMB <= 0:
+		return store.ResourceLimits{}, invalidField("memory_swap_mb").withDetail("requires memory_mb")
+	case r.CPUShares < -1 || r.CPUShares == 1:
+		return store.ResourceLimits{}, invalidField("cpu_shares").withDetail("must be -1 or at least 2")
+	case r.CPUQuota < -1 || (r.CPUQuota > 0 && r.CPUQuota < 1000):
+		return store.ResourceLimits{}, invalidField("cpu_quota").withDetail("must be -1 or at least 1000")
+	case r.PidsLimit < -1:
+		return store.ResourceLimits{}, invalidField("pids_limit")
+	}
+	return store.ResourceLimits{
+		MemoryMB:     r.MemoryMB,
+		MemorySwapMB: r.MemorySwapMB,
+		CPUShares:    r.CPUShares,
+		CPUQuota:     r.CPUQuota,
+		PidsLimit:    r.PidsLimit,
+	}, nil
+}
+
+// updateProjectResources 修改资源限制，下次部署时生效
+func (s *Server) updateProjectResources(c *gin.Context) {
+	var req resourceLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	rl, err := req.toResourceLimits()
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	if err := s.st.SetProjectResourceLimits(c.Request.Context(), c.Param("id"), rl); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resource_limits": rl})
+}
@@ -6,8 +6,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"last-deploy/internal/certs"
 	"last-deploy/internal/config"
+	"last-deploy/internal/dbbackup"
+	"last-deploy/internal/gitauth"
+	"last-deploy/internal/gitops"
 	"last-deploy/internal/jobs"
+	"last-deploy/internal/portalloc"
+	"last-deploy/internal/preview"
+	"last-deploy/internal/proxy"
 	"last-deploy/internal/store"
 )
 
@@ -15,10 +22,32 @@ type Server struct {
 	st    *store.Store
 	queue *jobs.Queue
 	cfg   config.Config
+	ports *portalloc.Allocator
+	proxy *proxy.Proxy
+	certs *certs.Manager
+
+	dbBackups *dbbackup.Manager
+	gitops    *gitops.Reconciler
+	previews  *preview.Manager
+	gitAuth   *gitauth.Manager
 }
 
-func NewRouter(st *store.Store, q *jobs.Queue, cfg config.Config) *gin.Engine {
-	s := &Server{st: st, queue: q, cfg: cfg}
+func NewRouter(st *store.Store, q *jobs.Queue, cfg config.Config, px *proxy.Proxy, cm *certs.Manager, dbb *dbbackup.Manager, gr *gitops.Reconciler, pv *preview.Manager, ga *gitauth.Manager) *gin.Engine {
+	s := &Server{
+		st:    st,
+		queue: q,
+		cfg:   cfg,
+		ports: portalloc.New(st, cfg.PortRangeStart, cfg.PortRangeEnd),
+		proxy: px,
+		certs: cm,
+
+		dbBackups: dbb,
+		gitops:    gr,
+		previews:  pv,
+		gitAuth:   ga,
+	}
+	gr.SetImporter(s)
+	pv.SetProjects(s)
 
 	r := gin.New()
 	r.Use(gin.Recovery())
@@ -28,17 +57,53 @@ func NewRouter(st *store.Store, q *jobs.Queue, cfg config.Config) *gin.Engine {
 		staticDir = "./static"
 	}
 
+	r.GET("/api/health", health)
+	r.GET("/api/openapi.json", getOpenAPI)
+	r.POST("/api/previews/github/:id", s.previewWebhook)
+
 	api := r.Group("/api")
-	api.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"ok": true})
-	})
+	if len(cfg.APITokens) > 0 {
+		api.Use(requireToken(cfg.APITokens))
+	}
 
 	api.GET("/projects", s.listProjects)
 	api.POST("/projects", s.createProject)
 	api.POST("/projects/detect", s.detectProject)
 	api.POST("/projects/from-draft", s.createProjectFromDraft)
+	api.GET("/projects/export", s.exportProjects)
+	api.POST("/projects/import", s.importProjects)
 	api.GET("/projects/:id", s.getProject)
+	api.PATCH("/projects/:id", s.updateProject)
+	api.POST("/projects/:id/clone", s.cloneProject)
+	api.GET("/projects/:id/previews", s.listProjectPreviews)
 	api.PUT("/projects/:id/config", s.updateProjectConfig)
+	api.PUT("/projects/:id/exposure", s.updateProjectExposure)
+	api.PUT("/projects/:id/health-check", s.updateProjectHealthCheck)
+	api.PUT("/projects/:id/deploy-strategy", s.updateProjectDeployStrategy)
+	api.PUT("/projects/:id/auto-rollback", s.updateProjectAutoRollback)
+	api.PUT("/projects/:id/resources", s.updateProjectResources)
+	api.GET("/projects/:id/ports", s.listProjectPorts)
+	api.POST("/projects/:id/ports", s.createProjectPort)
+	api.PUT("/projects/:id/ports/:portId", s.updateProjectPort)
+	api.DELETE("/projects/:id/ports/:portId", s.deleteProjectPort)
+	api.GET("/projects/:id/domains", s.listProjectDomains)
+	api.POST("/projects/:id/domains", s.createProjectDomain)
+	api.DELETE("/projects/:id/domains/:domainId", s.deleteProjectDomain)
+	api.GET("/projects/:id/domains/:domainId/certificate", s.getDomainCertificate)
+	api.PUT("/projects/:id/domains/:domainId/certificate", s.uploadDomainCertificate)
+	api.DELETE("/projects/:id/domains/:domainId/certificate", s.deleteDomainCertificate)
+	api.GET("/projects/:id/volumes", s.listProjectVolumes)
+	api.POST("/projects/:id/volumes", s.createProjectVolume)
+	api.DELETE("/projects/:id/volumes/:volumeId", s.deleteProjectVolume)
+	api.GET("/projects/:id/backups", s.listProjectBackups)
+	api.POST("/projects/:id/backups", s.createProjectBackup)
+	api.GET("/projects/:id/backups/:backupId/download", s.downloadProjectBackup)
+	api.DELETE("/projects/:id/backups/:backupId", s.deleteProjectBackup)
+	api.POST("/projects/:id/backups/:backupId/restore", s.restoreProjectBackup)
+	api.GET("/projects/:id/env", s.listProjectEnv)
+	api.PUT("/projects/:id/env/:name", s.setProjectEnv)
+	api.DELETE("/projects/:id/env/:name", s.deleteProjectEnv)
+	api.GET("/projects/:id/logs", s.getProjectLogs)
 	api.GET("/projects/:id/jobs/latest", s.getProjectLatestJob)
 	api.POST("/projects/:id/deploy", s.deployProject)
 	api.POST("/projects/:id/start", s.startProject)
@@ -49,6 +114,19 @@ func NewRouter(st *store.Store, q *jobs.Queue, cfg config.Config) *gin.Engine {
 
 	api.GET("/jobs/:id", s.getJob)
 
+	api.GET("/admin/db-backups", s.listDBBackups)
+	api.POST("/admin/db-backups", s.createDBBackup)
+	api.GET("/admin/db-backups/:name/download", s.downloadDBBackup)
+
+	api.GET("/gitops", s.getGitOpsStatus)
+	api.POST("/gitops/plan", s.planGitOps)
+	api.POST("/gitops/sync", s.syncGitOps)
+
+	api.GET("/git-credentials", s.listGitCredentials)
+	api.POST("/git-credentials", s.createGitCredential)
+	api.PATCH("/git-credentials/:id", s.updateGitCredential)
+	api.DELETE("/git-credentials/:id", s.deleteGitCredential)
+
 	// 静态文件放最后，使用 NoRoute 避免与 API 路由冲突
 	r.NoRoute(gin.WrapH(http.FileServer(http.Dir(staticDir))))
 
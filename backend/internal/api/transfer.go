@@ -0,0 +1,377 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"last-deploy/internal/bundle"
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+)
+
+// maxBundleSize 限制导入内容的大小
+const maxBundleSize = 16 << 20
+
+// exportProjects 导出全部项目定义；?format=yaml 时输出 YAML，默认 JSON
+func (s *Server) exportProjects(c *gin.Context) {
+	ctx := c.Request.Context()
+	projects, err := s.st.ListProjects(ctx)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	b := bundle.Bundle{Version: bundle.Version, ExportedAt: time.Now().Unix(), Projects: []bundle.Project{}}
+	// 按创建顺序导出，重新导入时保持原来的顺序
+	for i := len(projects) - 1; i >= 0; i-- {
+		bp, err := s.bundleProject(ctx, projects[i])
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		b.Projects = append(b.Projects, bp)
+	}
+
+	if strings.EqualFold(c.Query("format"), "yaml") {
+		data, err := yaml.Marshal(b)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="last-deploy-projects.yaml"`)
+		c.Data(http.StatusOK, "application/yaml", data)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// importProjects 导入项目定义（YAML 或 JSON）。按名称匹配已有项目：
+// 相同名称和仓库地址时更新，否则创建；?dry_run=true 只返回计划，存在冲突时不做任何修改
+func (s *Server) importProjects(c *gin.Context) {
+	ctx := c.Request.Context()
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBundleSize+1))
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if len(data) > maxBundleSize {
+		writeError(c, newError(http.StatusRequestEntityTooLarge, codeBundleTooLarge))
+		return
+	}
+	b, err := bundle.Parse(data)
+	if err != nil {
+		badRequest(c, newError(http.StatusBadRequest, codeInvalidBundle).withDetail("%s", err))
+		return
+	}
+
+	items, err := s.PlanImport(ctx, b.Projects)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	conflicts := 0
+	for _, it := range items {
+		if it.Action == bundle.ActionConflict {
+			conflicts++
+		}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "items": items})
+		return
+	}
+	if conflicts > 0 {
+		status, body := errorStatus(c, newError(http.StatusConflict, codeImportConflict).withDetails(gin.H{"conflicts": conflicts}))
+		body["items"] = items
+		c.JSON(status, body)
+		return
+	}
+
+	failed := false
+	for i := range items {
+		if err := s.ApplyImport(ctx, &items[i], b.Projects[i]); err != nil {
+			items[i].Error = err.Error()
+			failed = true
+		}
+	}
+
+	status := http.StatusOK
+	if failed {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{"dry_run": false, "items": items})
+}
+
+// PlanImport 校验并规范化待导入的项目，再与当前实例比对
+func (s *Server) PlanImport(ctx context.Context, projects []bundle.Project) ([]bundle.Item, error) {
+	invalid := make([]error, len(projects))
+	for i := range projects {
+		invalid[i] = normalizeBundleProject(&projects[i])
+	}
+
+	inst := bundle.Instance{Hostnames: make(map[string]string)}
+	existing, err := s.st.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range existing {
+		bp, err := s.bundleProject(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		inst.Projects = append(inst.Projects, bundle.Existing{ID: p.ID, Project: bp})
+	}
+	if inst.HostPorts, err = s.st.ListUsedHostPorts(ctx, ""); err != nil {
+		return nil, err
+	}
+	domains, err := s.st.ListActiveDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range domains {
+		inst.Hostnames[d.Hostname] = d.ProjectID
+	}
+
+	items := bundle.Plan(projects, inst)
+	for i, err := range invalid {
+		if err != nil {
+			items[i].Conflict("%v", err)
+		}
+	}
+	return items, nil
+}
+
+// ApplyImport 按计划创建或更新一个项目；卷、域名和环境变量只会新增。
+// 项目设置与新增的内容在同一个事务中写入，失败时不会留下导入了一半的项目
+func (s *Server) ApplyImport(ctx context.Context, it *bundle.Item, p bundle.Project) error {
+	sp := p.ToStore()
+	switch it.Action {
+	case bundle.ActionCreate:
+		ports, err := s.ports.Assign(ctx, "", sp.Ports)
+		if err != nil {
+			return err
+		}
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		sp.ID = id
+		sp.Ports = ports
+		sp.LastStatus = store.ProjectStatusUnknown
+	case bundle.ActionUpdate:
+		// 未指定主机端口的映射沿用当前分配的端口
+		cur, err := s.st.ListProjectPorts(ctx, it.ProjectID)
+		if err != nil {
+			return err
+		}
+		keepHostPorts(sp.Ports, cur)
+		ports, err := s.ports.Assign(ctx, it.ProjectID, sp.Ports)
+		if err != nil {
+			return err
+		}
+		sp.ID = it.ProjectID
+		sp.Ports = ports
+	default:
+		return nil
+	}
+
+	add, err := s.importAdditions(ctx, it, p)
+	if err != nil {
+		return err
+	}
+	if it.Action == bundle.ActionCreate {
+		if _, err := s.st.CreateProjectWith(ctx, sp, add); err != nil {
+			return err
+		}
+		it.ProjectID = sp.ID
+	} else if err := s.st.UpdateProjectWith(ctx, sp, add); err != nil {
+		return err
+	}
+	s.proxy.Trigger()
+	return nil
+}
+
+// importAdditions 返回项目还没有的卷、域名和环境变量；新项目的全部导入。
+// 环境变量只导入名称，已有的变量保留原值，新变量的值留空待填写
+func (s *Server) importAdditions(ctx context.Context, it *bundle.Item, p bundle.Project) (store.ProjectAdditions, error) {
+	var (
+		add        store.ProjectAdditions
+		curVolumes []bundle.Volume
+		curDomains []bundle.Domain
+		curEnv     []store.ProjectEnvVar
+	)
+	if it.Action == bundle.ActionUpdate {
+		volumes, err := s.st.ListProjectVolumes(ctx, it.ProjectID)
+		if err != nil {
+			return add, err
+		}
+		for _, v := range volumes {
+			curVolumes = append(curVolumes, bundle.Volume{Name: v.Name, ContainerPath: v.ContainerPath, ReadOnly: v.ReadOnly})
+		}
+		domains, err := s.st.ListProjectDomains(ctx, it.ProjectID)
+		if err != nil {
+			return add, err
+		}
+		for _, d := range domains {
+			curDomains = append(curDomains, bundle.Domain{Hostname: d.Hostname})
+		}
+		if curEnv, err = s.st.ListProjectEnv(ctx, it.ProjectID); err != nil {
+			return add, err
+		}
+	}
+
+	for _, v := range bundle.MissingVolumes(curVolumes, p.Volumes) {
+		id, err := newID()
+		if err != nil {
+			return add, err
+		}
+		add.Volumes = append(add.Volumes, store.ProjectVolume{
+			ID:            id,
+			Name:          v.Name,
+			ContainerPath: v.ContainerPath,
+			ReadOnly:      v.ReadOnly,
+		})
+	}
+	for _, d := range bundle.MissingDomains(curDomains, p.Domains) {
+		id, err := newID()
+		if err != nil {
+			return add, err
+		}
+		add.Domains = append(add.Domains, store.ProjectDomain{
+			ID:            id,
+			Hostname:      d.Hostname,
+			Service:       d.Service,
+			ContainerPort: d.ContainerPort,
+		})
+	}
+	for _, name := range p.Env {
+		if slices.ContainsFunc(curEnv, func(v store.ProjectEnvVar) bool { return v.Name == name }) {
+			continue
+		}
+		add.Env = append(add.Env, store.ProjectEnvVar{Name: name})
+	}
+	return add, nil
+}
+
+func (s *Server) bundleProject(ctx context.Context, p store.Project) (bundle.Project, error) {
+	volumes, err := s.st.ListProjectVolumes(ctx, p.ID)
+	if err != nil {
+		return bundle.Project{}, err
+	}
+	domains, err := s.st.ListProjectDomains(ctx, p.ID)
+	if err != nil {
+		return bundle.Project{}, err
+	}
+	env, err := s.st.ListProjectEnv(ctx, p.ID)
+	if err != nil {
+		return bundle.Project{}, err
+	}
+	return bundle.FromProject(p, volumes, domains, env), nil
+}
+
+// normalizeBundleProject 使用与各个接口相同的规则校验导入的项目
+func normalizeBundleProject(p *bundle.Project) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.GitURL = strings.TrimSpace(p.GitURL)
+
+	deployType := strings.ToLower(strings.TrimSpace(p.DeployType))
+	switch deployType {
+	case "":
+		deployType = "auto"
+	case "auto", "dockerfile", "compose":
+	default:
+		return fmt.Errorf("invalid deploy_type")
+	}
+	p.DeployType = deployType
+
+	for _, svc := range strings.Split(p.ComposeService, ",") {
+		svc = strings.TrimSpace(svc)
+		if svc != "" && !composeServiceRe.MatchString(svc) {
+			return fmt.Errorf("invalid compose_service: %s", svc)
+		}
+	}
+
+	mode, bindIP, err := normalizeExposure(p.ExposeMode, p.BindIP)
+	if err != nil {
+		return err
+	}
+	p.ExposeMode, p.BindIP = mode, bindIP
+
+	for i, port := range p.Ports {
+		pp, err := projectPortRequest(port).toProjectPort()
+		if err != nil {
+			return fmt.Errorf("ports[%d]: %w", i, err)
+		}
+		p.Ports[i] = bundle.Port{
+			Service:       pp.Service,
+			HostIP:        pp.HostIP,
+			HostPort:      pp.HostPort,
+			ContainerPort: pp.ContainerPort,
+			Protocol:      pp.Protocol,
+		}
+	}
+
+	if p.HealthCheck != nil {
+		hc, err := healthCheckRequest(*p.HealthCheck).toHealthCheck()
+		if err != nil {
+			return fmt.Errorf("health_check: %w", err)
+		}
+		p.HealthCheck = nil
+		if hc.Type != store.HealthCheckNone {
+			bhc := bundle.HealthCheck(hc)
+			p.HealthCheck = &bhc
+		}
+	}
+
+	strategy := strings.ToLower(strings.TrimSpace(p.DeployStrategy))
+	switch strategy {
+	case "":
+		strategy = store.DeployStrategyRecreate
+	case store.DeployStrategyRecreate, store.DeployStrategyBlueGreen:
+	default:
+		return fmt.Errorf("invalid deploy_strategy")
+	}
+	p.DeployStrategy = strategy
+
+	if p.ResourceLimits != nil {
+		if _, err := resourceLimitsRequest(*p.ResourceLimits).toResourceLimits(); err != nil {
+			return fmt.Errorf("resource_limits: %w", err)
+		}
+	}
+
+	if len(p.Volumes) > 0 && engine.ResolveDeployType(p.DeployType, p.ComposeFile) == engine.DeployTypeCompose {
+		return fmt.Errorf("volumes of compose projects are declared in the compose file")
+	}
+	for i, v := range p.Volumes {
+		name, containerPath, err := normalizeVolume(v.Name, v.ContainerPath)
+		if err != nil {
+			return fmt.Errorf("volumes[%d]: %w", i, err)
+		}
+		p.Volumes[i].Name, p.Volumes[i].ContainerPath = name, containerPath
+	}
+
+	for i, d := range p.Domains {
+		hostname := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d.Hostname)), ".")
+		if len(hostname) > 253 || !hostnameRe.MatchString(hostname) {
+			return fmt.Errorf("domains[%d]: invalid hostname", i)
+		}
+		if d.Service != "" && !composeServiceRe.MatchString(d.Service) {
+			return fmt.Errorf("domains[%d]: invalid service: %s", i, d.Service)
+		}
+		if d.ContainerPort < 0 || d.ContainerPort > 65535 {
+			return fmt.Errorf("domains[%d]: invalid container_port", i)
+		}
+		p.Domains[i].Hostname = hostname
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+)
+
+type createProjectVolumeRequest struct {
+	Name          string `json:"name"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+}
+
+func (s *Server) listProjectVolumes(c *gin.Context) {
+	projectID := c.Param("id")
+	if _, err := s.st.GetProject(c.Request.Context(), projectID); err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+
+	volumes, err := s.st.ListProjectVolumes(c.Request.Context(), projectID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"volumes": volumes})
+}
+
+// normalizeVolume 校验卷名和容器内的绝对路径
+func normalizeVolume(name, containerPath string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) > 64 || !composeServiceRe.MatchString(name) {
+		return "", "", invalidField("name")
+	}
+	containerPath = strings.TrimSpace(containerPath)
+	if !strings.HasPrefix(containerPath, "/") || strings.ContainsAny(containerPath, ":,") {
+		return "", "", invalidField("container_path").withDetail("must be an absolute path")
+	}
+	containerPath = path.Clean(containerPath)
+	if containerPath == "/" {
+		return "", "", invalidField("container_path").withDetail("must not be /")
+	}
+	return name, containerPath, nil
+}
+
+// createProjectVolume 添加数据卷挂载，下次部署时生效
+func (s *Server) createProjectVolume(c *gin.Context) {
+	projectID := c.Param("id")
+	var req createProjectVolumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	name, containerPath, err := normalizeVolume(req.Name, req.ContainerPath)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	project, err := s.st.GetProject(c.Request.Context(), projectID)
+	if err != nil {
+		writeLookupError(c, err, codeProjectNotFound)
+		return
+	}
+	// compose 项目的卷由 compose 文件声明
+	if engine.ResolveDeployType(project.DeployType, project.ComposeFile) == engine.DeployTypeCompose {
+		badRequest(c, newError(http.StatusBadRequest, codeComposeVolumesUnsupported))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	created, err := s.st.CreateProjectVolume(c.Request.Context(), store.ProjectVolume{
+		ID:            id,
+		ProjectID:     projectID,
+		Name:          name,
+		ContainerPath: containerPath,
+		ReadOnly:      req.ReadOnly,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"volume": created})
+}
+
+// deleteProjectVolume 只移除挂载配置，卷中的数据保留到项目被 purge 删除
+func (s *Server) deleteProjectVolume(c *gin.Context) {
+	if err := s.st.DeleteProjectVolume(c.Request.Context(), c.Param("id"), c.Param("volumeId")); err != nil {
+		writeLookupError(c, err, codeVolumeNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,252 @@
+// Package bundle is the portable form of project definitions, used to move
+// projects between instances or keep them in git. Runtime state, ids and
+// secret values are never part of a bundle.
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"last-deploy/internal/compose"
+	"last-deploy/internal/store"
+)
+
+// Version is the bundle format written by this build. Older versions are
+// still read.
+const Version = 1
+
+type Bundle struct {
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt int64     `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Projects   []Project `json:"projects" yaml:"projects"`
+}
+
+type Project struct {
+	Name              string `json:"name" yaml:"name"`
+	GitURL            string `json:"git_url" yaml:"git_url"`
+	GitRef            string `json:"git_ref,omitempty" yaml:"git_ref,omitempty"`
+	RepoSubdir        string `json:"repo_subdir,omitempty" yaml:"repo_subdir,omitempty"`
+	DeployType        string `json:"deploy_type,omitempty" yaml:"deploy_type,omitempty"`
+	ComposeFile       string `json:"compose_file,omitempty" yaml:"compose_file,omitempty"`
+	ComposeService    string `json:"compose_service,omitempty" yaml:"compose_service,omitempty"`
+	DockerfilePath    string `json:"dockerfile_path,omitempty" yaml:"dockerfile_path,omitempty"`
+	DockerfileContent string `json:"dockerfile_content,omitempty" yaml:"dockerfile_content,omitempty"`
+	ComposeContent    string `json:"compose_content,omitempty" yaml:"compose_content,omitempty"`
+	ExposeMode        string `json:"expose_mode,omitempty" yaml:"expose_mode,omitempty"`
+	BindIP            string `json:"bind_ip,omitempty" yaml:"bind_ip,omitempty"`
+
+	Ports          []Port          `json:"ports,omitempty" yaml:"ports,omitempty"`
+	HealthCheck    *HealthCheck    `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	DeployStrategy string          `json:"deploy_strategy,omitempty" yaml:"deploy_strategy,omitempty"`
+	AutoRollback   bool            `json:"auto_rollback,omitempty" yaml:"auto_rollback,omitempty"`
+	SparseCheckout bool            `json:"sparse_checkout,omitempty" yaml:"sparse_checkout,omitempty"`
+	ResourceLimits *ResourceLimits `json:"resource_limits,omitempty" yaml:"resource_limits,omitempty"`
+	Volumes        []Volume        `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Domains        []Domain        `json:"domains,omitempty" yaml:"domains,omitempty"`
+
+	BuildArgs map[string]string `json:"build_args,omitempty" yaml:"build_args,omitempty"`
+
+	// Env lists the variables the compose file reads from the host and the
+	// environment variables set on the project. Only the names are exported;
+	// the target instance has to provide the values.
+	Env []string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
+type Port struct {
+	Service       string `json:"service,omitempty" yaml:"service,omitempty"`
+	HostIP        string `json:"host_ip,omitempty" yaml:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty" yaml:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port" yaml:"container_port"`
+	Protocol      string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+}
+
+type HealthCheck struct {
+	Type           string `json:"type" yaml:"type"`
+	Path           string `json:"path,omitempty" yaml:"path,omitempty"`
+	ExpectedStatus int    `json:"expected_status,omitempty" yaml:"expected_status,omitempty"`
+	Service        string `json:"service,omitempty" yaml:"service,omitempty"`
+	Port           int    `json:"port,omitempty" yaml:"port,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+}
+
+type ResourceLimits struct {
+	MemoryMB     int64 `json:"memory_mb,omitempty" yaml:"memory_mb,omitempty"`
+	MemorySwapMB int64 `json:"memory_swap_mb,omitempty" yaml:"memory_swap_mb,omitempty"`
+	CPUShares    int64 `json:"cpu_shares,omitempty" yaml:"cpu_shares,omitempty"`
+	CPUQuota     int64 `json:"cpu_quota,omitempty" yaml:"cpu_quota,omitempty"`
+	PidsLimit    int64 `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
+}
+
+type Volume struct {
+	Name          string `json:"name" yaml:"name"`
+	ContainerPath string `json:"container_path" yaml:"container_path"`
+	ReadOnly      bool   `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+}
+
+type Domain struct {
+	Hostname      string `json:"hostname" yaml:"hostname"`
+	Service       string `json:"service,omitempty" yaml:"service,omitempty"`
+	ContainerPort int    `json:"container_port,omitempty" yaml:"container_port,omitempty"`
+}
+
+// Parse reads a bundle in YAML or JSON.
+func Parse(data []byte) (Bundle, error) {
+	var b Bundle
+	if err := yaml.Unmarshal(data, &b); err != nil {
+		return Bundle{}, err
+	}
+	switch {
+	case b.Version == 0:
+		return Bundle{}, errors.New("version is required")
+	case b.Version > Version:
+		return Bundle{}, fmt.Errorf("unsupported bundle version %d, this instance reads up to %d", b.Version, Version)
+	}
+	return b, nil
+}
+
+// FromProject converts a stored project with its volumes, domains and
+// environment variables. Variable values are left out.
+func FromProject(p store.Project, volumes []store.ProjectVolume, domains []store.ProjectDomain, env []store.ProjectEnvVar) Project {
+	out := Project{
+		Name:              p.Name,
+		GitURL:            p.GitURL,
+		GitRef:            p.GitRef,
+		RepoSubdir:        p.RepoSubdir,
+		DeployType:        p.DeployType,
+		ComposeFile:       p.ComposeFile,
+		ComposeService:    p.ComposeService,
+		DockerfilePath:    p.DockerfilePath,
+		DockerfileContent: p.DockerfileContent,
+		ComposeContent:    p.ComposeContent,
+		ExposeMode:        p.ExposeMode,
+		BindIP:            p.BindIP,
+		DeployStrategy:    p.DeployStrategy,
+		AutoRollback:      p.AutoRollback,
+		SparseCheckout:    p.SparseCheckout,
+		BuildArgs:         maps.Clone(p.BuildArgs),
+	}
+	for _, port := range p.Ports {
+		out.Ports = append(out.Ports, Port{
+			Service:       port.Service,
+			HostIP:        port.HostIP,
+			HostPort:      port.HostPort,
+			ContainerPort: port.ContainerPort,
+			Protocol:      port.Protocol,
+		})
+	}
+	if p.HealthCheck.Type != store.HealthCheckNone {
+		hc := HealthCheck(p.HealthCheck)
+		out.HealthCheck = &hc
+	}
+	if p.ResourceLimits != (store.ResourceLimits{}) {
+		rl := ResourceLimits(p.ResourceLimits)
+		out.ResourceLimits = &rl
+	}
+	for _, v := range volumes {
+		out.Volumes = append(out.Volumes, Volume{Name: v.Name, ContainerPath: v.ContainerPath, ReadOnly: v.ReadOnly})
+	}
+	for _, d := range domains {
+		out.Domains = append(out.Domains, Domain{Hostname: d.Hostname, Service: d.Service, ContainerPort: d.ContainerPort})
+	}
+	if p.ComposeContent != "" {
+		// An unparsable compose file is exported as is; deploys report it.
+		out.Env, _ = compose.Variables([]byte(p.ComposeContent))
+	}
+	for _, v := range env {
+		if !slices.Contains(out.Env, v.Name) {
+			out.Env = append(out.Env, v.Name)
+		}
+	}
+	slices.Sort(out.Env)
+	return out.normalized()
+}
+
+// ToStore returns the stored form of p without id, status or timestamps.
+func (p Project) ToStore() store.Project {
+	p = p.normalized()
+	out := store.Project{
+		Name:              p.Name,
+		GitURL:            p.GitURL,
+		GitRef:            p.GitRef,
+		RepoSubdir:        p.RepoSubdir,
+		DeployType:        p.DeployType,
+		ComposeFile:       p.ComposeFile,
+		ComposeService:    p.ComposeService,
+		DockerfilePath:    p.DockerfilePath,
+		DockerfileContent: p.DockerfileContent,
+		ComposeContent:    p.ComposeContent,
+		ExposeMode:        p.ExposeMode,
+		BindIP:            p.BindIP,
+		DeployStrategy:    p.DeployStrategy,
+		AutoRollback:      p.AutoRollback,
+		SparseCheckout:    p.SparseCheckout,
+		BuildArgs:         maps.Clone(p.BuildArgs),
+		Ports:             []store.ProjectPort{},
+	}
+	for _, port := range p.Ports {
+		out.Ports = append(out.Ports, store.ProjectPort{
+			Service:       port.Service,
+			HostIP:        port.HostIP,
+			HostPort:      port.HostPort,
+			ContainerPort: port.ContainerPort,
+			Protocol:      port.Protocol,
+		})
+	}
+	if p.HealthCheck != nil {
+		out.HealthCheck = store.HealthCheck(*p.HealthCheck)
+	}
+	if p.ResourceLimits != nil {
+		out.ResourceLimits = store.ResourceLimits(*p.ResourceLimits)
+	}
+	return out
+}
+
+// normalized fills in the defaults the store applies, so that comparing an
+// imported project with an exported one only reports real differences.
+func (p Project) normalized() Project {
+	if p.DeployType == "" {
+		p.DeployType = "auto"
+	}
+	if p.DockerfilePath == "" {
+		p.DockerfilePath = "Dockerfile"
+	}
+	if p.ExposeMode == "" {
+		p.ExposeMode = store.ExposeLoopback
+	}
+	if p.DeployStrategy == "" {
+		p.DeployStrategy = store.DeployStrategyRecreate
+	}
+	ports := make([]Port, len(p.Ports))
+	for i, port := range p.Ports {
+		if port.Protocol == "" {
+			port.Protocol = "tcp"
+		}
+		ports[i] = port
+	}
+	if len(ports) > 0 {
+		p.Ports = ports
+	}
+	if p.HealthCheck != nil && p.HealthCheck.Type == store.HealthCheckNone {
+		p.HealthCheck = nil
+	}
+	if p.ResourceLimits != nil && *p.ResourceLimits == (ResourceLimits{}) {
+		p.ResourceLimits = nil
+	}
+	if len(p.BuildArgs) == 0 {
+		p.BuildArgs = nil
+	}
+	if len(p.Domains) > 0 {
+		domains := make([]Domain, len(p.Domains))
+		for i, d := range p.Domains {
+			d.Hostname = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d.Hostname)), ".")
+			domains[i] = d
+		}
+		p.Domains = domains
+	}
+	return p
+}
@@ -0,0 +1,129 @@
+package bundle
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"last-deploy/internal/store"
+)
+
+func TestFromProjectRoundTrip(t *testing.T) {
+	sp := store.Project{
+		ID:             "a",
+		Name:           "app",
+		GitURL:         "https://example.com/app.git",
+		DeployType:     "compose",
+		ComposeFile:    "docker-compose.yml",
+		ComposeContent: "services:\n  web:\n    image: app:${TAG:-latest}\n    environment:\n      SECRET: $API_KEY\n",
+		ExposeMode:     store.ExposeLoopback,
+		DeployStrategy: store.DeployStrategyRecreate,
+		Ports:          []store.ProjectPort{{ID: "p1", ProjectID: "a", Service: "web", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+		HealthCheck:    store.HealthCheck{Type: store.HealthCheckHTTP, Path: "/", TimeoutSeconds: 60},
+		BuildArgs:      map[string]string{"GO_VERSION": "1.22"},
+	}
+	env := []store.ProjectEnvVar{{ProjectID: "a", Name: "DATABASE_URL", Value: "secret"}, {ProjectID: "a", Name: "TAG", Value: "v1"}}
+	p := FromProject(sp, nil, []store.ProjectDomain{{Hostname: "app.example.org"}}, env)
+	if !reflect.DeepEqual(p.Env, []string{"API_KEY", "DATABASE_URL", "TAG"}) {
+		t.Errorf("Env = %v, want [API_KEY DATABASE_URL TAG]", p.Env)
+	}
+
+	data, err := yaml.Marshal(Bundle{Version: Version, Projects: []Project{p}})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	b, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(b.Projects) != 1 {
+		t.Fatalf("Projects = %d, want 1", len(b.Projects))
+	}
+	if changes := Diff(p, b.Projects[0]); len(changes) != 0 {
+		t.Errorf("Diff after round trip = %v, want none", changes)
+	}
+
+	got := b.Projects[0].ToStore()
+	if got.ComposeContent != sp.ComposeContent || got.HealthCheck != sp.HealthCheck || len(got.Ports) != 1 || got.Ports[0].HostPort != 8080 ||
+		got.BuildArgs["GO_VERSION"] != "1.22" {
+		t.Errorf("ToStore = %+v", got)
+	}
+}
+
+func TestParse_Version(t *testing.T) {
+	for _, in := range []string{"projects: []", "version: 99\nprojects: []", "version: ["} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q): expected error", in)
+		}
+	}
+	if _, err := Parse([]byte(`{"version": 1, "projects": []}`)); err != nil {
+		t.Errorf("Parse(json): %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	existing := Project{
+		Name:    "api",
+		GitURL:  "https://example.com/api.git",
+		Ports:   []Port{{HostPort: 8080, ContainerPort: 80}},
+		Domains: []Domain{{Hostname: "api.example.org"}},
+	}
+	inst := Instance{
+		Projects: []Existing{
+			{ID: "p-api", Project: existing},
+			{ID: "p-web", Project: Project{Name: "web", GitURL: "https://example.com/web.git"}},
+			{ID: "p-dup1", Project: Project{Name: "dup", GitURL: "u"}},
+			{ID: "p-dup2", Project: Project{Name: "dup", GitURL: "u"}},
+		},
+		HostPorts: map[string]string{"8080/tcp": "p-api", "9000/tcp": "p-web"},
+		Hostnames: map[string]string{"api.example.org": "p-api"},
+	}
+
+	changedAPI := existing
+	changedAPI.GitRef = "main"
+	changedAPI.Ports = []Port{{ContainerPort: 80}}
+	withEnv := existing
+	withEnv.Env = []string{"API_KEY"}
+
+	tests := []struct {
+		name    string
+		project Project
+		action  string
+		changes []string
+	}{
+		{"unchanged", existing, ActionUnchanged, nil},
+		{"update keeps auto host port", changedAPI, ActionUpdate, []string{"git_ref"}},
+		{"create", Project{Name: "new", GitURL: "u", Ports: []Port{{HostPort: 7000, ContainerPort: 80}}}, ActionCreate, nil},
+		{"other git url", Project{Name: "web", GitURL: "https://example.com/other.git"}, ActionConflict, nil},
+		{"ambiguous name", Project{Name: "dup", GitURL: "u"}, ActionConflict, nil},
+		{"host port taken", Project{Name: "new", GitURL: "u", Ports: []Port{{HostPort: 9000, ContainerPort: 80}}}, ActionConflict, nil},
+		{"hostname taken", Project{Name: "new", GitURL: "u", Domains: []Domain{{Hostname: "API.example.org."}}}, ActionConflict, nil},
+		{"missing git url", Project{Name: "new"}, ActionConflict, nil},
+		{"new env name", withEnv, ActionUpdate, []string{"env"}},
+		{"invalid env name", Project{Name: "new", GitURL: "u", Env: []string{"A-B"}}, ActionConflict, nil},
+	}
+	for _, tt := range tests {
+		items := Plan([]Project{tt.project}, inst)
+		if items[0].Action != tt.action {
+			t.Errorf("%s: action = %s (%v), want %s", tt.name, items[0].Action, items[0].Conflicts, tt.action)
+			continue
+		}
+		if tt.action == ActionConflict && len(items[0].Conflicts) == 0 {
+			t.Errorf("%s: no conflict reason", tt.name)
+		}
+		if !reflect.DeepEqual(items[0].Changes, tt.changes) {
+			t.Errorf("%s: changes = %v, want %v", tt.name, items[0].Changes, tt.changes)
+		}
+	}
+
+	// Projects within one bundle must not collide either.
+	items := Plan([]Project{
+		{Name: "a", GitURL: "u", Ports: []Port{{HostPort: 7000, ContainerPort: 80}}},
+		{Name: "b", GitURL: "u", Ports: []Port{{HostPort: 7000, ContainerPort: 80}}},
+		{Name: "a", GitURL: "u"},
+	}, inst)
+	if items[0].Action != ActionCreate || items[1].Action != ActionConflict || items[2].Action != ActionConflict {
+		t.Errorf("bundle collisions = %+v", items)
+	}
+}
@@ -0,0 +1,238 @@
+package bundle
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Import actions reported for each project of a bundle.
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionConflict  = "conflict"
+)
+
+// Existing is a project of the importing instance in bundle form.
+type Existing struct {
+	ID      string
+	Project Project
+}
+
+// Instance is what a bundle is checked against: the current projects and the
+// host ports ("8080/tcp") and hostnames taken, each mapped to its project id.
+type Instance struct {
+	Projects  []Existing
+	HostPorts map[string]string
+	Hostnames map[string]string
+}
+
+// Item reports what importing one project does. Changes names the fields an
+// update touches; Conflicts explains why the project can not be imported.
+type Item struct {
+	Name      string   `json:"name"`
+	Action    string   `json:"action"`
+	ProjectID string   `json:"project_id,omitempty"`
+	Changes   []string `json:"changes,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Env       []string `json:"env,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Conflict records a reason the project can not be imported.
+func (it *Item) Conflict(format string, args ...any) {
+	it.Action = ActionConflict
+	it.Changes = nil
+	it.Conflicts = append(it.Conflicts, fmt.Sprintf(format, args...))
+}
+
+// Plan matches the projects of a bundle to the instance by name. A project
+// with the same name and git URL is updated; the same name with another git
+// URL, a name shared by several projects, or a host port or hostname owned
+// by a project outside the match is a conflict. Volumes, domains and env
+// names are only ever added by an import, never removed.
+func Plan(projects []Project, inst Instance) []Item {
+	byName := make(map[string][]Existing)
+	names := make(map[string]string)
+	for _, e := range inst.Projects {
+		byName[e.Project.Name] = append(byName[e.Project.Name], e)
+		names[e.ID] = e.Project.Name
+	}
+	owner := func(id string) string {
+		if name := names[id]; name != "" {
+			return fmt.Sprintf("project %q", name)
+		}
+		return "project " + id
+	}
+
+	seenNames := make(map[string]bool)
+	seenPorts := make(map[string]string)
+	seenHosts := make(map[string]string)
+	items := make([]Item, len(projects))
+	for i, p := range projects {
+		p = p.normalized()
+		it := Item{Name: p.Name, Env: p.Env}
+
+		switch {
+		case strings.TrimSpace(p.Name) == "":
+			it.Conflict("name is required")
+		case seenNames[p.Name]:
+			it.Conflict("name appears more than once in the bundle")
+		}
+		seenNames[p.Name] = true
+		if strings.TrimSpace(p.GitURL) == "" {
+			it.Conflict("git_url is required")
+		}
+		for _, name := range p.Env {
+			if !envNameRe.MatchString(name) {
+				it.Conflict("env name %q is invalid", name)
+			}
+		}
+
+		var match *Existing
+		switch matches := byName[p.Name]; {
+		case p.Name == "" || len(matches) == 0:
+		case len(matches) > 1:
+			it.Conflict("name is used by %d projects", len(matches))
+		case matches[0].Project.GitURL != p.GitURL:
+			it.Conflict("name is used by project %s with git_url %s", matches[0].ID, matches[0].Project.GitURL)
+		default:
+			match = &matches[0]
+			it.ProjectID = match.ID
+		}
+
+		for _, port := range p.Ports {
+			if port.HostPort == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%d/%s", port.HostPort, port.Protocol)
+			if other, ok := seenPorts[key]; ok && other != p.Name {
+				it.Conflict("host port %s is also used by %q in the bundle", key, other)
+			}
+			seenPorts[key] = p.Name
+			if id, ok := inst.HostPorts[key]; ok && (match == nil || id != match.ID) {
+				it.Conflict("host port %s is used by %s", key, owner(id))
+			}
+		}
+		for _, d := range p.Domains {
+			if other, ok := seenHosts[d.Hostname]; ok && other != p.Name {
+				it.Conflict("hostname %s is also used by %q in the bundle", d.Hostname, other)
+			}
+			seenHosts[d.Hostname] = p.Name
+			if id, ok := inst.Hostnames[d.Hostname]; ok && (match == nil || id != match.ID) {
+				it.Conflict("hostname %s is used by %s", d.Hostname, owner(id))
+			}
+		}
+
+		switch {
+		case it.Action == ActionConflict:
+		case match == nil:
+			it.Action = ActionCreate
+		default:
+			it.Changes = Diff(match.Project, p)
+			it.Action = ActionUpdate
+			if len(it.Changes) == 0 {
+				it.Action = ActionUnchanged
+			}
+		}
+		items[i] = it
+	}
+	return items
+}
+
+// Diff returns the names of the fields an import of want changes in cur.
+// Ports without a host port match any host port, and only volumes, domains
+// and env names missing from cur count as changes.
+func Diff(cur, want Project) []string {
+	cur, want = cur.normalized(), want.normalized()
+
+	var changes []string
+	cv, wv := reflect.ValueOf(cur), reflect.ValueOf(want)
+	for i := 0; i < cv.NumField(); i++ {
+		f := cv.Type().Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "env":
+			if slices.ContainsFunc(want.Env, func(name string) bool { return !slices.Contains(cur.Env, name) }) {
+				changes = append(changes, name)
+			}
+			continue
+		case "ports":
+			if !portsMatch(cur.Ports, want.Ports) {
+				changes = append(changes, name)
+			}
+			continue
+		case "volumes":
+			if len(MissingVolumes(cur.Volumes, want.Volumes)) > 0 {
+				changes = append(changes, name)
+			}
+			continue
+		case "domains":
+			if len(MissingDomains(cur.Domains, want.Domains)) > 0 {
+				changes = append(changes, name)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(cv.Field(i).Interface(), wv.Field(i).Interface()) {
+			changes = append(changes, name)
+		}
+	}
+	return changes
+}
+
+// MissingVolumes returns the volumes of want that cur does not mount.
+func MissingVolumes(cur, want []Volume) []Volume {
+	var out []Volume
+	for _, w := range want {
+		found := false
+		for _, c := range cur {
+			if c == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// MissingDomains returns the domains of want whose hostname cur lacks.
+func MissingDomains(cur, want []Domain) []Domain {
+	var out []Domain
+	for _, w := range want {
+		found := false
+		for _, c := range cur {
+			if c.Hostname == w.Hostname {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func portsMatch(cur, want []Port) bool {
+	if len(cur) != len(want) {
+		return false
+	}
+	for i := range want {
+		w := want[i]
+		if w.HostPort == 0 {
+			w.HostPort = cur[i].HostPort
+		}
+		if cur[i] != w {
+			return false
+		}
+	}
+	return true
+}
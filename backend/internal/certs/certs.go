@@ -0,0 +1,268 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"last-deploy/internal/config"
+)
+
+const (
+	SourceCustom = "custom"
+	SourceACME   = "acme"
+)
+
+var ErrNoCertificate = errors.New("no certificate")
+
+// Info describes the certificate served for a hostname.
+type Info struct {
+	Hostname  string   `json:"hostname"`
+	Source    string   `json:"source"`
+	Issuer    string   `json:"issuer"`
+	DNSNames  []string `json:"dns_names"`
+	NotBefore int64    `json:"not_before"`
+	NotAfter  int64    `json:"not_after"`
+}
+
+// Manager serves certificates for project domains. Uploaded certificates
+// take precedence; everything else is issued and renewed through ACME.
+//
+// Layout under CertsDir:
+//
+//	acme/                 autocert cache (account key, issued certificates)
+//	custom/<host>.crt     uploaded certificate chain
+//	custom/<host>.key     uploaded private key
+type Manager struct {
+	dir  string
+	acme *autocert.Manager
+
+	mu     sync.RWMutex
+	custom map[string]*tls.Certificate
+}
+
+// New builds a Manager. hostPolicy decides which hostnames ACME may issue
+// certificates for; the proxy allows only routed hostnames.
+func New(cfg config.Config, hostPolicy autocert.HostPolicy) (*Manager, error) {
+	dir := cfg.CertsDir()
+	if err := os.MkdirAll(filepath.Join(dir, "custom"), 0o700); err != nil {
+		return nil, err
+	}
+
+	client := &acme.Client{DirectoryURL: cfg.ACMEDirectory}
+	if cfg.ACMECAFile != "" {
+		pemBytes, err := os.ReadFile(cfg.ACMECAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read acme ca file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("acme ca file: no certificates found")
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	m := &Manager{
+		dir: dir,
+		acme: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(filepath.Join(dir, "acme")),
+			HostPolicy: hostPolicy,
+			Client:     client,
+			Email:      cfg.ACMEEmail,
+		},
+		custom: make(map[string]*tls.Certificate),
+	}
+	if err := m.loadAll(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TLSConfig enables TLS-ALPN-01 challenges on the HTTPS listener.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// HTTPHandler answers HTTP-01 challenges and passes everything else to fallback.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.acme.HTTPHandler(fallback)
+}
+
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	// TLS-ALPN-01 challenge handshakes must be answered by autocert.
+	isChallenge := len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+	if host != "" && !isChallenge {
+		if cert, err := m.loadCustom(host); err == nil {
+			return cert, nil
+		}
+	}
+	return m.acme.GetCertificate(hello)
+}
+
+// SaveCustom validates a PEM certificate chain and private key for hostname
+// and stores them. The leaf must cover hostname and must not be expired.
+func (m *Manager) SaveCustom(hostname string, certPEM, keyPEM []byte) (Info, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return Info{}, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return Info{}, fmt.Errorf("invalid certificate: %w", err)
+		}
+	}
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		return Info{}, fmt.Errorf("certificate does not match %s", hostname)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return Info{}, fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	crt, key := m.customPaths(hostname)
+	if err := writeFile(key, keyPEM, 0o600); err != nil {
+		return Info{}, err
+	}
+	if err := writeFile(crt, certPEM, 0o644); err != nil {
+		return Info{}, err
+	}
+
+	cert.Leaf = leaf
+	m.mu.Lock()
+	m.custom[hostname] = &cert
+	m.mu.Unlock()
+	return infoFor(hostname, SourceCustom, leaf), nil
+}
+
+// DeleteCustom removes an uploaded certificate; hostname falls back to ACME.
+func (m *Manager) DeleteCustom(hostname string) error {
+	m.mu.Lock()
+	delete(m.custom, hostname)
+	m.mu.Unlock()
+
+	crt, key := m.customPaths(hostname)
+	for _, p := range []string{crt, key} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Info reports the certificate currently available for hostname without
+// triggering issuance. ErrNoCertificate means ACME has not issued one yet.
+func (m *Manager) Info(ctx context.Context, hostname string) (Info, error) {
+	if cert, err := m.loadCustom(hostname); err == nil {
+		return infoFor(hostname, SourceCustom, cert.Leaf), nil
+	}
+
+	// ECDSA certificates are cached under the hostname, RSA ones (for clients
+	// without ECDSA support) under "<hostname>+rsa".
+	data, err := m.acme.Cache.Get(ctx, hostname)
+	if errors.Is(err, autocert.ErrCacheMiss) {
+		data, err = m.acme.Cache.Get(ctx, hostname+"+rsa")
+	}
+	if err != nil {
+		if errors.Is(err, autocert.ErrCacheMiss) {
+			return Info{}, ErrNoCertificate
+		}
+		return Info{}, err
+	}
+	// autocert stores the private key followed by the certificate chain.
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return Info{}, err
+		}
+		return infoFor(hostname, SourceACME, leaf), nil
+	}
+	return Info{}, ErrNoCertificate
+}
+
+func (m *Manager) loadCustom(hostname string) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cert, ok := m.custom[hostname]
+	if !ok {
+		return nil, ErrNoCertificate
+	}
+	return cert, nil
+}
+
+// loadAll reads uploaded certificates from disk; afterwards the in-memory
+// map is authoritative so handshakes never touch the file system.
+func (m *Manager) loadAll() error {
+	crts, err := filepath.Glob(filepath.Join(m.dir, "custom", "*.crt"))
+	if err != nil {
+		return err
+	}
+	for _, crt := range crts {
+		key := strings.TrimSuffix(crt, ".crt") + ".key"
+		c, err := tls.LoadX509KeyPair(crt, key)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", crt, err)
+		}
+		if c.Leaf == nil {
+			if c.Leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+				return fmt.Errorf("load %s: %w", crt, err)
+			}
+		}
+		m.custom[strings.TrimSuffix(filepath.Base(crt), ".crt")] = &c
+	}
+	return nil
+}
+
+func (m *Manager) customPaths(hostname string) (string, string) {
+	base := filepath.Join(m.dir, "custom", hostname)
+	return base + ".crt", base + ".key"
+}
+
+func infoFor(hostname, source string, leaf *x509.Certificate) Info {
+	return Info{
+		Hostname:  hostname,
+		Source:    source,
+		Issuer:    leaf.Issuer.String(),
+		DNSNames:  leaf.DNSNames,
+		NotBefore: leaf.NotBefore.Unix(),
+		NotAfter:  leaf.NotAfter.Unix(),
+	}
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,128 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"last-deploy/internal/config"
+)
+
+func selfSigned(t *testing.T, notAfter time.Time, hosts ...string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func allowAll(context.Context, string) error { return nil }
+
+func TestCustomCertificates(t *testing.T) {
+	cfg := config.Config{DataDir: t.TempDir(), ACMEDirectory: "http://127.0.0.1:0/dir"}
+	m, err := New(cfg, allowAll)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	certPEM, keyPEM := selfSigned(t, time.Now().Add(24*time.Hour), "app.example.com")
+	_, otherKey := selfSigned(t, time.Now().Add(24*time.Hour), "app.example.com")
+	expiredCert, expiredKey := selfSigned(t, time.Now().Add(-time.Minute), "app.example.com")
+
+	if _, err := m.SaveCustom("www.example.com", certPEM, keyPEM); err == nil {
+		t.Fatalf("SaveCustom accepted a certificate for another host")
+	}
+	if _, err := m.SaveCustom("app.example.com", certPEM, otherKey); err == nil {
+		t.Fatalf("SaveCustom accepted a mismatched key")
+	}
+	if _, err := m.SaveCustom("app.example.com", expiredCert, expiredKey); err == nil {
+		t.Fatalf("SaveCustom accepted an expired certificate")
+	}
+
+	info, err := m.SaveCustom("app.example.com", certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("SaveCustom: %v", err)
+	}
+	if info.Source != SourceCustom || info.DNSNames[0] != "app.example.com" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	// Uploaded pairs survive a restart.
+	m, err = New(cfg, allowAll)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "APP.example.com."})
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "app.example.com" {
+		t.Fatalf("served wrong certificate: %+v", cert.Leaf)
+	}
+
+	if err := m.DeleteCustom("app.example.com"); err != nil {
+		t.Fatalf("DeleteCustom: %v", err)
+	}
+	if _, err := m.Info(context.Background(), "app.example.com"); !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("Info after delete = %v, want ErrNoCertificate", err)
+	}
+}
+
+// TestACMEIssuance runs against a local ACME server, e.g. Pebble started with
+// PEBBLE_VA_ALWAYS_VALID=1:
+//
+//	LAST_DEPLOY_TEST_ACME_DIRECTORY=https://localhost:14000/dir \
+//	LAST_DEPLOY_TEST_ACME_CA_FILE=pebble.minica.pem go test ./internal/certs
+func TestACMEIssuance(t *testing.T) {
+	dir := os.Getenv("LAST_DEPLOY_TEST_ACME_DIRECTORY")
+	if dir == "" {
+		t.Skip("LAST_DEPLOY_TEST_ACME_DIRECTORY not set")
+	}
+	cfg := config.Config{
+		DataDir:       t.TempDir(),
+		ACMEDirectory: dir,
+		ACMECAFile:    os.Getenv("LAST_DEPLOY_TEST_ACME_CA_FILE"),
+	}
+	m, err := New(cfg, allowAll)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "app.last-deploy.test"}); err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	info, err := m.Info(context.Background(), "app.last-deploy.test")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Source != SourceACME {
+		t.Fatalf("source = %q, want %q", info.Source, SourceACME)
+	}
+}
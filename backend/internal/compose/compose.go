@@ -0,0 +1,239 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the subset of a compose file that last-deploy needs to understand.
+// Services keep the order in which they appear in the document.
+type File struct {
+	Services []Service
+}
+
+type Service struct {
+	Name  string
+	Image string
+	Ports []PortMapping
+	// Limits holds the resource limits the service sets itself, keyed by the
+	// service attribute they map to (mem_limit, memswap_limit, cpu_shares,
+	// cpu_quota, pids_limit). cpus and deploy.resources.limits count as
+	// mem_limit, cpu_quota and pids_limit.
+	Limits map[string]bool
+}
+
+type rawService struct {
+	Image        string      `yaml:"image"`
+	Ports        []yaml.Node `yaml:"ports"`
+	MemLimit     any         `yaml:"mem_limit"`
+	MemswapLimit any         `yaml:"memswap_limit"`
+	CPUShares    any         `yaml:"cpu_shares"`
+	CPUQuota     any         `yaml:"cpu_quota"`
+	CPUs         any         `yaml:"cpus"`
+	PidsLimit    any         `yaml:"pids_limit"`
+	Deploy       struct {
+		Resources struct {
+			Limits struct {
+				CPUs   any `yaml:"cpus"`
+				Memory any `yaml:"memory"`
+				Pids   any `yaml:"pids"`
+			} `yaml:"limits"`
+		} `yaml:"resources"`
+	} `yaml:"deploy"`
+}
+
+// limits maps the resource attributes set on the service to the keys of
+// Service.Limits.
+func (r rawService) limits() map[string]bool {
+	dl := r.Deploy.Resources.Limits
+	set := map[string]bool{
+		"mem_limit":     r.MemLimit != nil || dl.Memory != nil,
+		"memswap_limit": r.MemswapLimit != nil,
+		"cpu_shares":    r.CPUShares != nil,
+		"cpu_quota":     r.CPUQuota != nil || r.CPUs != nil || dl.CPUs != nil,
+		"pids_limit":    r.PidsLimit != nil || dl.Pids != nil,
+	}
+	for k, v := range set {
+		if !v {
+			delete(set, k)
+		}
+	}
+	return set
+}
+
+// Parse decodes compose content, resolving anchors, merge keys and variable
+// interpolation. Variables are looked up in env; unset variables fall back to
+// their inline defaults (${VAR:-default}) or the empty string.
+func Parse(content []byte, env map[string]string) (*File, error) {
+	return parse(content, env, false)
+}
+
+// ParseLenient is Parse for detection, where the variables of the deploy are
+// not known yet. A value whose interpolation fails, such as a required
+// ${VAR:?msg} without a value, is left unresolved instead of failing the
+// whole file; port entries that use one are skipped.
+func ParseLenient(content []byte, env map[string]string) (*File, error) {
+	return parse(content, env, true)
+}
+
+func parse(content []byte, env map[string]string, lenient bool) (*File, error) {
+	servicesNode, err := decodeServices(content, func(root *yaml.Node) error {
+		return interpolateNode(root, env, lenient)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if servicesNode == nil {
+		return &File{}, nil
+	}
+
+	f := &File{}
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		name := servicesNode.Content[i].Value
+		if name == "" {
+			return nil, fmt.Errorf("invalid compose service name: empty")
+		}
+		if name == "<<" {
+			continue
+		}
+
+		var raw rawService
+		if err := servicesNode.Content[i+1].Decode(&raw); err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		svc := Service{Name: name, Image: raw.Image, Limits: raw.limits()}
+		for _, pn := range raw.Ports {
+			if unresolved(&pn) {
+				continue
+			}
+			mappings, err := parsePortNode(&pn)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: ports: %w", name, err)
+			}
+			for _, m := range mappings {
+				m.Service = name
+				svc.Ports = append(svc.Ports, m)
+			}
+		}
+		f.Services = append(f.Services, svc)
+	}
+	return f, nil
+}
+
+// ServiceNamesOf returns the service names of content in document order.
+// Values are not interpolated, so unset variables, required ones included,
+// do not matter; service names are mapping keys, which compose never
+// interpolates.
+func ServiceNamesOf(content []byte) ([]string, error) {
+	servicesNode, err := decodeServices(content, nil)
+	if err != nil || servicesNode == nil {
+		return nil, err
+	}
+	var names []string
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		switch name := servicesNode.Content[i].Value; name {
+		case "":
+			return nil, fmt.Errorf("invalid compose service name: empty")
+		case "<<":
+		default:
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// decodeServices returns the services mapping of content, or nil when the
+// file defines none. prepare, when set, runs on the root mapping first.
+func decodeServices(content []byte, prepare func(root *yaml.Node) error) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("compose file must be a mapping")
+	}
+	if prepare != nil {
+		if err := prepare(root); err != nil {
+			return nil, err
+		}
+	}
+
+	servicesNode := mappingValue(root, "services")
+	if servicesNode == nil {
+		return nil, nil
+	}
+	servicesNode = resolveAlias(servicesNode)
+	if servicesNode.Kind == yaml.ScalarNode && servicesNode.Tag == "!!null" {
+		return nil, nil
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("services must be a mapping")
+	}
+	return servicesNode, nil
+}
+
+// ServiceNames returns the service names in document order.
+func (f *File) ServiceNames() []string {
+	names := make([]string, 0, len(f.Services))
+	for _, s := range f.Services {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func (f *File) Service(name string) (Service, bool) {
+	for _, s := range f.Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Service{}, false
+}
+
+// Ports returns every port mapping of the given services, in document order.
+// An empty selection returns the mappings of all services. selection uses the
+// same comma separated form as store.Project.ComposeService.
+func (f *File) Ports(selection string) []PortMapping {
+	var names []string
+	for _, s := range strings.Split(selection, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			names = append(names, s)
+		}
+	}
+
+	var out []PortMapping
+	if len(names) == 0 {
+		for _, s := range f.Services {
+			out = append(out, s.Ports...)
+		}
+		return out
+	}
+	for _, n := range names {
+		if s, ok := f.Service(n); ok {
+			out = append(out, s.Ports...)
+		}
+	}
+	return out
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
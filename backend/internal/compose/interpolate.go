@@ -0,0 +1,256 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unresolvedTag marks the scalars a lenient interpolation could not expand.
+const unresolvedTag = "!last-deploy/unresolved"
+
+// interpolateNode substitutes variables in every scalar value of the tree.
+// Mapping keys are left untouched, as in docker compose. When lenient is
+// set, scalars that fail to expand are emptied and tagged unresolved.
+func interpolateNode(n *yaml.Node, env map[string]string, lenient bool) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := interpolateNode(c, env, lenient); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := interpolateNode(n.Content[i], env, lenient); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := Interpolate(n.Value, env)
+		if err != nil {
+			if lenient {
+				n.Tag, n.Value = unresolvedTag, ""
+				return nil
+			}
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+	}
+	return nil
+}
+
+// unresolved reports whether n holds a value a lenient interpolation could
+// not expand.
+func unresolved(n *yaml.Node) bool {
+	n = resolveAlias(n)
+	if n.Tag == unresolvedTag {
+		return true
+	}
+	for _, c := range n.Content {
+		if unresolved(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interpolate expands $VAR and ${VAR} references using the compose rules:
+// ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error},
+// ${VAR:+replacement}, ${VAR+replacement} and $$ for a literal dollar sign.
+func Interpolate(s string, env map[string]string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch != '$' || i+1 >= len(s) {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end == -1 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			v, err := expandBraced(s[i+2:end], env)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			sb.WriteString(env[s[i+1:j]])
+			i = j - 1
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String(), nil
+}
+
+// Variables returns the sorted names of the variables the compose file reads,
+// including ones that only appear in default values.
+func Variables(content []byte) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	collectNodeVariables(&doc, seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func collectNodeVariables(n *yaml.Node, seen map[string]bool) {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			collectNodeVariables(c, seen)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			collectNodeVariables(n.Content[i], seen)
+		}
+	case yaml.ScalarNode:
+		collectVariables(n.Value, seen)
+	}
+}
+
+func collectVariables(s string, seen map[string]bool) {
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '$':
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end == -1 {
+				return
+			}
+			expr := s[i+2 : end]
+			n := 0
+			for n < len(expr) && isNameChar(expr[n]) {
+				n++
+			}
+			if n > 0 && isNameStart(expr[0]) {
+				seen[expr[:n]] = true
+			}
+			collectVariables(expr[n:], seen)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			seen[s[i+1:j]] = true
+			i = j - 1
+		}
+	}
+}
+
+func expandBraced(expr string, env map[string]string) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	val, set := env[name]
+	if rest == "" {
+		return val, nil
+	}
+
+	op := rest[:1]
+	arg := rest[1:]
+	if op == ":" && len(rest) > 1 {
+		op = rest[:2]
+		arg = rest[2:]
+	}
+	// Only evaluate the operand when it is used, so nested references in an
+	// unused default do not trigger errors.
+	operand := func() (string, error) { return Interpolate(arg, env) }
+
+	switch op {
+	case ":-":
+		if val == "" {
+			return operand()
+		}
+		return val, nil
+	case "-":
+		if !set {
+			return operand()
+		}
+		return val, nil
+	case ":?", "?":
+		if !set || (op == ":?" && val == "") {
+			msg, err := operand()
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = "required variable is missing a value"
+			}
+			return "", fmt.Errorf("%s: %s", name, msg)
+		}
+		return val, nil
+	case ":+":
+		if val != "" {
+			return operand()
+		}
+		return "", nil
+	case "+":
+		if set {
+			return operand()
+		}
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+	}
+}
+
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
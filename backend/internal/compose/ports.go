@@ -0,0 +1,205 @@
+package compose
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PortMapping is a single container port published (or not) on the host.
+// HostPort is 0 when the host side is left to Docker to pick.
+type PortMapping struct {
+	Service       string `json:"service"`
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+type longPort struct {
+	Target    string `yaml:"target"`
+	Published string `yaml:"published"`
+	HostIP    string `yaml:"host_ip"`
+	Protocol  string `yaml:"protocol"`
+	Mode      string `yaml:"mode"`
+}
+
+func parsePortNode(n *yaml.Node) ([]PortMapping, error) {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return ParsePortSpec(n.Value)
+	case yaml.MappingNode:
+		var lp longPort
+		if err := n.Decode(&lp); err != nil {
+			return nil, err
+		}
+		return parseLongPort(lp)
+	default:
+		return nil, fmt.Errorf("line %d: unsupported port entry", n.Line)
+	}
+}
+
+// ParsePortSpec parses the compose short port syntax:
+//
+//	[[HOST_IP:][HOST_PORT[-END]]:]CONTAINER_PORT[-END][/PROTOCOL]
+//
+// IPv6 host addresses may be written in brackets ("[::1]:8080:80"). Ranges
+// are expanded into one mapping per port. A host range paired with a single
+// container port lets Docker pick any port of the range; it is reported with
+// the first port of the range.
+func ParsePortSpec(spec string) ([]PortMapping, error) {
+	raw := spec
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty port spec")
+	}
+
+	protocol := "tcp"
+	if i := strings.LastIndex(spec, "/"); i != -1 {
+		protocol = strings.ToLower(spec[i+1:])
+		spec = spec[:i]
+	}
+	if err := validateProtocol(protocol); err != nil {
+		return nil, fmt.Errorf("%q: %w", raw, err)
+	}
+
+	var hostIP, hostPart, containerPart string
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("%q: unterminated IPv6 address", raw)
+		}
+		hostIP = spec[1:end]
+		rest := strings.TrimPrefix(spec[end+1:], ":")
+		parts := strings.Split(rest, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q: invalid port spec", raw)
+		}
+		hostPart, containerPart = parts[0], parts[1]
+	} else {
+		parts := strings.Split(spec, ":")
+		switch len(parts) {
+		case 1:
+			containerPart = parts[0]
+		case 2:
+			hostPart, containerPart = parts[0], parts[1]
+		default:
+			hostIP = strings.Join(parts[:len(parts)-2], ":")
+			hostPart, containerPart = parts[len(parts)-2], parts[len(parts)-1]
+		}
+	}
+
+	if hostIP != "" {
+		if _, err := netip.ParseAddr(hostIP); err != nil {
+			return nil, fmt.Errorf("%q: invalid host ip %q", raw, hostIP)
+		}
+	}
+
+	cStart, cEnd, err := parsePortRange(containerPart)
+	if err != nil {
+		return nil, fmt.Errorf("%q: container port: %w", raw, err)
+	}
+	var hStart, hEnd int
+	if hostPart != "" {
+		hStart, hEnd, err = parsePortRange(hostPart)
+		if err != nil {
+			return nil, fmt.Errorf("%q: host port: %w", raw, err)
+		}
+	}
+
+	return expandRange(hostIP, hStart, hEnd, cStart, cEnd, protocol, raw)
+}
+
+func parseLongPort(lp longPort) ([]PortMapping, error) {
+	protocol := strings.ToLower(strings.TrimSpace(lp.Protocol))
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if err := validateProtocol(protocol); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(lp.Target) == "" {
+		return nil, fmt.Errorf("target is required")
+	}
+	cStart, cEnd, err := parsePortRange(lp.Target)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	var hStart, hEnd int
+	if strings.TrimSpace(lp.Published) != "" {
+		hStart, hEnd, err = parsePortRange(lp.Published)
+		if err != nil {
+			return nil, fmt.Errorf("published: %w", err)
+		}
+	}
+	hostIP := strings.Trim(strings.TrimSpace(lp.HostIP), "[]")
+	if hostIP != "" {
+		if _, err := netip.ParseAddr(hostIP); err != nil {
+			return nil, fmt.Errorf("invalid host_ip %q", lp.HostIP)
+		}
+	}
+	return expandRange(hostIP, hStart, hEnd, cStart, cEnd, protocol, lp.Target)
+}
+
+func expandRange(hostIP string, hStart, hEnd, cStart, cEnd int, protocol, raw string) ([]PortMapping, error) {
+	cCount := cEnd - cStart + 1
+	hCount := hEnd - hStart + 1
+
+	var out []PortMapping
+	switch {
+	case hStart == 0:
+		for p := cStart; p <= cEnd; p++ {
+			out = append(out, PortMapping{HostIP: hostIP, ContainerPort: p, Protocol: protocol})
+		}
+	case cCount == 1:
+		out = append(out, PortMapping{HostIP: hostIP, HostPort: hStart, ContainerPort: cStart, Protocol: protocol})
+	case hCount == cCount:
+		for i := 0; i < cCount; i++ {
+			out = append(out, PortMapping{HostIP: hostIP, HostPort: hStart + i, ContainerPort: cStart + i, Protocol: protocol})
+		}
+	default:
+		return nil, fmt.Errorf("%q: host and container port ranges differ in size", raw)
+	}
+	return out, nil
+}
+
+func parsePortRange(s string) (start, end int, err error) {
+	s = strings.TrimSpace(s)
+	lo, hi, isRange := strings.Cut(s, "-")
+	start, err = parsePort(lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err = parsePort(hi)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	return start, end, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p <= 0 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+func validateProtocol(p string) error {
+	switch p {
+	case "tcp", "udp", "sctp":
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol %q", p)
+	}
+}
@@ -0,0 +1,240 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []PortMapping
+	}{
+		{"80", []PortMapping{{ContainerPort: 80, Protocol: "tcp"}}},
+		{"8080:80", []PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"53:53/udp", []PortMapping{{HostPort: 53, ContainerPort: 53, Protocol: "udp"}}},
+		{"127.0.0.1:8080:80", []PortMapping{{HostIP: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"127.0.0.1::80", []PortMapping{{HostIP: "127.0.0.1", ContainerPort: 80, Protocol: "tcp"}}},
+		{"[::1]:8080:80", []PortMapping{{HostIP: "::1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"::1:8080:80", []PortMapping{{HostIP: "::1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}},
+		{"3000-3001", []PortMapping{
+			{ContainerPort: 3000, Protocol: "tcp"},
+			{ContainerPort: 3001, Protocol: "tcp"},
+		}},
+		{"9090-9091:8080-8081", []PortMapping{
+			{HostPort: 9090, ContainerPort: 8080, Protocol: "tcp"},
+			{HostPort: 9091, ContainerPort: 8081, Protocol: "tcp"},
+		}},
+		{"8000-8010:80", []PortMapping{{HostPort: 8000, ContainerPort: 80, Protocol: "tcp"}}},
+		{"6060:6060/UDP", []PortMapping{{HostPort: 6060, ContainerPort: 6060, Protocol: "udp"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParsePortSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("ParsePortSpec(%q): %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParsePortSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePortSpec_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"abc",
+		"0:80",
+		"70000:80",
+		"8080:80/icmp",
+		"1.2.3:8080:80",
+		"9000-9002:80-81",
+		"81-80",
+		"[::1:8080:80",
+	} {
+		if _, err := ParsePortSpec(spec); err == nil {
+			t.Errorf("ParsePortSpec(%q): expected error", spec)
+		}
+	}
+}
+
+func TestParse_Ports(t *testing.T) {
+	content := `
+x-ports: &web-ports
+  - "8080:80"
+  - target: 443
+    published: "8443"
+    host_ip: 0.0.0.0
+    protocol: tcp
+    mode: host
+services:
+  web:
+    image: nginx
+    ports: *web-ports
+  api:
+    image: app
+    ports:
+      - "${API_PORT:-3000}:3000"
+      - target: 9100
+      - ${METRICS_HOST}:9101:9101
+  worker:
+    image: worker
+`
+	f, err := Parse([]byte(content), map[string]string{"METRICS_HOST": "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if got, want := f.ServiceNames(), []string{"web", "api", "worker"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ServiceNames() = %v, want %v", got, want)
+	}
+
+	want := []PortMapping{
+		{Service: "web", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+		{Service: "web", HostIP: "0.0.0.0", HostPort: 8443, ContainerPort: 443, Protocol: "tcp"},
+		{Service: "api", HostPort: 3000, ContainerPort: 3000, Protocol: "tcp"},
+		{Service: "api", ContainerPort: 9100, Protocol: "tcp"},
+		{Service: "api", HostIP: "127.0.0.1", HostPort: 9101, ContainerPort: 9101, Protocol: "tcp"},
+	}
+	if got := f.Ports(""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Ports(\"\") = %+v, want %+v", got, want)
+	}
+	if got := f.Ports("api, web"); len(got) != 5 || got[0].Service != "api" {
+		t.Fatalf("Ports(\"api, web\") = %+v", got)
+	}
+	if got := f.Ports("worker"); len(got) != 0 {
+		t.Fatalf("Ports(\"worker\") = %+v, want none", got)
+	}
+}
+
+func TestParse_MergeKeys(t *testing.T) {
+	content := `
+x-base: &base
+  image: app
+  ports:
+    - "5000:5000"
+services:
+  a:
+    <<: *base
+  b:
+    <<: *base
+    ports:
+      - "5001:5000"
+`
+	f, err := Parse([]byte(content), nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	a, _ := f.Service("a")
+	b, _ := f.Service("b")
+	if a.Image != "app" || len(a.Ports) != 1 || a.Ports[0].HostPort != 5000 {
+		t.Fatalf("service a = %+v", a)
+	}
+	if len(b.Ports) != 1 || b.Ports[0].HostPort != 5001 {
+		t.Fatalf("service b = %+v", b)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for name, content := range map[string]string{
+		"invalid yaml":     "services: [\n",
+		"services list":    "services:\n  - web\n",
+		"bad port":         "services:\n  web:\n    ports:\n      - \"x:80\"\n",
+		"required var":     "services:\n  web:\n    ports:\n      - \"${PORT:?port required}:80\"\n",
+		"long missing tgt": "services:\n  web:\n    ports:\n      - published: 80\n",
+	} {
+		if _, err := Parse([]byte(content), nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	content := `
+x-admin: &admin "${ADMIN_PORT:?}:81"
+services:
+  web:
+    image: ${IMAGE:?image required}
+    ports:
+      - "8080:80"
+      - *admin
+      - target: 443
+        published: ${HTTPS_PORT?}
+  worker:
+    image: worker
+    environment:
+      TOKEN: ${TOKEN:?}
+`
+	if _, err := Parse([]byte(content), nil); err == nil {
+		t.Fatalf("Parse: expected error for required variables")
+	}
+	f, err := ParseLenient([]byte(content), nil)
+	if err != nil {
+		t.Fatalf("ParseLenient: %v", err)
+	}
+	want := []PortMapping{{Service: "web", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}
+	if got := f.Ports(""); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Ports(\"\") = %+v, want %+v", got, want)
+	}
+	if svc, _ := f.Service("web"); svc.Image != "" {
+		t.Fatalf("unresolved image = %q, want empty", svc.Image)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"SET": "v", "EMPTY": ""}
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"$SET", "v"},
+		{"${SET}", "v"},
+		{"$$SET", "$SET"},
+		{"${UNSET:-d}", "d"},
+		{"${EMPTY:-d}", "d"},
+		{"${EMPTY-d}", ""},
+		{"${UNSET-d}", "d"},
+		{"${SET:+alt}", "alt"},
+		{"${EMPTY:+alt}", ""},
+		{"${EMPTY+alt}", "alt"},
+		{"${UNSET:-${SET}}", "v"},
+		{"a-${SET}-b", "a-v-b"},
+	}
+	for _, tt := range tests {
+		got, err := Interpolate(tt.in, env)
+		if err != nil {
+			t.Fatalf("Interpolate(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("Interpolate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"${UNSET?missing}", "${EMPTY:?missing}", "${SET", "${1X}"} {
+		if _, err := Interpolate(in, env); err == nil {
+			t.Errorf("Interpolate(%q): expected error", in)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	content := []byte(`
+services:
+  web:
+    image: "app:${TAG:-latest}"
+    environment:
+      DB_URL: postgres://$DB_USER:${DB_PASS:?required}@db/app
+      LITERAL: $$HOME
+      FALLBACK: ${PRIMARY:-${SECONDARY}}
+`)
+	got, err := Variables(content)
+	if err != nil {
+		t.Fatalf("Variables: %v", err)
+	}
+	want := []string{"DB_PASS", "DB_USER", "PRIMARY", "SECONDARY", "TAG"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Variables = %v, want %v", got, want)
+	}
+}
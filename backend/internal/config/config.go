@@ -3,19 +3,141 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Addr        string
 	DataDir     string
 	HostDataDir string
+
+	// APITokens, when not empty, are the bearer tokens accepted by the API;
+	// requests without one of them are rejected. Empty leaves the API open.
+	APITokens []string
+
+	// PortRangeStart/PortRangeEnd bound the host ports handed out when a
+	// project's preferred port is already taken.
+	PortRangeStart int
+	PortRangeEnd   int
+
+	// ProxyAddr enables the built-in reverse proxy when set. Projects are
+	// reachable at <project>.<BaseDomain> and at their custom domains.
+	ProxyAddr  string
+	BaseDomain string
+
+	// ProxyTLSAddr serves project domains over HTTPS. Certificates come from
+	// uploaded pairs first, then from the ACME directory (Let's Encrypt by
+	// default; point it at Pebble for local testing). ACMECAFile adds a CA
+	// bundle for talking to a directory with a private root.
+	ProxyTLSAddr  string
+	ACMEDirectory string
+	ACMEEmail     string
+	ACMECAFile    string
+
+	// Default resource limits for projects that leave a limit at zero; a
+	// project sets -1 to opt out of one. Zero here means unlimited;
+	// DefaultMemorySwapMB -1 allows unlimited swap. A default swap limit
+	// that does not fit the project's own memory limit is not applied.
+	DefaultMemoryMB     int64
+	DefaultMemorySwapMB int64
+	DefaultCPUShares    int64
+	DefaultCPUQuota     int64
+	DefaultPidsLimit    int64
+
+	// BackupRetention is how many volume backups are kept per project; older
+	// ones are deleted after each new backup. Zero keeps all of them.
+	BackupRetention int
+
+	// DBBackupInterval is how often the deployer's own database is copied to
+	// DBBackupsDir; zero disables scheduled copies. DBBackupRetention works
+	// like BackupRetention.
+	DBBackupInterval  time.Duration
+	DBBackupRetention int
+
+	// GitOpsRepo enables reconciling projects from the manifest at
+	// GitOpsPath on branch GitOpsRef every GitOpsInterval. Projects that
+	// leave the manifest are only deleted when GitOpsAllowDestructive is set.
+	// A manifest entry named like a project created outside the manifest is
+	// a conflict unless GitOpsAdopt lets the manifest take that project over.
+	GitOpsRepo             string
+	GitOpsRef              string
+	GitOpsPath             string
+	GitOpsInterval         time.Duration
+	GitOpsAllowDestructive bool
+	GitOpsAdopt            bool
+
+	// PreviewWebhookSecret enables pull request previews: GitHub webhooks
+	// signed with it create a copy of the template project for each open PR.
+	// Previews are deleted when the PR closes or PreviewTTL after the last
+	// push. PreviewCallbackURL, when set, receives a signed JSON POST with
+	// the preview URL whenever a preview is deployed or removed.
+	PreviewWebhookSecret string
+	PreviewTTL           time.Duration
+	PreviewCallbackURL   string
+
+	// Pull requests from forks run code from outside the repository, so they
+	// are only previewed when their author is one of PreviewForkUsers or
+	// they carry PreviewForkLabel, which takes write access to add. Fork
+	// previews never get the template's env values or git credential.
+	PreviewForkUsers []string
+	PreviewForkLabel string
+
+	// SecretKey is the base64 AES-256 key that encrypts stored git
+	// credentials. When empty the key is read from SecretKeyPath, which is
+	// created on first use and copied next to the database backups; the
+	// credentials in a backup cannot be decrypted without it.
+	SecretKey string
+
+	// GitCloneDepth limits the history fetched for project repositories;
+	// zero fetches all of it. Auto rollback fetches the commit that was
+	// deployed before by hash when a fresh clone no longer has it.
+	GitCloneDepth int
 }
 
 func Load() Config {
+	start, end := parsePortRange(getenv("LAST_DEPLOY_PORT_RANGE", "20000-29999"), 20000, 29999)
 	return Config{
-		Addr:        getenv("LAST_DEPLOY_ADDR", "127.0.0.1:8080"),
-		DataDir:     getenv("LAST_DEPLOY_DATA_DIR", "./data"),
-		HostDataDir: getenv("LAST_DEPLOY_HOST_DATA_DIR", ""),
+		Addr:           getenv("LAST_DEPLOY_ADDR", "127.0.0.1:8080"),
+		DataDir:        getenv("LAST_DEPLOY_DATA_DIR", "./data"),
+		HostDataDir:    getenv("LAST_DEPLOY_HOST_DATA_DIR", ""),
+		APITokens:      getenvList("LAST_DEPLOY_API_TOKENS"),
+		PortRangeStart: start,
+		PortRangeEnd:   end,
+		ProxyAddr:      getenv("LAST_DEPLOY_PROXY_ADDR", ""),
+		BaseDomain:     strings.ToLower(strings.Trim(getenv("LAST_DEPLOY_BASE_DOMAIN", ""), ".")),
+		ProxyTLSAddr:   getenv("LAST_DEPLOY_PROXY_TLS_ADDR", ""),
+		ACMEDirectory:  getenv("LAST_DEPLOY_ACME_DIRECTORY", "https://acme-v02.api.letsencrypt.org/directory"),
+		ACMEEmail:      getenv("LAST_DEPLOY_ACME_EMAIL", ""),
+		ACMECAFile:     getenv("LAST_DEPLOY_ACME_CA_FILE", ""),
+
+		DefaultMemoryMB:     getenvInt("LAST_DEPLOY_DEFAULT_MEMORY_MB", 0),
+		DefaultMemorySwapMB: getenvInt("LAST_DEPLOY_DEFAULT_MEMORY_SWAP_MB", 0),
+		DefaultCPUShares:    getenvInt("LAST_DEPLOY_DEFAULT_CPU_SHARES", 0),
+		DefaultCPUQuota:     getenvInt("LAST_DEPLOY_DEFAULT_CPU_QUOTA", 0),
+		DefaultPidsLimit:    getenvInt("LAST_DEPLOY_DEFAULT_PIDS_LIMIT", 4096),
+
+		BackupRetention: int(getenvInt("LAST_DEPLOY_BACKUP_RETENTION", 7)),
+
+		DBBackupInterval:  getenvDuration("LAST_DEPLOY_DB_BACKUP_INTERVAL", 24*time.Hour),
+		DBBackupRetention: int(getenvInt("LAST_DEPLOY_DB_BACKUP_RETENTION", 7)),
+
+		GitOpsRepo:             getenv("LAST_DEPLOY_GITOPS_REPO", ""),
+		GitOpsRef:              getenv("LAST_DEPLOY_GITOPS_REF", "main"),
+		GitOpsPath:             getenv("LAST_DEPLOY_GITOPS_PATH", "last-deploy.yaml"),
+		GitOpsInterval:         getenvDuration("LAST_DEPLOY_GITOPS_INTERVAL", time.Minute),
+		GitOpsAllowDestructive: getenvBool("LAST_DEPLOY_GITOPS_ALLOW_DESTRUCTIVE", false),
+		GitOpsAdopt:            getenvBool("LAST_DEPLOY_GITOPS_ADOPT", false),
+
+		PreviewWebhookSecret: getenv("LAST_DEPLOY_PREVIEW_WEBHOOK_SECRET", ""),
+		PreviewTTL:           getenvDuration("LAST_DEPLOY_PREVIEW_TTL", 72*time.Hour),
+		PreviewCallbackURL:   getenv("LAST_DEPLOY_PREVIEW_CALLBACK_URL", ""),
+		PreviewForkUsers:     getenvList("LAST_DEPLOY_PREVIEW_FORK_USERS"),
+		PreviewForkLabel:     getenv("LAST_DEPLOY_PREVIEW_FORK_LABEL", ""),
+
+		SecretKey:     getenv("LAST_DEPLOY_SECRET_KEY", ""),
+		GitCloneDepth: int(getenvInt("LAST_DEPLOY_GIT_CLONE_DEPTH", 1)),
 	}
 }
 
@@ -27,9 +149,84 @@ func (c Config) ReposDir() string {
 	return filepath.Join(c.DataDir, "repos")
 }
 
+func (c Config) SecretKeyPath() string {
+	return filepath.Join(c.DataDir, "secret.key")
+}
+
+func (c Config) CertsDir() string {
+	return filepath.Join(c.DataDir, "certs")
+}
+
+func (c Config) BackupsDir() string {
+	return filepath.Join(c.DataDir, "backups")
+}
+
+func (c Config) GitOpsDir() string {
+	return filepath.Join(c.DataDir, "gitops")
+}
+
+func (c Config) DBBackupsDir() string {
+	return filepath.Join(c.DataDir, "db-backups")
+}
+
 func getenv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+func getenvInt(key string, fallback int64) int64 {
+	v, err := strconv.ParseInt(strings.TrimSpace(os.Getenv(key)), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getenvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getenvDuration(key string, fallback time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback
+	}
+	if v == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return fallback
+	}
+	return d
+}
+
+func parsePortRange(v string, defStart, defEnd int) (int, int) {
+	lo, hi, ok := strings.Cut(strings.TrimSpace(v), "-")
+	if !ok {
+		return defStart, defEnd
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+	end, err2 := strconv.Atoi(strings.TrimSpace(hi))
+	if err1 != nil || err2 != nil || start <= 0 || end > 65535 || start > end {
+		return defStart, defEnd
+	}
+	return start, end
+}
+
+// getenvList splits a comma-separated variable, dropping empty entries.
+func getenvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
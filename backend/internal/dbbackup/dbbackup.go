@@ -0,0 +1,231 @@
+// Package dbbackup keeps rotating copies of the deployer's own database.
+package dbbackup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/store"
+)
+
+var nameRe = regexp.MustCompile(`^db-\d{8}-\d{6}\.\d{3}\.sqlite$`)
+
+// KeyName is the copy of the secret key kept next to the snapshots. Git
+// credentials in a snapshot can only be decrypted with the key that was in
+// use when it was taken.
+const KeyName = "secret.key"
+
+// ErrNotFound is returned by Path for names that are not a snapshot.
+var ErrNotFound = errors.New("not found")
+
+type Snapshot struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type Manager struct {
+	st        *store.Store
+	dir       string
+	keyPath   string
+	interval  time.Duration
+	retention int
+
+	mu sync.Mutex
+}
+
+func New(st *store.Store, cfg config.Config) *Manager {
+	// A key given in the environment is not on disk; whoever set it keeps it.
+	keyPath := cfg.SecretKeyPath()
+	if cfg.SecretKey != "" {
+		keyPath = ""
+	}
+	return &Manager{
+		st:        st,
+		dir:       cfg.DBBackupsDir(),
+		keyPath:   keyPath,
+		interval:  cfg.DBBackupInterval,
+		retention: cfg.DBBackupRetention,
+	}
+}
+
+// Run takes a snapshot every interval. The first one is taken right away
+// when the newest snapshot is already older than the interval, so restarts
+// do not postpone backups indefinitely.
+func (m *Manager) Run(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+	wait := time.Duration(0)
+	if list, err := m.List(); err == nil && len(list) > 0 {
+		age := time.Since(time.Unix(list[0].CreatedAt, 0))
+		if age < m.interval {
+			wait = m.interval - age
+		}
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if snap, err := m.Create(ctx); err != nil {
+			log.Printf("db backup: %v", err)
+		} else {
+			log.Printf("db backup: wrote %s (%d bytes)", snap.Name, snap.SizeBytes)
+		}
+		t.Reset(m.interval)
+	}
+}
+
+// Create writes a new snapshot and removes the ones beyond the retention.
+func (m *Manager) Create(ctx context.Context) (Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return Snapshot{}, err
+	}
+	if err := m.copyKey(); err != nil {
+		return Snapshot{}, fmt.Errorf("copy secret key: %w", err)
+	}
+	now := time.Now().UTC()
+	name, path := m.snapshotPath(now)
+	for {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		now = now.Add(time.Millisecond)
+		name, path = m.snapshotPath(now)
+	}
+	tmp := filepath.Join(m.dir, "."+name+".tmp")
+	_ = os.Remove(tmp)
+
+	// VACUUM INTO writes the file with the default umask; the copy holds
+	// every project secret, so keep it private.
+	if err := m.st.BackupTo(ctx, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return Snapshot{}, err
+	}
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return Snapshot{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return Snapshot{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	m.prune()
+	return Snapshot{Name: name, SizeBytes: info.Size(), CreatedAt: now.Unix()}, nil
+}
+
+// List returns the snapshots, newest first.
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Snapshot
+	for _, e := range entries {
+		if e.IsDir() || !nameRe.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Snapshot{Name: e.Name(), SizeBytes: info.Size(), CreatedAt: createdAt(e.Name(), info)})
+	}
+	// Names embed the UTC time, so they sort chronologically.
+	sort.Slice(out, func(i, j int) bool { return out[i].Name > out[j].Name })
+	return out, nil
+}
+
+// Path returns the file of the named snapshot.
+func (m *Manager) Path(name string) (string, error) {
+	if !nameRe.MatchString(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// copyKey keeps KeyName in the snapshot directory up to date with the
+// secret key file.
+func (m *Manager) copyKey() error {
+	if m.keyPath == "" {
+		return nil
+	}
+	key, err := os.ReadFile(m.keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(m.dir, KeyName)
+	if cur, err := os.ReadFile(dst); err == nil && bytes.Equal(cur, key) {
+		return nil
+	}
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, key, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (m *Manager) snapshotPath(t time.Time) (string, string) {
+	name := "db-" + t.Format("20060102-150405.000") + ".sqlite"
+	return name, filepath.Join(m.dir, name)
+}
+
+func (m *Manager) prune() {
+	if m.retention <= 0 {
+		return
+	}
+	list, err := m.List()
+	if err != nil || len(list) <= m.retention {
+		return
+	}
+	for _, s := range list[m.retention:] {
+		if err := os.Remove(filepath.Join(m.dir, s.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("db backup: remove %s: %v", s.Name, err)
+		}
+	}
+}
+
+func createdAt(name string, info os.FileInfo) int64 {
+	ts := name[len("db-") : len(name)-len(".sqlite")]
+	if t, err := time.Parse("20060102-150405.000", ts); err == nil {
+		return t.Unix()
+	}
+	return info.ModTime().Unix()
+}
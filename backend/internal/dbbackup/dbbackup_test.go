@@ -0,0 +1,83 @@
+package dbbackup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/store"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Config{DataDir: t.TempDir(), DBBackupRetention: 2}
+
+	st, err := store.Open(ctx, cfg.DBPath())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := st.CreateProject(ctx, store.Project{ID: "a", Name: "a", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	if err := os.WriteFile(cfg.SecretKeyPath(), []byte("key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(st, cfg)
+	var names []string
+	for range 3 {
+		snap, err := m.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		names = append(names, snap.Name)
+	}
+	list, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != names[2] || list[1].Name != names[1] {
+		t.Fatalf("List = %+v, want the two newest of %v", list, names)
+	}
+	if key, err := os.ReadFile(filepath.Join(cfg.DBBackupsDir(), KeyName)); err != nil || string(key) != "key\n" {
+		t.Errorf("key copy = %q, %v", key, err)
+	}
+	if _, err := m.Path(names[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Path(pruned) = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Path("../db.sqlite"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Path(../db.sqlite) = %v, want ErrNotFound", err)
+	}
+	snapPath, err := m.Path(names[2])
+	if err != nil {
+		t.Fatalf("Path: %v", err)
+	}
+
+	// Changes after the snapshot are gone once it is restored.
+	if _, err := st.CreateProject(ctx, store.Project{ID: "b", Name: "b", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	_ = st.Close()
+
+	if err := store.RestoreFile(ctx, filepath.Join(cfg.DataDir, "missing.sqlite"), cfg.DBPath()); err == nil {
+		t.Fatal("RestoreFile(missing) succeeded")
+	}
+	if err := store.RestoreFile(ctx, snapPath, cfg.DBPath()); err != nil {
+		t.Fatalf("RestoreFile: %v", err)
+	}
+	st, err = store.Open(ctx, cfg.DBPath())
+	if err != nil {
+		t.Fatalf("Open restored: %v", err)
+	}
+	defer st.Close()
+	if _, err := st.GetProject(ctx, "a"); err != nil {
+		t.Errorf("GetProject(a) = %v, want the project", err)
+	}
+	if _, err := st.GetProject(ctx, "b"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetProject(b) = %v, want ErrNotFound", err)
+	}
+}
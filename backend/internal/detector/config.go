@@ -0,0 +1,106 @@
+package detector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfig 是应用仓库中可选的 last-deploy.yaml，由应用团队声明部署方式。
+// 所有字段都可省略，字段名与项目导出文件一致
+type RepoConfig struct {
+	DeployType     string           `json:"deploy_type,omitempty" yaml:"deploy_type"`
+	DockerfilePath string           `json:"dockerfile_path,omitempty" yaml:"dockerfile_path"`
+	ComposeFile    string           `json:"compose_file,omitempty" yaml:"compose_file"`
+	ComposeService string           `json:"compose_service,omitempty" yaml:"compose_service"`
+	Ports          []RepoPort       `json:"ports,omitempty" yaml:"ports"`
+	HealthCheck    *RepoHealthCheck `json:"health_check,omitempty" yaml:"health_check"`
+	// Env 只列出变量名，值由部署方提供
+	Env       []string          `json:"env,omitempty" yaml:"env"`
+	BuildArgs map[string]string `json:"build_args,omitempty" yaml:"build_args"`
+}
+
+// RepoPort 是 last-deploy.yaml 中声明的端口映射
+type RepoPort struct {
+	Service       string `json:"service,omitempty" yaml:"service"`
+	HostIP        string `json:"host_ip,omitempty" yaml:"host_ip"`
+	HostPort      int    `json:"host_port,omitempty" yaml:"host_port"`
+	ContainerPort int    `json:"container_port" yaml:"container_port"`
+	Protocol      string `json:"protocol,omitempty" yaml:"protocol"`
+}
+
+// RepoHealthCheck 是 last-deploy.yaml 中声明的健康检查
+type RepoHealthCheck struct {
+	Type           string `json:"type" yaml:"type"`
+	Path           string `json:"path,omitempty" yaml:"path"`
+	ExpectedStatus int    `json:"expected_status,omitempty" yaml:"expected_status"`
+	Service        string `json:"service,omitempty" yaml:"service"`
+	Port           int    `json:"port,omitempty" yaml:"port"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" yaml:"timeout_seconds"`
+}
+
+var repoConfigCandidates = []string{
+	"last-deploy.yaml",
+	"last-deploy.yml",
+}
+
+var varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseRepoConfig 解析 last-deploy.yaml，未知字段视为错误，避免拼写错误被静默忽略
+func ParseRepoConfig(data []byte) (*RepoConfig, error) {
+	var cfg RepoConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	switch cfg.DeployType {
+	case "", "auto", "dockerfile", "compose":
+	default:
+		return nil, fmt.Errorf("invalid deploy_type: %s", cfg.DeployType)
+	}
+	for _, p := range []struct{ key, path string }{
+		{"dockerfile_path", cfg.DockerfilePath},
+		{"compose_file", cfg.ComposeFile},
+	} {
+		if p.path != "" && !filepath.IsLocal(filepath.FromSlash(p.path)) {
+			return nil, fmt.Errorf("%s must be a relative path inside the repository", p.key)
+		}
+	}
+	for _, name := range cfg.Env {
+		if !varNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid env name: %s", name)
+		}
+	}
+	for name := range cfg.BuildArgs {
+		if !varNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid build_args name: %s", name)
+		}
+	}
+	return &cfg, nil
+}
+
+// readRepoConfig 读取仓库根目录的 last-deploy.yaml；不存在时返回 nil
+func readRepoConfig(repoDir string) (cfg *RepoConfig, rel, content string, _ error) {
+	for _, rel := range repoConfigCandidates {
+		content, ok, err := readFileIfExists(filepath.Join(repoDir, rel))
+		if err != nil {
+			return nil, "", "", fmt.Errorf("read %s: %w", rel, err)
+		}
+		if !ok {
+			continue
+		}
+		cfg, err := ParseRepoConfig([]byte(content))
+		if err != nil {
+			return nil, "", "", fmt.Errorf("parse %s: %w", rel, err)
+		}
+		return cfg, rel, content, nil
+	}
+	return nil, "", "", nil
+}
@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 type DetectResult struct {
-	DeployType        string   // "compose" | "dockerfile" | "none"
-	DockerfilePath    string   // Dockerfile 路径（相对 repoDir）
-	DockerfileContent string   // Dockerfile 内容
-	ComposePath       string   // compose 文件路径（相对 repoDir）
-	ComposeContent    string   // compose 文件内容
-	Services          []string // compose 项目的 service 列表
+	DeployType        string      // "compose" | "dockerfile" | "none"
+	DockerfilePath    string      // Dockerfile 路径（相对 repoDir）
+	DockerfileContent string      // Dockerfile 内容
+	ComposePath       string      // compose 文件路径（相对 repoDir）
+	ComposeContent    string      // compose 文件内容
+	Services          []string    // compose 项目的 service 列表
+	ConfigPath        string      // last-deploy.yaml 路径（相对 repoDir），没有时为空
+	ConfigContent     string      // last-deploy.yaml 原始内容
+	Config            *RepoConfig // 解析后的 last-deploy.yaml，没有时为 nil
 }
 
 const defaultDockerfileTemplate = `FROM alpine:3.20
@@ -36,6 +40,15 @@ func Detect(repoDir string) (*DetectResult, error) {
 		return nil, fmt.Errorf("repo dir is required")
 	}
 
+	repoConfig, configPath, configContent, err := readRepoConfig(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg := repoConfig
+	if cfg == nil {
+		cfg = &RepoConfig{}
+	}
+
 	var composePath, composeContent string
 	var services []string
 
@@ -45,12 +58,19 @@ func Detect(repoDir string) (*DetectResult, error) {
 		"compose.yml",
 		"compose.yaml",
 	}
+	if cfg.ComposeFile != "" {
+		// last-deploy.yaml 指定了 compose 文件时只使用该文件
+		composeCandidates = []string{cfg.ComposeFile}
+	}
 	for _, rel := range composeCandidates {
 		content, ok, err := readFileIfExists(filepath.Join(repoDir, filepath.FromSlash(rel)))
 		if err != nil {
 			return nil, fmt.Errorf("read %s: %w", rel, err)
 		}
 		if !ok {
+			if cfg.ComposeFile != "" {
+				return nil, fmt.Errorf("%s: compose_file %s not found", configPath, rel)
+			}
 			continue
 		}
 		composePath = rel
@@ -64,6 +84,9 @@ func Detect(repoDir string) (*DetectResult, error) {
 
 	var dockerfilePath, dockerfileContent string
 	dockerfileRel := "Dockerfile"
+	if cfg.DockerfilePath != "" {
+		dockerfileRel = cfg.DockerfilePath
+	}
 	content, ok, err := readFileIfExists(filepath.Join(repoDir, filepath.FromSlash(dockerfileRel)))
 	if err != nil {
 		return nil, fmt.Errorf("read %s: %w", dockerfileRel, err)
@@ -71,10 +94,27 @@ func Detect(repoDir string) (*DetectResult, error) {
 	if ok {
 		dockerfilePath = dockerfileRel
 		dockerfileContent = content
+	} else if cfg.DockerfilePath != "" {
+		return nil, fmt.Errorf("%s: dockerfile_path %s not found", configPath, dockerfileRel)
+	}
+
+	if cfg.ComposeService != "" && composePath != "" && !slices.Contains(services, cfg.ComposeService) {
+		return nil, fmt.Errorf("%s: compose_service %s is not defined in %s", configPath, cfg.ComposeService, composePath)
 	}
 
 	var deployType string
-	if composePath != "" {
+	if cfg.DeployType == "dockerfile" {
+		// last-deploy.yaml 声明的部署类型优先于自动识别
+		deployType = "dockerfile"
+		if dockerfileContent == "" {
+			dockerfileContent = defaultDockerfileTemplate
+		}
+		composeContent = defaultComposeTemplate
+		composePath = ""
+		services = nil
+	} else if cfg.DeployType == "compose" && composePath == "" {
+		return nil, fmt.Errorf("%s: deploy_type is compose but no compose file was found", configPath)
+	} else if composePath != "" {
 		deployType = "compose"
 		// compose 类型如果没有 Dockerfile，也提供默认模板
 		if dockerfileContent == "" {
@@ -96,6 +136,9 @@ func Detect(repoDir string) (*DetectResult, error) {
 		ComposePath:       composePath,
 		ComposeContent:    composeContent,
 		Services:          services,
+		ConfigPath:        configPath,
+		ConfigContent:     configContent,
+		Config:            repoConfig,
 	}, nil
 }
 
@@ -109,4 +152,3 @@ func readFileIfExists(path string) (content string, ok bool, _ error) {
 	}
 	return "", false, err
 }
-
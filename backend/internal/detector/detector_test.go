@@ -3,6 +3,7 @@ package detector
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -117,3 +118,98 @@ func TestDetect_ComposeParseError(t *testing.T) {
 	}
 }
 
+func TestDetect_ComposeUnsetVariables(t *testing.T) {
+	dir := t.TempDir()
+
+	compose := "services:\n  web:\n    image: app\n    ports:\n      - \"${WEB_PORT}:80\"\n    environment:\n      DB: ${DATABASE_URL:?set DATABASE_URL}\n  db:\n    image: postgres\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(compose), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if want := []string{"db", "web"}; !slices.Equal(got.Services, want) {
+		t.Fatalf("Services = %v, want %v", got.Services, want)
+	}
+}
+
+func TestDetect_RepoConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"docker-compose.yml":    "services:\n  web: {}\n",
+		"docker/app.Dockerfile": "FROM golang:1.22\n",
+		"last-deploy.yaml": `deploy_type: dockerfile
+dockerfile_path: docker/app.Dockerfile
+ports:
+  - container_port: 8080
+health_check:
+  type: http
+  path: /healthz
+env: [DATABASE_URL]
+build_args:
+  GO_VERSION: "1.22"
+`,
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Detect(dir)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if got.DeployType != "dockerfile" {
+		t.Fatalf("DeployType = %q, want %q", got.DeployType, "dockerfile")
+	}
+	if got.DockerfilePath != "docker/app.Dockerfile" || got.DockerfileContent != "FROM golang:1.22\n" {
+		t.Fatalf("Dockerfile = %q %q", got.DockerfilePath, got.DockerfileContent)
+	}
+	if got.ComposePath != "" || got.Services != nil {
+		t.Fatalf("compose = %q %#v, want none", got.ComposePath, got.Services)
+	}
+	if got.ConfigPath != "last-deploy.yaml" || got.ConfigContent != files["last-deploy.yaml"] {
+		t.Fatalf("ConfigPath = %q", got.ConfigPath)
+	}
+	cfg := got.Config
+	if cfg == nil || len(cfg.Ports) != 1 || cfg.Ports[0].ContainerPort != 8080 || cfg.HealthCheck == nil ||
+		cfg.HealthCheck.Path != "/healthz" || len(cfg.Env) != 1 || cfg.BuildArgs["GO_VERSION"] != "1.22" {
+		t.Fatalf("Config = %+v", cfg)
+	}
+}
+
+func TestDetect_RepoConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"unknown field", "deploy: compose\n"},
+		{"invalid deploy_type", "deploy_type: helm\n"},
+		{"path outside repo", "dockerfile_path: ../Dockerfile\n"},
+		{"missing dockerfile", "dockerfile_path: build/Dockerfile\n"},
+		{"undefined service", "compose_service: api\n"},
+		{"invalid env name", "env: [\"1BAD\"]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("services:\n  web: {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "last-deploy.yaml"), []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Detect(dir); err == nil {
+				t.Fatalf("Detect: expected error")
+			}
+		})
+	}
+}
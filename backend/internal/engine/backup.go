@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/client"
+)
+
+// RoleBackup marks the helper container that mounts volumes for backup and
+// restore. It is created but never started.
+const RoleBackup = "backup"
+
+// backupMountRoot is where the helper container mounts each volume, under a
+// directory named after the volume. Archives use the same layout.
+const backupMountRoot = "/last-deploy-backup"
+
+// HelperImage picks an image already present for the project to create the
+// backup helper container from, so nothing has to be pulled. The container
+// never runs, so the image does not need a shell.
+func (d *Docker) HelperImage(ctx context.Context, projectID string) (string, error) {
+	images, err := d.ProjectImages(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	if len(images) > 0 {
+		return images[0].ID, nil
+	}
+	if _, err := d.cli.ImageInspect(ctx, imageTag(projectID)); err == nil {
+		return imageTag(projectID), nil
+	}
+	return "", fmt.Errorf("no image available for project %s, deploy it first", projectID)
+}
+
+// BackupVolumes writes a gzip-compressed tarball holding one top-level
+// directory per volume.
+func (d *Docker) BackupVolumes(ctx context.Context, projectID, image string, volumes []string, w io.Writer) error {
+	helper, err := d.createBackupHelper(ctx, projectID, image, volumes)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.RemoveContainer(context.WithoutCancel(ctx), helper) }()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, v := range volumes {
+		res, err := d.cli.CopyFromContainer(ctx, helper, client.CopyFromContainerOptions{SourcePath: path.Join(backupMountRoot, v)})
+		if err != nil {
+			return fmt.Errorf("read volume %s: %w", v, err)
+		}
+		err = copyTarEntries(tw, tar.NewReader(res.Content))
+		_ = res.Content.Close()
+		if err != nil {
+			return fmt.Errorf("archive volume %s: %w", v, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// RestoreVolumes replaces the content of volumes with a tarball written by
+// BackupVolumes. Containers using the volumes must be removed first: every
+// volume is recreated empty, keeping its driver, options and labels.
+func (d *Docker) RestoreVolumes(ctx context.Context, projectID, image string, volumes []string, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer gz.Close()
+
+	for _, v := range volumes {
+		opts := client.VolumeCreateOptions{
+			Name:   v,
+			Labels: map[string]string{ProjectIDLabelKey: projectID},
+		}
+		info, err := d.cli.VolumeInspect(ctx, v, client.VolumeInspectOptions{})
+		switch {
+		case err == nil:
+			opts.Driver = info.Volume.Driver
+			opts.DriverOpts = info.Volume.Options
+			opts.Labels = info.Volume.Labels
+			if _, err := d.cli.VolumeRemove(ctx, v, client.VolumeRemoveOptions{}); err != nil {
+				return fmt.Errorf("remove volume %s: %w", v, err)
+			}
+		case !cerrdefs.IsNotFound(err):
+			return err
+		}
+		if _, err := d.cli.VolumeCreate(ctx, opts); err != nil {
+			return fmt.Errorf("create volume %s: %w", v, err)
+		}
+	}
+
+	helper, err := d.createBackupHelper(ctx, projectID, image, volumes)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.RemoveContainer(context.WithoutCancel(ctx), helper) }()
+
+	_, err = d.cli.CopyToContainer(ctx, helper, client.CopyToContainerOptions{
+		DestinationPath: backupMountRoot,
+		Content:         gz,
+		CopyUIDGID:      true,
+	})
+	return err
+}
+
+func (d *Docker) createBackupHelper(ctx context.Context, projectID, image string, volumes []string) (string, error) {
+	if len(volumes) == 0 {
+		return "", errors.New("no volumes")
+	}
+	mounts := make([]mount.Mount, 0, len(volumes))
+	for _, v := range volumes {
+		mounts = append(mounts, mount.Mount{Type: mount.TypeVolume, Source: v, Target: path.Join(backupMountRoot, v)})
+	}
+	name := containerName(projectID) + "-backup"
+	_ = d.RemoveContainer(ctx, name)
+	created, err := d.cli.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Config: &container.Config{
+			Image:      image,
+			Entrypoint: []string{"/bin/true"},
+			Labels:     map[string]string{RoleLabelKey: RoleBackup},
+		},
+		HostConfig: &container.HostConfig{Mounts: mounts},
+		Name:       name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create backup helper: %w", err)
+	}
+	return created.ID, nil
+}
+
+func copyTarEntries(tw *tar.Writer, tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"last-deploy/internal/compose"
 )
 
 type DeployType string
@@ -37,11 +39,24 @@ type ComposeSpec struct {
 	HostWorkDir    string
 	ComposeFile    string
 	ComposeService string
+	// BindIP replaces the host address of published ports that do not name
+	// one in the compose file. NoPublish drops all published ports.
+	BindIP    string
+	NoPublish bool
+	// Resources is applied to every service, replacing limits set in the
+	// compose file.
+	Resources Resources
+	// Env holds NAME=value pairs added to the environment of docker compose,
+	// where they override the .env file.
+	Env []string
 }
 
 var composeServiceRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
 
 func ComposeUp(ctx context.Context, spec ComposeSpec) error {
+	if err := spec.Resources.Validate(); err != nil {
+		return err
+	}
 	return runComposeUpStop(ctx, spec, "up", "-d")
 }
 
@@ -78,6 +93,7 @@ func ComposeDown(ctx context.Context, spec ComposeSpec) error {
 
 	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
 	cmd.Dir = spec.WorkDir
+	cmd.Env = append(os.Environ(), spec.Env...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker %s: %w: %s", strings.Join(cmdArgs, " "), err, strings.TrimSpace(string(out)))
@@ -126,8 +142,8 @@ func runComposeUpStop(ctx context.Context, spec ComposeSpec, args ...string) err
 	projectName := "last-deploy-" + spec.ProjectID
 	cmdArgs := []string{"compose", "-p", projectName, "-f", composeFile}
 
-	if len(services) > 0 {
-		override, err := writeComposeOverride(spec.ProjectID, services)
+	if len(services) > 0 || spec.BindIP != "" || spec.NoPublish || !spec.Resources.IsZero() {
+		override, err := writeComposeOverride(spec, composeFile, services)
 		if err != nil {
 			return err
 		}
@@ -140,6 +156,7 @@ func runComposeUpStop(ctx context.Context, spec ComposeSpec, args ...string) err
 
 	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
 	cmd.Dir = spec.WorkDir
+	cmd.Env = append(os.Environ(), spec.Env...)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -148,7 +165,19 @@ func runComposeUpStop(ctx context.Context, spec ComposeSpec, args ...string) err
 	return nil
 }
 
-func writeComposeOverride(projectID string, services []string) (string, error) {
+func writeComposeOverride(spec ComposeSpec, composeFile string, services []string) (string, error) {
+	var parsed *compose.File
+	if spec.BindIP != "" || spec.NoPublish || !spec.Resources.IsZero() {
+		content, err := os.ReadFile(composeFile)
+		if err != nil {
+			return "", err
+		}
+		parsed, err = compose.Parse(content, composeEnv(filepath.Dir(composeFile), spec.Env))
+		if err != nil {
+			return "", fmt.Errorf("parse %s: %w", filepath.Base(composeFile), err)
+		}
+	}
+
 	f, err := os.CreateTemp("", "last-deploy-compose-*.yml")
 	if err != nil {
 		return "", err
@@ -157,17 +186,139 @@ func writeComposeOverride(projectID string, services []string) (string, error) {
 		_ = f.Close()
 	}()
 
+	if _, err := f.WriteString(buildComposeOverride(spec, parsed, services)); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func buildComposeOverride(spec ComposeSpec, parsed *compose.File, services []string) string {
+	labelled := make(map[string]bool, len(services))
+	for _, svc := range services {
+		labelled[svc] = true
+	}
+
+	// Services in file order, followed by selected services not found in it.
+	var names []string
+	if parsed != nil {
+		names = parsed.ServiceNames()
+	}
+	for _, svc := range services {
+		if parsed == nil {
+			names = append(names, svc)
+		} else if _, ok := parsed.Service(svc); !ok {
+			names = append(names, svc)
+		}
+	}
+
 	var sb strings.Builder
 	sb.WriteString("services:\n")
-	for _, svc := range services {
-		sb.WriteString(fmt.Sprintf("  %s:\n    labels:\n      %s: %q\n", svc, ProjectIDLabelKey, projectID))
+	for _, name := range names {
+		var ports []compose.PortMapping
+		var limits map[string]bool
+		if parsed != nil {
+			svc, _ := parsed.Service(name)
+			ports, limits = svc.Ports, svc.Limits
+		}
+		resources := composeResources(spec.Resources, limits)
+		if !labelled[name] && len(ports) == 0 && resources == "" {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("  %s:\n", name))
+		if labelled[name] {
+			sb.WriteString(fmt.Sprintf("    labels:\n      %s: %q\n", ProjectIDLabelKey, spec.ProjectID))
+		}
+		sb.WriteString(resources)
+		if len(ports) == 0 {
+			continue
+		}
+		if spec.NoPublish {
+			// Keep the ports reachable on the compose network only.
+			sb.WriteString("    ports: !reset []\n    expose:\n")
+			for _, p := range ports {
+				sb.WriteString(fmt.Sprintf("      - \"%d/%s\"\n", p.ContainerPort, p.Protocol))
+			}
+			continue
+		}
+		sb.WriteString("    ports: !override\n")
+		for _, p := range ports {
+			hostIP := p.HostIP
+			if hostIP == "" {
+				hostIP = spec.BindIP
+			}
+			sb.WriteString(fmt.Sprintf("      - target: %d\n", p.ContainerPort))
+			if p.HostPort > 0 {
+				sb.WriteString(fmt.Sprintf("        published: \"%d\"\n", p.HostPort))
+			}
+			if hostIP != "" {
+				sb.WriteString(fmt.Sprintf("        host_ip: %q\n", hostIP))
+			}
+			sb.WriteString(fmt.Sprintf("        protocol: %s\n", p.Protocol))
+		}
 	}
+	return sb.String()
+}
 
-	if _, err := f.WriteString(sb.String()); err != nil {
-		_ = os.Remove(f.Name())
-		return "", err
+// composeResources renders the service attributes for r, leaving out the
+// limits the service already sets; docker compose rejects a mem_limit next to
+// a different deploy.resources.limits.memory, for example. The memory limits
+// are kept or dropped together so swap is never checked against another
+// memory limit than its own.
+func composeResources(r Resources, set map[string]bool) string {
+	var sb strings.Builder
+	memory := !set["mem_limit"] && !set["memswap_limit"]
+	if memory && r.MemoryMB > 0 {
+		sb.WriteString(fmt.Sprintf("    mem_limit: %dm\n", r.MemoryMB))
 	}
-	return f.Name(), nil
+	switch {
+	case !memory:
+	case r.MemorySwapMB > 0:
+		sb.WriteString(fmt.Sprintf("    memswap_limit: %dm\n", r.MemorySwapMB))
+	case r.MemorySwapMB < 0:
+		sb.WriteString("    memswap_limit: -1\n")
+	}
+	if !set["cpu_shares"] && r.CPUShares > 0 {
+		sb.WriteString(fmt.Sprintf("    cpu_shares: %d\n", r.CPUShares))
+	}
+	if !set["cpu_quota"] && r.CPUQuota > 0 {
+		sb.WriteString(fmt.Sprintf("    cpu_quota: %d\n    cpu_period: %d\n", r.CPUQuota, CPUPeriod))
+	}
+	if !set["pids_limit"] && r.PidsLimit > 0 {
+		sb.WriteString(fmt.Sprintf("    pids_limit: %d\n", r.PidsLimit))
+	}
+	return sb.String()
+}
+
+// composeEnv mirrors the variables docker compose sees when interpolating:
+// the project's .env file, overridden by the process environment and then
+// by extra.
+func composeEnv(dir string, extra []string) map[string]string {
+	env := make(map[string]string)
+	if b, err := os.ReadFile(filepath.Join(dir, ".env")); err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+			if !ok {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+				v = v[1 : len(v)-1]
+			}
+			env[strings.TrimSpace(k)] = v
+		}
+	}
+	for _, kv := range append(os.Environ(), extra...) {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
 }
 
 // normalizeComposeFile strips any repo path prefix from the compose file path.
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"testing"
+
+	"last-deploy/internal/compose"
+)
+
+const overrideTestCompose = `services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+      - "127.0.0.1:9100:9100"
+  worker:
+    image: worker
+  dns:
+    image: dns
+    ports:
+      - "53:53/udp"
+`
+
+func TestBuildComposeOverride_BindIP(t *testing.T) {
+	parsed, err := compose.Parse([]byte(overrideTestCompose), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buildComposeOverride(ComposeSpec{ProjectID: "p1", BindIP: "0.0.0.0"}, parsed, []string{"web"})
+	want := `services:
+  web:
+    labels:
+      com.last-deploy.project_id: "p1"
+    ports: !override
+      - target: 80
+        published: "8080"
+        host_ip: "0.0.0.0"
+        protocol: tcp
+      - target: 9100
+        published: "9100"
+        host_ip: "127.0.0.1"
+        protocol: tcp
+  dns:
+    ports: !override
+      - target: 53
+        published: "53"
+        host_ip: "0.0.0.0"
+        protocol: udp
+`
+	if got != want {
+		t.Fatalf("override =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildComposeOverride_NoPublish(t *testing.T) {
+	parsed, err := compose.Parse([]byte(overrideTestCompose), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buildComposeOverride(ComposeSpec{ProjectID: "p1", NoPublish: true}, parsed, nil)
+	want := `services:
+  web:
+    ports: !reset []
+    expose:
+      - "80/tcp"
+      - "9100/tcp"
+  dns:
+    ports: !reset []
+    expose:
+      - "53/udp"
+`
+	if got != want {
+		t.Fatalf("override =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildComposeOverride_LabelsOnly(t *testing.T) {
+	got := buildComposeOverride(ComposeSpec{ProjectID: "p1"}, nil, []string{"a", "b"})
+	want := `services:
+  a:
+    labels:
+      com.last-deploy.project_id: "p1"
+  b:
+    labels:
+      com.last-deploy.project_id: "p1"
+`
+	if got != want {
+		t.Fatalf("override =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildComposeOverride_Resources(t *testing.T) {
+	parsed, err := compose.Parse([]byte(overrideTestCompose), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := ComposeSpec{
+		ProjectID: "p1",
+		NoPublish: true,
+		Resources: Resources{MemoryMB: 512, MemorySwapMB: -1, CPUQuota: 50000, PidsLimit: 256},
+	}
+	got := buildComposeOverride(spec, parsed, nil)
+	want := `services:
+  web:
+    mem_limit: 512m
+    memswap_limit: -1
+    cpu_quota: 50000
+    cpu_period: 100000
+    pids_limit: 256
+    ports: !reset []
+    expose:
+      - "80/tcp"
+      - "9100/tcp"
+  worker:
+    mem_limit: 512m
+    memswap_limit: -1
+    cpu_quota: 50000
+    cpu_period: 100000
+    pids_limit: 256
+  dns:
+    mem_limit: 512m
+    memswap_limit: -1
+    cpu_quota: 50000
+    cpu_period: 100000
+    pids_limit: 256
+    ports: !reset []
+    expose:
+      - "53/udp"
+`
+	if got != want {
+		t.Fatalf("override =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBuildComposeOverride_ResourcesSetInCompose(t *testing.T) {
+	parsed, err := compose.Parse([]byte(`services:
+  web:
+    image: nginx
+    deploy:
+      resources:
+        limits:
+          memory: 1g
+          cpus: "0.5"
+  worker:
+    image: worker
+    pids_limit: 100
+  cron:
+    image: cron
+    mem_limit: 256m
+    cpu_shares: 512
+    cpus: 1
+    pids_limit: 10
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := ComposeSpec{
+		ProjectID: "p1",
+		Resources: Resources{MemoryMB: 512, MemorySwapMB: 1024, CPUShares: 256, CPUQuota: 50000, PidsLimit: 256},
+	}
+	got := buildComposeOverride(spec, parsed, nil)
+	want := `services:
+  web:
+    cpu_shares: 256
+    pids_limit: 256
+  worker:
+    mem_limit: 512m
+    memswap_limit: 1024m
+    cpu_shares: 256
+    cpu_quota: 50000
+    cpu_period: 100000
+`
+	if got != want {
+		t.Fatalf("override =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResourcesValidate(t *testing.T) {
+	tests := []struct {
+		r  Resources
+		ok bool
+	}{
+		{Resources{}, true},
+		{Resources{MemoryMB: 512, MemorySwapMB: 1024}, true},
+		{Resources{MemoryMB: 512, MemorySwapMB: -1}, true},
+		{Resources{MemoryMB: -1, PidsLimit: -1}, true},
+		{Resources{MemoryMB: 1024, MemorySwapMB: 512}, false},
+		{Resources{MemoryMB: -1, MemorySwapMB: 512}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.r.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.r, err, tt.ok)
+		}
+	}
+}
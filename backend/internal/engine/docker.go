@@ -2,24 +2,44 @@ package engine
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/pkg/stdcopy"
 	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
 	"github.com/moby/moby/api/types/network"
 	"github.com/moby/moby/client"
 )
 
 const ProjectIDLabelKey = "com.last-deploy.project_id"
 
+// RoleLabelKey marks the temporary containers of a blue/green deploy, which
+// must not receive traffic through regular routing.
+const (
+	RoleLabelKey  = "com.last-deploy.role"
+	RoleCandidate = "candidate"
+)
+
+// SharedNetwork is the bridge network joined by the server and by every
+// project container. The proxy, health checks and blue/green switches reach
+// containers by their address on it, which works whether the server runs on
+// the host or in a container of its own; published host ports do not, since
+// 127.0.0.1 inside the server container is not the host.
+const SharedNetwork = "last-deploy"
+
 type Docker struct {
 	cli *client.Client
 }
@@ -36,7 +56,15 @@ func (d *Docker) Close() error {
 	return d.cli.Close()
 }
 
-func (d *Docker) BuildProjectImage(ctx context.Context, projectID, contextDir, dockerfilePath string) error {
+// IsUnavailable reports whether err means the Docker daemon could not be
+// reached.
+func IsUnavailable(err error) bool {
+	return client.IsErrConnectionFailed(err)
+}
+
+// BuildProjectImage builds the project under its candidate tag so the image
+// of the running container is left alone until PromoteProjectImage.
+func (d *Docker) BuildProjectImage(ctx context.Context, projectID, contextDir, dockerfilePath string, buildArgs map[string]string) error {
 	if projectID == "" {
 		return fmt.Errorf("project id is required")
 	}
@@ -56,10 +84,16 @@ func (d *Docker) BuildProjectImage(ctx context.Context, projectID, contextDir, d
 	}
 	defer r.Close()
 
-	tag := imageTag(projectID)
+	args := make(map[string]*string, len(buildArgs))
+	for k, v := range buildArgs {
+		args[k] = &v
+	}
+
+	tag := candidateImageTag(projectID)
 	resp, err := d.cli.ImageBuild(ctx, r, client.ImageBuildOptions{
 		Tags:       []string{tag},
 		Dockerfile: dockerfilePath,
+		BuildArgs:  args,
 		Remove:     true,
 	})
 	if err != nil {
@@ -70,39 +104,211 @@ func (d *Docker) BuildProjectImage(ctx context.Context, projectID, contextDir, d
 	return consumeDockerJSONMessages(resp.Body)
 }
 
-func (d *Docker) RunProjectContainer(ctx context.Context, projectID string, hostPort, containerPort int) error {
+// PromoteProjectImage retags the candidate image as last-deploy:<id> and
+// drops the candidate tag.
+func (d *Docker) PromoteProjectImage(ctx context.Context, projectID string) error {
 	if projectID == "" {
 		return fmt.Errorf("project id is required")
 	}
-	if hostPort <= 0 || hostPort > 65535 {
-		return fmt.Errorf("invalid host_port: %d", hostPort)
+	if _, err := d.cli.ImageTag(ctx, client.ImageTagOptions{
+		Source: candidateImageTag(projectID),
+		Target: imageTag(projectID),
+	}); err != nil {
+		return err
 	}
-	if containerPort <= 0 || containerPort > 65535 {
-		return fmt.Errorf("invalid container_port: %d", containerPort)
+	return d.RemoveCandidateImage(ctx, projectID)
+}
+
+// RemoveCandidateImage untags the candidate image; the image itself is only
+// deleted when nothing else references it.
+func (d *Docker) RemoveCandidateImage(ctx context.Context, projectID string) error {
+	_, err := d.cli.ImageRemove(ctx, candidateImageTag(projectID), client.ImageRemoveOptions{})
+	if cerrdefs.IsNotFound(err) {
+		return nil
 	}
+	return err
+}
 
-	exposed, err := network.ParsePort(fmt.Sprintf("%d/tcp", containerPort))
+// ImageRef records the image a deployed container was started from, so the
+// reference can be pointed back at it after a newer build replaced the tag.
+type ImageRef struct {
+	Ref string
+	ID  string
+}
+
+// ProjectImages returns the image of every deployed container of the project,
+// blue/green candidates excluded.
+func (d *Docker) ProjectImages(ctx context.Context, projectID string) ([]ImageRef, error) {
+	containers, err := d.listDeployedContainers(ctx, projectID)
 	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(containers))
+	var out []ImageRef
+	for _, c := range containers {
+		if c.Labels[RoleLabelKey] == RoleCandidate || c.ImageID == "" {
+			continue
+		}
+		// A bare image ID means the reference already moved on.
+		if c.Image == "" || strings.HasPrefix(c.Image, "sha256:") || seen[c.Image] {
+			continue
+		}
+		seen[c.Image] = true
+		out = append(out, ImageRef{Ref: c.Image, ID: c.ImageID})
+	}
+	return out, nil
+}
+
+// RestoreImages tags each recorded image ID with its reference again.
+func (d *Docker) RestoreImages(ctx context.Context, refs []ImageRef) error {
+	for _, r := range refs {
+		if _, err := d.cli.ImageTag(ctx, client.ImageTagOptions{Source: r.ID, Target: r.Ref}); err != nil {
+			return fmt.Errorf("retag %s: %w", r.Ref, err)
+		}
+	}
+	return nil
+}
+
+// PortBinding publishes a container port on the host. An empty HostIP binds
+// to the loopback interface; HostPort 0 lets Docker pick a free port.
+type PortBinding struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+}
+
+// ContainerSpec describes the container started for a Dockerfile project.
+type ContainerSpec struct {
+	ProjectID string
+	Ports     []PortBinding
+	// NoPublish keeps the ports exposed on the container network only.
+	NoPublish bool
+	// Candidate starts a blue/green candidate from the candidate image under
+	// CandidateName instead of the project's regular container.
+	Candidate bool
+	Resources Resources
+	Volumes   []VolumeMount
+	// Env holds NAME=value pairs set in the container.
+	Env []string
+}
+
+// VolumeMount mounts the project volume Name (see VolumeName) at Target.
+type VolumeMount struct {
+	Name     string
+	Target   string
+	ReadOnly bool
+}
+
+// Resources limits a container; zero and negative fields are unlimited.
+// MemorySwapMB -1 allows unlimited swap. CPUQuota is microseconds per
+// CPUPeriod.
+type Resources struct {
+	MemoryMB     int64
+	MemorySwapMB int64
+	CPUShares    int64
+	CPUQuota     int64
+	PidsLimit    int64
+}
+
+// CPUPeriod is the CFS period CPUQuota is measured against (Docker's default).
+const CPUPeriod = 100000
+
+func (r Resources) IsZero() bool {
+	return r == Resources{}
+}
+
+// Validate reports limits Docker would reject.
+func (r Resources) Validate() error {
+	switch {
+	case r.MemorySwapMB > 0 && r.MemoryMB <= 0:
+		return fmt.Errorf("memory swap limit %dm requires a memory limit", r.MemorySwapMB)
+	case r.MemorySwapMB > 0 && r.MemorySwapMB < r.MemoryMB:
+		return fmt.Errorf("memory swap limit %dm is less than the memory limit %dm", r.MemorySwapMB, r.MemoryMB)
+	}
+	return nil
+}
+
+func (r Resources) hostResources() container.Resources {
+	var out container.Resources
+	if r.MemoryMB > 0 {
+		out.Memory = r.MemoryMB << 20
+	}
+	switch {
+	case r.MemorySwapMB > 0:
+		out.MemorySwap = r.MemorySwapMB << 20
+	case r.MemorySwapMB < 0:
+		out.MemorySwap = -1
+	}
+	if r.CPUShares > 0 {
+		out.CPUShares = r.CPUShares
+	}
+	if r.CPUQuota > 0 {
+		out.CPUQuota = r.CPUQuota
+		out.CPUPeriod = CPUPeriod
+	}
+	if r.PidsLimit > 0 {
+		pids := r.PidsLimit
+		out.PidsLimit = &pids
+	}
+	return out
+}
+
+func (d *Docker) RunProjectContainer(ctx context.Context, spec ContainerSpec) error {
+	projectID := spec.ProjectID
+	if projectID == "" {
+		return fmt.Errorf("project id is required")
+	}
+	if err := spec.Resources.Validate(); err != nil {
 		return err
 	}
+
+	exposedPorts, portBindings, err := buildPortMap(spec.Ports)
+	if err != nil {
+		return err
+	}
+	if spec.NoPublish {
+		portBindings = nil
+	}
 	labels := map[string]string{
 		ProjectIDLabelKey: projectID,
 	}
 	name := containerName(projectID)
+	image := imageTag(projectID)
+	if spec.Candidate {
+		labels[RoleLabelKey] = RoleCandidate
+		name = CandidateName(projectID)
+		image = candidateImageTag(projectID)
+	}
 
 	cfg := &container.Config{
-		Image:        imageTag(projectID),
+		Image:        image,
 		Labels:       labels,
-		ExposedPorts: network.PortSet{exposed: struct{}{}},
+		ExposedPorts: exposedPorts,
+		Env:          spec.Env,
 	}
 	hostCfg := &container.HostConfig{
-		PortBindings: network.PortMap{
-			exposed: []network.PortBinding{
-				{HostIP: netip.MustParseAddr("127.0.0.1"), HostPort: strconv.Itoa(hostPort)},
-			},
-		},
+		PortBindings:  portBindings,
 		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+		Resources:     spec.Resources.hostResources(),
+	}
+	for _, v := range spec.Volumes {
+		source, err := d.ensureVolume(ctx, projectID, v.Name)
+		if err != nil {
+			return err
+		}
+		hostCfg.Mounts = append(hostCfg.Mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   source,
+			Target:   v.Target,
+			ReadOnly: v.ReadOnly,
+		})
+	}
+
+	if err := d.ensureSharedNetwork(ctx); err != nil {
+		return err
 	}
+	hostCfg.NetworkMode = container.NetworkMode(SharedNetwork)
 
 	_, _ = d.cli.ContainerRemove(ctx, name, client.ContainerRemoveOptions{Force: true})
 
@@ -118,6 +324,42 @@ func (d *Docker) RunProjectContainer(ctx context.Context, projectID string, host
 	return err
 }
 
+func buildPortMap(ports []PortBinding) (network.PortSet, network.PortMap, error) {
+	exposed := network.PortSet{}
+	bindings := network.PortMap{}
+	for _, p := range ports {
+		if p.HostPort < 0 || p.HostPort > 65535 {
+			return nil, nil, fmt.Errorf("invalid host_port: %d", p.HostPort)
+		}
+		if p.ContainerPort <= 0 || p.ContainerPort > 65535 {
+			return nil, nil, fmt.Errorf("invalid container_port: %d", p.ContainerPort)
+		}
+		proto := p.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		port, err := network.ParsePort(fmt.Sprintf("%d/%s", p.ContainerPort, proto))
+		if err != nil {
+			return nil, nil, err
+		}
+		hostIP := p.HostIP
+		if hostIP == "" {
+			hostIP = "127.0.0.1"
+		}
+		addr, err := netip.ParseAddr(hostIP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid host_ip: %q", p.HostIP)
+		}
+		hostPort := ""
+		if p.HostPort > 0 {
+			hostPort = strconv.Itoa(p.HostPort)
+		}
+		exposed[port] = struct{}{}
+		bindings[port] = append(bindings[port], network.PortBinding{HostIP: addr, HostPort: hostPort})
+	}
+	return exposed, bindings, nil
+}
+
 func (d *Docker) RemoveProjectContainers(ctx context.Context, projectID string) error {
 	containers, err := d.listProjectContainers(ctx, projectID)
 	if err != nil {
@@ -185,6 +427,7 @@ func (d *Docker) UnpauseProjectContainers(ctx context.Context, projectID string)
 }
 
 func (d *Docker) RemoveProjectImage(ctx context.Context, projectID string) error {
+	_ = d.RemoveCandidateImage(ctx, projectID)
 	_, err := d.cli.ImageRemove(ctx, imageTag(projectID), client.ImageRemoveOptions{
 		Force:         true,
 		PruneChildren: true,
@@ -192,6 +435,73 @@ func (d *Docker) RemoveProjectImage(ctx context.Context, projectID string) error
 	return err
 }
 
+// VolumeName is the Docker volume backing the project volume name.
+func VolumeName(projectID, name string) string {
+	return "last-deploy-" + projectID + "-" + name
+}
+
+// ensureVolume creates the project volume with the project label unless it
+// already exists, so data survives redeploys.
+func (d *Docker) ensureVolume(ctx context.Context, projectID, name string) (string, error) {
+	volName := VolumeName(projectID, name)
+	if _, err := d.cli.VolumeInspect(ctx, volName, client.VolumeInspectOptions{}); err == nil {
+		return volName, nil
+	} else if !cerrdefs.IsNotFound(err) {
+		return "", err
+	}
+	_, err := d.cli.VolumeCreate(ctx, client.VolumeCreateOptions{
+		Name:   volName,
+		Labels: map[string]string{ProjectIDLabelKey: projectID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("create volume %s: %w", volName, err)
+	}
+	return volName, nil
+}
+
+// RemoveProjectVolumes deletes the project's volumes, including those created
+// by docker compose for the project. Containers using them must be removed
+// first.
+func (d *Docker) RemoveProjectVolumes(ctx context.Context, projectID string) (int, error) {
+	names, err := d.ProjectVolumeNames(ctx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	for i, name := range names {
+		if _, err := d.cli.VolumeRemove(ctx, name, client.VolumeRemoveOptions{Force: true}); err != nil && !cerrdefs.IsNotFound(err) {
+			return i, fmt.Errorf("remove volume %s: %w", name, err)
+		}
+	}
+	return len(names), nil
+}
+
+// ProjectVolumeNames lists the Docker volumes labelled with the project and
+// those docker compose created for it.
+func (d *Docker) ProjectVolumeNames(ctx context.Context, projectID string) ([]string, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("project id is required")
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, label := range []string{
+		fmt.Sprintf("%s=%s", ProjectIDLabelKey, projectID),
+		fmt.Sprintf("%s=%s", ComposeProjectLabelKey, "last-deploy-"+projectID),
+	} {
+		res, err := d.cli.VolumeList(ctx, client.VolumeListOptions{Filters: make(client.Filters).Add("label", label)})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Items {
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				out = append(out, v.Name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
 func (d *Docker) RemoveProjectNetworks(ctx context.Context, projectID string) error {
 	if projectID == "" {
 		return fmt.Errorf("project id is required")
@@ -210,6 +520,246 @@ func (d *Docker) RemoveProjectNetworks(ctx context.Context, projectID string) er
 	return nil
 }
 
+func (d *Docker) ensureSharedNetwork(ctx context.Context) error {
+	if _, err := d.cli.NetworkInspect(ctx, SharedNetwork, client.NetworkInspectOptions{}); err == nil {
+		return nil
+	} else if !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	_, err := d.cli.NetworkCreate(ctx, SharedNetwork, client.NetworkCreateOptions{Driver: "bridge"})
+	if err != nil && !cerrdefs.IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// JoinSharedNetwork creates SharedNetwork if needed and connects the
+// container the server runs in to it. Outside of a container, where the
+// hostname names no container, it only creates the network.
+func (d *Docker) JoinSharedNetwork(ctx context.Context) error {
+	if err := d.ensureSharedNetwork(ctx); err != nil {
+		return err
+	}
+	self, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	res, err := d.cli.ContainerInspect(ctx, self, client.ContainerInspectOptions{})
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	var mode container.NetworkMode
+	if res.Container.HostConfig != nil {
+		mode = res.Container.HostConfig.NetworkMode
+	}
+	var networks map[string]*network.EndpointSettings
+	if res.Container.NetworkSettings != nil {
+		networks = res.Container.NetworkSettings.Networks
+	}
+	return d.connectShared(ctx, res.Container.ID, mode, networks)
+}
+
+// ConnectSharedNetwork connects the project's containers to SharedNetwork.
+// Compose creates its own networks, so this runs after every compose up;
+// containers sharing the host's or another container's network are skipped.
+func (d *Docker) ConnectSharedNetwork(ctx context.Context, projectID string) error {
+	if err := d.ensureSharedNetwork(ctx); err != nil {
+		return err
+	}
+	containers, err := d.listDeployedContainers(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		var networks map[string]*network.EndpointSettings
+		if c.NetworkSettings != nil {
+			networks = c.NetworkSettings.Networks
+		}
+		if err := d.connectShared(ctx, c.ID, container.NetworkMode(c.HostConfig.NetworkMode), networks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Docker) connectShared(ctx context.Context, id string, mode container.NetworkMode, networks map[string]*network.EndpointSettings) error {
+	if _, ok := networks[SharedNetwork]; ok {
+		return nil
+	}
+	if mode.IsHost() || mode.IsContainer() || mode.IsNone() {
+		return nil
+	}
+	_, err := d.cli.NetworkConnect(ctx, SharedNetwork, client.NetworkConnectOptions{Container: id})
+	if err != nil && !cerrdefs.IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// PublishedHostPorts returns the host ports ("8080/tcp") published by running
+// containers, skipping those that belong to excludeProjectID.
+func (d *Docker) PublishedHostPorts(ctx context.Context, excludeProjectID string) (map[string]bool, error) {
+	res, err := d.cli.ContainerList(ctx, client.ContainerListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool)
+	for _, c := range res.Items {
+		if excludeProjectID != "" && c.Labels[ProjectIDLabelKey] == excludeProjectID {
+			continue
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort > 0 {
+				out[fmt.Sprintf("%d/%s", p.PublicPort, p.Type)] = true
+			}
+		}
+	}
+	return out, nil
+}
+
+// ComposeServiceLabelKey is set by docker compose on every service container.
+const ComposeServiceLabelKey = "com.docker.compose.service"
+
+// ContainerAddr returns the address on SharedNetwork of a running container
+// of the project. For compose projects service selects the service container.
+func (d *Docker) ContainerAddr(ctx context.Context, projectID, service string) (netip.Addr, error) {
+	containers, err := d.listDeployedContainers(ctx, projectID)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, c := range containers {
+		if c.State != container.StateRunning {
+			continue
+		}
+		if service != "" && c.Labels[ComposeServiceLabelKey] != service {
+			continue
+		}
+		if c.Labels[RoleLabelKey] == RoleCandidate {
+			continue
+		}
+		if c.NetworkSettings == nil {
+			continue
+		}
+		if ep := c.NetworkSettings.Networks[SharedNetwork]; ep != nil && ep.IPAddress.IsValid() {
+			return ep.IPAddress, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("no running container of project %s on the %s network", projectID, SharedNetwork)
+}
+
+// ContainerStatus is the state of one project container. Health is empty
+// when the image defines no HEALTHCHECK.
+type ContainerStatus struct {
+	Name    string
+	Service string
+	State   string
+	Status  string // e.g. "Exited (0) 2 seconds ago"
+	Health  string
+}
+
+func (d *Docker) ProjectContainerStatus(ctx context.Context, projectID string) ([]ContainerStatus, error) {
+	containers, err := d.listDeployedContainers(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ContainerStatus, 0, len(containers))
+	for _, c := range containers {
+		st := ContainerStatus{
+			Name:    c.ID[:12],
+			Service: c.Labels[ComposeServiceLabelKey],
+			State:   string(c.State),
+			Status:  c.Status,
+		}
+		if len(c.Names) > 0 {
+			st.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if c.Health != nil && c.Health.Status != container.NoHealthcheck {
+			st.Health = string(c.Health.Status)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// ProjectLogs returns the last tail lines of stdout/stderr of every project
+// container, each section headed by the container name.
+func (d *Docker) ProjectLogs(ctx context.Context, projectID string, tail int) (string, error) {
+	containers, err := d.listDeployedContainers(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, c := range containers {
+		name := c.ID[:12]
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		logs, err := d.ContainerLogs(ctx, c.ID, tail)
+		if err != nil {
+			fmt.Fprintf(&b, "==> %s <==\n(logs unavailable: %v)\n", name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "==> %s <==\n%s", name, logs)
+	}
+	return b.String(), nil
+}
+
+// ContainerLogs returns the last tail lines of stdout/stderr of a container.
+func (d *Docker) ContainerLogs(ctx context.Context, nameOrID string, tail int) (string, error) {
+	rc, err := d.cli.ContainerLogs(ctx, nameOrID, client.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(tail),
+	})
+	if err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+	_ = rc.Close()
+	if err != nil {
+		return "", err
+	}
+	// Containers without a TTY multiplex stdout/stderr with frame headers.
+	var demuxed bytes.Buffer
+	if _, err := stdcopy.StdCopy(&demuxed, &demuxed, bytes.NewReader(raw)); err == nil {
+		raw = demuxed.Bytes()
+	}
+	if len(raw) > 0 && raw[len(raw)-1] != '\n' {
+		raw = append(raw, '\n')
+	}
+	return string(raw), nil
+}
+
+// ComposeProjectLabelKey is set by docker compose to the -p project name.
+const ComposeProjectLabelKey = "com.docker.compose.project"
+
+// listDeployedContainers also finds compose containers, which carry the
+// project id label only when services were selected explicitly.
+func (d *Docker) listDeployedContainers(ctx context.Context, projectID string) ([]container.Summary, error) {
+	out, err := d.listProjectContainers(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(out))
+	for _, c := range out {
+		seen[c.ID] = true
+	}
+	f := make(client.Filters).Add("label", fmt.Sprintf("%s=%s", ComposeProjectLabelKey, "last-deploy-"+projectID))
+	res, err := d.cli.ContainerList(ctx, client.ContainerListOptions{All: true, Filters: f})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range res.Items {
+		if !seen[c.ID] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
 func (d *Docker) listProjectContainers(ctx context.Context, projectID string) ([]container.Summary, error) {
 	if projectID == "" {
 		return nil, fmt.Errorf("project id is required")
@@ -222,10 +772,103 @@ func (d *Docker) listProjectContainers(ctx context.Context, projectID string) ([
 	return res.Items, nil
 }
 
+// CandidateName is the container started next to the running one during a
+// blue/green deploy; PreviousName holds the replaced container until the
+// deploy succeeds.
+func CandidateName(projectID string) string {
+	return containerName(projectID) + "-candidate"
+}
+
+func PreviousName(projectID string) string {
+	return containerName(projectID) + "-previous"
+}
+
+// ContainerName is the regular container of a Dockerfile project.
+func ContainerName(projectID string) string {
+	return containerName(projectID)
+}
+
+// ContainerExists reports whether a container with the given name exists.
+func (d *Docker) ContainerExists(ctx context.Context, name string) (bool, error) {
+	_, err := d.cli.ContainerInspect(ctx, name, client.ContainerInspectOptions{})
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *Docker) RenameContainer(ctx context.Context, name, newName string) error {
+	_, err := d.cli.ContainerRename(ctx, name, client.ContainerRenameOptions{NewName: newName})
+	return err
+}
+
+func (d *Docker) StopContainer(ctx context.Context, name string, timeout time.Duration) error {
+	_, err := d.cli.ContainerStop(ctx, name, client.ContainerStopOptions{Timeout: ptrSeconds(timeout)})
+	return err
+}
+
+func (d *Docker) StartContainer(ctx context.Context, name string) error {
+	_, err := d.cli.ContainerStart(ctx, name, client.ContainerStartOptions{})
+	return err
+}
+
+// RemoveContainer force-removes a container; a missing container is not an error.
+func (d *Docker) RemoveContainer(ctx context.Context, name string) error {
+	_, err := d.cli.ContainerRemove(ctx, name, client.ContainerRemoveOptions{Force: true})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *Docker) ContainerStatusByName(ctx context.Context, name string) (ContainerStatus, error) {
+	res, err := d.cli.ContainerInspect(ctx, name, client.ContainerInspectOptions{})
+	if err != nil {
+		return ContainerStatus{}, err
+	}
+	c := res.Container
+	st := ContainerStatus{Name: strings.TrimPrefix(c.Name, "/")}
+	if c.Config != nil {
+		st.Service = c.Config.Labels[ComposeServiceLabelKey]
+	}
+	if c.State != nil {
+		st.State = string(c.State.Status)
+		if !c.State.Running {
+			st.Status = fmt.Sprintf("Exited (%d)", c.State.ExitCode)
+		}
+		if c.State.Health != nil && c.State.Health.Status != container.NoHealthcheck {
+			st.Health = string(c.State.Health.Status)
+		}
+	}
+	return st, nil
+}
+
+// ContainerEndpoint returns the address of containerPort of the named
+// container on SharedNetwork.
+func (d *Docker) ContainerEndpoint(ctx context.Context, name string, containerPort int) (string, error) {
+	res, err := d.cli.ContainerInspect(ctx, name, client.ContainerInspectOptions{})
+	if err != nil {
+		return "", err
+	}
+	if ns := res.Container.NetworkSettings; ns != nil {
+		if ep := ns.Networks[SharedNetwork]; ep != nil && ep.IPAddress.IsValid() {
+			return net.JoinHostPort(ep.IPAddress.String(), strconv.Itoa(containerPort)), nil
+		}
+	}
+	return "", fmt.Errorf("container %s is not on the %s network", name, SharedNetwork)
+}
+
 func imageTag(projectID string) string {
 	return "last-deploy:" + projectID
 }
 
+func candidateImageTag(projectID string) string {
+	return imageTag(projectID) + "-candidate"
+}
+
 func containerName(projectID string) string {
 	return "last-deploy-" + projectID
 }
@@ -2,18 +2,37 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 var hex40 = regexp.MustCompile(`\A[0-9a-fA-F]{40}\z`)
 
-func CloneRepo(ctx context.Context, url, ref, destDir string) error {
+// CloneOptions tunes how CloneRepo talks to the remote.
+type CloneOptions struct {
+	// Auth is used for both the clone and later fetches; nil means
+	// anonymous access.
+	Auth transport.AuthMethod
+
+	// Depth > 0 fetches only the target ref with that many commits of
+	// history. Refs given as a commit hash are fetched by hash, which falls
+	// back to a full clone on servers that only serve advertised refs.
+	Depth int
+
+	// SparseDirs, when set, limits the working tree to these directories.
+	SparseDirs []string
+}
+
+func CloneRepo(ctx context.Context, url, ref, destDir string, opts CloneOptions) error {
 	if url == "" {
 		return fmt.Errorf("git url is required")
 	}
@@ -21,55 +40,215 @@ func CloneRepo(ctx context.Context, url, ref, destDir string) error {
 		return fmt.Errorf("dest dir is required")
 	}
 
+	shallowHash := opts.Depth > 0 && hex40.MatchString(ref)
+
 	// Try to open existing repo first
 	repo, err := git.PlainOpen(destDir)
-	if err == nil {
-		// Repo exists, fetch latest changes
-		if err := fetchRepo(ctx, repo); err != nil {
-			// Fetch failed, fall back to fresh clone
-			goto freshClone
+	if err == nil && originURL(repo) == url {
+		// Repo exists, fetch latest changes. A shallow clone only tracks its
+		// original ref, so when the ref changed the checkout fails and the
+		// repo is cloned again; so is a repo cloned from another URL.
+		if shallowHash {
+			err = fetchCommit(ctx, repo, ref, opts.Depth, opts.Auth)
+		} else {
+			err = fetchRepo(ctx, repo, opts)
+		}
+		if err == nil {
+			if err := checkoutRef(repo, ref, opts.SparseDirs); err == nil {
+				return nil
+			}
 		}
-		return checkoutRef(repo, ref)
 	}
 
-freshClone:
-	if err := os.RemoveAll(destDir); err != nil {
+	if shallowHash {
+		repo, err = initRepo(destDir, url)
+		if err != nil {
+			return err
+		}
+		err = fetchCommit(ctx, repo, ref, opts.Depth, opts.Auth)
+		if err == nil {
+			return checkoutRef(repo, ref, opts.SparseDirs)
+		}
+		if !errors.Is(err, git.ErrExactSHA1NotSupported) {
+			return err
+		}
+		// The server only serves advertised refs; clone in full below.
+	} else if opts.Depth > 0 {
+		for _, name := range shallowRefNames(ref) {
+			repo, err = cloneRepo(ctx, destDir, &git.CloneOptions{
+				URL:           url,
+				Auth:          opts.Auth,
+				ReferenceName: name,
+				SingleBranch:  true,
+				Depth:         opts.Depth,
+				Tags:          git.NoTags,
+				NoCheckout:    len(opts.SparseDirs) > 0,
+			})
+			if err == nil {
+				return checkoutRef(repo, ref, opts.SparseDirs)
+			}
+			if !isMissingRef(err) {
+				return err
+			}
+		}
+		// Not a branch or tag name, e.g. "origin/main"; resolve it from a
+		// full clone below.
+	}
+
+	repo, err = cloneRepo(ctx, destDir, &git.CloneOptions{
+		URL:        url,
+		Auth:       opts.Auth,
+		NoCheckout: len(opts.SparseDirs) > 0,
+	})
+	if err != nil {
 		return err
 	}
+	return checkoutRef(repo, ref, opts.SparseDirs)
+}
+
+func originURL(repo *git.Repository) string {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
+
+func cloneRepo(ctx context.Context, destDir string, o *git.CloneOptions) (*git.Repository, error) {
+	if err := os.RemoveAll(destDir); err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(filepath.Dir(destDir), 0o755); err != nil {
-		return err
+		return nil, err
 	}
+	return git.PlainCloneContext(ctx, destDir, false, o)
+}
+
+// initRepo replaces destDir with an empty repository whose origin is url.
+func initRepo(destDir, url string) (*git.Repository, error) {
+	if err := os.RemoveAll(destDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(destDir), 0o755); err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainInit(destDir, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}}); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
 
-	repo, err = git.PlainCloneContext(ctx, destDir, false, &git.CloneOptions{
-		URL: url,
+// fetchedCommitRef keeps a commit fetched by hash reachable.
+const fetchedCommitRef = "refs/last-deploy/commit"
+
+// fetchCommit fetches the commit hash from origin with depth commits of
+// history, unless the repository already has it.
+func fetchCommit(ctx context.Context, repo *git.Repository, hash string, depth int, auth transport.AuthMethod) error {
+	if _, err := repo.CommitObject(plumbing.NewHash(hash)); err == nil {
+		return nil
+	}
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		Auth:     auth,
+		Depth:    depth,
+		Tags:     git.NoTags,
+		Force:    true,
+		RefSpecs: []config.RefSpec{config.RefSpec(hash + ":" + fetchedCommitRef)},
 	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return err
+}
+
+// shallowRefNames lists what ref may name on the remote, most likely first.
+func shallowRefNames(ref string) []plumbing.ReferenceName {
+	switch {
+	case ref == "":
+		return []plumbing.ReferenceName{plumbing.HEAD}
+	case strings.HasPrefix(ref, "refs/"):
+		return []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		return []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+}
+
+func isMissingRef(err error) bool {
+	return errors.Is(err, git.NoMatchingRefSpecError{}) || errors.Is(err, plumbing.ErrReferenceNotFound)
+}
+
+// RepoHead returns the commit checked out in dir.
+func RepoHead(dir string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// CheckoutCommit checks out hash in dir, discarding local changes. A commit
+// missing from a shallow clone is fetched from origin first, using auth.
+func CheckoutCommit(ctx context.Context, dir, hash string, auth transport.AuthMethod) error {
+	repo, err := git.PlainOpen(dir)
 	if err != nil {
 		return err
 	}
-	return checkoutRef(repo, ref)
+	if err := fetchCommit(ctx, repo, hash, 1, auth); err != nil {
+		return fmt.Errorf("fetch %s: %w", hash, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(hash), Force: true})
 }
 
-func fetchRepo(ctx context.Context, repo *git.Repository) error {
-	err := repo.FetchContext(ctx, &git.FetchOptions{
+func fetchRepo(ctx context.Context, repo *git.Repository, opts CloneOptions) error {
+	o := &git.FetchOptions{
+		Auth:  opts.Auth,
 		Force: true,
-	})
+	}
+	if opts.Depth > 0 {
+		o.Depth = opts.Depth
+	}
+	err := repo.FetchContext(ctx, o)
 	if err == git.NoErrAlreadyUpToDate {
 		return nil
 	}
 	return err
 }
 
-func checkoutRef(repo *git.Repository, ref string) error {
-	if ref == "" {
+// checkoutRef checks out ref, limited to sparse when set. An empty ref keeps
+// the commit the clone checked out, or HEAD for a clone made without
+// checkout.
+func checkoutRef(repo *git.Repository, ref string, sparse []string) error {
+	if ref == "" && len(sparse) == 0 {
 		return nil
 	}
 	wt, err := repo.Worktree()
 	if err != nil {
 		return err
 	}
+	checkout := func(h plumbing.Hash) error {
+		return wt.Checkout(&git.CheckoutOptions{Hash: h, Force: true, SparseCheckoutDirectories: sparse})
+	}
 
+	if ref == "" {
+		head, err := repo.Head()
+		if err != nil {
+			return err
+		}
+		return checkout(head.Hash())
+	}
 	if hex40.MatchString(ref) {
-		return wt.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(ref)})
+		return checkout(plumbing.NewHash(ref))
 	}
 
 	candidates := []plumbing.ReferenceName{
@@ -81,7 +260,7 @@ func checkoutRef(repo *git.Repository, ref string) error {
 	for _, name := range candidates {
 		r, err := repo.Reference(name, true)
 		if err == nil {
-			return wt.Checkout(&git.CheckoutOptions{Hash: r.Hash()})
+			return checkout(r.Hash())
 		}
 	}
 
@@ -95,7 +274,7 @@ func checkoutRef(repo *git.Repository, ref string) error {
 	for _, rev := range revCandidates {
 		h, err := repo.ResolveRevision(plumbing.Revision(rev))
 		if err == nil {
-			return wt.Checkout(&git.CheckoutOptions{Hash: *h})
+			return checkout(*h)
 		}
 	}
 
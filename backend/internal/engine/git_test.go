@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestRepoHeadAndCheckoutCommit(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	file := filepath.Join(dir, "app.txt")
+	commit := func(content string) string {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if _, err := wt.Add("app.txt"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		h, err := wt.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		return h.String()
+	}
+
+	first := commit("v1")
+	second := commit("v2")
+	if got, err := RepoHead(dir); err != nil || got != second {
+		t.Fatalf("RepoHead = %q, %v; want %q", got, err, second)
+	}
+
+	// local edits, like a written compose file, must not block the checkout
+	if err := os.WriteFile(file, []byte("local"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := CheckoutCommit(context.Background(), dir, first, nil); err != nil {
+		t.Fatalf("CheckoutCommit: %v", err)
+	}
+	if got, _ := RepoHead(dir); got != first {
+		t.Fatalf("RepoHead after checkout = %q, want %q", got, first)
+	}
+	if b, _ := os.ReadFile(file); string(b) != "v1" {
+		t.Fatalf("content = %q, want v1", b)
+	}
+}
+
+func TestCloneRepoShallowAndSparse(t *testing.T) {
+	ctx := context.Background()
+	src := t.TempDir()
+	repo, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	commit := func(msg string, files ...string) string {
+		t.Helper()
+		for _, f := range files {
+			path := filepath.Join(src, f)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(msg), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := wt.Add(f); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+		}
+		h, err := wt.Commit(msg, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		return h.String()
+	}
+	first := commit("v1", "services/api/Dockerfile", "services/web/Dockerfile", "README.md")
+	second := commit("v2", "services/api/Dockerfile")
+	if _, err := repo.CreateTag("v2", plumbing.NewHash(second), nil); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+
+	countCommits := func(dir string) int {
+		t.Helper()
+		r, err := git.PlainOpen(dir)
+		if err != nil {
+			t.Fatalf("PlainOpen: %v", err)
+		}
+		iter, err := r.Log(&git.LogOptions{})
+		if err != nil {
+			t.Fatalf("Log: %v", err)
+		}
+		n := 0
+		_ = iter.ForEach(func(*object.Commit) error { n++; return nil })
+		return n
+	}
+
+	t.Run("depth", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, "v2", dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != second {
+			t.Fatalf("head = %s, want %s", got, second)
+		}
+		if n := countCommits(dir); n != 1 {
+			t.Errorf("history has %d commits, want 1", n)
+		}
+		// Fetching into the existing clone keeps working.
+		if err := CloneRepo(ctx, src, "v2", dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo again: %v", err)
+		}
+	})
+
+	t.Run("changed url", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, "", dir, CloneOptions{}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		other := t.TempDir()
+		r, err := git.PlainInit(other, false)
+		if err != nil {
+			t.Fatalf("PlainInit: %v", err)
+		}
+		w, _ := r.Worktree()
+		if err := os.WriteFile(filepath.Join(other, "other.txt"), []byte("other"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		_, _ = w.Add("other.txt")
+		h, err := w.Commit("other", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		if err := CloneRepo(ctx, other, "", dir, CloneOptions{}); err != nil {
+			t.Fatalf("CloneRepo other url: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != h.String() {
+			t.Fatalf("head = %s, want %s from the new url", got, h)
+		}
+	})
+
+	t.Run("hash ref clones in full", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, first, dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != first {
+			t.Fatalf("head = %s, want %s", got, first)
+		}
+	})
+
+	// Hosts like GitHub serve commits by hash; the remaining cases need it.
+	f, err := os.OpenFile(filepath.Join(src, ".git", "config"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	_, _ = f.WriteString("[uploadpack]\n\tallowReachableSHA1InWant = true\n")
+	_ = f.Close()
+
+	t.Run("hash ref shallow", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, first, dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != first {
+			t.Fatalf("head = %s, want %s", got, first)
+		}
+		if n := countCommits(dir); n != 1 {
+			t.Errorf("history has %d commits, want 1", n)
+		}
+		if err := CloneRepo(ctx, src, second, dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo next hash: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != second {
+			t.Fatalf("head = %s, want %s", got, second)
+		}
+	})
+
+	t.Run("checkout fetches a missing commit", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, "v2", dir, CloneOptions{Depth: 1}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		if err := CheckoutCommit(ctx, dir, first, nil); err != nil {
+			t.Fatalf("CheckoutCommit: %v", err)
+		}
+		if got, _ := RepoHead(dir); got != first {
+			t.Fatalf("head = %s, want %s", got, first)
+		}
+	})
+
+	t.Run("sparse", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "repo")
+		if err := CloneRepo(ctx, src, "", dir, CloneOptions{Depth: 1, SparseDirs: []string{"services/api"}}); err != nil {
+			t.Fatalf("CloneRepo: %v", err)
+		}
+		if b, err := os.ReadFile(filepath.Join(dir, "services/api/Dockerfile")); err != nil || string(b) != "v2" {
+			t.Fatalf("services/api/Dockerfile = %q, %v", b, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "services/web/Dockerfile")); !os.IsNotExist(err) {
+			t.Errorf("services/web was checked out: %v", err)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/client"
+)
+
+// ErrNoContainers is returned when a project has no containers to read from.
+var ErrNoContainers = errors.New("project has no containers")
+
+// FollowProjectLogs streams stdout/stderr of every project container to w,
+// starting with the last tail lines, until ctx is done or all containers
+// stop. With several containers each line is prefixed with the container
+// name.
+func (d *Docker) FollowProjectLogs(ctx context.Context, projectID string, tail int, w io.Writer) error {
+	containers, err := d.listDeployedContainers(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return ErrNoContainers
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(containers))
+	for i, c := range containers {
+		prefix := ""
+		if len(containers) > 1 {
+			name := c.ID[:12]
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			prefix = name + " | "
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lw := &lineWriter{mu: &mu, w: w, prefix: prefix}
+			errs[i] = d.followContainerLogs(ctx, c.ID, tail, lw)
+			lw.flush()
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Docker) followContainerLogs(ctx context.Context, id string, tail int, w io.Writer) error {
+	info, err := d.cli.ContainerInspect(ctx, id, client.ContainerInspectOptions{})
+	if err != nil {
+		return err
+	}
+	rc, err := d.cli.ContainerLogs(ctx, id, client.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Tail:       strconv.Itoa(tail),
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Only containers without a TTY multiplex stdout/stderr.
+	if info.Container.Config != nil && info.Container.Config.Tty {
+		_, err = io.Copy(w, rc)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, rc)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// lineWriter writes whole lines under a shared lock so that the output of
+// concurrent streams does not interleave mid-line.
+type lineWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	i := bytes.LastIndexByte(l.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := l.buf[:i+1]
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(lines) > 0 {
+		j := bytes.IndexByte(lines, '\n')
+		if _, err := io.WriteString(l.w, l.prefix); err != nil {
+			return 0, err
+		}
+		if _, err := l.w.Write(lines[:j+1]); err != nil {
+			return 0, err
+		}
+		lines = lines[j+1:]
+	}
+	l.buf = append(l.buf[:0], l.buf[i+1:]...)
+	if f, ok := l.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return len(p), nil
+}
+
+func (l *lineWriter) flush() {
+	if len(l.buf) > 0 {
+		_, _ = l.Write([]byte("\n"))
+	}
+}
@@ -0,0 +1,117 @@
+package gitauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"last-deploy/internal/config"
+)
+
+const (
+	keySize     = 32
+	sealVersion = "v1:"
+)
+
+// Box encrypts secrets with AES-256-GCM before they reach the database.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// LoadKey returns cfg.SecretKey, or the key stored at cfg.SecretKeyPath(),
+// generating and saving a random one when the file does not exist yet.
+func LoadKey(cfg config.Config) ([]byte, error) {
+	if cfg.SecretKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.SecretKey)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("secret key must be %d bytes of base64", keySize)
+		}
+		return key, nil
+	}
+
+	path := cfg.SecretKeyPath()
+	b, err := os.ReadFile(path)
+	if err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("%s: not a %d byte base64 key", path, keySize)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// O_EXCL keeps a key written concurrently by another process.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+func NewBox(key []byte) (*Box, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plain into a printable string. The empty string stays empty.
+func (b *Box) Seal(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return sealVersion + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal.
+func (b *Box) Open(sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	enc, ok := strings.CutPrefix(sealed, sealVersion)
+	if !ok {
+		return "", errors.New("unknown secret format")
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return "", err
+	}
+	n := b.aead.NonceSize()
+	if len(raw) < n {
+		return "", errors.New("secret is truncated")
+	}
+	plain, err := b.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt secret, was the secret key changed?")
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,198 @@
+// Package gitauth stores the credentials used to clone private repositories
+// and turns them into go-git auth methods. Private keys, tokens and
+// passwords are encrypted with a Box before they are saved.
+package gitauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+
+	"last-deploy/internal/store"
+)
+
+var (
+	ErrInvalidKey        = errors.New("not an unencrypted SSH private key")
+	ErrInvalidKnownHosts = errors.New("invalid known_hosts line")
+	ErrMissingKnownHosts = errors.New("SSH credentials need the known_hosts lines of the git host")
+	ErrMissingSecret     = errors.New("a token or password is required")
+)
+
+// defaultTokenUser is sent with tokens that have no username. GitHub
+// requires it to be non-empty; GitLab and Gitea ignore it.
+const defaultTokenUser = "x-access-token"
+
+type Manager struct {
+	st  *store.Store
+	box *Box
+}
+
+func New(st *store.Store, box *Box) *Manager {
+	return &Manager{st: st, box: box}
+}
+
+// Create saves c with secret encrypted. SSH credentials without a secret
+// get a new ed25519 deploy key; with one, secret must be a private key in
+// PEM form. c.PublicKey is filled in for SSH credentials in both cases.
+func (m *Manager) Create(ctx context.Context, c store.GitCredential, secret string) (store.GitCredential, error) {
+	if err := m.seal(&c, secret, true); err != nil {
+		return store.GitCredential{}, err
+	}
+	return m.st.CreateGitCredential(ctx, c)
+}
+
+// Update saves c, replacing the secret only when one is given. For SSH
+// credentials an empty secret generates a new key.
+func (m *Manager) Update(ctx context.Context, c store.GitCredential, secret *string) (store.GitCredential, error) {
+	if secret != nil {
+		if err := m.seal(&c, *secret, true); err != nil {
+			return store.GitCredential{}, err
+		}
+	} else if err := m.seal(&c, "", false); err != nil {
+		return store.GitCredential{}, err
+	}
+	return m.st.UpdateGitCredential(ctx, c)
+}
+
+// seal validates c and, when replace is set, stores secret in it. SSH
+// credentials must carry the host keys they trust: the server has no
+// known_hosts files of its own to fall back to.
+func (m *Manager) seal(c *store.GitCredential, secret string, replace bool) error {
+	if err := checkKnownHosts(c.KnownHosts); err != nil {
+		return err
+	}
+	if c.Type == store.GitCredentialSSH && strings.TrimSpace(c.KnownHosts) == "" {
+		return ErrMissingKnownHosts
+	}
+	if !replace {
+		return nil
+	}
+	switch c.Type {
+	case store.GitCredentialSSH:
+		if secret == "" {
+			var err error
+			if secret, err = generateKey(c.Name); err != nil {
+				return err
+			}
+		}
+		signer, err := ssh.ParsePrivateKey([]byte(secret))
+		if err != nil {
+			return ErrInvalidKey
+		}
+		c.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))) + " last-deploy:" + c.Name
+	default:
+		if secret == "" {
+			return ErrMissingSecret
+		}
+		c.PublicKey = ""
+	}
+	sealed, err := m.box.Seal(secret)
+	if err != nil {
+		return err
+	}
+	c.Secret = sealed
+	return nil
+}
+
+// AuthMethod returns the auth for the credential id, or nil for "".
+func (m *Manager) AuthMethod(ctx context.Context, id string) (transport.AuthMethod, error) {
+	if id == "" {
+		return nil, nil
+	}
+	if m == nil {
+		return nil, errors.New("git credentials are not available")
+	}
+	c, err := m.st.GetGitCredential(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("git credential %s: %w", id, err)
+	}
+	secret, err := m.box.Open(c.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("git credential %s: %w", c.Name, err)
+	}
+
+	switch c.Type {
+	case store.GitCredentialSSH:
+		user := c.Username
+		if user == "" {
+			user = "git"
+		}
+		auth, err := gitssh.NewPublicKeys(user, []byte(secret), "")
+		if err != nil {
+			return nil, fmt.Errorf("git credential %s: %w", c.Name, err)
+		}
+		if strings.TrimSpace(c.KnownHosts) == "" {
+			return nil, fmt.Errorf("git credential %s: %w", c.Name, ErrMissingKnownHosts)
+		}
+		if auth.HostKeyCallback, err = hostKeyCallback(c.KnownHosts); err != nil {
+			return nil, fmt.Errorf("git credential %s: %w", c.Name, err)
+		}
+		return auth, nil
+	case store.GitCredentialToken:
+		user := c.Username
+		if user == "" {
+			user = defaultTokenUser
+		}
+		return &githttp.BasicAuth{Username: user, Password: secret}, nil
+	case store.GitCredentialBasic:
+		return &githttp.BasicAuth{Username: c.Username, Password: secret}, nil
+	default:
+		return nil, fmt.Errorf("git credential %s: unknown type %q", c.Name, c.Type)
+	}
+}
+
+func generateKey(name string) (string, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "last-deploy:"+name)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+func checkKnownHosts(text string) error {
+	rest := []byte(text)
+	for len(rest) > 0 {
+		var err error
+		_, _, _, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("%w: %v", ErrInvalidKnownHosts, err)
+		}
+	}
+	return nil
+}
+
+// hostKeyCallback checks host keys against known_hosts text. The knownhosts
+// package only reads files, so the text goes through a temporary one.
+func hostKeyCallback(text string) (ssh.HostKeyCallback, error) {
+	f, err := os.CreateTemp("", "last-deploy-known-hosts-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return gitssh.NewKnownHostsCallback(f.Name())
+}
@@ -0,0 +1,129 @@
+package gitauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/store"
+)
+
+func TestLoadKey(t *testing.T) {
+	cfg := config.Config{DataDir: t.TempDir()}
+	first, err := LoadKey(cfg)
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	again, err := LoadKey(cfg)
+	if err != nil {
+		t.Fatalf("LoadKey again: %v", err)
+	}
+	if len(first) != keySize || !bytes.Equal(first, again) {
+		t.Fatalf("keys differ between loads")
+	}
+
+	cfg.SecretKey = "c2hvcnQ="
+	if _, err := LoadKey(cfg); err == nil {
+		t.Fatal("LoadKey accepted a short key")
+	}
+}
+
+func TestBox(t *testing.T) {
+	box, err := NewBox(make([]byte, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.Seal("ghp_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "ghp_secret") {
+		t.Fatalf("sealed value contains the secret: %s", sealed)
+	}
+	if got, err := box.Open(sealed); err != nil || got != "ghp_secret" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	other, _ := NewBox(bytes.Repeat([]byte{1}, keySize))
+	if _, err := other.Open(sealed); err == nil {
+		t.Fatal("Open with another key succeeded")
+	}
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	ctx := context.Background()
+	st, err := store.Open(ctx, filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	box, err := NewBox(make([]byte, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(st, box)
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	key, err := m.Create(ctx, store.GitCredential{ID: "k", Name: "deploy", Type: store.GitCredentialSSH,
+		KnownHosts: "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"}, "")
+	if err != nil {
+		t.Fatalf("Create ssh: %v", err)
+	}
+	if !strings.HasPrefix(key.PublicKey, "ssh-ed25519 ") || !strings.HasSuffix(key.PublicKey, " last-deploy:deploy") {
+		t.Errorf("public key = %q", key.PublicKey)
+	}
+	if strings.Contains(key.Secret, "PRIVATE KEY") {
+		t.Error("private key stored in clear")
+	}
+	auth, err := m.AuthMethod(ctx, "k")
+	if err != nil {
+		t.Fatalf("AuthMethod ssh: %v", err)
+	}
+	if pk, ok := auth.(*gitssh.PublicKeys); !ok || pk.User != "git" || pk.HostKeyCallback == nil {
+		t.Errorf("ssh auth = %#v", auth)
+	}
+
+	if _, err := m.Create(ctx, store.GitCredential{ID: "t", Name: "token", Type: store.GitCredentialToken}, "ghp_x"); err != nil {
+		t.Fatalf("Create token: %v", err)
+	}
+	auth, err = m.AuthMethod(ctx, "t")
+	if err != nil {
+		t.Fatalf("AuthMethod token: %v", err)
+	}
+	if ba, ok := auth.(*githttp.BasicAuth); !ok || ba.Username != defaultTokenUser || ba.Password != "ghp_x" {
+		t.Errorf("token auth = %#v", auth)
+	}
+
+	if auth, err := m.AuthMethod(ctx, ""); auth != nil || err != nil {
+		t.Errorf("AuthMethod(\"\") = %v, %v", auth, err)
+	}
+
+	cases := []struct {
+		name   string
+		c      store.GitCredential
+		secret string
+		want   error
+	}{
+		{name: "bad key", c: store.GitCredential{ID: "b1", Name: "b1", Type: store.GitCredentialSSH, KnownHosts: key.KnownHosts}, secret: "not a key", want: ErrInvalidKey},
+		{name: "empty token", c: store.GitCredential{ID: "b2", Name: "b2", Type: store.GitCredentialToken}, want: ErrMissingSecret},
+		{name: "bad known_hosts", c: store.GitCredential{ID: "b3", Name: "b3", Type: store.GitCredentialSSH, KnownHosts: "github.com nonsense"}, want: ErrInvalidKnownHosts},
+		{name: "no known_hosts", c: store.GitCredential{ID: "b4", Name: "b4", Type: store.GitCredentialSSH}, want: ErrMissingKnownHosts},
+	}
+	for _, tc := range cases {
+		if _, err := m.Create(ctx, tc.c, tc.secret); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Package gitops keeps projects in line with a bundle manifest stored in a git
+// repository: projects in the manifest are created or updated and deployed,
+// and projects it created are deleted once they leave the manifest.
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"last-deploy/internal/bundle"
+	"last-deploy/internal/config"
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+	"last-deploy/internal/workspace"
+)
+
+// ManagedBy marks projects owned by the manifest.
+const ManagedBy = "gitops"
+
+// ActionDelete is reported for managed projects missing from the manifest.
+const ActionDelete = "delete"
+
+var (
+	ErrDisabled    = errors.New("gitops is not configured")
+	ErrDestructive = errors.New("the manifest removes projects; destructive changes are not allowed")
+)
+
+// Importer plans and applies bundle projects. The API server implements it
+// so that reconciles and manual imports share the same validation.
+type Importer interface {
+	PlanImport(ctx context.Context, projects []bundle.Project) ([]bundle.Item, error)
+	ApplyImport(ctx context.Context, it *bundle.Item, p bundle.Project) error
+	EnqueueJob(ctx context.Context, projectID, jobType string) (store.Job, error)
+}
+
+// Plan is what a reconcile of the manifest at Commit does.
+type Plan struct {
+	Commit      string        `json:"commit"`
+	Items       []bundle.Item `json:"items"`
+	Destructive bool          `json:"destructive"`
+}
+
+type Status struct {
+	Enabled          bool   `json:"enabled"`
+	Repo             string `json:"repo,omitempty"`
+	Ref              string `json:"ref,omitempty"`
+	Path             string `json:"path,omitempty"`
+	AllowDestructive bool   `json:"allow_destructive"`
+	Adopt            bool   `json:"adopt"`
+	LastSyncAt       int64  `json:"last_sync_at,omitempty"`
+	LastCommit       string `json:"last_commit,omitempty"`
+	LastError        string `json:"last_error,omitempty"`
+	LastPlan         *Plan  `json:"last_plan,omitempty"`
+}
+
+type Reconciler struct {
+	cfg config.Config
+	st  *store.Store
+	imp Importer
+
+	// mu serializes fetches and syncs; statusMu only guards status.
+	mu       sync.Mutex
+	statusMu sync.Mutex
+	status   Status
+}
+
+func New(cfg config.Config, st *store.Store) *Reconciler {
+	return &Reconciler{
+		cfg: cfg,
+		st:  st,
+		status: Status{
+			Enabled:          cfg.GitOpsRepo != "",
+			Repo:             cfg.GitOpsRepo,
+			Ref:              cfg.GitOpsRef,
+			Path:             cfg.GitOpsPath,
+			AllowDestructive: cfg.GitOpsAllowDestructive,
+			Adopt:            cfg.GitOpsAdopt,
+		},
+	}
+}
+
+// SetImporter must be called before Run, Plan or Sync.
+func (r *Reconciler) SetImporter(imp Importer) {
+	r.imp = imp
+}
+
+func (r *Reconciler) Status() Status {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.status
+}
+
+// Run syncs every GitOpsInterval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.cfg.GitOpsRepo == "" || r.cfg.GitOpsInterval <= 0 {
+		return
+	}
+	t := time.NewTicker(r.cfg.GitOpsInterval)
+	defer t.Stop()
+	for {
+		if _, err := r.Sync(ctx, false); err != nil && ctx.Err() == nil {
+			log.Printf("gitops: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Plan fetches the manifest and reports what a sync would do without
+// changing anything.
+func (r *Reconciler) Plan(ctx context.Context) (Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plan, _, err := r.plan(ctx)
+	return plan, err
+}
+
+// Sync converges the projects to the manifest. Conflicting projects, which
+// include projects of the same name not created by the manifest unless
+// GitOpsAdopt is set, are skipped and reported; deletes are refused with
+// ErrDestructive unless allowDestructive or GitOpsAllowDestructive is set.
+func (r *Reconciler) Sync(ctx context.Context, allowDestructive bool) (Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	plan, projects, err := r.plan(ctx)
+	if err == nil {
+		err = r.apply(ctx, &plan, projects, allowDestructive || r.cfg.GitOpsAllowDestructive)
+	}
+
+	r.statusMu.Lock()
+	r.status.LastSyncAt = time.Now().Unix()
+	if plan.Commit != "" {
+		r.status.LastCommit = plan.Commit
+		r.status.LastPlan = &plan
+	}
+	r.status.LastError = ""
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+	r.statusMu.Unlock()
+	return plan, err
+}
+
+func (r *Reconciler) plan(ctx context.Context) (Plan, []bundle.Project, error) {
+	if r.cfg.GitOpsRepo == "" {
+		return Plan{}, nil, ErrDisabled
+	}
+	commit, b, err := r.fetch(ctx)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	items, err := r.imp.PlanImport(ctx, b.Projects)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+
+	existing, err := r.st.ListProjects(ctx)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+	byID := make(map[string]store.Project, len(existing))
+	for _, p := range existing {
+		byID[p.ID] = p
+	}
+
+	plan := Plan{Commit: commit, Items: items}
+	for i := range plan.Items {
+		it := &plan.Items[i]
+		if it.Action == bundle.ActionConflict || it.ProjectID == "" || r.cfg.GitOpsAdopt {
+			continue
+		}
+		// A project created by hand is only taken over when adopting is allowed.
+		if p, ok := byID[it.ProjectID]; ok && p.ManagedBy != ManagedBy {
+			it.Conflict("project %s was not created by the manifest; set LAST_DEPLOY_GITOPS_ADOPT to manage it", p.ID)
+		}
+	}
+	inManifest := make(map[string]bool)
+	for _, p := range b.Projects {
+		inManifest[p.Name] = true
+	}
+	for _, p := range existing {
+		if p.ManagedBy == ManagedBy && !inManifest[p.Name] {
+			plan.Items = append(plan.Items, bundle.Item{Name: p.Name, Action: ActionDelete, ProjectID: p.ID})
+			plan.Destructive = true
+		}
+	}
+	return plan, b.Projects, nil
+}
+
+func (r *Reconciler) apply(ctx context.Context, plan *Plan, projects []bundle.Project, allowDestructive bool) error {
+	if plan.Destructive && !allowDestructive {
+		return ErrDestructive
+	}
+
+	var errs []error
+	for i := range plan.Items {
+		it := &plan.Items[i]
+		var err error
+		switch it.Action {
+		case bundle.ActionConflict:
+			err = fmt.Errorf("%s: %v", it.Name, it.Conflicts)
+		case ActionDelete:
+			err = r.enqueue(ctx, it.ProjectID, store.JobTypeDelete)
+		default:
+			err = r.applyProject(ctx, it, projects[i])
+		}
+		if err != nil {
+			if it.Error == "" {
+				it.Error = err.Error()
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyProject creates or updates one project, takes ownership of it and
+// deploys it when something changed.
+func (r *Reconciler) applyProject(ctx context.Context, it *bundle.Item, p bundle.Project) error {
+	if err := r.imp.ApplyImport(ctx, it, p); err != nil {
+		return fmt.Errorf("%s: %w", it.Name, err)
+	}
+	project, err := r.st.GetProject(ctx, it.ProjectID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", it.Name, err)
+	}
+	if project.ManagedBy != ManagedBy {
+		if err := r.st.SetProjectManagedBy(ctx, project.ID, ManagedBy); err != nil {
+			return fmt.Errorf("%s: %w", it.Name, err)
+		}
+	}
+	if it.Action == bundle.ActionUnchanged {
+		return nil
+	}
+	if err := r.enqueue(ctx, project.ID, store.JobTypeDeploy); err != nil {
+		return fmt.Errorf("%s: %w", it.Name, err)
+	}
+	return nil
+}
+
+// enqueue adds a job unless the same job is already waiting or running, so a
+// slow deploy is not queued again on every tick.
+func (r *Reconciler) enqueue(ctx context.Context, projectID, jobType string) error {
+	last, err := r.st.GetLatestJobByProject(ctx, projectID)
+	switch {
+	case err == nil:
+		if last.Type == jobType && (last.Status == store.JobStatusQueued || last.Status == store.JobStatusRunning) {
+			return nil
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return err
+	}
+	_, err = r.imp.EnqueueJob(ctx, projectID, jobType)
+	return err
+}
+
+func (r *Reconciler) fetch(ctx context.Context) (string, bundle.Bundle, error) {
+	dir := r.cfg.GitOpsDir()
+	if err := engine.CloneRepo(ctx, r.cfg.GitOpsRepo, r.cfg.GitOpsRef, dir, engine.CloneOptions{Depth: 1}); err != nil {
+		return "", bundle.Bundle{}, fmt.Errorf("fetch %s: %w", r.cfg.GitOpsRepo, err)
+	}
+	commit, err := engine.RepoHead(dir)
+	if err != nil {
+		return "", bundle.Bundle{}, err
+	}
+	path, err := workspace.SafeJoin(dir, r.cfg.GitOpsPath)
+	if err != nil {
+		return "", bundle.Bundle{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", bundle.Bundle{}, err
+	}
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return "", bundle.Bundle{}, fmt.Errorf("%s: %w", r.cfg.GitOpsPath, err)
+	}
+	return commit, b, nil
+}
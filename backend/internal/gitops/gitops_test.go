@@ -0,0 +1,189 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"last-deploy/internal/bundle"
+	"last-deploy/internal/config"
+	"last-deploy/internal/store"
+)
+
+// fakeImporter creates projects directly in the store and records jobs.
+type fakeImporter struct {
+	st   *store.Store
+	jobs []string
+}
+
+func (f *fakeImporter) PlanImport(ctx context.Context, projects []bundle.Project) ([]bundle.Item, error) {
+	existing, err := f.st.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]bundle.Item, len(projects))
+	for i, p := range projects {
+		items[i] = bundle.Item{Name: p.Name, Action: bundle.ActionCreate}
+		for _, e := range existing {
+			if e.Name == p.Name {
+				items[i] = bundle.Item{Name: p.Name, Action: bundle.ActionUnchanged, ProjectID: e.ID}
+			}
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeImporter) ApplyImport(ctx context.Context, it *bundle.Item, p bundle.Project) error {
+	if it.Action != bundle.ActionCreate {
+		return nil
+	}
+	sp := p.ToStore()
+	sp.ID = p.Name
+	if _, err := f.st.CreateProject(ctx, sp); err != nil {
+		return err
+	}
+	it.ProjectID = sp.ID
+	return nil
+}
+
+func (f *fakeImporter) EnqueueJob(ctx context.Context, projectID, jobType string) (store.Job, error) {
+	f.jobs = append(f.jobs, jobType+":"+projectID)
+	return store.Job{ProjectID: projectID, Type: jobType}, nil
+}
+
+// manifestRepo creates a git repository and returns it with a function that
+// commits manifest as last-deploy.yaml.
+func manifestRepo(t *testing.T) (string, func(manifest string)) {
+	t.Helper()
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	return repoDir, func(manifest string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repoDir, "last-deploy.yaml"), []byte(manifest), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if _, err := wt.Add("last-deploy.yaml"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if _, err := wt.Commit("manifest", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		}); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	repoDir, commit := manifestRepo(t)
+
+	st, err := store.Open(ctx, filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	// A project created by hand is never deleted by a sync.
+	if _, err := st.CreateProject(ctx, store.Project{ID: "manual", Name: "manual", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	cfg := config.Config{DataDir: t.TempDir(), GitOpsRepo: repoDir, GitOpsRef: "master", GitOpsPath: "last-deploy.yaml"}
+	imp := &fakeImporter{st: st}
+	r := New(cfg, st)
+	r.SetImporter(imp)
+
+	commit("version: 1\nprojects:\n  - name: a\n    git_url: u\n  - name: b\n    git_url: u\n")
+	if _, err := r.Sync(ctx, false); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(imp.jobs) != 2 || imp.jobs[0] != "deploy:a" || imp.jobs[1] != "deploy:b" {
+		t.Fatalf("jobs = %v", imp.jobs)
+	}
+	if p, _ := st.GetProject(ctx, "a"); p.ManagedBy != ManagedBy {
+		t.Fatalf("managed_by = %q, want %q", p.ManagedBy, ManagedBy)
+	}
+
+	// Removing b from the manifest is refused until deletes are allowed.
+	commit("version: 1\nprojects:\n  - name: a\n    git_url: u\n")
+	imp.jobs = nil
+	plan, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !plan.Destructive || len(plan.Items) != 2 || plan.Items[1].Action != ActionDelete || plan.Items[1].ProjectID != "b" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if _, err := r.Sync(ctx, false); !errors.Is(err, ErrDestructive) {
+		t.Fatalf("Sync = %v, want ErrDestructive", err)
+	}
+	if len(imp.jobs) != 0 {
+		t.Fatalf("jobs after refused sync = %v", imp.jobs)
+	}
+	if r.Status().LastError == "" {
+		t.Fatalf("status has no error after refused sync")
+	}
+	if _, err := r.Sync(ctx, true); err != nil {
+		t.Fatalf("Sync(allowDestructive): %v", err)
+	}
+	if len(imp.jobs) != 1 || imp.jobs[0] != "delete:b" {
+		t.Fatalf("jobs = %v, want [delete:b]", imp.jobs)
+	}
+}
+
+func TestSync_Adopt(t *testing.T) {
+	ctx := context.Background()
+	repoDir, commit := manifestRepo(t)
+	commit("version: 1\nprojects:\n  - name: manual\n    git_url: u\n")
+
+	st, err := store.Open(ctx, filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	if _, err := st.CreateProject(ctx, store.Project{ID: "manual", Name: "manual", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	cfg := config.Config{DataDir: t.TempDir(), GitOpsRepo: repoDir, GitOpsRef: "master", GitOpsPath: "last-deploy.yaml"}
+	imp := &fakeImporter{st: st}
+	r := New(cfg, st)
+	r.SetImporter(imp)
+
+	// A manifest entry named like a project created by hand is a conflict.
+	plan, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Items) != 1 || plan.Items[0].Action != bundle.ActionConflict {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if _, err := r.Sync(ctx, false); err == nil {
+		t.Fatalf("Sync adopted a project created by hand")
+	}
+	if p, _ := st.GetProject(ctx, "manual"); p.ManagedBy != "" {
+		t.Fatalf("managed_by = %q after refused sync", p.ManagedBy)
+	}
+
+	cfg.GitOpsAdopt = true
+	r = New(cfg, st)
+	r.SetImporter(imp)
+	if _, err := r.Sync(ctx, false); err != nil {
+		t.Fatalf("Sync(adopt): %v", err)
+	}
+	if p, _ := st.GetProject(ctx, "manual"); p.ManagedBy != ManagedBy {
+		t.Fatalf("managed_by = %q, want %q", p.ManagedBy, ManagedBy)
+	}
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Probe runs a single check; nil means healthy.
+type Probe func(ctx context.Context) error
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a probe error that retrying cannot fix, such as an exited
+// container. Wait returns it immediately.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Wait runs probe every interval until it succeeds, returns a permanent
+// error, or timeout elapses. On timeout the last probe error is returned.
+func Wait(ctx context.Context, timeout, interval time.Duration, probe Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := probe(ctx)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not healthy after %s: %w", timeout, err)
+		case <-t.C:
+		}
+	}
+}
+
+// HTTPProbe requests path on addr and expects status, or any 2xx/3xx status
+// when status is 0. Redirects are not followed.
+func HTTPProbe(addr, path string, status int) Probe {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+
+		ok := resp.StatusCode >= 200 && resp.StatusCode < 400
+		if status != 0 {
+			ok = resp.StatusCode == status
+		}
+		if !ok {
+			return fmt.Errorf("GET %s returned %d", path, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// TCPProbe succeeds once addr accepts a connection.
+func TCPProbe(addr string) Probe {
+	return func(ctx context.Context) error {
+		d := net.Dialer{Timeout: 5 * time.Second}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
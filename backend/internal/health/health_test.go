@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	err := Wait(ctx, time.Second, time.Millisecond, func(context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls.Load() != 3 {
+		t.Fatalf("Wait = %v after %d calls, want nil after 3", err, calls.Load())
+	}
+
+	exited := errors.New("container exited")
+	calls.Store(0)
+	err = Wait(ctx, time.Second, time.Millisecond, func(context.Context) error {
+		calls.Add(1)
+		return Permanent(exited)
+	})
+	if !errors.Is(err, exited) || calls.Load() != 1 {
+		t.Fatalf("Wait = %v after %d calls, want exited after 1", err, calls.Load())
+	}
+
+	err = Wait(ctx, 20*time.Millisecond, time.Millisecond, func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("Wait = %v, want timeout with last error", err)
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusNoContent)
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	tests := []struct {
+		path   string
+		status int
+		ok     bool
+	}{
+		{path: "healthz", ok: true},
+		{path: "/healthz", status: http.StatusNoContent, ok: true},
+		{path: "/healthz", status: http.StatusOK, ok: false},
+		{path: "/moved", ok: true},
+		{path: "/moved", status: http.StatusFound, ok: true},
+		{path: "/down", ok: false},
+	}
+	for _, tt := range tests {
+		err := HTTPProbe(addr, tt.path, tt.status)(context.Background())
+		if (err == nil) != tt.ok {
+			t.Errorf("HTTPProbe(%q, %d) = %v, want ok=%v", tt.path, tt.status, err, tt.ok)
+		}
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := l.Addr().String()
+	if err := TCPProbe(addr)(context.Background()); err != nil {
+		t.Fatalf("TCPProbe open port: %v", err)
+	}
+	_ = l.Close()
+	if err := TCPProbe(addr)(context.Background()); err == nil {
+		t.Fatalf("TCPProbe closed port succeeded")
+	}
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+	"last-deploy/internal/workspace"
+)
+
+// backup 把项目的所有数据卷打包到 DataDir/backups/<项目>/<任务ID>.tar.gz，备份 ID 即任务 ID
+func (w *Worker) backup(ctx context.Context, project store.Project, jobID string) error {
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+
+	_ = w.st.SetJobStep(ctx, jobID, "backup_volumes")
+	volumes, err := dk.ProjectVolumeNames(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		return errors.New("project has no volumes")
+	}
+	image, err := dk.HelperImage(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("backing up %s\n", strings.Join(volumes, ", ")))
+
+	fileName := project.ID + "/" + jobID + ".tar.gz"
+	fullPath := filepath.Join(w.cfg.BackupsDir(), project.ID, jobID+".tar.gz")
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(fullPath), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := dk.BackupVolumes(ctx, project.ID, image, volumes, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), fullPath); err != nil {
+		return err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.st.CreateBackup(ctx, store.Backup{
+		ID:        jobID,
+		ProjectID: project.ID,
+		FileName:  fileName,
+		SizeBytes: info.Size(),
+		Volumes:   volumes,
+	}); err != nil {
+		_ = os.Remove(fullPath)
+		return err
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("backup %s written (%d bytes)\n", jobID, info.Size()))
+
+	w.pruneBackups(ctx, project.ID, jobID)
+	return nil
+}
+
+// pruneBackups 按保留数量删除较早的备份
+func (w *Worker) pruneBackups(ctx context.Context, projectID, jobID string) {
+	if w.cfg.BackupRetention <= 0 {
+		return
+	}
+	backups, err := w.st.ListBackups(ctx, projectID)
+	if err != nil || len(backups) <= w.cfg.BackupRetention {
+		return
+	}
+	for _, b := range backups[w.cfg.BackupRetention:] {
+		if err := workspace.RemoveBackup(ctx, w.cfg, w.st, b); err != nil {
+			_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("remove old backup %s: %v\n", b.ID, err))
+			continue
+		}
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("removed old backup %s\n", b.ID))
+	}
+}
+
+// restore 停止项目、用备份替换数据卷内容后重新启动
+func (w *Worker) restore(ctx context.Context, project store.Project, jobID, backupID string) error {
+	b, err := w.st.GetBackup(ctx, project.ID, backupID)
+	if err != nil {
+		return fmt.Errorf("load backup %s: %w", backupID, err)
+	}
+	path, err := workspace.BackupPath(w.cfg, b)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+
+	// 删除容器之前先确定辅助容器使用的镜像
+	image, err := dk.HelperImage(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+
+	isCompose := engine.ResolveDeployType(project.DeployType, project.ComposeFile) == engine.DeployTypeCompose
+	if isCompose {
+		if err := w.composeDown(ctx, project, jobID); err != nil {
+			return err
+		}
+	} else {
+		_ = w.st.SetJobStep(ctx, jobID, "docker_cleanup")
+		if err := dk.RemoveProjectContainers(ctx, project.ID); err != nil {
+			return err
+		}
+	}
+	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusStopped)
+
+	_ = w.st.SetJobStep(ctx, jobID, "restore_volumes")
+	if err := dk.RestoreVolumes(ctx, project.ID, image, b.Volumes, f); err != nil {
+		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
+		return err
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("restored %s from backup %s\n", strings.Join(b.Volumes, ", "), b.ID))
+
+	if isCompose {
+		err = w.composeUp(ctx, project, jobID)
+	} else {
+		_ = w.st.SetJobStep(ctx, jobID, "docker_run")
+		var spec engine.ContainerSpec
+		if spec, err = w.containerSpec(ctx, project); err == nil {
+			err = dk.RunProjectContainer(ctx, spec)
+		}
+	}
+	if err != nil {
+		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
+		return err
+	}
+	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusRunning)
+	return nil
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+	"last-deploy/internal/workspace"
+)
+
+// TrafficSwitch points proxied traffic of a project at fixed addresses while
+// a blue/green deploy replaces its container.
+type TrafficSwitch interface {
+	Pin(ctx context.Context, projectID string, addrs map[int]string) error
+	Unpin(ctx context.Context, projectID string) error
+}
+
+// SetTrafficSwitch 设置蓝绿部署切换流量所用的代理，需在 Run 之前调用
+func (w *Worker) SetTrafficSwitch(ts TrafficSwitch) {
+	w.traffic = ts
+}
+
+// keptError reports a failed deploy after which the previously running
+// container is still serving.
+type keptError struct{ err error }
+
+func (e *keptError) Error() string { return e.err.Error() }
+func (e *keptError) Unwrap() error { return e.err }
+
+// blueGreenDeploy 先构建镜像并在临时端口启动候选容器，健康检查通过后代理切到候选容器，
+// 再用正式端口替换旧容器；任何一步失败都会恢复旧容器
+func (w *Worker) blueGreenDeploy(ctx context.Context, project store.Project, jobID string) error {
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+
+	current := engine.ContainerName(project.ID)
+	previous := engine.PreviousName(project.ID)
+	candidate := engine.CandidateName(project.ID)
+
+	oldRunning := false
+	if st, err := dk.ContainerStatusByName(ctx, current); err == nil {
+		oldRunning = st.State == "running"
+	}
+	kept := func(err error) error {
+		if oldRunning {
+			return &keptError{err: err}
+		}
+		return err
+	}
+
+	workDir, err := workspace.WorkDir(w.cfg, project)
+	if err != nil {
+		return fmt.Errorf("work dir: %w", err)
+	}
+	_ = w.st.SetJobStep(ctx, jobID, "docker_build")
+	if err := dk.BuildProjectImage(ctx, project.ID, workDir, project.DockerfilePath, project.BuildArgs); err != nil {
+		_ = dk.RemoveCandidateImage(ctx, project.ID)
+		return kept(err)
+	}
+
+	spec, err := w.containerSpec(ctx, project)
+	if err != nil {
+		_ = dk.RemoveCandidateImage(ctx, project.ID)
+		return kept(err)
+	}
+	ports := spec.Ports
+	hc := blueGreenHealthCheck(project)
+
+	// 候选容器不发布端口，不与旧容器冲突；健康检查和代理经共享网络访问
+	candidateSpec := spec
+	candidateSpec.NoPublish = true
+	candidateSpec.Candidate = true
+	_ = w.st.SetJobStep(ctx, jobID, "docker_run_candidate")
+	if err := dk.RunProjectContainer(ctx, candidateSpec); err != nil {
+		_ = dk.RemoveContainer(ctx, candidate)
+		_ = dk.RemoveCandidateImage(ctx, project.ID)
+		return kept(err)
+	}
+	_ = w.st.SetJobStep(ctx, jobID, "wait_healthy_candidate")
+	if err := w.waitFor(ctx, jobID, project, hc, containerTarget(dk, candidate)); err != nil {
+		_ = dk.RemoveContainer(ctx, candidate)
+		_ = dk.RemoveCandidateImage(ctx, project.ID)
+		return kept(err)
+	}
+
+	_ = w.st.SetJobStep(ctx, jobID, "switch_traffic")
+	pinned := false
+	if w.traffic != nil {
+		addrs := make(map[int]string)
+		for _, p := range ports {
+			if p.Protocol != "tcp" {
+				continue
+			}
+			if addr, err := dk.ContainerEndpoint(ctx, candidate, p.ContainerPort); err == nil {
+				addrs[p.ContainerPort] = addr
+			}
+		}
+		if len(addrs) > 0 {
+			if err := w.traffic.Pin(ctx, project.ID, addrs); err != nil {
+				_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("proxy switch to candidate failed: %v\n", err))
+			} else {
+				pinned = true
+				_ = w.st.AppendJobLog(ctx, jobID, "proxy traffic switched to candidate container\n")
+			}
+		}
+	}
+	unpin := func() {
+		if pinned {
+			_ = w.traffic.Unpin(context.WithoutCancel(ctx), project.ID)
+			pinned = false
+		}
+	}
+
+	// 旧容器改名并停止以释放正式端口，成功前不删除
+	hadPrevious, err := dk.ContainerExists(ctx, current)
+	if err != nil {
+		unpin()
+		_ = dk.RemoveContainer(ctx, candidate)
+		return kept(err)
+	}
+	if hadPrevious {
+		_ = dk.RemoveContainer(ctx, previous)
+		if err := dk.RenameContainer(ctx, current, previous); err != nil {
+			unpin()
+			_ = dk.RemoveContainer(ctx, candidate)
+			return kept(err)
+		}
+		_ = dk.StopContainer(ctx, previous, 10*time.Second)
+	}
+	restore := func(cause error) error {
+		_ = dk.RemoveContainer(ctx, current)
+		if hadPrevious {
+			_ = dk.RenameContainer(ctx, previous, current)
+			if oldRunning {
+				if err := dk.StartContainer(ctx, current); err != nil {
+					cause = errors.Join(cause, fmt.Errorf("restart previous container: %w", err))
+					oldRunning = false
+				} else {
+					_ = w.st.AppendJobLog(ctx, jobID, "previous container restored\n")
+				}
+			}
+		}
+		unpin()
+		_ = dk.RemoveContainer(ctx, candidate)
+		return kept(cause)
+	}
+
+	// 候选容器已验证，镜像转为正式标签；旧容器按镜像 ID 引用，不受影响
+	if err := dk.PromoteProjectImage(ctx, project.ID); err != nil {
+		return restore(err)
+	}
+	_ = w.st.SetJobStep(ctx, jobID, "docker_run")
+	if err := dk.RunProjectContainer(ctx, spec); err != nil {
+		return restore(err)
+	}
+	_ = w.st.SetJobStep(ctx, jobID, "wait_healthy")
+	if err := w.waitFor(ctx, jobID, project, hc, containerTarget(dk, current)); err != nil {
+		return restore(err)
+	}
+
+	unpin()
+	_ = dk.RemoveContainer(ctx, candidate)
+	_ = dk.RemoveContainer(ctx, previous)
+	_ = w.st.AppendJobLog(ctx, jobID, "blue/green switch complete\n")
+	return nil
+}
+
+// blueGreenHealthCheck 未配置健康检查时，以主端口 TCP 连通作为切换条件
+func blueGreenHealthCheck(project store.Project) store.HealthCheck {
+	if project.HealthCheck.Type != store.HealthCheckNone {
+		return project.HealthCheck
+	}
+	if _, port := project.PrimaryPort(); port > 0 {
+		return store.HealthCheck{Type: store.HealthCheckTCP}
+	}
+	return store.HealthCheck{}
+}
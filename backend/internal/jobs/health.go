@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/health"
+	"last-deploy/internal/store"
+)
+
+const (
+	defaultHealthTimeout = 60 * time.Second
+	healthCheckInterval  = 2 * time.Second
+	healthLogTail        = 50
+)
+
+// probeTarget is what a health check looks at: every container of the
+// project, or a single container during a blue/green deploy.
+type probeTarget struct {
+	statuses func(ctx context.Context) ([]engine.ContainerStatus, error)
+	addr     func(ctx context.Context, service string, port int) (string, error)
+	logs     func(ctx context.Context) (string, error)
+}
+
+func projectTarget(dk *engine.Docker, project store.Project) probeTarget {
+	return probeTarget{
+		statuses: func(ctx context.Context) ([]engine.ContainerStatus, error) {
+			return dk.ProjectContainerStatus(ctx, project.ID)
+		},
+		addr: func(ctx context.Context, service string, port int) (string, error) {
+			ip, err := dk.ContainerAddr(ctx, project.ID, service)
+			if err != nil {
+				return "", err
+			}
+			return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+		},
+		logs: func(ctx context.Context) (string, error) {
+			return dk.ProjectLogs(ctx, project.ID, healthLogTail)
+		},
+	}
+}
+
+func containerTarget(dk *engine.Docker, name string) probeTarget {
+	return probeTarget{
+		statuses: func(ctx context.Context) ([]engine.ContainerStatus, error) {
+			st, err := dk.ContainerStatusByName(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			return []engine.ContainerStatus{st}, nil
+		},
+		addr: func(ctx context.Context, _ string, port int) (string, error) {
+			return dk.ContainerEndpoint(ctx, name, port)
+		},
+		logs: func(ctx context.Context) (string, error) {
+			return dk.ContainerLogs(ctx, name, healthLogTail)
+		},
+	}
+}
+
+// waitHealthy 在容器启动后等待健康检查通过；失败时把最后的容器日志写入任务日志
+func (w *Worker) waitHealthy(ctx context.Context, project store.Project, jobID string) error {
+	if project.HealthCheck.Type == store.HealthCheckNone {
+		return nil
+	}
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+
+	_ = w.st.SetJobStep(ctx, jobID, "wait_healthy")
+	return w.waitFor(ctx, jobID, project, project.HealthCheck, projectTarget(dk, project))
+}
+
+func (w *Worker) waitFor(ctx context.Context, jobID string, project store.Project, hc store.HealthCheck, target probeTarget) error {
+	timeout := time.Duration(hc.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	kind := hc.Type
+	if kind == store.HealthCheckNone {
+		kind = "running"
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("waiting up to %s for %s health check\n", timeout, kind))
+
+	if err := health.Wait(ctx, timeout, healthCheckInterval, healthProbe(project, hc, target)); err != nil {
+		if logs, lerr := target.logs(ctx); lerr == nil && logs != "" {
+			_ = w.st.AppendJobLog(ctx, jobID, "last container logs:\n"+logs)
+		}
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, "health check passed\n")
+	return nil
+}
+
+func healthProbe(project store.Project, hc store.HealthCheck, target probeTarget) health.Probe {
+	service, port := hc.Service, hc.Port
+	if port == 0 {
+		primaryService, primaryPort := project.PrimaryPort()
+		if service == "" {
+			service = primaryService
+		}
+		port = primaryPort
+	}
+	isCompose := engine.ResolveDeployType(project.DeployType, project.ComposeFile) == engine.DeployTypeCompose
+
+	return func(ctx context.Context) error {
+		statuses, err := target.statuses(ctx)
+		if err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			return health.Permanent(errors.New("no containers found"))
+		}
+
+		withHealthcheck := 0
+		for _, st := range statuses {
+			switch st.State {
+			case "running":
+			case "exited", "dead":
+				// compose 中正常退出的一次性服务（如迁移）不算失败
+				if isCompose && strings.HasPrefix(st.Status, "Exited (0)") {
+					continue
+				}
+				return health.Permanent(fmt.Errorf("container %s %s", st.Name, strings.ToLower(st.Status)))
+			default:
+				return fmt.Errorf("container %s is %s", st.Name, st.State)
+			}
+			if st.Health == "" {
+				continue
+			}
+			withHealthcheck++
+			if hc.Type == store.HealthCheckDocker && st.Health != "healthy" {
+				return fmt.Errorf("container %s is %s", st.Name, st.Health)
+			}
+		}
+
+		switch hc.Type {
+		case store.HealthCheckNone:
+			return nil
+		case store.HealthCheckDocker:
+			if withHealthcheck == 0 {
+				return health.Permanent(errors.New("image defines no HEALTHCHECK"))
+			}
+			return nil
+		case store.HealthCheckHTTP, store.HealthCheckTCP:
+			if port == 0 {
+				return health.Permanent(errors.New("no container port to probe"))
+			}
+			addr, err := target.addr(ctx, service, port)
+			if err != nil {
+				return err
+			}
+			if hc.Type == store.HealthCheckHTTP {
+				return health.HTTPProbe(addr, hc.Path, hc.ExpectedStatus)(ctx)
+			}
+			return health.TCPProbe(addr)(ctx)
+		default:
+			return health.Permanent(fmt.Errorf("unknown health check type %q", hc.Type))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+	"last-deploy/internal/workspace"
+)
+
+// rollbackFunc brings back the version that was running before a deploy.
+type rollbackFunc func(ctx context.Context) error
+
+// prepareRollback 在部署开始前记录正在运行的镜像（compose 项目还记录代码提交），
+// 未开启自动回滚、项目未在运行或无可恢复版本时返回 nil
+func (w *Worker) prepareRollback(ctx context.Context, project store.Project, jobID string) rollbackFunc {
+	if !project.AutoRollback {
+		return nil
+	}
+	if project.LastStatus != store.ProjectStatusRunning && project.LastStatus != store.ProjectStatusDegraded {
+		return nil
+	}
+	isCompose := engine.ResolveDeployType(project.DeployType, project.ComposeFile) == engine.DeployTypeCompose
+	// 蓝绿部署失败时本身会保留旧容器
+	if !isCompose && project.DeployStrategy == store.DeployStrategyBlueGreen {
+		return nil
+	}
+
+	dk, err := engine.NewDocker()
+	if err != nil {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("auto rollback unavailable: %v\n", err))
+		return nil
+	}
+	images, err := dk.ProjectImages(ctx, project.ID)
+	_ = dk.Close()
+	if err != nil {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("auto rollback unavailable: %v\n", err))
+		return nil
+	}
+	if len(images) == 0 {
+		return nil
+	}
+
+	if !isCompose {
+		return func(ctx context.Context) error {
+			dk, err := engine.NewDocker()
+			if err != nil {
+				return err
+			}
+			defer dk.Close()
+
+			if err := dk.RemoveProjectContainers(ctx, project.ID); err != nil {
+				return err
+			}
+			if err := dk.RestoreImages(ctx, images); err != nil {
+				return err
+			}
+			spec, err := w.containerSpec(ctx, project)
+			if err != nil {
+				return err
+			}
+			return dk.RunProjectContainer(ctx, spec)
+		}
+	}
+
+	repoDir := workspace.RepoDir(w.cfg, project.ID)
+	commit, err := engine.RepoHead(repoDir)
+	if err != nil {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("auto rollback unavailable: %v\n", err))
+		return nil
+	}
+	return func(ctx context.Context) error {
+		// 浅克隆重新克隆后可能已没有旧提交，检出时按哈希补取
+		auth, err := w.gitAuth.AuthMethod(ctx, project.GitCredentialID)
+		if err != nil {
+			return err
+		}
+		if err := engine.CheckoutCommit(ctx, repoDir, commit, auth); err != nil {
+			return fmt.Errorf("checkout %s: %w", commit, err)
+		}
+		if err := w.writeProjectFiles(ctx, project, jobID); err != nil {
+			return err
+		}
+		dk, err := engine.NewDocker()
+		if err != nil {
+			return err
+		}
+		defer dk.Close()
+		if err := dk.RestoreImages(ctx, images); err != nil {
+			return err
+		}
+		return w.composeUp(ctx, project, jobID)
+	}
+}
+
+// deployFailed 处理旧版本已被停止后的部署失败：开启自动回滚时恢复之前的版本并标记为 degraded，
+// 否则标记为 failed。任务本身始终以原错误失败
+func (w *Worker) deployFailed(ctx context.Context, project store.Project, jobID string, cause error, rollback rollbackFunc) error {
+	if rollback == nil {
+		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
+		return cause
+	}
+
+	_ = w.st.SetJobStep(ctx, jobID, "rollback")
+	_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("deploy failed: %v\nrolling back to the previously running version\n", cause))
+	if err := rollback(ctx); err != nil {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("rollback failed: %v\n", err))
+		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
+		return errors.Join(cause, fmt.Errorf("rollback: %w", err))
+	}
+	_ = w.st.AppendJobLog(ctx, jobID, "rolled back to the previously running version\n")
+	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusDegraded)
+	return cause
+}
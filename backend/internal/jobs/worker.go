@@ -10,6 +10,7 @@ import (
 
 	"last-deploy/internal/config"
 	"last-deploy/internal/engine"
+	"last-deploy/internal/gitauth"
 	"last-deploy/internal/store"
 	"last-deploy/internal/workspace"
 )
@@ -18,12 +19,37 @@ type Worker struct {
 	st    *store.Store
 	queue *Queue
 	cfg   config.Config
+
+	onFinished []func(jobID string)
+	traffic    TrafficSwitch
+	gitAuth    *gitauth.Manager
+	certs      Certificates
+}
+
+// Certificates 删除项目域名上传的证书
+type Certificates interface {
+	DeleteCustom(hostname string) error
 }
 
 func NewWorker(st *store.Store, q *Queue, cfg config.Config) *Worker {
 	return &Worker{st: st, queue: q, cfg: cfg}
 }
 
+// OnFinished 注册任务结束（无论成功失败）后的回调，需在 Run 之前调用
+func (w *Worker) OnFinished(fn func(jobID string)) {
+	w.onFinished = append(w.onFinished, fn)
+}
+
+// SetGitAuth 设置拉取私有仓库所用的凭据，需在 Run 之前调用
+func (w *Worker) SetGitAuth(ga *gitauth.Manager) {
+	w.gitAuth = ga
+}
+
+// SetCertificates 设置删除项目时清理证书所用的证书管理器，需在 Run 之前调用
+func (w *Worker) SetCertificates(c Certificates) {
+	w.certs = c
+}
+
 func (w *Worker) Run(ctx context.Context) {
 	for {
 		select {
@@ -31,6 +57,9 @@ func (w *Worker) Run(ctx context.Context) {
 			return
 		case jobID := <-w.queue.C():
 			w.runJob(ctx, jobID)
+			for _, fn := range w.onFinished {
+				fn(jobID)
+			}
 		}
 	}
 }
@@ -65,7 +94,13 @@ func (w *Worker) runJob(ctx context.Context, jobID string) {
 	case store.JobTypeUnpause:
 		err = w.unpause(ctx, project, jobID)
 	case store.JobTypeDelete:
-		err = w.delete(ctx, project, jobID)
+		err = w.delete(ctx, project, jobID, false)
+	case store.JobTypePurge:
+		err = w.delete(ctx, project, jobID, true)
+	case store.JobTypeBackup:
+		err = w.backup(ctx, project, jobID)
+	case store.JobTypeRestore:
+		err = w.restore(ctx, project, jobID, job.Target)
 	default:
 		err = fmt.Errorf("unknown job type: %q", job.Type)
 	}
@@ -90,15 +125,56 @@ func (w *Worker) deploy(ctx context.Context, project store.Project, jobID string
 	_ = w.st.SetJobStep(ctx, jobID, "set_project_status")
 	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusDeploying)
 
+	// 拉取新代码前记录当前运行的版本
+	rollback := w.prepareRollback(ctx, project, jobID)
+
 	if err := w.cloneProject(ctx, project, jobID); err != nil {
 		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
 		return err
 	}
 
-	// 写入配置文件
+	if err := w.writeProjectFiles(ctx, project, jobID); err != nil {
+		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
+		return err
+	}
+
+	switch engine.ResolveDeployType(project.DeployType, project.ComposeFile) {
+	case engine.DeployTypeCompose:
+		if err := w.composeUp(ctx, project, jobID); err != nil {
+			return w.deployFailed(ctx, project, jobID, err, rollback)
+		}
+		if err := w.waitHealthy(ctx, project, jobID); err != nil {
+			return w.deployFailed(ctx, project, jobID, err, rollback)
+		}
+	default:
+		deployFn := w.dockerfileDeploy
+		if project.DeployStrategy == store.DeployStrategyBlueGreen {
+			deployFn = w.blueGreenDeploy
+		}
+		if err := deployFn(ctx, project, jobID); err != nil {
+			// 旧容器仍在运行时保持原状态
+			var kept *keptError
+			if errors.As(err, &kept) {
+				_ = w.st.SetProjectStatus(ctx, project.ID, project.LastStatus)
+				return err
+			}
+			return w.deployFailed(ctx, project, jobID, err, rollback)
+		}
+		if project.DeployStrategy != store.DeployStrategyBlueGreen {
+			if err := w.waitHealthy(ctx, project, jobID); err != nil {
+				return w.deployFailed(ctx, project, jobID, err, rollback)
+			}
+		}
+	}
+
+	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusRunning)
+	return nil
+}
+
+// writeProjectFiles 把项目保存的 Dockerfile / docker-compose 内容写入工作目录
+func (w *Worker) writeProjectFiles(ctx context.Context, project store.Project, jobID string) error {
 	workDir, err := workspace.WorkDir(w.cfg, project)
 	if err != nil {
-		_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
 		return fmt.Errorf("work dir: %w", err)
 	}
 
@@ -106,7 +182,6 @@ func (w *Worker) deploy(ctx context.Context, project store.Project, jobID string
 	if project.DockerfileContent != "" && project.DockerfilePath != "" {
 		fullPath := filepath.Join(workDir, project.DockerfilePath)
 		if err := os.WriteFile(fullPath, []byte(project.DockerfileContent), 0644); err != nil {
-			_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
 			return fmt.Errorf("write dockerfile: %w", err)
 		}
 		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("wrote Dockerfile to %s\n", project.DockerfilePath))
@@ -116,26 +191,11 @@ func (w *Worker) deploy(ctx context.Context, project store.Project, jobID string
 	if project.ComposeContent != "" && project.ComposeFile != "" {
 		fullPath := filepath.Join(workDir, project.ComposeFile)
 		if err := os.WriteFile(fullPath, []byte(project.ComposeContent), 0644); err != nil {
-			_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
 			return fmt.Errorf("write compose: %w", err)
 		}
 		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("wrote docker-compose to %s\n", project.ComposeFile))
 	}
 
-	switch engine.ResolveDeployType(project.DeployType, project.ComposeFile) {
-	case engine.DeployTypeCompose:
-		if err := w.composeUp(ctx, project, jobID); err != nil {
-			_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
-			return err
-		}
-	default:
-		if err := w.dockerfileDeploy(ctx, project, jobID); err != nil {
-			_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusFailed)
-			return err
-		}
-	}
-
-	_ = w.st.SetProjectStatus(ctx, project.ID, store.ProjectStatusRunning)
 	return nil
 }
 
@@ -251,7 +311,8 @@ func (w *Worker) unpause(ctx context.Context, project store.Project, jobID strin
 	return nil
 }
 
-func (w *Worker) delete(ctx context.Context, project store.Project, jobID string) error {
+// delete 删除项目；purge 为 true 时连同数据卷一起删除，否则保留卷中的数据
+func (w *Worker) delete(ctx context.Context, project store.Project, jobID string, purge bool) error {
 	dk, err := engine.NewDocker()
 	if err != nil {
 		return err
@@ -264,13 +325,56 @@ func (w *Worker) delete(ctx context.Context, project store.Project, jobID string
 	_ = dk.RemoveProjectNetworks(ctx, project.ID)
 	_ = dk.RemoveProjectImage(ctx, project.ID)
 
+	if purge {
+		_ = w.st.SetJobStep(ctx, jobID, "remove_volumes")
+		n, err := dk.RemoveProjectVolumes(ctx, project.ID)
+		if err != nil {
+			return err
+		}
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("removed %d volume(s)\n", n))
+	}
+
 	_ = w.st.SetJobStep(ctx, jobID, "remove_repo")
 	_ = os.RemoveAll(workspace.RepoDir(w.cfg, project.ID))
 
+	_ = w.st.SetJobStep(ctx, jobID, "remove_files")
+	w.removeProjectFiles(ctx, project.ID, jobID)
+
 	_ = w.st.SetJobStep(ctx, jobID, "mark_deleted")
 	return w.st.MarkProjectDeleted(ctx, project.ID)
 }
 
+// removeProjectFiles 删除项目域名上传的证书和卷备份归档，失败只记录到任务日志
+func (w *Worker) removeProjectFiles(ctx context.Context, projectID, jobID string) {
+	if w.certs != nil {
+		domains, err := w.st.ListProjectDomains(ctx, projectID)
+		if err != nil {
+			_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("list domains: %v\n", err))
+		}
+		for _, d := range domains {
+			if err := w.certs.DeleteCustom(d.Hostname); err != nil {
+				_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("remove certificate of %s: %v\n", d.Hostname, err))
+			}
+		}
+	}
+
+	backups, err := w.st.ListBackups(ctx, projectID)
+	if err != nil {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("list backups: %v\n", err))
+	}
+	removed := 0
+	for _, b := range backups {
+		if err := workspace.RemoveBackup(ctx, w.cfg, w.st, b); err != nil {
+			_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("remove backup %s: %v\n", b.FileName, err))
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("removed %d backup(s)\n", removed))
+	}
+}
+
 func (w *Worker) cloneProject(ctx context.Context, project store.Project, jobID string) error {
 	repoDir := workspace.RepoDir(w.cfg, project.ID)
 	_ = w.st.SetJobStep(ctx, jobID, "sync_repo")
@@ -281,7 +385,15 @@ func (w *Worker) cloneProject(ctx context.Context, project store.Project, jobID
 	} else {
 		_ = w.st.AppendJobLog(ctx, jobID, fmt.Sprintf("cloning %s\n", project.GitURL))
 	}
-	return engine.CloneRepo(ctx, project.GitURL, project.GitRef, repoDir)
+	auth, err := w.gitAuth.AuthMethod(ctx, project.GitCredentialID)
+	if err != nil {
+		return err
+	}
+	opts := engine.CloneOptions{Auth: auth, Depth: w.cfg.GitCloneDepth}
+	if project.SparseCheckout && project.RepoSubdir != "" {
+		opts.SparseDirs = []string{project.RepoSubdir}
+	}
+	return engine.CloneRepo(ctx, project.GitURL, project.GitRef, repoDir, opts)
 }
 
 func (w *Worker) dockerfileDeploy(ctx context.Context, project store.Project, jobID string) error {
@@ -291,100 +403,216 @@ func (w *Worker) dockerfileDeploy(ctx context.Context, project store.Project, jo
 	}
 	defer dk.Close()
 
-	_ = w.st.SetJobStep(ctx, jobID, "docker_cleanup")
-	if err := dk.RemoveProjectContainers(ctx, project.ID); err != nil {
-		return err
-	}
-
+	// 先构建候选镜像，构建失败时旧容器继续运行
 	workDir, err := workspace.WorkDir(w.cfg, project)
 	if err != nil {
 		return fmt.Errorf("work dir: %w", err)
 	}
 	_ = w.st.SetJobStep(ctx, jobID, "docker_build")
-	if err := dk.BuildProjectImage(ctx, project.ID, workDir, project.DockerfilePath); err != nil {
+	if err := dk.BuildProjectImage(ctx, project.ID, workDir, project.DockerfilePath, project.BuildArgs); err != nil {
+		_ = dk.RemoveCandidateImage(ctx, project.ID)
+		if st, serr := dk.ContainerStatusByName(ctx, engine.ContainerName(project.ID)); serr == nil && st.State == "running" {
+			_ = w.st.AppendJobLog(ctx, jobID, "build failed, previous container left running\n")
+			return &keptError{err: err}
+		}
+		return err
+	}
+
+	_ = w.st.SetJobStep(ctx, jobID, "docker_cleanup")
+	if err := dk.RemoveProjectContainers(ctx, project.ID); err != nil {
+		return err
+	}
+	if err := dk.PromoteProjectImage(ctx, project.ID); err != nil {
 		return err
 	}
 
 	_ = w.st.SetJobStep(ctx, jobID, "docker_run")
-	return dk.RunProjectContainer(ctx, project.ID, project.HostPort, project.ContainerPort)
+	spec, err := w.containerSpec(ctx, project)
+	if err != nil {
+		return err
+	}
+	return dk.RunProjectContainer(ctx, spec)
 }
 
-func (w *Worker) composeUp(ctx context.Context, project store.Project, jobID string) error {
-	_ = w.st.SetJobStep(ctx, jobID, "compose_up")
+func (w *Worker) containerSpec(ctx context.Context, project store.Project) (engine.ContainerSpec, error) {
+	volumes, err := w.st.ListProjectVolumes(ctx, project.ID)
+	if err != nil {
+		return engine.ContainerSpec{}, fmt.Errorf("load volumes: %w", err)
+	}
+	mounts := make([]engine.VolumeMount, 0, len(volumes))
+	for _, v := range volumes {
+		mounts = append(mounts, engine.VolumeMount{Name: v.Name, Target: v.ContainerPath, ReadOnly: v.ReadOnly})
+	}
+	env, err := w.projectEnv(ctx, project.ID)
+	if err != nil {
+		return engine.ContainerSpec{}, err
+	}
+	bindIP, publish := project.BindAddress()
+	return engine.ContainerSpec{
+		ProjectID: project.ID,
+		Ports:     portBindings(project, bindIP),
+		NoPublish: !publish,
+		Resources: w.resourceLimits(project),
+		Volumes:   mounts,
+		Env:       env,
+	}, nil
+}
+
+// projectEnv 以 NAME=value 形式返回项目的环境变量
+func (w *Worker) projectEnv(ctx context.Context, projectID string) ([]string, error) {
+	vars, err := w.st.ListProjectEnv(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("load env: %w", err)
+	}
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return env, nil
+}
+
+// portBindings 端口自身指定的 host_ip 优先于项目级绑定地址
+func portBindings(project store.Project, bindIP string) []engine.PortBinding {
+	if len(project.Ports) == 0 && project.ContainerPort > 0 {
+		return []engine.PortBinding{{HostIP: bindIP, HostPort: project.HostPort, ContainerPort: project.ContainerPort, Protocol: "tcp"}}
+	}
+	out := make([]engine.PortBinding, 0, len(project.Ports))
+	for _, p := range project.Ports {
+		hostIP := p.HostIP
+		if hostIP == "" {
+			hostIP = bindIP
+		}
+		out = append(out, engine.PortBinding{
+			HostIP:        hostIP,
+			HostPort:      p.HostPort,
+			ContainerPort: p.ContainerPort,
+			Protocol:      p.Protocol,
+		})
+	}
+	return out
+}
+
+// resourceLimits 项目未设置（0）的限制项使用服务端默认值，-1 表示不限制
+func (w *Worker) resourceLimits(project store.Project) engine.Resources {
+	pick := func(v, def int64) int64 {
+		if v != 0 {
+			return v
+		}
+		return def
+	}
+	rl := project.ResourceLimits
+	r := engine.Resources{
+		MemoryMB:     pick(rl.MemoryMB, w.cfg.DefaultMemoryMB),
+		MemorySwapMB: pick(rl.MemorySwapMB, w.cfg.DefaultMemorySwapMB),
+		CPUShares:    pick(rl.CPUShares, w.cfg.DefaultCPUShares),
+		CPUQuota:     pick(rl.CPUQuota, w.cfg.DefaultCPUQuota),
+		PidsLimit:    pick(rl.PidsLimit, w.cfg.DefaultPidsLimit),
+	}
+	// 默认 swap 是按默认内存设定的，与项目自己的内存限制不匹配时不使用
+	if rl.MemorySwapMB == 0 && r.MemorySwapMB > 0 && (r.MemoryMB <= 0 || r.MemorySwapMB < r.MemoryMB) {
+		r.MemorySwapMB = 0
+	}
+	return r
+}
+
+func (w *Worker) composeSpec(ctx context.Context, project store.Project) (engine.ComposeSpec, error) {
 	workDir, err := workspace.WorkDir(w.cfg, project)
 	if err != nil {
-		return fmt.Errorf("work dir: %w", err)
+		return engine.ComposeSpec{}, fmt.Errorf("work dir: %w", err)
+	}
+	env, err := w.projectEnv(ctx, project.ID)
+	if err != nil {
+		return engine.ComposeSpec{}, err
 	}
 	hostWorkDir, _ := workspace.HostWorkDir(w.cfg, project)
-	return engine.ComposeUp(ctx, engine.ComposeSpec{
+	bindIP, publish := project.BindAddress()
+	return engine.ComposeSpec{
 		ProjectID:      project.ID,
 		WorkDir:        workDir,
 		HostWorkDir:    hostWorkDir,
 		ComposeFile:    project.ComposeFile,
 		ComposeService: project.ComposeService,
-	})
+		BindIP:         bindIP,
+		NoPublish:      !publish,
+		Resources:      w.resourceLimits(project),
+		Env:            env,
+	}, nil
+}
+
+func (w *Worker) composeUp(ctx context.Context, project store.Project, jobID string) error {
+	_ = w.st.SetJobStep(ctx, jobID, "compose_up")
+	spec, err := w.composeSpec(ctx, project)
+	if err != nil {
+		return err
+	}
+	if err := engine.ComposeUp(ctx, spec); err != nil {
+		return err
+	}
+	// compose 只创建项目自己的网络，需再接入共享网络供代理和健康检查访问
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+	return dk.ConnectSharedNetwork(ctx, project.ID)
 }
 
 func (w *Worker) composeStop(ctx context.Context, project store.Project, jobID string) error {
 	_ = w.st.SetJobStep(ctx, jobID, "compose_stop")
-	workDir, err := workspace.WorkDir(w.cfg, project)
+	spec, err := w.composeSpec(ctx, project)
 	if err != nil {
-		return fmt.Errorf("work dir: %w", err)
+		return err
 	}
-	hostWorkDir, _ := workspace.HostWorkDir(w.cfg, project)
-	return engine.ComposeStop(ctx, engine.ComposeSpec{
-		ProjectID:      project.ID,
-		WorkDir:        workDir,
-		HostWorkDir:    hostWorkDir,
-		ComposeFile:    project.ComposeFile,
-		ComposeService: project.ComposeService,
-	})
+	return engine.ComposeStop(ctx, spec)
 }
 
 func (w *Worker) composePause(ctx context.Context, project store.Project, jobID string) error {
 	_ = w.st.SetJobStep(ctx, jobID, "compose_pause")
-	workDir, err := workspace.WorkDir(w.cfg, project)
+	spec, err := w.composeSpec(ctx, project)
 	if err != nil {
-		return fmt.Errorf("work dir: %w", err)
+		return err
 	}
-	hostWorkDir, _ := workspace.HostWorkDir(w.cfg, project)
-	return engine.ComposePause(ctx, engine.ComposeSpec{
-		ProjectID:      project.ID,
-		WorkDir:        workDir,
-		HostWorkDir:    hostWorkDir,
-		ComposeFile:    project.ComposeFile,
-		ComposeService: project.ComposeService,
-	})
+	return engine.ComposePause(ctx, spec)
 }
 
 func (w *Worker) composeUnpause(ctx context.Context, project store.Project, jobID string) error {
 	_ = w.st.SetJobStep(ctx, jobID, "compose_unpause")
-	workDir, err := workspace.WorkDir(w.cfg, project)
+	spec, err := w.composeSpec(ctx, project)
 	if err != nil {
-		return fmt.Errorf("work dir: %w", err)
+		return err
 	}
-	hostWorkDir, _ := workspace.HostWorkDir(w.cfg, project)
-	return engine.ComposeUnpause(ctx, engine.ComposeSpec{
-		ProjectID:      project.ID,
-		WorkDir:        workDir,
-		HostWorkDir:    hostWorkDir,
-		ComposeFile:    project.ComposeFile,
-		ComposeService: project.ComposeService,
-	})
+	return engine.ComposeUnpause(ctx, spec)
 }
 
 func (w *Worker) composeDown(ctx context.Context, project store.Project, jobID string) error {
 	_ = w.st.SetJobStep(ctx, jobID, "compose_down")
-	workDir, err := workspace.WorkDir(w.cfg, project)
+	spec, err := w.composeSpec(ctx, project)
 	if err != nil {
-		return fmt.Errorf("work dir: %w", err)
+		return err
 	}
-	hostWorkDir, _ := workspace.HostWorkDir(w.cfg, project)
-	return engine.ComposeDown(ctx, engine.ComposeSpec{
-		ProjectID:      project.ID,
-		WorkDir:        workDir,
-		HostWorkDir:    hostWorkDir,
-		ComposeFile:    project.ComposeFile,
-		ComposeService: project.ComposeService,
-	})
+	return engine.ComposeDown(ctx, spec)
+}
+
+// JoinSharedNetwork 在启动时把服务自身和已部署的项目容器接入共享网络；
+// 旧版本创建的容器只在默认网络上，健康检查和代理无法访问
+func JoinSharedNetwork(ctx context.Context, st *store.Store) error {
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return err
+	}
+	defer dk.Close()
+	if err := dk.JoinSharedNetwork(ctx); err != nil {
+		return err
+	}
+	projects, err := st.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range projects {
+		if err := dk.ConnectSharedNetwork(ctx, p.ID); err != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.ID, err))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,361 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenerateClient writes Go types for the document's component schemas and
+// one method per operation on a Client type. The target package provides
+// Client with these helpers:
+//
+//	do(ctx, method, path string, query url.Values, body, out any) error
+//	stream(ctx, method, path string, query url.Values, body any) (io.ReadCloser, error)
+//
+// A JSON response holding a single property is unwrapped; one that only
+// holds "ok" makes the method return just an error. Other media types are
+// returned as a stream.
+func GenerateClient(doc *Document, pkg, generator string) ([]byte, error) {
+	g := &clientGen{imports: map[string]bool{}}
+	g.types(doc.Components.Schemas)
+	for _, op := range doc.Operations() {
+		if err := g.operation(op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by %s; DO NOT EDIT.\n\npackage %s\n\n", generator, pkg)
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.decls.Bytes())
+	out.Write(g.methods.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated client: %w", err)
+	}
+	return src, nil
+}
+
+type clientGen struct {
+	imports map[string]bool
+	decls   bytes.Buffer
+	methods bytes.Buffer
+}
+
+func (g *clientGen) types(schemas map[string]*Schema) {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.typeDecl(name, schemas[name])
+	}
+}
+
+func (g *clientGen) typeDecl(name string, s *Schema) {
+	g.decls.WriteString("\n")
+	if s.Description != "" {
+		fmt.Fprintf(&g.decls, "// %s\n", s.Description)
+	}
+	if s.Type != "object" || s.AdditionalProperties != nil {
+		fmt.Fprintf(&g.decls, "type %s = %s\n", name, g.goType(s))
+		return
+	}
+	fmt.Fprintf(&g.decls, "type %s struct {\n", name)
+	for _, prop := range s.PropertyOrder {
+		tag := prop
+		if !slices.Contains(s.Required, prop) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&g.decls, "\t%s %s `json:%q`\n", goName(prop), g.goType(s.Properties[prop]), tag)
+	}
+	g.decls.WriteString("}\n")
+}
+
+func (g *clientGen) goType(s *Schema) string {
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.RefName()
+	case len(s.AllOf) == 1:
+		t = g.goType(s.AllOf[0])
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" && s.Format == "int64":
+		t = "int64"
+	case s.Type == "integer":
+		t = "int"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "array":
+		return "[]" + g.goType(s.Items)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map[string]" + g.goType(s.AdditionalProperties)
+	default:
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *clientGen) operation(op PathOperation) error {
+	name := goName(op.OperationID)
+	g.imports["context"] = true
+	g.imports["net/http"] = true
+
+	params := []string{"ctx context.Context"}
+	var pathParams, queryParams []Parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			pathParams = append(pathParams, p)
+			params = append(params, goVar(p.Name)+" string")
+		case "query":
+			queryParams = append(queryParams, p)
+		}
+	}
+
+	body := "nil"
+	if op.RequestBody != nil {
+		mt, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("request body is not JSON")
+		}
+		params = append(params, "req "+g.goType(mt.Schema))
+		body = "req"
+	}
+
+	query := "nil"
+	if len(queryParams) > 0 {
+		paramsType := name + "Params"
+		g.decls.WriteString("\n")
+		fmt.Fprintf(&g.decls, "// %s holds the query parameters of %s.\n", paramsType, name)
+		fmt.Fprintf(&g.decls, "type %s struct {\n", paramsType)
+		for _, p := range queryParams {
+			if p.Description != "" {
+				fmt.Fprintf(&g.decls, "\t// %s\n", p.Description)
+			}
+			fmt.Fprintf(&g.decls, "\t%s %s\n", goName(p.Name), g.goType(p.Schema))
+		}
+		g.decls.WriteString("}\n")
+		params = append(params, "params "+paramsType)
+		query = "q"
+	}
+
+	resp := successResponse(op.Operation)
+	if resp == nil {
+		return fmt.Errorf("no success response")
+	}
+	var (
+		results string
+		call    []string
+	)
+	mt, isJSON := resp.Content["application/json"]
+	switch {
+	case len(resp.Content) == 0:
+		results = "error"
+	case !isJSON:
+		g.imports["io"] = true
+		results = "(io.ReadCloser, error)"
+	default:
+		s := mt.Schema
+		switch {
+		case len(s.Properties) == 1 && s.Properties["ok"] != nil:
+			results = "error"
+		case len(s.Properties) == 1:
+			prop := s.PropertyOrder[0]
+			t := g.goType(s.Properties[prop])
+			results = "(" + t + ", error)"
+			call = append(call,
+				"var out struct {",
+				fmt.Sprintf("%s %s `json:%q`", goName(prop), t, prop),
+				"}")
+		case s.Ref == "" && len(s.Properties) > 1:
+			respType := name + "Response"
+			g.typeDecl(respType, s)
+			results = "(" + respType + ", error)"
+			call = append(call, "var out "+respType)
+		default:
+			t := g.goType(s)
+			results = "(" + t + ", error)"
+			call = append(call, "var out "+t)
+		}
+	}
+
+	fmt.Fprintf(&g.methods, "\n// %s calls %s %s", name, op.Method, op.Path)
+	if op.Summary != "" {
+		fmt.Fprintf(&g.methods, ": %s", lowerFirst(op.Summary))
+	}
+	fmt.Fprintf(&g.methods, ".\nfunc (c *Client) %s(%s) %s {\n", name, strings.Join(params, ", "), results)
+	if len(queryParams) > 0 {
+		g.imports["net/url"] = true
+		g.methods.WriteString("q := url.Values{}\n")
+		for _, p := range queryParams {
+			g.queryParam(p)
+		}
+	}
+	path := g.pathExpr(op.Path, pathParams)
+	method := "http.Method" + methodName(op.Method)
+	switch {
+	case results == "error":
+		fmt.Fprintf(&g.methods, "return c.do(ctx, %s, %s, %s, %s, nil)\n", method, path, query, body)
+	case !isJSON:
+		fmt.Fprintf(&g.methods, "return c.stream(ctx, %s, %s, %s, %s)\n", method, path, query, body)
+	default:
+		for _, line := range call {
+			g.methods.WriteString(line + "\n")
+		}
+		fmt.Fprintf(&g.methods, "err := c.do(ctx, %s, %s, %s, %s, &out)\n", method, path, query, body)
+		if len(mt.Schema.Properties) == 1 && mt.Schema.Ref == "" {
+			fmt.Fprintf(&g.methods, "return out.%s, err\n", goName(mt.Schema.PropertyOrder[0]))
+		} else {
+			g.methods.WriteString("return out, err\n")
+		}
+	}
+	g.methods.WriteString("}\n")
+	return nil
+}
+
+func (g *clientGen) queryParam(p Parameter) {
+	field := "params." + goName(p.Name)
+	switch p.Schema.Type {
+	case "boolean":
+		fmt.Fprintf(&g.methods, "if %s {\nq.Set(%q, \"true\")\n}\n", field, p.Name)
+	case "integer":
+		g.imports["strconv"] = true
+		conv := "strconv.Itoa(" + field + ")"
+		if p.Schema.Format == "int64" {
+			conv = "strconv.FormatInt(" + field + ", 10)"
+		}
+		fmt.Fprintf(&g.methods, "if %s != 0 {\nq.Set(%q, %s)\n}\n", field, p.Name, conv)
+	default:
+		fmt.Fprintf(&g.methods, "if %s != \"\" {\nq.Set(%q, %s)\n}\n", field, p.Name, field)
+	}
+}
+
+// pathExpr turns /projects/{id} into "/projects/"+url.PathEscape(id).
+func (g *clientGen) pathExpr(path string, params []Parameter) string {
+	if len(params) == 0 {
+		return fmt.Sprintf("%q", path)
+	}
+	g.imports["net/url"] = true
+	var parts []string
+	rest := path
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(rest, '}')
+		parts = append(parts, fmt.Sprintf("%q", rest[:i]), "url.PathEscape("+goVar(rest[i+1:j])+")")
+		rest = rest[j+1:]
+	}
+	if rest != "" {
+		parts = append(parts, fmt.Sprintf("%q", rest))
+	}
+	return strings.Join(parts, "+")
+}
+
+// successResponse returns the lowest 2xx response of op.
+func successResponse(op *Operation) *Response {
+	var codes []string
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	sort.Strings(codes)
+	r := op.Responses[codes[0]]
+	return &r
+}
+
+func methodName(method string) string {
+	switch method {
+	case http.MethodGet:
+		return "Get"
+	case http.MethodPost:
+		return "Post"
+	case http.MethodPut:
+		return "Put"
+	case http.MethodPatch:
+		return "Patch"
+	case http.MethodDelete:
+		return "Delete"
+	default:
+		return strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+	}
+}
+
+var initialisms = map[string]string{
+	"api": "API", "cpu": "CPU", "dns": "DNS", "http": "HTTP", "id": "ID", "ip": "IP",
+	"json": "JSON", "mb": "MB", "tls": "TLS", "ttl": "TTL", "url": "URL", "yaml": "YAML",
+}
+
+// goName turns a snake_case or camelCase name into an exported Go name.
+func goName(name string) string {
+	var b strings.Builder
+	for _, w := range words(name) {
+		if up, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(up)
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+// goVar turns a parameter name into an unexported Go name.
+func goVar(name string) string {
+	ws := words(name)
+	first := strings.ToLower(ws[0])
+	return first + strings.TrimPrefix(goName(name), goName(ws[0]))
+}
+
+func words(name string) []string {
+	var ws []string
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		start := 0
+		r := []rune(part)
+		for i := 1; i < len(r); i++ {
+			if unicode.IsUpper(r[i]) && !unicode.IsUpper(r[i-1]) {
+				ws = append(ws, string(r[start:i]))
+				start = i
+			}
+		}
+		ws = append(ws, string(r[start:]))
+	}
+	return ws
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
@@ -0,0 +1,312 @@
+// Package openapi describes HTTP APIs as OpenAPI 3 documents. Schemas are
+// derived from the Go types the handlers encode, so the document follows
+// the code instead of being maintained by hand.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// SecurityRequirement maps a security scheme name to its scopes.
+type SecurityRequirement map[string][]string
+
+// PathItem maps a lower-case HTTP method to its operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+	// Security overrides the document default; an empty slice makes the
+	// operation public.
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+
+	// PropertyOrder lists Properties in declaration order for code
+	// generation; it is not part of the document.
+	PropertyOrder []string `json:"-"`
+}
+
+// Operations returns every operation of the document keyed by method and
+// path, sorted by operation id.
+func (d *Document) Operations() []PathOperation {
+	var ops []PathOperation
+	for path, item := range d.Paths {
+		for method, op := range item {
+			ops = append(ops, PathOperation{Method: strings.ToUpper(method), Path: path, Operation: op})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].OperationID < ops[j].OperationID })
+	return ops
+}
+
+type PathOperation struct {
+	Method string
+	Path   string
+	*Operation
+}
+
+// RefName returns the component name a $ref points at, or "".
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+func refTo(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+// Schemas derives schemas from Go values following encoding/json rules.
+// Named struct types become components referenced with $ref.
+type Schemas struct {
+	names      map[reflect.Type]string
+	types      map[string]reflect.Type
+	components map[string]*Schema
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{
+		names:      map[reflect.Type]string{},
+		types:      map[string]reflect.Type{},
+		components: map[string]*Schema{},
+	}
+}
+
+// Name sets the component name of t, for types whose Go names collide.
+// It must be called before t is first used.
+func (s *Schemas) Name(t reflect.Type, name string) {
+	s.claim(t, name)
+}
+
+// Components returns the component schemas collected so far.
+func (s *Schemas) Components() map[string]*Schema {
+	return s.components
+}
+
+// Object is an inline JSON object such as a gin.H response. Nil pointer
+// values are documented as optional, everything else as required.
+type Object map[string]any
+
+// For returns the schema of v. v is a value of the encoded type, an Object,
+// or a *Schema that is returned as is.
+func (s *Schemas) For(v any) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return &Schema{}
+	case *Schema:
+		return v
+	case Object:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			schema.Properties[k] = s.For(v[k])
+			schema.PropertyOrder = append(schema.PropertyOrder, k)
+			if rv := reflect.ValueOf(v[k]); !rv.IsValid() || rv.Kind() != reflect.Pointer || !rv.IsNil() {
+				schema.Required = append(schema.Required, k)
+			}
+		}
+		return schema
+	}
+	return s.typeSchema(reflect.TypeOf(v))
+}
+
+func (s *Schemas) typeSchema(t reflect.Type) *Schema {
+	if t == rawMessageType {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.typeSchema(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// A nil slice encodes as null.
+		return &Schema{Type: "array", Items: s.typeSchema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			panic(fmt.Sprintf("openapi: unsupported map key in %v", t))
+		}
+		return &Schema{Type: "object", AdditionalProperties: s.typeSchema(t.Elem()), Nullable: true}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = exportedName(t.Name())
+			s.claim(t, name)
+		}
+		if _, ok := s.components[name]; !ok {
+			// Placeholder first so recursive types terminate.
+			s.components[name] = &Schema{}
+			*s.components[name] = *s.structSchema(t)
+		}
+		return refTo(name)
+	default:
+		panic(fmt.Sprintf("openapi: unsupported type %v", t))
+	}
+}
+
+func (s *Schemas) claim(t reflect.Type, name string) {
+	if other, ok := s.types[name]; ok && other != t {
+		panic(fmt.Sprintf("openapi: schema name %s is used by both %v and %v", name, other, t))
+	}
+	s.types[name] = t
+	s.names[t] = name
+}
+
+func (s *Schemas) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *Schemas) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.addFields(schema, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = s.typeSchema(f.Type)
+		schema.PropertyOrder = append(schema.PropertyOrder, name)
+		if !hasOption(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func hasOption(opts, want string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == want {
+			return true
+		}
+	}
+	return false
+}
+
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	c := *s
+	c.Nullable = true
+	return &c
+}
+
+func exportedName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type inner struct {
+	Port int `json:"port"`
+}
+
+type Embedded struct {
+	CreatedAt int64 `json:"created_at"`
+}
+
+type sample struct {
+	Embedded
+	ID       string            `json:"id"`
+	Note     string            `json:"note,omitempty"`
+	Started  *int64            `json:"started,omitempty"`
+	Tags     []string          `json:"tags"`
+	Args     map[string]string `json:"args,omitempty"`
+	Inner    inner             `json:"inner"`
+	Optional *inner            `json:"optional,omitempty"`
+	Raw      json.RawMessage   `json:"raw"`
+	Skipped  string            `json:"-"`
+	hidden   string
+}
+
+func TestSchemas(t *testing.T) {
+	s := NewSchemas()
+	ref := s.For(sample{})
+	if ref.Ref != "#/components/schemas/Sample" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	got := s.Components()["Sample"]
+	wantOrder := []string{"created_at", "id", "note", "started", "tags", "args", "inner", "optional", "raw"}
+	if !slices.Equal(got.PropertyOrder, wantOrder) {
+		t.Fatalf("properties = %v, want %v", got.PropertyOrder, wantOrder)
+	}
+	wantRequired := []string{"created_at", "id", "tags", "inner", "raw"}
+	if !slices.Equal(got.Required, wantRequired) {
+		t.Fatalf("required = %v, want %v", got.Required, wantRequired)
+	}
+
+	tests := []struct {
+		prop string
+		want Schema
+	}{
+		{"created_at", Schema{Type: "integer", Format: "int64"}},
+		{"started", Schema{Type: "integer", Format: "int64", Nullable: true}},
+		{"tags", Schema{Type: "array", Items: &Schema{Type: "string"}, Nullable: true}},
+		{"args", Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}, Nullable: true}},
+		{"inner", Schema{Ref: "#/components/schemas/Inner"}},
+		{"optional", Schema{AllOf: []*Schema{{Ref: "#/components/schemas/Inner"}}, Nullable: true}},
+		{"raw", Schema{}},
+	}
+	for _, tt := range tests {
+		if p := got.Properties[tt.prop]; !reflect.DeepEqual(*p, tt.want) {
+			t.Errorf("%s = %+v, want %+v", tt.prop, *p, tt.want)
+		}
+	}
+
+	obj := s.For(Object{"job": (*inner)(nil), "ok": true})
+	if !slices.Equal(obj.Required, []string{"ok"}) {
+		t.Fatalf("object required = %v", obj.Required)
+	}
+}
+
+func TestSchemasNameCollision(t *testing.T) {
+	type Inner struct{}
+	s := NewSchemas()
+	s.For(inner{})
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for two types named Inner")
+		}
+	}()
+	s.For(Inner{})
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct{ in, name, v string }{
+		{"id", "ID", "id"},
+		{"git_url", "GitURL", "gitURL"},
+		{"portId", "PortID", "portID"},
+		{"memory_mb", "MemoryMB", "memoryMB"},
+		{"getOpenAPI", "GetOpenAPI", "getOpenAPI"},
+		{"allow_destructive", "AllowDestructive", "allowDestructive"},
+	}
+	for _, tt := range tests {
+		if got := goName(tt.in); got != tt.name {
+			t.Errorf("goName(%q) = %q, want %q", tt.in, got, tt.name)
+		}
+		if got := goVar(tt.in); got != tt.v {
+			t.Errorf("goVar(%q) = %q, want %q", tt.in, got, tt.v)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package portalloc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+)
+
+var ErrNoFreePort = errors.New("no free host port in range")
+
+// ConflictError reports an explicitly requested host port that is taken.
+type ConflictError struct {
+	Port        int
+	Protocol    string
+	Suggestions []int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("host port %d/%s is already in use", e.Port, e.Protocol)
+}
+
+// Allocator hands out host ports that are neither reserved by another project
+// in the database nor currently published by Docker. Ports bound by other
+// processes are only detected when the server runs on the host itself.
+type Allocator struct {
+	st    *store.Store
+	start int
+	end   int
+
+	// hostPorts returns ports ("8080/tcp") published by containers outside
+	// excludeProjectID. Errors are ignored so a missing Docker daemon does not
+	// block project creation.
+	hostPorts func(ctx context.Context, excludeProjectID string) (map[string]bool, error)
+	// bound reports whether a port can not be bound on this host right now.
+	// It is nil inside a container, where a listen probe would only test the
+	// container's own network namespace.
+	bound func(port int, protocol string) bool
+}
+
+func New(st *store.Store, start, end int) *Allocator {
+	a := &Allocator{
+		st:        st,
+		start:     start,
+		end:       end,
+		hostPorts: dockerPublishedPorts,
+	}
+	if !inContainer() {
+		a.bound = listenProbe
+	}
+	return a
+}
+
+// snapshot captures everything that makes a port unavailable for a project.
+type snapshot struct {
+	a        *Allocator
+	own      map[string]bool
+	reserved map[string]string
+	docker   map[string]bool
+}
+
+func (a *Allocator) snapshot(ctx context.Context, excludeProjectID string) (*snapshot, error) {
+	reserved, err := a.st.ListUsedHostPorts(ctx, excludeProjectID)
+	if err != nil {
+		return nil, err
+	}
+	// A project's own ports are bound by its running container; they stay
+	// available to it without probing.
+	own := make(map[string]bool)
+	if excludeProjectID != "" {
+		ports, err := a.st.ListProjectPorts(ctx, excludeProjectID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ports {
+			own[fmt.Sprintf("%d/%s", p.HostPort, p.Protocol)] = true
+		}
+	}
+	docker, _ := a.hostPorts(ctx, excludeProjectID)
+	return &snapshot{a: a, own: own, reserved: reserved, docker: docker}, nil
+}
+
+func (s *snapshot) free(port int, protocol string) bool {
+	key := fmt.Sprintf("%d/%s", port, protocol)
+	if s.own[key] {
+		return true
+	}
+	if _, ok := s.reserved[key]; ok {
+		return false
+	}
+	if s.docker[key] {
+		return false
+	}
+	return s.a.bound == nil || !s.a.bound(port, protocol)
+}
+
+// Assign checks the given mappings for projectID. Mappings with an explicit
+// host port must be free, otherwise a *ConflictError with suggestions is
+// returned; mappings without one get the container port if it is free, or the
+// first free port of the range.
+func (a *Allocator) Assign(ctx context.Context, projectID string, ports []store.ProjectPort) ([]store.ProjectPort, error) {
+	snap, err := a.snapshot(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]store.ProjectPort, len(ports))
+	copy(out, ports)
+	pending := make(map[string]bool)
+	take := func(port int, protocol string) bool {
+		key := fmt.Sprintf("%d/%s", port, protocol)
+		if pending[key] || !snap.free(port, protocol) {
+			return false
+		}
+		pending[key] = true
+		return true
+	}
+
+	for i := range out {
+		p := &out[i]
+		if p.Protocol == "" {
+			p.Protocol = "tcp"
+		}
+		if p.HostPort > 0 {
+			if !take(p.HostPort, p.Protocol) {
+				suggestions, _ := a.Suggest(ctx, projectID, p.HostPort, p.Protocol, 5)
+				return nil, &ConflictError{Port: p.HostPort, Protocol: p.Protocol, Suggestions: suggestions}
+			}
+			continue
+		}
+		if take(p.ContainerPort, p.Protocol) {
+			p.HostPort = p.ContainerPort
+			continue
+		}
+		for port := a.start; port <= a.end; port++ {
+			if take(port, p.Protocol) {
+				p.HostPort = port
+				break
+			}
+		}
+		if p.HostPort == 0 {
+			return nil, ErrNoFreePort
+		}
+	}
+	return out, nil
+}
+
+// Suggest returns up to n free ports: the ones right after near first, then
+// the beginning of the configured range.
+func (a *Allocator) Suggest(ctx context.Context, projectID string, near int, protocol string, n int) ([]int, error) {
+	snap, err := a.snapshot(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []int
+	seen := make(map[int]bool)
+	try := func(p int) {
+		if p <= 0 || p > 65535 || seen[p] {
+			return
+		}
+		seen[p] = true
+		if snap.free(p, protocol) {
+			out = append(out, p)
+		}
+	}
+
+	for p := near + 1; p <= near+100 && len(out) < n; p++ {
+		try(p)
+	}
+	for p := a.start; p <= a.end && len(out) < n; p++ {
+		try(p)
+	}
+	return out, nil
+}
+
+func dockerPublishedPorts(ctx context.Context, excludeProjectID string) (map[string]bool, error) {
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return nil, err
+	}
+	defer dk.Close()
+	return dk.PublishedHostPorts(ctx, excludeProjectID)
+}
+
+// inContainer reports whether the server runs inside a Docker container.
+func inContainer() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
+
+func listenProbe(port int, protocol string) bool {
+	addr := net.JoinHostPort("", strconv.Itoa(port))
+	switch protocol {
+	case "udp":
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return true
+		}
+		_ = pc.Close()
+	default:
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return true
+		}
+		_ = l.Close()
+	}
+	return false
+}
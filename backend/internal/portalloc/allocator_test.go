@@ -0,0 +1,89 @@
+package portalloc
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"last-deploy/internal/store"
+)
+
+func newTestAllocator(t *testing.T, bound map[int]bool) (*Allocator, *store.Store) {
+	t.Helper()
+	st, err := store.Open(context.Background(), filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	a := New(st, 20000, 20004)
+	a.hostPorts = func(context.Context, string) (map[string]bool, error) {
+		return map[string]bool{"20001/tcp": true}, nil
+	}
+	a.bound = func(port int, _ string) bool { return bound[port] }
+	return a, st
+}
+
+func TestAssign(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAllocator(t, map[int]bool{20002: true})
+
+	if _, err := st.CreateProject(ctx, store.Project{ID: "p1", Name: "p1", GitURL: "u", HostPort: 8080, ContainerPort: 8080}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	got, err := a.Assign(ctx, "", []store.ProjectPort{
+		{ContainerPort: 8080},                  // taken in db -> range
+		{ContainerPort: 9000},                  // free -> same as container port
+		{ContainerPort: 53, Protocol: "udp"},   // free
+		{ContainerPort: 8080, Protocol: "udp"}, // other protocol -> free
+		{ContainerPort: 8081},                  // free
+		{ContainerPort: 8081},                  // taken by the previous entry -> range
+	})
+	if err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	var hostPorts []int
+	for _, p := range got {
+		hostPorts = append(hostPorts, p.HostPort)
+	}
+	// 20001 is published by docker and 20002 is bound on the host.
+	if want := []int{20000, 9000, 53, 8080, 8081, 20003}; !reflect.DeepEqual(hostPorts, want) {
+		t.Fatalf("host ports = %v, want %v", hostPorts, want)
+	}
+
+	// The owning project keeps its own port.
+	got, err = a.Assign(ctx, "p1", []store.ProjectPort{{HostPort: 8080, ContainerPort: 80}})
+	if err != nil || got[0].HostPort != 8080 {
+		t.Fatalf("Assign own port = %+v, %v", got, err)
+	}
+}
+
+func TestAssign_ExplicitConflict(t *testing.T) {
+	ctx := context.Background()
+	a, st := newTestAllocator(t, nil)
+
+	if _, err := st.CreateProject(ctx, store.Project{ID: "p1", Name: "p1", GitURL: "u", HostPort: 8080, ContainerPort: 8080}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	_, err := a.Assign(ctx, "", []store.ProjectPort{{HostPort: 8080, ContainerPort: 80}})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Assign err = %v, want *ConflictError", err)
+	}
+	if len(conflict.Suggestions) != 5 || conflict.Suggestions[0] != 8081 {
+		t.Fatalf("suggestions = %v", conflict.Suggestions)
+	}
+}
+
+func TestAssign_RangeExhausted(t *testing.T) {
+	a, _ := newTestAllocator(t, map[int]bool{80: true, 20000: true, 20002: true, 20003: true, 20004: true})
+
+	_, err := a.Assign(context.Background(), "", []store.ProjectPort{{ContainerPort: 80}})
+	if !errors.Is(err, ErrNoFreePort) {
+		t.Fatalf("Assign err = %v, want ErrNoFreePort", err)
+	}
+}
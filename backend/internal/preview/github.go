@@ -0,0 +1,141 @@
+package preview
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Pull request actions handled by Manager.Handle. Other actions, such as
+// edited, are ignored.
+const (
+	ActionOpened      = "opened"
+	ActionReopened    = "reopened"
+	ActionSynchronize = "synchronize"
+	ActionLabeled     = "labeled"
+	ActionClosed      = "closed"
+)
+
+// Event is the part of a GitHub pull_request webhook that previews use.
+type Event struct {
+	Action   string
+	Number   int
+	HeadRef  string
+	HeadSHA  string
+	HeadRepo Repo
+	BaseRepo Repo
+	// Fork is set when the head branch lives outside the base repository,
+	// including forks that have since been deleted.
+	Fork   bool
+	Author string
+	Labels []string
+}
+
+// Repo lists the URLs a repository is known by.
+type Repo struct {
+	FullName string `json:"full_name"`
+	CloneURL string `json:"clone_url"`
+	SSHURL   string `json:"ssh_url"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// Matches reports whether gitURL points at the repository, ignoring the
+// scheme, credentials and a trailing .git.
+func (r Repo) Matches(gitURL string) bool {
+	want := repoKey(gitURL)
+	if want == "" {
+		return false
+	}
+	for _, u := range []string{r.CloneURL, r.SSHURL, r.HTMLURL} {
+		if repoKey(u) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// repoKey reduces https://user@host/owner/repo.git and git@host:owner/repo
+// to host/owner/repo.
+func repoKey(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	} else if host, path, ok := strings.Cut(u, ":"); ok && !strings.Contains(host, "/") {
+		// scp-like syntax
+		u = host + "/" + path
+	}
+	if i := strings.Index(u, "@"); i >= 0 && i < strings.Index(u+"/", "/") {
+		u = u[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+}
+
+// ParseEvent decodes the body of a pull_request webhook.
+func ParseEvent(body []byte) (Event, error) {
+	var payload struct {
+		Action      string `json:"action"`
+		Number      int    `json:"number"`
+		PullRequest struct {
+			Head struct {
+				Ref  string `json:"ref"`
+				SHA  string `json:"sha"`
+				Repo *Repo  `json:"repo"`
+			} `json:"head"`
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+			Labels []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"pull_request"`
+		Repository Repo `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Event{}, err
+	}
+	if payload.Number <= 0 || payload.Action == "" {
+		return Event{}, fmt.Errorf("not a pull_request event")
+	}
+	ev := Event{
+		Action:   payload.Action,
+		Number:   payload.Number,
+		HeadRef:  payload.PullRequest.Head.Ref,
+		HeadSHA:  payload.PullRequest.Head.SHA,
+		BaseRepo: payload.Repository,
+		HeadRepo: payload.Repository,
+		Author:   payload.PullRequest.User.Login,
+	}
+	// The head repository is null when the fork was deleted.
+	if head := payload.PullRequest.Head.Repo; head != nil {
+		ev.HeadRepo = *head
+		ev.Fork = repoKey(head.CloneURL) != repoKey(payload.Repository.CloneURL)
+	} else {
+		ev.Fork = true
+	}
+	for _, l := range payload.PullRequest.Labels {
+		ev.Labels = append(ev.Labels, l.Name)
+	}
+	return ev, nil
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook.
+func VerifySignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, sign(secret, body))
+}
+
+func sign(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,341 @@
+// Package preview deploys a short-lived copy of a template project for each
+// open pull request of its repository. Previews are created and updated from
+// GitHub pull_request webhooks and removed when the pull request closes or
+// their TTL passes without a new push.
+package preview
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/proxy"
+	"last-deploy/internal/store"
+)
+
+// ManagedBy marks preview projects so that other sources leave them alone.
+const ManagedBy = "preview"
+
+// Callback events.
+const (
+	EventDeployed = "deployed"
+	EventFailed   = "failed"
+	EventRemoved  = "removed"
+)
+
+// sweepInterval is how often expired previews are looked for.
+const sweepInterval = time.Minute
+
+var (
+	ErrDisabled     = errors.New("previews are not configured")
+	ErrRepoMismatch = errors.New("the webhook is for another repository than the template project")
+	ErrNotTemplate  = errors.New("a preview project cannot be used as a template")
+)
+
+// Projects creates preview projects and queues their jobs. The API server
+// implements it so that previews get the same port allocation as clones.
+type Projects interface {
+	CloneProject(ctx context.Context, srcID string, p store.Project, opts store.CloneOptions) (store.Project, error)
+	EnqueueJob(ctx context.Context, projectID, jobType string) (store.Job, error)
+}
+
+// Callback is the JSON body posted to PreviewCallbackURL.
+type Callback struct {
+	Event      string `json:"event"`
+	TemplateID string `json:"template_id"`
+	ProjectID  string `json:"project_id"`
+	PRNumber   int    `json:"pr_number"`
+	HeadSHA    string `json:"head_sha"`
+	URL        string `json:"url,omitempty"`
+	HostPort   int    `json:"host_port,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+}
+
+type Manager struct {
+	cfg  config.Config
+	st   *store.Store
+	px   *proxy.Proxy
+	proj Projects
+	http *http.Client
+
+	// mu serializes webhook handling and sweeps so one PR never gets two
+	// projects.
+	mu sync.Mutex
+}
+
+func New(cfg config.Config, st *store.Store, px *proxy.Proxy) *Manager {
+	return &Manager{cfg: cfg, st: st, px: px, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// SetProjects must be called before Run or Handle.
+func (m *Manager) SetProjects(p Projects) {
+	m.proj = p
+}
+
+func (m *Manager) Enabled() bool {
+	return m.cfg.PreviewWebhookSecret != ""
+}
+
+// VerifySignature checks a webhook body against PreviewWebhookSecret.
+func (m *Manager) VerifySignature(body []byte, header string) bool {
+	return VerifySignature(m.cfg.PreviewWebhookSecret, body, header)
+}
+
+// Run removes expired previews every sweepInterval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	if !m.Enabled() {
+		return
+	}
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := m.Sweep(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("preview: %v", err)
+		}
+	}
+}
+
+// Handle applies a pull_request event to the previews of the template
+// project. It returns the affected preview, or a zero Preview when the event
+// needs no action.
+func (m *Manager) Handle(ctx context.Context, templateID string, ev Event) (store.Preview, error) {
+	if !m.Enabled() {
+		return store.Preview{}, ErrDisabled
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tpl, err := m.st.GetProject(ctx, templateID)
+	if err != nil {
+		return store.Preview{}, err
+	}
+	if tpl.ManagedBy == ManagedBy {
+		return store.Preview{}, ErrNotTemplate
+	}
+	if !ev.BaseRepo.Matches(tpl.GitURL) {
+		return store.Preview{}, ErrRepoMismatch
+	}
+
+	existing, err := m.st.GetPreview(ctx, tpl.ID, ev.Number)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		existing = store.Preview{}
+	case err != nil:
+		return store.Preview{}, err
+	}
+
+	switch ev.Action {
+	case ActionOpened, ActionReopened, ActionSynchronize, ActionLabeled:
+		// A label only matters when it approves a fork that has no preview yet.
+		if ev.Action == ActionLabeled && existing.ProjectID != "" {
+			return store.Preview{}, nil
+		}
+		if ev.Fork && !m.forkApproved(ev) {
+			log.Printf("preview: %s PR #%d from fork %s by %s is not approved", tpl.ID, ev.Number, ev.HeadRepo.FullName, ev.Author)
+			return store.Preview{}, nil
+		}
+		return m.deploy(ctx, tpl, existing, ev)
+	case ActionClosed:
+		if existing.ProjectID == "" {
+			return store.Preview{}, nil
+		}
+		return existing, m.remove(ctx, existing)
+	default:
+		return store.Preview{}, nil
+	}
+}
+
+// forkApproved reports whether a PR from a fork may be deployed.
+func (m *Manager) forkApproved(ev Event) bool {
+	for _, u := range m.cfg.PreviewForkUsers {
+		if strings.EqualFold(u, ev.Author) {
+			return true
+		}
+	}
+	return m.cfg.PreviewForkLabel != "" && slices.Contains(ev.Labels, m.cfg.PreviewForkLabel)
+}
+
+// deploy creates the preview project on the first event of a PR, or points
+// it at the new head, and queues a deploy. Every push extends the TTL.
+func (m *Manager) deploy(ctx context.Context, tpl store.Project, pv store.Preview, ev Event) (store.Preview, error) {
+	// PRs from forks are built from the fork.
+	gitURL := tpl.GitURL
+	if ev.Fork && ev.HeadRepo.CloneURL != "" {
+		gitURL = ev.HeadRepo.CloneURL
+	}
+
+	if pv.ProjectID == "" {
+		p := tpl
+		p.Name = fmt.Sprintf("%s-pr-%d", tpl.Name, ev.Number)
+		p.GitURL, p.GitRef = gitURL, ev.HeadSHA
+		p.ManagedBy = ManagedBy
+		// Fork code must not see the template's secrets.
+		var opts store.CloneOptions
+		if ev.Fork {
+			p.GitCredentialID = ""
+			opts.OmitEnvValues = true
+		}
+		// Host ports of the template are taken; let the allocator pick.
+		p.Ports = make([]store.ProjectPort, len(tpl.Ports))
+		for i, port := range tpl.Ports {
+			port.ID, port.HostPort = "", 0
+			p.Ports[i] = port
+		}
+		created, err := m.proj.CloneProject(ctx, tpl.ID, p, opts)
+		if err != nil {
+			return store.Preview{}, err
+		}
+		pv = store.Preview{ProjectID: created.ID, TemplateID: tpl.ID, PRNumber: ev.Number}
+	} else if pv.HeadSHA != ev.HeadSHA {
+		if err := m.st.UpdateProjectSettings(ctx, pv.ProjectID, store.ProjectSettings{GitURL: &gitURL, GitRef: &ev.HeadSHA}); err != nil {
+			return store.Preview{}, err
+		}
+	}
+
+	pv.HeadRef, pv.HeadSHA = ev.HeadRef, ev.HeadSHA
+	pv.ExpiresAt = time.Now().Add(m.cfg.PreviewTTL).Unix()
+	pv, err := m.st.SavePreview(ctx, pv)
+	if err != nil {
+		return store.Preview{}, err
+	}
+	if _, err := m.proj.EnqueueJob(ctx, pv.ProjectID, store.JobTypeDeploy); err != nil {
+		return store.Preview{}, err
+	}
+	return pv, nil
+}
+
+// remove queues the deletion of the preview project together with its
+// volumes and forgets the preview.
+func (m *Manager) remove(ctx context.Context, pv store.Preview) error {
+	if _, err := m.st.GetProject(ctx, pv.ProjectID); err == nil {
+		if _, err := m.proj.EnqueueJob(ctx, pv.ProjectID, store.JobTypePurge); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err := m.st.DeletePreview(ctx, pv.ProjectID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	m.notify(Callback{Event: EventRemoved, TemplateID: pv.TemplateID, ProjectID: pv.ProjectID, PRNumber: pv.PRNumber, HeadSHA: pv.HeadSHA})
+	return nil
+}
+
+// Sweep removes the previews whose TTL has passed.
+func (m *Manager) Sweep(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired, err := m.st.ListExpiredPreviews(ctx, now.Unix())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, pv := range expired {
+		if err := m.remove(ctx, pv); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", pv.ProjectID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// JobFinished reports the outcome of preview deploys to the callback. It is
+// registered with the job worker.
+func (m *Manager) JobFinished(jobID string) {
+	if m.cfg.PreviewCallbackURL == "" {
+		return
+	}
+	ctx := context.Background()
+	job, err := m.st.GetJob(ctx, jobID)
+	if err != nil || job.Type != store.JobTypeDeploy {
+		return
+	}
+	pv, err := m.st.GetPreviewByProject(ctx, job.ProjectID)
+	if err != nil {
+		return
+	}
+	p, err := m.st.GetProject(ctx, pv.ProjectID)
+	if err != nil {
+		return
+	}
+	cb := Callback{
+		Event:      EventDeployed,
+		TemplateID: pv.TemplateID,
+		ProjectID:  pv.ProjectID,
+		PRNumber:   pv.PRNumber,
+		HeadSHA:    pv.HeadSHA,
+		URL:        m.URL(p),
+		HostPort:   p.HostPort,
+		ExpiresAt:  pv.ExpiresAt,
+	}
+	if job.Status != store.JobStatusSucceeded {
+		cb.Event = EventFailed
+	}
+	m.notify(cb)
+}
+
+// URL returns the address of a preview behind the proxy, or "" when no base
+// domain is configured.
+func (m *Manager) URL(p store.Project) string {
+	if m.px == nil {
+		return ""
+	}
+	host := m.px.DefaultHostname(p)
+	if host == "" {
+		return ""
+	}
+	if m.cfg.ProxyTLSAddr != "" {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+// notify posts cb to PreviewCallbackURL in the background. The body is
+// signed like GitHub webhooks, in X-Last-Deploy-Signature-256.
+func (m *Manager) notify(cb Callback) {
+	if m.cfg.PreviewCallbackURL == "" {
+		return
+	}
+	body, err := json.Marshal(cb)
+	if err != nil {
+		return
+	}
+	go func() {
+		if err := m.post(body); err != nil {
+			log.Printf("preview: callback %s for %s: %v", cb.Event, cb.ProjectID, err)
+		}
+	}()
+}
+
+func (m *Manager) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, m.cfg.PreviewCallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Last-Deploy-Signature-256", "sha256="+hex.EncodeToString(sign(m.cfg.PreviewWebhookSecret, body)))
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package preview
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"last-deploy/internal/config"
+	"last-deploy/internal/store"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	good := "sha256=" + hex.EncodeToString(sign("s3cret", body))
+	cases := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{name: "valid", secret: "s3cret", header: good, want: true},
+		{name: "wrong secret", secret: "other", header: good},
+		{name: "no secret", secret: "", header: good},
+		{name: "missing", secret: "s3cret", header: ""},
+		{name: "sha1", secret: "s3cret", header: "sha1=" + good[7:]},
+		{name: "not hex", secret: "s3cret", header: "sha256=zz"},
+	}
+	for _, tc := range cases {
+		if got := VerifySignature(tc.secret, body, tc.header); got != tc.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRepoMatches(t *testing.T) {
+	r := Repo{
+		CloneURL: "https://github.com/acme/web.git",
+		SSHURL:   "git@github.com:acme/web.git",
+		HTMLURL:  "https://github.com/acme/web",
+	}
+	cases := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://github.com/acme/web.git", want: true},
+		{url: "https://github.com/Acme/Web", want: true},
+		{url: "https://token@github.com/acme/web.git", want: true},
+		{url: "git@github.com:acme/web", want: true},
+		{url: "ssh://git@github.com/acme/web.git", want: true},
+		{url: "https://github.com/acme/web-fork.git"},
+		{url: "https://gitlab.com/acme/web.git"},
+		{url: ""},
+	}
+	for _, tc := range cases {
+		if got := r.Matches(tc.url); got != tc.want {
+			t.Errorf("Matches(%q) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	body := []byte(`{
+		"action": "synchronize",
+		"number": 7,
+		"pull_request": {
+			"head": {"ref": "feature", "sha": "abc123", "repo": {"clone_url": "https://github.com/bob/web.git"}},
+			"user": {"login": "bob"},
+			"labels": [{"name": "safe-to-preview"}]
+		},
+		"repository": {"full_name": "acme/web", "clone_url": "https://github.com/acme/web.git"}
+	}`)
+	ev, err := ParseEvent(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Action != ActionSynchronize || ev.Number != 7 || ev.HeadRef != "feature" || ev.HeadSHA != "abc123" {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev.HeadRepo.CloneURL != "https://github.com/bob/web.git" || ev.BaseRepo.FullName != "acme/web" || !ev.Fork {
+		t.Errorf("repos = %+v / %+v", ev.HeadRepo, ev.BaseRepo)
+	}
+	if ev.Author != "bob" || !slices.Equal(ev.Labels, []string{"safe-to-preview"}) {
+		t.Errorf("author = %q, labels = %v", ev.Author, ev.Labels)
+	}
+
+	// A deleted fork leaves the head repository null.
+	ev, err = ParseEvent([]byte(`{"action":"closed","number":7,"pull_request":{"head":{"repo":null}},"repository":{"clone_url":"https://github.com/acme/web.git"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.HeadRepo != ev.BaseRepo || !ev.Fork {
+		t.Errorf("head repo = %+v, want the base repository of a fork", ev.HeadRepo)
+	}
+
+	if _, err := ParseEvent([]byte(`{"zen":"ping"}`)); err == nil {
+		t.Error("ParseEvent accepted a ping payload")
+	}
+}
+
+// fakeProjects clones projects directly in the store and records jobs.
+type fakeProjects struct {
+	st   *store.Store
+	jobs []string
+}
+
+func (f *fakeProjects) CloneProject(ctx context.Context, srcID string, p store.Project, opts store.CloneOptions) (store.Project, error) {
+	p.ID = p.Name
+	return f.st.CloneProject(ctx, srcID, p, opts)
+}
+
+func (f *fakeProjects) EnqueueJob(ctx context.Context, projectID, jobType string) (store.Job, error) {
+	f.jobs = append(f.jobs, jobType+":"+projectID)
+	return store.Job{ProjectID: projectID, Type: jobType}, nil
+}
+
+func TestHandle(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(ctx, filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	tpl := store.Project{ID: "tpl", Name: "web", GitURL: "https://github.com/acme/web.git", GitRef: "main",
+		Ports: []store.ProjectPort{{ContainerPort: 80, HostPort: 8080, Protocol: "tcp"}}}
+	if _, err := st.CreateProject(ctx, tpl); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+
+	if _, err := st.SetProjectEnv(ctx, store.ProjectEnvVar{ProjectID: "tpl", Name: "API_KEY", Value: "s3cret"}); err != nil {
+		t.Fatalf("SetProjectEnv: %v", err)
+	}
+
+	m := New(config.Config{PreviewWebhookSecret: "s", PreviewTTL: time.Hour, PreviewForkLabel: "safe-to-preview"}, st, nil)
+	fp := &fakeProjects{st: st}
+	m.SetProjects(fp)
+
+	base := Repo{CloneURL: "https://github.com/acme/web.git"}
+	fork := Repo{CloneURL: "https://github.com/bob/web.git"}
+	ev := Event{Action: ActionOpened, Number: 3, HeadRef: "fix", HeadSHA: "aaa", HeadRepo: fork, BaseRepo: base, Fork: true, Author: "bob"}
+
+	// Forks wait for the approval label.
+	if pv, err := m.Handle(ctx, "tpl", ev); err != nil || pv.ProjectID != "" {
+		t.Fatalf("Handle unapproved fork = %+v, %v", pv, err)
+	}
+	ev.Action, ev.Labels = ActionLabeled, []string{"safe-to-preview"}
+	pv, err := m.Handle(ctx, "tpl", ev)
+	if err != nil {
+		t.Fatalf("Handle labeled: %v", err)
+	}
+	p, err := st.GetProject(ctx, pv.ProjectID)
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	if p.Name != "web-pr-3" || p.GitRef != "aaa" || p.GitURL != fork.CloneURL || p.ManagedBy != ManagedBy {
+		t.Errorf("preview project = %+v", p)
+	}
+	if env, _ := st.ListProjectEnv(ctx, p.ID); len(env) != 1 || env[0].Value != "" {
+		t.Errorf("fork preview env = %+v, want names without values", env)
+	}
+	if len(p.Ports) != 1 || p.Ports[0].HostPort == 8080 {
+		t.Errorf("preview ports = %+v, want the template port reassigned", p.Ports)
+	}
+
+	ev.Action, ev.HeadSHA = ActionSynchronize, "bbb"
+	if _, err := m.Handle(ctx, "tpl", ev); err != nil {
+		t.Fatalf("Handle synchronize: %v", err)
+	}
+	if p, _ = st.GetProject(ctx, pv.ProjectID); p.GitRef != "bbb" {
+		t.Errorf("git ref after push = %q", p.GitRef)
+	}
+
+	if got, err := m.Handle(ctx, "tpl", Event{Action: ActionLabeled, Number: 3, HeadSHA: "bbb", HeadRepo: fork, BaseRepo: base, Fork: true}); err != nil || got.ProjectID != "" {
+		t.Errorf("Handle labeled again = %+v, %v", got, err)
+	}
+
+	if _, err := m.Handle(ctx, pv.ProjectID, ev); !errors.Is(err, ErrNotTemplate) {
+		t.Errorf("Handle on a preview: err = %v", err)
+	}
+	other := ev
+	other.BaseRepo = Repo{CloneURL: "https://github.com/acme/api.git"}
+	if _, err := m.Handle(ctx, "tpl", other); !errors.Is(err, ErrRepoMismatch) {
+		t.Errorf("Handle for another repo: err = %v", err)
+	}
+
+	ev.Action = ActionClosed
+	if _, err := m.Handle(ctx, "tpl", ev); err != nil {
+		t.Fatalf("Handle closed: %v", err)
+	}
+	if _, err := st.GetPreview(ctx, "tpl", 3); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("preview after close: err = %v", err)
+	}
+
+	want := []string{"deploy:web-pr-3", "deploy:web-pr-3", "purge:web-pr-3"}
+	if !slices.Equal(fp.jobs, want) {
+		t.Errorf("jobs = %v, want %v", fp.jobs, want)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.Open(ctx, filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	for _, id := range []string{"tpl", "old", "new"} {
+		if _, err := st.CreateProject(ctx, store.Project{ID: id, Name: id, GitURL: "u"}); err != nil {
+			t.Fatalf("CreateProject: %v", err)
+		}
+	}
+	now := time.Now()
+	for i, pv := range []store.Preview{
+		{ProjectID: "old", TemplateID: "tpl", PRNumber: 1, ExpiresAt: now.Add(-time.Minute).Unix()},
+		{ProjectID: "new", TemplateID: "tpl", PRNumber: 2, ExpiresAt: now.Add(time.Hour).Unix()},
+	} {
+		if _, err := st.SavePreview(ctx, pv); err != nil {
+			t.Fatalf("SavePreview %d: %v", i, err)
+		}
+	}
+
+	m := New(config.Config{PreviewWebhookSecret: "s", PreviewTTL: time.Hour}, st, nil)
+	fp := &fakeProjects{st: st}
+	m.SetProjects(fp)
+	if err := m.Sweep(ctx, now); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if !slices.Equal(fp.jobs, []string{"purge:old"}) {
+		t.Errorf("jobs = %v", fp.jobs)
+	}
+	left, err := st.ListPreviews(ctx, "tpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].ProjectID != "new" {
+		t.Errorf("previews after sweep = %+v", left)
+	}
+}
@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"last-deploy/internal/engine"
+	"last-deploy/internal/store"
+)
+
+const reloadInterval = 30 * time.Second
+
+// Route is one entry of the routing table. Target is nil when the project is
+// not running or its container could not be located.
+type Route struct {
+	Hostname  string
+	ProjectID string
+	Target    *url.URL
+
+	handler http.Handler
+}
+
+// Proxy is an HTTP reverse proxy that routes requests by Host header to
+// project containers. The routing table is rebuilt from the store on Reload.
+type Proxy struct {
+	st         *store.Store
+	baseDomain string
+
+	// containerAddr locates a project container on engine.SharedNetwork.
+	containerAddr func(ctx context.Context, projectID, service string) (string, error)
+
+	// reloadMu serialises reloads so a pin is never overwritten by a table
+	// built before it.
+	reloadMu sync.Mutex
+
+	mu     sync.RWMutex
+	routes map[string]*Route
+	// pins override the upstream of a project's container ports while a
+	// blue/green deploy replaces its container.
+	pins map[string]map[int]string
+
+	trigger chan struct{}
+}
+
+func New(st *store.Store, baseDomain string) *Proxy {
+	return &Proxy{
+		st:            st,
+		baseDomain:    baseDomain,
+		containerAddr: dockerContainerAddr,
+		routes:        make(map[string]*Route),
+		pins:          make(map[string]map[int]string),
+		trigger:       make(chan struct{}, 1),
+	}
+}
+
+// Run reloads the routing table whenever Trigger is called, and periodically
+// to pick up containers restarted outside of last-deploy jobs.
+func (p *Proxy) Run(ctx context.Context) {
+	if err := p.Reload(ctx); err != nil {
+		log.Printf("proxy: reload routes: %v", err)
+	}
+	t := time.NewTicker(reloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-p.trigger:
+		}
+		if err := p.Reload(ctx); err != nil {
+			log.Printf("proxy: reload routes: %v", err)
+		}
+	}
+}
+
+// Trigger schedules a reload without blocking the caller.
+func (p *Proxy) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// DefaultHostname returns <slug(name)>.<base-domain>, or "" when no base
+// domain is configured.
+func (p *Proxy) DefaultHostname(project store.Project) string {
+	if p.baseDomain == "" {
+		return ""
+	}
+	slug := Slug(project.Name)
+	if slug == "" {
+		slug = project.ID
+	}
+	return slug + "." + p.baseDomain
+}
+
+// Pin routes the given container ports of a project to fixed addresses and
+// reloads the routing table before returning.
+func (p *Proxy) Pin(ctx context.Context, projectID string, addrs map[int]string) error {
+	p.mu.Lock()
+	p.pins[projectID] = addrs
+	p.mu.Unlock()
+	return p.Reload(ctx)
+}
+
+// Unpin restores regular routing for a project and reloads synchronously.
+func (p *Proxy) Unpin(ctx context.Context, projectID string) error {
+	p.mu.Lock()
+	delete(p.pins, projectID)
+	p.mu.Unlock()
+	return p.Reload(ctx)
+}
+
+// HostPolicy only lets ACME issue certificates for routed hostnames.
+func (p *Proxy) HostPolicy(_ context.Context, host string) error {
+	p.mu.RLock()
+	_, ok := p.routes[strings.ToLower(host)]
+	p.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("host %q is not routed by the proxy", host)
+	}
+	return nil
+}
+
+// Routes returns a snapshot of the routing table.
+func (p *Proxy) Routes() []Route {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]Route, 0, len(p.routes))
+	for _, r := range p.routes {
+		out = append(out, *r)
+	}
+	return out
+}
+
+func (p *Proxy) Reload(ctx context.Context) error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	projects, err := p.st.ListProjects(ctx)
+	if err != nil {
+		return err
+	}
+	domains, err := p.st.ListActiveDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]store.Project, len(projects))
+	routes := make(map[string]*Route)
+	// Default hostnames first so that custom domains win on collisions.
+	// Projects are listed newest first; iterate backwards so the oldest
+	// project keeps a contested default hostname.
+	for i := len(projects) - 1; i >= 0; i-- {
+		project := projects[i]
+		byID[project.ID] = project
+		host := p.DefaultHostname(project)
+		if host == "" {
+			continue
+		}
+		if _, taken := routes[host]; taken {
+			continue
+		}
+		routes[host] = p.buildRoute(ctx, host, project, "", 0)
+	}
+	for _, d := range domains {
+		project, ok := byID[d.ProjectID]
+		if !ok {
+			continue
+		}
+		routes[d.Hostname] = p.buildRoute(ctx, d.Hostname, project, d.Service, d.ContainerPort)
+	}
+
+	p.mu.Lock()
+	p.routes = routes
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Proxy) buildRoute(ctx context.Context, host string, project store.Project, service string, containerPort int) *Route {
+	r := &Route{Hostname: host, ProjectID: project.ID}
+	// degraded 项目仍在运行回滚后的旧版本
+	if project.LastStatus != store.ProjectStatusRunning && project.LastStatus != store.ProjectStatusDegraded {
+		return r
+	}
+	addr, err := p.upstream(ctx, project, service, containerPort)
+	if err != nil {
+		log.Printf("proxy: %s: %v", host, err)
+		return r
+	}
+	r.Target = &url.URL{Scheme: "http", Host: addr}
+	r.handler = newReverseProxy(r.Target)
+	return r
+}
+
+// upstream picks the address to dial for a project port: the pinned address
+// during a blue/green switch, otherwise the container address on
+// engine.SharedNetwork.
+func (p *Proxy) upstream(ctx context.Context, project store.Project, service string, containerPort int) (string, error) {
+	if containerPort == 0 {
+		primaryService, primaryPort := project.PrimaryPort()
+		if service == "" {
+			service = primaryService
+		}
+		containerPort = primaryPort
+	}
+	if containerPort == 0 {
+		return "", fmt.Errorf("project has no container port")
+	}
+
+	p.mu.RLock()
+	pinned, ok := p.pins[project.ID][containerPort]
+	p.mu.RUnlock()
+	if ok {
+		return pinned, nil
+	}
+
+	ip, err := p.containerAddr(ctx, project.ID, service)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(containerPort)), nil
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	p.mu.RLock()
+	route := p.routes[host]
+	p.mu.RUnlock()
+
+	if route == nil {
+		http.Error(w, "unknown host", http.StatusNotFound)
+		return
+	}
+	if route.handler == nil {
+		http.Error(w, "project is not running", http.StatusServiceUnavailable)
+		return
+	}
+	route.handler.ServeHTTP(w, r)
+}
+
+func newReverseProxy(target *url.URL) http.Handler {
+	// ReverseProxy forwards Upgrade requests as-is, so WebSockets pass through.
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Host = pr.In.Host
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("proxy: %s: %v", r.Host, err)
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		},
+	}
+}
+
+var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug turns a project name into a DNS label.
+func Slug(name string) string {
+	s := slugRe.ReplaceAllString(strings.ToLower(name), "-")
+	s = strings.Trim(s, "-")
+	if len(s) > 63 {
+		s = strings.TrimRight(s[:63], "-")
+	}
+	return s
+}
+
+func dockerContainerAddr(ctx context.Context, projectID, service string) (string, error) {
+	dk, err := engine.NewDocker()
+	if err != nil {
+		return "", err
+	}
+	defer dk.Close()
+	addr, err := dk.ContainerAddr(ctx, projectID, service)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
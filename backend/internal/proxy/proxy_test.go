@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"last-deploy/internal/store"
+)
+
+func newTestProxy(t *testing.T) (*Proxy, *store.Store) {
+	t.Helper()
+	st, err := store.Open(context.Background(), filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	p := New(st, "apps.example.com")
+	p.containerAddr = func(context.Context, string, string) (string, error) {
+		return "127.0.0.1", nil
+	}
+	return p, st
+}
+
+// backendPort starts an upstream that echoes the Host header and switches
+// protocols on "Upgrade: websocket", then echoes raw bytes.
+func backendPort(t *testing.T) int {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			fmt.Fprintf(w, "host=%s xff=%s", r.Host, r.Header.Get("X-Forwarded-Host"))
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	t.Cleanup(srv.Close)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func TestServeHTTP_Routing(t *testing.T) {
+	ctx := context.Background()
+	p, st := newTestProxy(t)
+	port := backendPort(t)
+
+	if _, err := st.CreateProject(ctx, store.Project{ID: "a", Name: "My App", GitURL: "u", ContainerPort: port}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if err := st.SetProjectStatus(ctx, "a", store.ProjectStatusRunning); err != nil {
+		t.Fatalf("SetProjectStatus: %v", err)
+	}
+	if _, err := st.CreateProject(ctx, store.Project{ID: "b", Name: "stopped", GitURL: "u", ContainerPort: port + 1}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if _, err := st.CreateProjectDomain(ctx, store.ProjectDomain{ID: "d1", ProjectID: "a", Hostname: "www.example.org"}); err != nil {
+		t.Fatalf("CreateProjectDomain: %v", err)
+	}
+	if err := p.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	tests := []struct {
+		host string
+		code int
+		body string
+	}{
+		{host: "my-app.apps.example.com", code: http.StatusOK, body: "host=my-app.apps.example.com xff=my-app.apps.example.com"},
+		{host: "WWW.example.org:8000", code: http.StatusOK, body: "host=WWW.example.org:8000"},
+		{host: "stopped.apps.example.com", code: http.StatusServiceUnavailable},
+		{host: "other.example.com", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: code = %d, want %d", tt.host, rec.Code, tt.code)
+			continue
+		}
+		if tt.body != "" && !strings.HasPrefix(rec.Body.String(), tt.body) {
+			t.Errorf("%s: body = %q, want prefix %q", tt.host, rec.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestServeHTTP_WebSocketUpgrade(t *testing.T) {
+	ctx := context.Background()
+	p, st := newTestProxy(t)
+	port := backendPort(t)
+
+	if _, err := st.CreateProject(ctx, store.Project{ID: "a", Name: "ws", GitURL: "u", ContainerPort: port}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if err := st.SetProjectStatus(ctx, "a", store.ProjectStatusRunning); err != nil {
+		t.Fatalf("SetProjectStatus: %v", err)
+	}
+	if err := p.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	front := httptest.NewServer(p)
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	_, _ = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: ws.apps.example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+
+	_, _ = io.WriteString(conn, "ping")
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("echo = %q, want ping", buf)
+	}
+}
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	p, st := newTestProxy(t)
+	oldPort := backendPort(t)
+	candidate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "candidate")
+	}))
+	defer candidate.Close()
+
+	if _, err := st.CreateProject(ctx, store.Project{ID: "a", Name: "app", GitURL: "u", ContainerPort: oldPort}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if err := st.SetProjectStatus(ctx, "a", store.ProjectStatusRunning); err != nil {
+		t.Fatalf("SetProjectStatus: %v", err)
+	}
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = "app.apps.example.com"
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if err := p.Pin(ctx, "a", map[int]string{oldPort: candidate.Listener.Addr().String()}); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if got := get(); got != "candidate" {
+		t.Fatalf("pinned body = %q, want candidate", got)
+	}
+	if err := p.Unpin(ctx, "a"); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+	if got := get(); !strings.HasPrefix(got, "host=") {
+		t.Fatalf("unpinned body = %q, want the regular upstream", got)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"My App":      "my-app",
+		"  --api_v2 ": "api-v2",
+		"中文":          "",
+	}
+	for in, want := range tests {
+		if got := Slug(in); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
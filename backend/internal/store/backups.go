@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Backup is a volume snapshot of a project. FileName is relative to the
+// backups directory; Volumes lists the Docker volumes it contains.
+type Backup struct {
+	ID        string   `json:"id"`
+	ProjectID string   `json:"project_id"`
+	FileName  string   `json:"file_name"`
+	SizeBytes int64    `json:"size_bytes"`
+	Volumes   []string `json:"volumes"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// ListBackups returns the backups of a project, newest first.
+func (s *Store) ListBackups(ctx context.Context, projectID string) ([]Backup, error) {
+	return s.queryBackups(ctx, `
+		SELECT id, project_id, file_name, size_bytes, volumes, created_at
+		FROM backups
+		WHERE project_id = ?
+		ORDER BY created_at DESC, rowid DESC`, projectID)
+}
+
+func (s *Store) GetBackup(ctx context.Context, projectID, id string) (Backup, error) {
+	backups, err := s.queryBackups(ctx, `
+		SELECT id, project_id, file_name, size_bytes, volumes, created_at
+		FROM backups
+		WHERE id = ? AND project_id = ?`, id, projectID)
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) == 0 {
+		return Backup{}, ErrNotFound
+	}
+	return backups[0], nil
+}
+
+func (s *Store) CreateBackup(ctx context.Context, b Backup) (Backup, error) {
+	if b.ID == "" {
+		return Backup{}, fmt.Errorf("backup id is required")
+	}
+	if b.ProjectID == "" {
+		return Backup{}, fmt.Errorf("project id is required")
+	}
+	if b.CreatedAt == 0 {
+		b.CreatedAt = time.Now().Unix()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO backups (id, project_id, file_name, size_bytes, volumes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		b.ID, b.ProjectID, b.FileName, b.SizeBytes, strings.Join(b.Volumes, ","), b.CreatedAt)
+	if err != nil {
+		return Backup{}, err
+	}
+	return b, nil
+}
+
+func (s *Store) DeleteBackup(ctx context.Context, projectID, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM backups
+		WHERE id = ? AND project_id = ?`, id, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) queryBackups(ctx context.Context, query string, args ...any) ([]Backup, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Backup
+	for rows.Next() {
+		var b Backup
+		var volumes string
+		if err := rows.Scan(&b.ID, &b.ProjectID, &b.FileName, &b.SizeBytes, &volumes, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		if volumes != "" {
+			b.Volumes = strings.Split(volumes, ",")
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBackups(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	for i, id := range []string{"b1", "b2"} {
+		b := Backup{ID: id, ProjectID: "a", FileName: "a/" + id + ".tar.gz", SizeBytes: 10, Volumes: []string{"v1", "v2"}, CreatedAt: int64(100 + i)}
+		if _, err := st.CreateBackup(ctx, b); err != nil {
+			t.Fatalf("CreateBackup %s: %v", id, err)
+		}
+	}
+
+	list, err := st.ListBackups(ctx, "a")
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "b2" || list[1].ID != "b1" {
+		t.Fatalf("ListBackups = %+v, want newest first", list)
+	}
+	if !reflect.DeepEqual(list[0].Volumes, []string{"v1", "v2"}) {
+		t.Errorf("Volumes = %v, want [v1 v2]", list[0].Volumes)
+	}
+
+	if _, err := st.GetBackup(ctx, "other", "b1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBackup from another project = %v, want ErrNotFound", err)
+	}
+	if err := st.DeleteBackup(ctx, "a", "b1"); err != nil {
+		t.Fatalf("DeleteBackup: %v", err)
+	}
+	if _, err := st.GetBackup(ctx, "a", "b1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetBackup after delete = %v, want ErrNotFound", err)
+	}
+	if err := st.DeleteBackup(ctx, "a", "b1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteBackup twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestJobTarget(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if _, err := st.CreateJob(ctx, Job{ID: "j1", ProjectID: "a", Type: JobTypeRestore, Status: JobStatusQueued, Target: "b1"}); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	job, err := st.GetJob(ctx, "j1")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.Target != "b1" {
+		t.Fatalf("Target = %q, want b1", job.Target)
+	}
+}
@@ -29,6 +29,11 @@ const (
 	JobTypePause   = "pause"
 	JobTypeUnpause = "unpause"
 	JobTypeDelete  = "delete"
+	// JobTypePurge deletes the project like JobTypeDelete and also removes
+	// its volumes.
+	JobTypePurge   = "purge"
+	JobTypeBackup  = "backup"
+	JobTypeRestore = "restore"
 )
 
 const (
@@ -39,6 +44,31 @@ const (
 	ProjectStatusFailed    = "failed"
 	ProjectStatusDeleted   = "deleted"
 	ProjectStatusDeploying = "deploying"
+	// ProjectStatusDegraded: a deploy failed and the previous version was
+	// rolled back automatically.
+	ProjectStatusDegraded = "degraded"
+)
+
+// Project exposure modes decide on which host address published ports are bound.
+const (
+	ExposeLoopback = "loopback" // 127.0.0.1
+	ExposeAll      = "all"      // 0.0.0.0
+	ExposeIP       = "ip"       // Project.BindIP
+	ExposeNone     = "none"     // not published, reachable through the proxy only
+)
+
+// Health check types. HealthCheckDocker waits for the image's own HEALTHCHECK.
+const (
+	HealthCheckNone   = ""
+	HealthCheckHTTP   = "http"
+	HealthCheckTCP    = "tcp"
+	HealthCheckDocker = "docker"
+)
+
+// Deploy strategies for Dockerfile projects.
+const (
+	DeployStrategyRecreate  = "recreate"
+	DeployStrategyBlueGreen = "blue_green"
 )
 
 type Store struct {
@@ -117,6 +147,187 @@ func (s *Store) migrate(ctx context.Context) error {
 		}
 	}
 
+	// Add expose_mode/bind_ip columns to projects if missing.
+	var emCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'expose_mode'`).Scan(&emCount)
+	if err != nil {
+		return fmt.Errorf("check expose_mode column: %w", err)
+	}
+	if emCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN expose_mode TEXT NOT NULL DEFAULT 'loopback'`); err != nil {
+			return fmt.Errorf("add expose_mode column: %w", err)
+		}
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN bind_ip TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add bind_ip column: %w", err)
+		}
+		// Compose projects used to bind whatever the file said, which for
+		// plain "8080:80" entries means all interfaces. Keep that behaviour.
+		if _, err := s.db.ExecContext(ctx,
+			`UPDATE projects SET expose_mode = 'all' WHERE deploy_type = 'compose'`); err != nil {
+			return fmt.Errorf("migrate compose expose_mode: %w", err)
+		}
+	}
+
+	// Add health check columns to projects if missing.
+	var hcCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'health_type'`).Scan(&hcCount)
+	if err != nil {
+		return fmt.Errorf("check health_type column: %w", err)
+	}
+	if hcCount == 0 {
+		for _, stmt := range []string{
+			`ALTER TABLE projects ADD COLUMN health_type TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE projects ADD COLUMN health_path TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE projects ADD COLUMN health_expected_status INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN health_service TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE projects ADD COLUMN health_port INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN health_timeout INTEGER NOT NULL DEFAULT 60`,
+		} {
+			if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("add health check columns: %w", err)
+			}
+		}
+	}
+
+	// Add deploy_strategy column to projects if missing.
+	var dsCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'deploy_strategy'`).Scan(&dsCount)
+	if err != nil {
+		return fmt.Errorf("check deploy_strategy column: %w", err)
+	}
+	if dsCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN deploy_strategy TEXT NOT NULL DEFAULT 'recreate'`); err != nil {
+			return fmt.Errorf("add deploy_strategy column: %w", err)
+		}
+	}
+
+	// Add target column to jobs if missing.
+	var targetCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('jobs') WHERE name = 'target'`).Scan(&targetCount)
+	if err != nil {
+		return fmt.Errorf("check jobs target column: %w", err)
+	}
+	if targetCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE jobs ADD COLUMN target TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add jobs target column: %w", err)
+		}
+	}
+
+	// Add resource limit columns to projects if missing.
+	var rlCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'memory_mb'`).Scan(&rlCount)
+	if err != nil {
+		return fmt.Errorf("check memory_mb column: %w", err)
+	}
+	if rlCount == 0 {
+		for _, stmt := range []string{
+			`ALTER TABLE projects ADD COLUMN memory_mb INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN memory_swap_mb INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN cpu_shares INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN cpu_quota INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE projects ADD COLUMN pids_limit INTEGER NOT NULL DEFAULT 0`,
+		} {
+			if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("add resource limit columns: %w", err)
+			}
+		}
+	}
+
+	// Add auto_rollback column to projects if missing.
+	var arCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'auto_rollback'`).Scan(&arCount)
+	if err != nil {
+		return fmt.Errorf("check auto_rollback column: %w", err)
+	}
+	if arCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN auto_rollback INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("add auto_rollback column: %w", err)
+		}
+	}
+
+	// Add managed_by column to projects if missing.
+	var mbCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'managed_by'`).Scan(&mbCount)
+	if err != nil {
+		return fmt.Errorf("check managed_by column: %w", err)
+	}
+	if mbCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN managed_by TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add managed_by column: %w", err)
+		}
+	}
+
+	// Add build_args column to projects if missing.
+	var baCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'build_args'`).Scan(&baCount)
+	if err != nil {
+		return fmt.Errorf("check build_args column: %w", err)
+	}
+	if baCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN build_args TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add build_args column: %w", err)
+		}
+	}
+
+	// Add repo_config column to project_drafts if missing.
+	var rcCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('project_drafts') WHERE name = 'repo_config'`).Scan(&rcCount)
+	if err != nil {
+		return fmt.Errorf("check repo_config column: %w", err)
+	}
+	if rcCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE project_drafts ADD COLUMN repo_config TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add repo_config column: %w", err)
+		}
+	}
+
+	// Add git_credential_id column to projects and project_drafts if missing.
+	for _, table := range []string{"projects", "project_drafts"} {
+		var gcCount int
+		err = s.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM pragma_table_info('`+table+`') WHERE name = 'git_credential_id'`).Scan(&gcCount)
+		if err != nil {
+			return fmt.Errorf("check %s git_credential_id column: %w", table, err)
+		}
+		if gcCount == 0 {
+			if _, err := s.db.ExecContext(ctx,
+				`ALTER TABLE `+table+` ADD COLUMN git_credential_id TEXT NOT NULL DEFAULT ''`); err != nil {
+				return fmt.Errorf("add %s git_credential_id column: %w", table, err)
+			}
+		}
+	}
+
+	// Add sparse_checkout column to projects if missing.
+	var scCount int
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('projects') WHERE name = 'sparse_checkout'`).Scan(&scCount)
+	if err != nil {
+		return fmt.Errorf("check sparse_checkout column: %w", err)
+	}
+	if scCount == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			`ALTER TABLE projects ADD COLUMN sparse_checkout INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("add sparse_checkout column: %w", err)
+		}
+	}
+
 	// Migrate old config_content to new columns if config_content column exists.
 	var oldCount int
 	err = s.db.QueryRowContext(ctx,
@@ -170,6 +381,21 @@ func (s *Store) migrate(ctx context.Context) error {
 		}
 	}
 
+	// Backfill project_ports from the legacy single host/container port columns.
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO project_ports (id, project_id, host_port, container_port, protocol, created_at)
+		SELECT lower(hex(randomblob(16))), p.id, p.host_port, p.container_port, 'tcp', p.created_at
+		FROM projects p
+		WHERE p.deleted_at IS NULL AND p.container_port > 0
+		  AND NOT EXISTS (SELECT 1 FROM project_ports pp WHERE pp.project_id = p.id)`); err != nil {
+		return fmt.Errorf("backfill project_ports: %w", err)
+	}
+	// projects.host_port only mirrors the primary mapping now; uniqueness is
+	// per protocol in project_ports, so 5353/udp and 5353/tcp may coexist.
+	if _, err := s.db.ExecContext(ctx, `DROP INDEX IF EXISTS idx_projects_host_port_active`); err != nil {
+		return fmt.Errorf("drop idx_projects_host_port_active: %w", err)
+	}
+
 	return nil
 }
 
@@ -187,17 +413,91 @@ type Project struct {
 	ComposeContent    string `json:"compose_content,omitempty"`
 	HostPort          int    `json:"host_port"`
 	ContainerPort     int    `json:"container_port"`
+	ExposeMode        string `json:"expose_mode"`
+	BindIP            string `json:"bind_ip"`
 	LastStatus        string `json:"last_status"`
 	LastStatusAt      *int64 `json:"last_status_at,omitempty"`
 	DeletedAt         *int64 `json:"deleted_at,omitempty"`
 	CreatedAt         int64  `json:"created_at"`
 	UpdatedAt         int64  `json:"updated_at"`
+
+	// Ports lists every published port; HostPort/ContainerPort mirror the first one.
+	Ports []ProjectPort `json:"ports"`
+
+	HealthCheck    HealthCheck `json:"health_check"`
+	DeployStrategy string      `json:"deploy_strategy"`
+	AutoRollback   bool        `json:"auto_rollback"`
+
+	ResourceLimits ResourceLimits `json:"resource_limits"`
+
+	// ManagedBy is set for projects owned by an external source such as the
+	// GitOps manifest; only those are deleted when they leave the source.
+	ManagedBy string `json:"managed_by"`
+
+	// BuildArgs are passed to docker build for Dockerfile deploys.
+	BuildArgs map[string]string `json:"build_args,omitempty"`
+
+	// GitCredentialID names the stored credential used to clone and fetch
+	// the repository; empty means anonymous access.
+	GitCredentialID string `json:"git_credential_id,omitempty"`
+
+	// SparseCheckout limits the working tree to RepoSubdir.
+	SparseCheckout bool `json:"sparse_checkout"`
+}
+
+// ResourceLimits caps the containers of a project. A zero field falls back
+// to the server-wide default. MemorySwapMB -1 allows unlimited swap;
+// CPUQuota is microseconds of CPU time per 100ms period.
+type ResourceLimits struct {
+	MemoryMB     int64 `json:"memory_mb"`
+	MemorySwapMB int64 `json:"memory_swap_mb"`
+	CPUShares    int64 `json:"cpu_shares"`
+	CPUQuota     int64 `json:"cpu_quota"`
+	PidsLimit    int64 `json:"pids_limit"`
+}
+
+// BindAddress resolves the exposure mode to the host address used for
+// published ports, and reports whether ports are published at all.
+func (p Project) BindAddress() (string, bool) {
+	switch p.ExposeMode {
+	case ExposeAll:
+		return "0.0.0.0", true
+	case ExposeIP:
+		return p.BindIP, true
+	case ExposeNone:
+		return "", false
+	default:
+		return "127.0.0.1", true
+	}
+}
+
+// HealthCheck gates deploy success. Service/Port select the container port
+// probed by http/tcp checks; a zero Port means the primary port.
+type HealthCheck struct {
+	Type           string `json:"type"`
+	Path           string `json:"path"`
+	ExpectedStatus int    `json:"expected_status"`
+	Service        string `json:"service"`
+	Port           int    `json:"port"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// PrimaryPort returns the service and container port used when a domain or
+// health check does not name one.
+func (p Project) PrimaryPort() (string, int) {
+	if len(p.Ports) > 0 {
+		return p.Ports[0].Service, p.Ports[0].ContainerPort
+	}
+	return p.ComposeService, p.ContainerPort
 }
 
 type Job struct {
-	ID          string `json:"id"`
-	ProjectID   string `json:"project_id"`
-	Type        string `json:"type"`
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Type      string `json:"type"`
+	// Target names what the job acts on besides the project, e.g. the
+	// backup a restore job restores.
+	Target      string `json:"target,omitempty"`
 	Status      string `json:"status"`
 	CurrentStep string `json:"current_step"`
 	Log         string `json:"log"`
@@ -217,6 +517,8 @@ type ProjectDraft struct {
 	ComposePath       string   `json:"compose_path"`
 	ComposeContent    string   `json:"compose_content"`
 	Services          []string `json:"services"`
+	RepoConfig        string   `json:"repo_config"` // raw last-deploy.yaml of the repo, if any
+	GitCredentialID   string   `json:"git_credential_id,omitempty"`
 	RepoDir           string   `json:"repo_dir"`
 	CreatedAt         int64    `json:"created_at"`
 	ExpiresAt         int64    `json:"expires_at"`
@@ -255,10 +557,10 @@ func (s *Store) CreateProjectDraft(ctx context.Context, d ProjectDraft) (Project
 	_, err = s.db.ExecContext(ctx, `
 		INSERT INTO project_drafts (
 		  id, name, git_url, deploy_type, dockerfile_path, dockerfile_content,
-		  compose_path, compose_content, services_json, repo_dir, created_at, expires_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		  compose_path, compose_content, services_json, repo_config, git_credential_id, repo_dir, created_at, expires_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		d.ID, d.Name, d.GitURL, d.DeployType, d.DockerfilePath, d.DockerfileContent,
-		d.ComposePath, d.ComposeContent, string(servicesJSON), d.RepoDir, d.CreatedAt, d.ExpiresAt)
+		d.ComposePath, d.ComposeContent, string(servicesJSON), d.RepoConfig, d.GitCredentialID, d.RepoDir, d.CreatedAt, d.ExpiresAt)
 	if err != nil {
 		return ProjectDraft{}, err
 	}
@@ -292,14 +594,14 @@ func (s *Store) GetProjectDraft(ctx context.Context, id string) (ProjectDraft, e
 	}
 	row := s.db.QueryRowContext(ctx, `
 		SELECT id, name, git_url, deploy_type, dockerfile_path, dockerfile_content,
-		       compose_path, compose_content, services_json, repo_dir, created_at, expires_at
+		       compose_path, compose_content, services_json, repo_config, git_credential_id, repo_dir, created_at, expires_at
 		FROM project_drafts
 		WHERE id = ?`, id)
 
 	var d ProjectDraft
 	var servicesJSON string
 	err := row.Scan(&d.ID, &d.Name, &d.GitURL, &d.DeployType, &d.DockerfilePath, &d.DockerfileContent,
-		&d.ComposePath, &d.ComposeContent, &servicesJSON, &d.RepoDir, &d.CreatedAt, &d.ExpiresAt)
+		&d.ComposePath, &d.ComposeContent, &servicesJSON, &d.RepoConfig, &d.GitCredentialID, &d.RepoDir, &d.CreatedAt, &d.ExpiresAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ProjectDraft{}, ErrNotFound
@@ -322,9 +624,7 @@ func (s *Store) DeleteProjectDraft(ctx context.Context, id string) error {
 
 func (s *Store) ListProjects(ctx context.Context) ([]Project, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, name, git_url, git_ref, repo_subdir, deploy_type, compose_file, compose_service,
-		       dockerfile_path, dockerfile_content, compose_content, host_port, container_port, last_status, last_status_at, deleted_at,
-		       created_at, updated_at
+		SELECT `+projectColumns+`
 		FROM projects
 		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC`)
@@ -341,14 +641,24 @@ func (s *Store) ListProjects(ctx context.Context) ([]Project, error) {
 		}
 		out = append(out, p)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ports, err := s.listActiveProjectPorts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Ports = ports[out[i].ID]
+	}
+	return out, nil
 }
 
 func (s *Store) GetProject(ctx context.Context, id string) (Project, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, name, git_url, git_ref, repo_subdir, deploy_type, compose_file, compose_service,
-		       dockerfile_path, dockerfile_content, compose_content, host_port, container_port, last_status, last_status_at, deleted_at,
-		       created_at, updated_at
+		SELECT `+projectColumns+`
 		FROM projects
 		WHERE id = ? AND deleted_at IS NULL`, id)
 	p, err := scanProject(row)
@@ -358,11 +668,73 @@ func (s *Store) GetProject(ctx context.Context, id string) (Project, error) {
 		}
 		return Project{}, err
 	}
+	p.Ports, err = s.ListProjectPorts(ctx, id)
+	if err != nil {
+		return Project{}, err
+	}
 	return p, nil
 }
 
 func (s *Store) CreateProject(ctx context.Context, p Project) (Project, error) {
+	setCreateDefaults(&p, time.Now().Unix())
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Project{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := insertProject(ctx, tx, &p); err != nil {
+		return Project{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Project{}, err
+	}
+	return p, nil
+}
+
+// ProjectAdditions are written together with a project by CreateProjectWith
+// and UpdateProjectWith. Volumes and domains are added to the ones the
+// project has; variables are set like SetProjectEnv does.
+type ProjectAdditions struct {
+	Volumes []ProjectVolume
+	Domains []ProjectDomain
+	Env     []ProjectEnvVar
+}
+
+// CreateProjectWith creates p with every setting UpdateProject stores and
+// writes a, all in one transaction.
+func (s *Store) CreateProjectWith(ctx context.Context, p Project, a ProjectAdditions) (Project, error) {
 	now := time.Now().Unix()
+	setCreateDefaults(&p, now)
+	setUpdateDefaults(&p)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Project{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := insertProject(ctx, tx, &p); err != nil {
+		return Project{}, err
+	}
+	if err := updateProjectRow(ctx, tx, p, now); err != nil {
+		return Project{}, err
+	}
+	if err := addToProject(ctx, tx, p.ID, a, now); err != nil {
+		return Project{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Project{}, err
+	}
+	return p, nil
+}
+
+func setCreateDefaults(p *Project, now int64) {
 	if p.CreatedAt == 0 {
 		p.CreatedAt = now
 	}
@@ -378,19 +750,78 @@ func (s *Store) CreateProject(ctx context.Context, p Project) (Project, error) {
 	if p.DockerfilePath == "" {
 		p.DockerfilePath = "Dockerfile"
 	}
+	if p.ExposeMode == "" {
+		p.ExposeMode = ExposeLoopback
+	}
 
-	_, err := s.db.ExecContext(ctx, `
+	// Without explicit mappings the single host/container pair becomes the only port.
+	if len(p.Ports) == 0 && p.ContainerPort > 0 {
+		p.Ports = []ProjectPort{{HostPort: p.HostPort, ContainerPort: p.ContainerPort}}
+	}
+}
+
+// addToProject writes a for the project id.
+func addToProject(ctx context.Context, tx dbtx, id string, a ProjectAdditions, now int64) error {
+	for _, v := range a.Volumes {
+		v.ProjectID = id
+		if v.CreatedAt == 0 {
+			v.CreatedAt = now
+		}
+		if err := insertProjectVolume(ctx, tx, v); err != nil {
+			return err
+		}
+	}
+	for _, d := range a.Domains {
+		d.ProjectID = id
+		if d.CreatedAt == 0 {
+			d.CreatedAt = now
+		}
+		if err := insertProjectDomain(ctx, tx, d); err != nil {
+			return err
+		}
+	}
+	for _, v := range a.Env {
+		v.ProjectID = id
+		if v.UpdatedAt == 0 {
+			v.UpdatedAt = now
+		}
+		if err := setProjectEnv(ctx, tx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertProject stores the basic fields and the port mappings of p.
+func insertProject(ctx context.Context, tx dbtx, p *Project) error {
+	_, err := tx.ExecContext(ctx, `
 		INSERT INTO projects (
 		  id, name, git_url, git_ref, repo_subdir, deploy_type, compose_file, compose_service,
-		  dockerfile_path, dockerfile_content, compose_content, host_port, container_port, last_status, last_status_at, deleted_at,
-		  created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		  dockerfile_path, dockerfile_content, compose_content, host_port, container_port, expose_mode, bind_ip, last_status, last_status_at, deleted_at,
+		  managed_by, git_credential_id, sparse_checkout, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		p.ID, p.Name, p.GitURL, p.GitRef, p.RepoSubdir, p.DeployType, p.ComposeFile, p.ComposeService,
-		p.DockerfilePath, p.DockerfileContent, p.ComposeContent, p.HostPort, p.ContainerPort, p.LastStatus, nil, nil, p.CreatedAt, p.UpdatedAt)
+		p.DockerfilePath, p.DockerfileContent, p.ComposeContent, 0, 0, p.ExposeMode, p.BindIP, p.LastStatus, nil, nil,
+		p.ManagedBy, p.GitCredentialID, p.SparseCheckout, p.CreatedAt, p.UpdatedAt)
 	if err != nil {
-		return Project{}, err
+		return err
 	}
-	return p, nil
+
+	for i := range p.Ports {
+		p.Ports[i].ProjectID = p.ID
+		p.Ports[i].CreatedAt = p.CreatedAt
+		p.Ports[i], err = insertProjectPort(ctx, tx, p.Ports[i])
+		if err != nil {
+			return err
+		}
+	}
+	if err := syncPrimaryPort(ctx, tx, p.ID); err != nil {
+		return err
+	}
+	if len(p.Ports) > 0 {
+		p.HostPort, p.ContainerPort = p.Ports[0].HostPort, p.Ports[0].ContainerPort
+	}
+	return nil
 }
 
 func (s *Store) SetProjectStatus(ctx context.Context, id, status string) error {
@@ -404,11 +835,151 @@ func (s *Store) SetProjectStatus(ctx context.Context, id, status string) error {
 
 func (s *Store) MarkProjectDeleted(ctx context.Context, id string) error {
 	now := time.Now().Unix()
-	_, err := s.db.ExecContext(ctx, `
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
 		UPDATE projects
 		SET deleted_at = ?, updated_at = ?, last_status = ?, last_status_at = ?
-		WHERE id = ? AND deleted_at IS NULL`, now, now, ProjectStatusDeleted, now, id)
-	return err
+		WHERE id = ? AND deleted_at IS NULL`, now, now, ProjectStatusDeleted, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE project_ports
+		SET deleted_at = ?
+		WHERE project_id = ? AND deleted_at IS NULL`, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE project_domains
+		SET deleted_at = ?
+		WHERE project_id = ? AND deleted_at IS NULL`, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE project_volumes
+		SET deleted_at = ?
+		WHERE project_id = ? AND deleted_at IS NULL`, now, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) SetProjectHealthCheck(ctx context.Context, id string, hc HealthCheck) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET health_type = ?, health_path = ?, health_expected_status = ?, health_service = ?, health_port = ?, health_timeout = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		hc.Type, hc.Path, hc.ExpectedStatus, hc.Service, hc.Port, hc.TimeoutSeconds, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetProjectResourceLimits(ctx context.Context, id string, rl ResourceLimits) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET memory_mb = ?, memory_swap_mb = ?, cpu_shares = ?, cpu_quota = ?, pids_limit = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		rl.MemoryMB, rl.MemorySwapMB, rl.CPUShares, rl.CPUQuota, rl.PidsLimit, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetProjectDeployStrategy(ctx context.Context, id, strategy string) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET deploy_strategy = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, strategy, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetProjectAutoRollback(ctx context.Context, id string, enabled bool) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET auto_rollback = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, enabled, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetProjectManagedBy(ctx context.Context, id, managedBy string) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET managed_by = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, managedBy, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetProjectBuildArgs replaces the build args of a project; nil clears them.
+func (s *Store) SetProjectBuildArgs(ctx context.Context, id string, args map[string]string) error {
+	encoded, err := encodeBuildArgs(args)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET build_args = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, encoded, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) SetProjectExposure(ctx context.Context, id, mode, bindIP string) error {
+	now := time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE projects
+		SET expose_mode = ?, bind_ip = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, mode, bindIP, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 func (s *Store) UpdateProjectConfig(ctx context.Context, id, dockerfileContent, composeContent string) error {
@@ -420,13 +991,255 @@ func (s *Store) UpdateProjectConfig(ctx context.Context, id, dockerfileContent,
 	return err
 }
 
+// UpdateProjectConfigWithPorts stores new config content and replaces the
+// project's primary (first) port mapping. Additional mappings are kept.
 func (s *Store) UpdateProjectConfigWithPorts(ctx context.Context, id, dockerfileContent, composeContent string, hostPort, containerPort int) error {
 	now := time.Now().Unix()
-	_, err := s.db.ExecContext(ctx, `
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
 		UPDATE projects
-		SET dockerfile_content = ?, compose_content = ?, host_port = ?, container_port = ?, updated_at = ?
-		WHERE id = ? AND deleted_at IS NULL`, dockerfileContent, composeContent, hostPort, containerPort, now, id)
-	return err
+		SET dockerfile_content = ?, compose_content = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, dockerfileContent, composeContent, now, id); err != nil {
+		return err
+	}
+
+	var primaryID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM project_ports
+		WHERE project_id = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid
+		LIMIT 1`, id).Scan(&primaryID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if _, err := insertProjectPort(ctx, tx, ProjectPort{ProjectID: id, HostPort: hostPort, ContainerPort: containerPort}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE project_ports SET host_port = ?, container_port = ? WHERE id = ?`,
+			hostPort, containerPort, primaryID); err != nil {
+			return mapConstraintErr(err)
+		}
+	}
+	if err := syncPrimaryPort(ctx, tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateProject stores every user-editable field of p in one transaction.
+// When p.Ports is not nil it replaces the project's port mappings.
+func (s *Store) UpdateProject(ctx context.Context, p Project) error {
+	return s.UpdateProjectWith(ctx, p, ProjectAdditions{})
+}
+
+// UpdateProjectWith is UpdateProject that also writes a in the same
+// transaction.
+func (s *Store) UpdateProjectWith(ctx context.Context, p Project, a ProjectAdditions) error {
+	now := time.Now().Unix()
+	setUpdateDefaults(&p)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := updateProjectRow(ctx, tx, p, now); err != nil {
+		return err
+	}
+	if p.Ports != nil {
+		if err := replaceProjectPorts(ctx, tx, p.ID, p.Ports, now); err != nil {
+			return err
+		}
+	}
+	if err := addToProject(ctx, tx, p.ID, a, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setUpdateDefaults(p *Project) {
+	if p.DeployType == "" {
+		p.DeployType = "auto"
+	}
+	if p.DockerfilePath == "" {
+		p.DockerfilePath = "Dockerfile"
+	}
+	if p.ExposeMode == "" {
+		p.ExposeMode = ExposeLoopback
+	}
+	if p.DeployStrategy == "" {
+		p.DeployStrategy = DeployStrategyRecreate
+	}
+}
+
+// updateProjectRow writes every user-editable column of p; ports are left alone.
+func updateProjectRow(ctx context.Context, tx dbtx, p Project, now int64) error {
+	buildArgs, err := encodeBuildArgs(p.BuildArgs)
+	if err != nil {
+		return err
+	}
+	hc, rl := p.HealthCheck, p.ResourceLimits
+	res, err := tx.ExecContext(ctx, `
+		UPDATE projects
+		SET name = ?, git_url = ?, git_ref = ?, repo_subdir = ?, deploy_type = ?, compose_file = ?, compose_service = ?,
+		  dockerfile_path = ?, dockerfile_content = ?, compose_content = ?, expose_mode = ?, bind_ip = ?,
+		  health_type = ?, health_path = ?, health_expected_status = ?, health_service = ?, health_port = ?, health_timeout = ?,
+		  deploy_strategy = ?, auto_rollback = ?, sparse_checkout = ?,
+		  memory_mb = ?, memory_swap_mb = ?, cpu_shares = ?, cpu_quota = ?, pids_limit = ?,
+		  build_args = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`,
+		p.Name, p.GitURL, p.GitRef, p.RepoSubdir, p.DeployType, p.ComposeFile, p.ComposeService,
+		p.DockerfilePath, p.DockerfileContent, p.ComposeContent, p.ExposeMode, p.BindIP,
+		hc.Type, hc.Path, hc.ExpectedStatus, hc.Service, hc.Port, hc.TimeoutSeconds,
+		p.DeployStrategy, p.AutoRollback, p.SparseCheckout,
+		rl.MemoryMB, rl.MemorySwapMB, rl.CPUShares, rl.CPUQuota, rl.PidsLimit,
+		buildArgs, now, p.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ProjectSettings holds the settings changed by a partial project update.
+// Nil fields are left as they are; Ports, when not nil, replaces every
+// mapping of the project.
+type ProjectSettings struct {
+	Name            *string
+	GitURL          *string
+	GitRef          *string
+	RepoSubdir      *string
+	DeployType      *string
+	ComposeFile     *string
+	ComposeService  *string
+	DockerfilePath  *string
+	ExposeMode      *string
+	BindIP          *string
+	GitCredentialID *string
+	SparseCheckout  *bool
+	Ports           []ProjectPort
+}
+
+// UpdateProjectSettings applies u to the project in one transaction, so a
+// port conflict leaves every other field unchanged. Only the given columns
+// are written, which keeps concurrent changes to other settings intact.
+func (s *Store) UpdateProjectSettings(ctx context.Context, id string, u ProjectSettings) error {
+	now := time.Now().Unix()
+	set := "updated_at = ?"
+	args := []any{now}
+	for _, col := range []struct {
+		name  string
+		value *string
+	}{
+		{"name", u.Name},
+		{"git_url", u.GitURL},
+		{"git_ref", u.GitRef},
+		{"repo_subdir", u.RepoSubdir},
+		{"deploy_type", u.DeployType},
+		{"compose_file", u.ComposeFile},
+		{"compose_service", u.ComposeService},
+		{"dockerfile_path", u.DockerfilePath},
+		{"expose_mode", u.ExposeMode},
+		{"bind_ip", u.BindIP},
+		{"git_credential_id", u.GitCredentialID},
+	} {
+		if col.value != nil {
+			set += ", " + col.name + " = ?"
+			args = append(args, *col.value)
+		}
+	}
+	if u.SparseCheckout != nil {
+		set += ", sparse_checkout = ?"
+		args = append(args, *u.SparseCheckout)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE projects SET `+set+`
+		WHERE id = ? AND deleted_at IS NULL`, append(args, id)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if u.Ports != nil {
+		if err := replaceProjectPorts(ctx, tx, id, u.Ports, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CloneOptions adjusts what CloneProject copies.
+type CloneOptions struct {
+	// OmitEnvValues copies environment variable names with empty values, for
+	// copies that run code which must not see the source's secrets.
+	OmitEnvValues bool
+}
+
+// CloneProject creates p as a copy of the project srcID in one transaction.
+// p is stored with all of its settings and ports; the environment variables
+// and volume definitions of the source are copied, volume data is not.
+func (s *Store) CloneProject(ctx context.Context, srcID string, p Project, opts CloneOptions) (Project, error) {
+	now := time.Now().Unix()
+	p.CreatedAt, p.UpdatedAt = now, now
+	p.LastStatus, p.LastStatusAt = ProjectStatusUnknown, nil
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Project{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := insertProject(ctx, tx, &p); err != nil {
+		return Project{}, err
+	}
+	if err := updateProjectRow(ctx, tx, p, now); err != nil {
+		return Project{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO project_env (project_id, name, value, updated_at)
+		SELECT ?, name, CASE WHEN ? THEN '' ELSE value END, ?
+		FROM project_env
+		WHERE project_id = ?`, p.ID, opts.OmitEnvValues, now, srcID); err != nil {
+		return Project{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO project_volumes (id, project_id, name, container_path, read_only, deleted_at, created_at)
+		SELECT lower(hex(randomblob(16))), ?, name, container_path, read_only, NULL, ?
+		FROM project_volumes
+		WHERE project_id = ? AND deleted_at IS NULL`, p.ID, now, srcID); err != nil {
+		return Project{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Project{}, err
+	}
+	return p, nil
 }
 
 func (s *Store) CreateJob(ctx context.Context, j Job) (Job, error) {
@@ -440,10 +1253,10 @@ func (s *Store) CreateJob(ctx context.Context, j Job) (Job, error) {
 
 	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO jobs (
-		  id, project_id, type, status, current_step, log, error,
+		  id, project_id, type, target, status, current_step, log, error,
 		  requested_at, started_at, finished_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		j.ID, j.ProjectID, j.Type, j.Status, j.CurrentStep, j.Log, j.Error, j.RequestedAt, nil, nil)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		j.ID, j.ProjectID, j.Type, j.Target, j.Status, j.CurrentStep, j.Log, j.Error, j.RequestedAt, nil, nil)
 	if err != nil {
 		return Job{}, err
 	}
@@ -452,7 +1265,7 @@ func (s *Store) CreateJob(ctx context.Context, j Job) (Job, error) {
 
 func (s *Store) GetJob(ctx context.Context, id string) (Job, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, project_id, type, status, current_step, log, error,
+		SELECT id, project_id, type, target, status, current_step, log, error,
 		       requested_at, started_at, finished_at
 		FROM jobs
 		WHERE id = ?`, id)
@@ -469,7 +1282,7 @@ func (s *Store) GetJob(ctx context.Context, id string) (Job, error) {
 
 func (s *Store) ListJobsByStatus(ctx context.Context, status string) ([]Job, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, project_id, type, status, current_step, log, error,
+		SELECT id, project_id, type, target, status, current_step, log, error,
 		       requested_at, started_at, finished_at
 		FROM jobs
 		WHERE status = ?
@@ -492,7 +1305,7 @@ func (s *Store) ListJobsByStatus(ctx context.Context, status string) ([]Job, err
 
 func (s *Store) GetLatestJobByProject(ctx context.Context, projectID string) (Job, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, project_id, type, status, current_step, log, error,
+		SELECT id, project_id, type, target, status, current_step, log, error,
 		       requested_at, started_at, finished_at
 		FROM jobs
 		WHERE project_id = ?
@@ -551,6 +1364,12 @@ func (s *Store) SetJobSucceeded(ctx context.Context, id string) error {
 	return err
 }
 
+// projectColumns matches the order read by scanProject.
+const projectColumns = `id, name, git_url, git_ref, repo_subdir, deploy_type, compose_file, compose_service,
+		       dockerfile_path, dockerfile_content, compose_content, host_port, container_port, expose_mode, bind_ip,
+		       health_type, health_path, health_expected_status, health_service, health_port, health_timeout,
+		       deploy_strategy, auto_rollback, memory_mb, memory_swap_mb, cpu_shares, cpu_quota, pids_limit, managed_by, build_args, git_credential_id, sparse_checkout, last_status, last_status_at, deleted_at, created_at, updated_at`
+
 type scanner interface {
 	Scan(dest ...any) error
 }
@@ -558,15 +1377,24 @@ type scanner interface {
 func scanProject(s scanner) (Project, error) {
 	var lastStatusAt sql.NullInt64
 	var deletedAt sql.NullInt64
+	var buildArgs string
 	var p Project
 	err := s.Scan(
 		&p.ID, &p.Name, &p.GitURL, &p.GitRef, &p.RepoSubdir, &p.DeployType, &p.ComposeFile, &p.ComposeService,
-		&p.DockerfilePath, &p.DockerfileContent, &p.ComposeContent, &p.HostPort, &p.ContainerPort, &p.LastStatus, &lastStatusAt, &deletedAt,
-		&p.CreatedAt, &p.UpdatedAt,
+		&p.DockerfilePath, &p.DockerfileContent, &p.ComposeContent, &p.HostPort, &p.ContainerPort, &p.ExposeMode, &p.BindIP,
+		&p.HealthCheck.Type, &p.HealthCheck.Path, &p.HealthCheck.ExpectedStatus, &p.HealthCheck.Service, &p.HealthCheck.Port, &p.HealthCheck.TimeoutSeconds,
+		&p.DeployStrategy, &p.AutoRollback,
+		&p.ResourceLimits.MemoryMB, &p.ResourceLimits.MemorySwapMB, &p.ResourceLimits.CPUShares, &p.ResourceLimits.CPUQuota, &p.ResourceLimits.PidsLimit,
+		&p.ManagedBy, &buildArgs, &p.GitCredentialID, &p.SparseCheckout, &p.LastStatus, &lastStatusAt, &deletedAt, &p.CreatedAt, &p.UpdatedAt,
 	)
 	if err != nil {
 		return Project{}, err
 	}
+	if buildArgs != "" {
+		if err := json.Unmarshal([]byte(buildArgs), &p.BuildArgs); err != nil {
+			return Project{}, fmt.Errorf("unmarshal build_args: %w", err)
+		}
+	}
 	if lastStatusAt.Valid {
 		v := lastStatusAt.Int64
 		p.LastStatusAt = &v
@@ -578,12 +1406,23 @@ func scanProject(s scanner) (Project, error) {
 	return p, nil
 }
 
+func encodeBuildArgs(args map[string]string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("marshal build_args: %w", err)
+	}
+	return string(b), nil
+}
+
 func scanJob(s scanner) (Job, error) {
 	var startedAt sql.NullInt64
 	var finishedAt sql.NullInt64
 	var j Job
 	err := s.Scan(
-		&j.ID, &j.ProjectID, &j.Type, &j.Status, &j.CurrentStep, &j.Log, &j.Error,
+		&j.ID, &j.ProjectID, &j.Type, &j.Target, &j.Status, &j.CurrentStep, &j.Log, &j.Error,
 		&j.RequestedAt, &startedAt, &finishedAt,
 	)
 	if err != nil {
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDomainConflict is returned when a hostname is already attached to an
+// active project.
+var ErrDomainConflict = errors.New("hostname already in use")
+
+// ProjectDomain routes a hostname to a project's container port. An empty
+// Service/zero ContainerPort fall back to the project's primary port.
+type ProjectDomain struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Hostname      string `json:"hostname"`
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+func (s *Store) ListProjectDomains(ctx context.Context, projectID string) ([]ProjectDomain, error) {
+	return s.queryDomains(ctx, `
+		SELECT id, project_id, hostname, service, container_port, created_at
+		FROM project_domains
+		WHERE project_id = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid`, projectID)
+}
+
+func (s *Store) ListActiveDomains(ctx context.Context) ([]ProjectDomain, error) {
+	return s.queryDomains(ctx, `
+		SELECT id, project_id, hostname, service, container_port, created_at
+		FROM project_domains
+		WHERE deleted_at IS NULL
+		ORDER BY created_at, rowid`)
+}
+
+func (s *Store) GetProjectDomain(ctx context.Context, projectID, id string) (ProjectDomain, error) {
+	domains, err := s.queryDomains(ctx, `
+		SELECT id, project_id, hostname, service, container_port, created_at
+		FROM project_domains
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	if err != nil {
+		return ProjectDomain{}, err
+	}
+	if len(domains) == 0 {
+		return ProjectDomain{}, ErrNotFound
+	}
+	return domains[0], nil
+}
+
+func (s *Store) CreateProjectDomain(ctx context.Context, d ProjectDomain) (ProjectDomain, error) {
+	if d.ID == "" {
+		return ProjectDomain{}, fmt.Errorf("domain id is required")
+	}
+	if d.ProjectID == "" {
+		return ProjectDomain{}, fmt.Errorf("project id is required")
+	}
+	if d.Hostname == "" {
+		return ProjectDomain{}, fmt.Errorf("hostname is required")
+	}
+	if d.CreatedAt == 0 {
+		d.CreatedAt = time.Now().Unix()
+	}
+	if err := insertProjectDomain(ctx, s.db, d); err != nil {
+		return ProjectDomain{}, err
+	}
+	return d, nil
+}
+
+func insertProjectDomain(ctx context.Context, tx dbtx, d ProjectDomain) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO project_domains (id, project_id, hostname, service, container_port, deleted_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.ProjectID, d.Hostname, d.Service, d.ContainerPort, nil, d.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %s", ErrDomainConflict, d.Hostname)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) DeleteProjectDomain(ctx context.Context, projectID, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM project_domains
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) queryDomains(ctx context.Context, query string, args ...any) ([]ProjectDomain, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ProjectDomain
+	for rows.Next() {
+		var d ProjectDomain
+		if err := rows.Scan(&d.ID, &d.ProjectID, &d.Hostname, &d.Service, &d.ContainerPort, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// ProjectEnvVar is an environment variable of a project. Dockerfile
+// containers receive it directly; compose sees it when interpolating the
+// compose file and for environment entries given without a value.
+type ProjectEnvVar struct {
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (s *Store) ListProjectEnv(ctx context.Context, projectID string) ([]ProjectEnvVar, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT project_id, name, value, updated_at
+		FROM project_env
+		WHERE project_id = ?
+		ORDER BY name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ProjectEnvVar
+	for rows.Next() {
+		var v ProjectEnvVar
+		if err := rows.Scan(&v.ProjectID, &v.Name, &v.Value, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// SetProjectEnv creates the variable or replaces its value.
+func (s *Store) SetProjectEnv(ctx context.Context, v ProjectEnvVar) (ProjectEnvVar, error) {
+	if v.UpdatedAt == 0 {
+		v.UpdatedAt = time.Now().Unix()
+	}
+	if err := setProjectEnv(ctx, s.db, v); err != nil {
+		return ProjectEnvVar{}, err
+	}
+	return v, nil
+}
+
+func setProjectEnv(ctx context.Context, tx dbtx, v ProjectEnvVar) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO project_env (project_id, name, value, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (project_id, name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		v.ProjectID, v.Name, v.Value, v.UpdatedAt)
+	return err
+}
+
+func (s *Store) DeleteProjectEnv(ctx context.Context, projectID, name string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM project_env
+		WHERE project_id = ? AND name = ?`, projectID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
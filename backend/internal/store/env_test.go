@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProjectEnv(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	for _, v := range []ProjectEnvVar{
+		{ProjectID: "a", Name: "TOKEN", Value: "one"},
+		{ProjectID: "a", Name: "DATABASE_URL", Value: "postgres://db"},
+		{ProjectID: "a", Name: "TOKEN", Value: "two"},
+	} {
+		if _, err := st.SetProjectEnv(ctx, v); err != nil {
+			t.Fatalf("SetProjectEnv(%s): %v", v.Name, err)
+		}
+	}
+
+	got, err := st.ListProjectEnv(ctx, "a")
+	if err != nil {
+		t.Fatalf("ListProjectEnv: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "DATABASE_URL" || got[1].Name != "TOKEN" || got[1].Value != "two" {
+		t.Fatalf("ListProjectEnv = %+v", got)
+	}
+
+	if err := st.DeleteProjectEnv(ctx, "a", "TOKEN"); err != nil {
+		t.Fatalf("DeleteProjectEnv: %v", err)
+	}
+	if err := st.DeleteProjectEnv(ctx, "a", "TOKEN"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("DeleteProjectEnv again = %v, want ErrNotFound", err)
+	}
+}
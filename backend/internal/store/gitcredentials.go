@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Git credential types.
+const (
+	GitCredentialSSH   = "ssh"
+	GitCredentialToken = "token"
+	GitCredentialBasic = "basic"
+)
+
+var (
+	// ErrCredentialConflict is returned when another credential has the name.
+	ErrCredentialConflict = errors.New("credential name already in use")
+	// ErrCredentialInUse is returned when deleting a credential that
+	// projects still reference.
+	ErrCredentialInUse = errors.New("credential is used by projects")
+)
+
+// GitCredential authenticates clones and fetches of private repositories.
+// Secret holds the private key, token or password encrypted by the caller
+// and is never serialized. Several projects may share one credential.
+type GitCredential struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	Secret     string `json:"-"`
+	PublicKey  string `json:"public_key,omitempty"`
+	KnownHosts string `json:"known_hosts"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+const gitCredentialColumns = `id, name, type, username, secret, public_key, known_hosts, created_at, updated_at`
+
+func (s *Store) ListGitCredentials(ctx context.Context) ([]GitCredential, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+gitCredentialColumns+`
+		FROM git_credentials
+		ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []GitCredential
+	for rows.Next() {
+		c, err := scanGitCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) GetGitCredential(ctx context.Context, id string) (GitCredential, error) {
+	c, err := scanGitCredential(s.db.QueryRowContext(ctx, `
+		SELECT `+gitCredentialColumns+`
+		FROM git_credentials
+		WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return GitCredential{}, ErrNotFound
+	}
+	return c, err
+}
+
+func (s *Store) CreateGitCredential(ctx context.Context, c GitCredential) (GitCredential, error) {
+	now := time.Now().Unix()
+	c.CreatedAt, c.UpdatedAt = now, now
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO git_credentials (`+gitCredentialColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Name, c.Type, c.Username, c.Secret, c.PublicKey, c.KnownHosts, c.CreatedAt, c.UpdatedAt)
+	if isUniqueViolation(err) {
+		return GitCredential{}, fmt.Errorf("%w: %s", ErrCredentialConflict, c.Name)
+	}
+	if err != nil {
+		return GitCredential{}, err
+	}
+	return c, nil
+}
+
+// UpdateGitCredential replaces the name, username, secret, public key and
+// known hosts of the credential. The type never changes.
+func (s *Store) UpdateGitCredential(ctx context.Context, c GitCredential) (GitCredential, error) {
+	c.UpdatedAt = time.Now().Unix()
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE git_credentials
+		SET name = ?, username = ?, secret = ?, public_key = ?, known_hosts = ?, updated_at = ?
+		WHERE id = ?`,
+		c.Name, c.Username, c.Secret, c.PublicKey, c.KnownHosts, c.UpdatedAt, c.ID)
+	if isUniqueViolation(err) {
+		return GitCredential{}, fmt.Errorf("%w: %s", ErrCredentialConflict, c.Name)
+	}
+	if err != nil {
+		return GitCredential{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return GitCredential{}, ErrNotFound
+	}
+	return s.GetGitCredential(ctx, c.ID)
+}
+
+// DeleteGitCredential refuses to delete a credential that a project which
+// is not deleted still uses.
+func (s *Store) DeleteGitCredential(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var users int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM projects
+		WHERE git_credential_id = ? AND deleted_at IS NULL`, id).Scan(&users); err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrCredentialInUse
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM git_credentials WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func scanGitCredential(s scanner) (GitCredential, error) {
+	var c GitCredential
+	err := s.Scan(&c.ID, &c.Name, &c.Type, &c.Username, &c.Secret, &c.PublicKey, &c.KnownHosts, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGitCredentials(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	c, err := st.CreateGitCredential(ctx, GitCredential{ID: "c1", Name: "github", Type: GitCredentialToken, Secret: "enc"})
+	if err != nil {
+		t.Fatalf("CreateGitCredential: %v", err)
+	}
+	if _, err := st.CreateGitCredential(ctx, GitCredential{ID: "c2", Name: "github", Type: GitCredentialSSH}); !errors.Is(err, ErrCredentialConflict) {
+		t.Fatalf("CreateGitCredential duplicate = %v, want ErrCredentialConflict", err)
+	}
+
+	c.KnownHosts = "github.com ssh-ed25519 AAAA"
+	if _, err := st.UpdateGitCredential(ctx, c); err != nil {
+		t.Fatalf("UpdateGitCredential: %v", err)
+	}
+	got, err := st.GetGitCredential(ctx, "c1")
+	if err != nil {
+		t.Fatalf("GetGitCredential: %v", err)
+	}
+	if got.Secret != "enc" || got.KnownHosts != c.KnownHosts || got.Type != GitCredentialToken {
+		t.Fatalf("GetGitCredential = %+v", got)
+	}
+
+	// The credential follows the project through creation, clone and update.
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u", GitCredentialID: "c1"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if _, err := st.CloneProject(ctx, "a", Project{ID: "b", Name: "b", GitURL: "u", GitCredentialID: "c1"}, CloneOptions{}); err != nil {
+		t.Fatalf("CloneProject: %v", err)
+	}
+	if p, _ := st.GetProject(ctx, "b"); p.GitCredentialID != "c1" {
+		t.Fatalf("clone credential = %q", p.GitCredentialID)
+	}
+	if err := st.DeleteGitCredential(ctx, "c1"); !errors.Is(err, ErrCredentialInUse) {
+		t.Fatalf("DeleteGitCredential in use = %v", err)
+	}
+
+	none := ""
+	for _, id := range []string{"a", "b"} {
+		if err := st.UpdateProjectSettings(ctx, id, ProjectSettings{GitCredentialID: &none}); err != nil {
+			t.Fatalf("UpdateProjectSettings: %v", err)
+		}
+	}
+	if err := st.DeleteGitCredential(ctx, "c1"); err != nil {
+		t.Fatalf("DeleteGitCredential: %v", err)
+	}
+	if _, err := st.GetGitCredential(ctx, "c1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetGitCredential after delete = %v", err)
+	}
+}
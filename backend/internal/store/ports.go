@@ -0,0 +1,291 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrPortConflict is returned when a host port is already bound by another
+// active project.
+var ErrPortConflict = errors.New("host port already in use")
+
+type ProjectPort struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Service       string `json:"service"`
+	HostIP        string `json:"host_ip"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *Store) ListProjectPorts(ctx context.Context, projectID string) ([]ProjectPort, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, project_id, service, host_ip, host_port, container_port, protocol, created_at
+		FROM project_ports
+		WHERE project_id = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ProjectPort
+	for rows.Next() {
+		p, err := scanProjectPort(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) listActiveProjectPorts(ctx context.Context) (map[string][]ProjectPort, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, project_id, service, host_ip, host_port, container_port, protocol, created_at
+		FROM project_ports
+		WHERE deleted_at IS NULL
+		ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]ProjectPort)
+	for rows.Next() {
+		p, err := scanProjectPort(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[p.ProjectID] = append(out[p.ProjectID], p)
+	}
+	return out, rows.Err()
+}
+
+// ListUsedHostPorts returns the host ports ("8080/tcp") reserved by active
+// projects other than excludeProjectID, mapped to the owning project id.
+func (s *Store) ListUsedHostPorts(ctx context.Context, excludeProjectID string) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT host_port, protocol, project_id
+		FROM project_ports
+		WHERE deleted_at IS NULL AND host_port > 0 AND project_id != ?`, excludeProjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var port int
+		var protocol, projectID string
+		if err := rows.Scan(&port, &protocol, &projectID); err != nil {
+			return nil, err
+		}
+		out[fmt.Sprintf("%d/%s", port, protocol)] = projectID
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) GetProjectPort(ctx context.Context, projectID, id string) (ProjectPort, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, project_id, service, host_ip, host_port, container_port, protocol, created_at
+		FROM project_ports
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	p, err := scanProjectPort(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ProjectPort{}, ErrNotFound
+		}
+		return ProjectPort{}, err
+	}
+	return p, nil
+}
+
+func (s *Store) CreateProjectPort(ctx context.Context, p ProjectPort) (ProjectPort, error) {
+	if p.ProjectID == "" {
+		return ProjectPort{}, fmt.Errorf("project id is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ProjectPort{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	p, err = insertProjectPort(ctx, tx, p)
+	if err != nil {
+		return ProjectPort{}, err
+	}
+	if err := syncPrimaryPort(ctx, tx, p.ProjectID); err != nil {
+		return ProjectPort{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return ProjectPort{}, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateProjectPort(ctx context.Context, p ProjectPort) error {
+	if p.Protocol == "" {
+		p.Protocol = "tcp"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE project_ports
+		SET service = ?, host_ip = ?, host_port = ?, container_port = ?, protocol = ?
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`,
+		p.Service, p.HostIP, p.HostPort, p.ContainerPort, p.Protocol, p.ID, p.ProjectID)
+	if err != nil {
+		return mapConstraintErr(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := syncPrimaryPort(ctx, tx, p.ProjectID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) DeleteProjectPort(ctx context.Context, projectID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM project_ports
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := syncPrimaryPort(ctx, tx, projectID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertProjectPort(ctx context.Context, tx dbtx, p ProjectPort) (ProjectPort, error) {
+	if p.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return ProjectPort{}, err
+		}
+		p.ID = id
+	}
+	if p.Protocol == "" {
+		p.Protocol = "tcp"
+	}
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO project_ports (
+		  id, project_id, service, host_ip, host_port, container_port, protocol, deleted_at, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.ProjectID, p.Service, p.HostIP, p.HostPort, p.ContainerPort, p.Protocol, nil, p.CreatedAt)
+	if err != nil {
+		return ProjectPort{}, mapConstraintErr(err)
+	}
+	return p, nil
+}
+
+// replaceProjectPorts swaps every active mapping of the project for ports.
+func replaceProjectPorts(ctx context.Context, tx dbtx, projectID string, ports []ProjectPort, now int64) error {
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM project_ports
+		WHERE project_id = ? AND deleted_at IS NULL`, projectID); err != nil {
+		return err
+	}
+	// Inserted in order, so the first mapping stays the primary one.
+	for _, port := range ports {
+		port.ID = ""
+		port.ProjectID = projectID
+		port.CreatedAt = now
+		if _, err := insertProjectPort(ctx, tx, port); err != nil {
+			return err
+		}
+	}
+	return syncPrimaryPort(ctx, tx, projectID)
+}
+
+// syncPrimaryPort mirrors the first port mapping of a project into
+// projects.host_port/container_port, which older clients still read.
+func syncPrimaryPort(ctx context.Context, tx dbtx, projectID string) error {
+	var hostPort, containerPort int
+	err := tx.QueryRowContext(ctx, `
+		SELECT host_port, container_port
+		FROM project_ports
+		WHERE project_id = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid
+		LIMIT 1`, projectID).Scan(&hostPort, &containerPort)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE projects
+		SET host_port = ?, container_port = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`, hostPort, containerPort, time.Now().Unix(), projectID)
+	return mapConstraintErr(err)
+}
+
+func mapConstraintErr(err error) error {
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %v", ErrPortConflict, err)
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var se sqlite3.Error
+	return errors.As(err, &se) && se.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+func randomID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func scanProjectPort(s scanner) (ProjectPort, error) {
+	var p ProjectPort
+	err := s.Scan(&p.ID, &p.ProjectID, &p.Service, &p.HostIP, &p.HostPort, &p.ContainerPort, &p.Protocol, &p.CreatedAt)
+	if err != nil {
+		return ProjectPort{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	st, err := Open(context.Background(), filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+func TestProjectPorts_UniqueAcrossActiveProjects(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	a, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u", Ports: []ProjectPort{
+		{HostPort: 8080, ContainerPort: 80},
+		{HostPort: 9100, ContainerPort: 9100},
+		{HostPort: 53, ContainerPort: 53, Protocol: "udp"},
+	}})
+	if err != nil {
+		t.Fatalf("CreateProject a: %v", err)
+	}
+	if a.HostPort != 8080 || a.ContainerPort != 80 {
+		t.Fatalf("primary port = %d:%d, want 8080:80", a.HostPort, a.ContainerPort)
+	}
+
+	// Same number with another protocol is fine, same number and protocol is not.
+	if _, err := st.CreateProject(ctx, Project{ID: "b", Name: "b", GitURL: "u", HostPort: 53, ContainerPort: 53}); err != nil {
+		t.Fatalf("CreateProject b: %v", err)
+	}
+	_, err = st.CreateProjectPort(ctx, ProjectPort{ProjectID: "b", HostPort: 9100, ContainerPort: 1})
+	if !errors.Is(err, ErrPortConflict) {
+		t.Fatalf("CreateProjectPort err = %v, want ErrPortConflict", err)
+	}
+
+	if err := st.MarkProjectDeleted(ctx, "a"); err != nil {
+		t.Fatalf("MarkProjectDeleted: %v", err)
+	}
+	if _, err := st.CreateProjectPort(ctx, ProjectPort{ProjectID: "b", HostPort: 9100, ContainerPort: 1}); err != nil {
+		t.Fatalf("CreateProjectPort after delete: %v", err)
+	}
+
+	b, err := st.GetProject(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	if len(b.Ports) != 2 || b.Ports[0].HostPort != 53 || b.Ports[1].HostPort != 9100 {
+		t.Fatalf("ports = %+v", b.Ports)
+	}
+
+	if err := st.DeleteProjectPort(ctx, "b", b.Ports[0].ID); err != nil {
+		t.Fatalf("DeleteProjectPort: %v", err)
+	}
+	b, _ = st.GetProject(ctx, "b")
+	if b.HostPort != 9100 {
+		t.Fatalf("primary host port = %d, want 9100", b.HostPort)
+	}
+
+	// The same holds when both are the primary port.
+	if _, err := st.CreateProject(ctx, Project{ID: "c", Name: "c", GitURL: "u", Ports: []ProjectPort{{HostPort: 5353, ContainerPort: 53, Protocol: "udp"}}}); err != nil {
+		t.Fatalf("CreateProject c: %v", err)
+	}
+	if _, err := st.CreateProject(ctx, Project{ID: "d", Name: "d", GitURL: "u", Ports: []ProjectPort{{HostPort: 5353, ContainerPort: 53, Protocol: "tcp"}}}); err != nil {
+		t.Fatalf("CreateProject d: %v", err)
+	}
+}
+
+func TestUpdateProject_ReplacesPorts(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u", HostPort: 8080, ContainerPort: 80}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	p := Project{
+		ID:             "a",
+		Name:           "renamed",
+		GitURL:         "u2",
+		HealthCheck:    HealthCheck{Type: HealthCheckTCP, TimeoutSeconds: 30},
+		AutoRollback:   true,
+		ResourceLimits: ResourceLimits{MemoryMB: 256},
+		BuildArgs:      map[string]string{"VERSION": "1.2.3"},
+		Ports: []ProjectPort{
+			{HostPort: 9090, ContainerPort: 90},
+			{HostPort: 9091, ContainerPort: 91, Protocol: "udp"},
+		},
+	}
+	if err := st.UpdateProject(ctx, p); err != nil {
+		t.Fatalf("UpdateProject: %v", err)
+	}
+
+	got, err := st.GetProject(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	if got.Name != "renamed" || got.GitURL != "u2" || !got.AutoRollback || got.HealthCheck.Type != HealthCheckTCP ||
+		got.ResourceLimits.MemoryMB != 256 || got.DeployStrategy != DeployStrategyRecreate || got.BuildArgs["VERSION"] != "1.2.3" {
+		t.Errorf("GetProject = %+v", got)
+	}
+	if len(got.Ports) != 2 || got.HostPort != 9090 || got.Ports[1].Protocol != "udp" {
+		t.Errorf("Ports = %+v, primary %d", got.Ports, got.HostPort)
+	}
+
+	// The old host port is free again.
+	if _, err := st.CreateProject(ctx, Project{ID: "b", Name: "b", GitURL: "u", HostPort: 8080, ContainerPort: 80}); err != nil {
+		t.Fatalf("CreateProject reusing the old port: %v", err)
+	}
+	if err := st.UpdateProject(ctx, Project{ID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateProject(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateProjectSettings(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u", HostPort: 8080, ContainerPort: 80}); err != nil {
+		t.Fatalf("CreateProject a: %v", err)
+	}
+	if _, err := st.CreateProject(ctx, Project{ID: "b", Name: "b", GitURL: "u", HostPort: 9090, ContainerPort: 90}); err != nil {
+		t.Fatalf("CreateProject b: %v", err)
+	}
+	if err := st.SetProjectAutoRollback(ctx, "a", true); err != nil {
+		t.Fatalf("SetProjectAutoRollback: %v", err)
+	}
+
+	name, ref := "renamed", "v2"
+	if err := st.UpdateProjectSettings(ctx, "a", ProjectSettings{Name: &name, GitRef: &ref}); err != nil {
+		t.Fatalf("UpdateProjectSettings: %v", err)
+	}
+	got, err := st.GetProject(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	// Fields that were not given keep their values, ports included.
+	if got.Name != "renamed" || got.GitRef != "v2" || got.GitURL != "u" || !got.AutoRollback || got.HostPort != 8080 {
+		t.Errorf("GetProject = %+v", got)
+	}
+
+	// A port conflict rolls back the whole update.
+	other := "other"
+	err = st.UpdateProjectSettings(ctx, "a", ProjectSettings{Name: &other, Ports: []ProjectPort{{HostPort: 9090, ContainerPort: 80}}})
+	if !errors.Is(err, ErrPortConflict) {
+		t.Fatalf("UpdateProjectSettings err = %v, want ErrPortConflict", err)
+	}
+	got, _ = st.GetProject(ctx, "a")
+	if got.Name != "renamed" || len(got.Ports) != 1 || got.Ports[0].HostPort != 8080 {
+		t.Errorf("after conflict: name %q, ports %+v", got.Name, got.Ports)
+	}
+
+	if err := st.UpdateProjectSettings(ctx, "a", ProjectSettings{Ports: []ProjectPort{{HostPort: 8081, ContainerPort: 81}}}); err != nil {
+		t.Fatalf("UpdateProjectSettings ports: %v", err)
+	}
+	got, _ = st.GetProject(ctx, "a")
+	if got.HostPort != 8081 || got.ContainerPort != 81 {
+		t.Errorf("primary port = %d:%d, want 8081:81", got.HostPort, got.ContainerPort)
+	}
+
+	if err := st.UpdateProjectSettings(ctx, "missing", ProjectSettings{Name: &name}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateProjectSettings(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCloneProject(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u", HostPort: 8080, ContainerPort: 80}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if _, err := st.SetProjectEnv(ctx, ProjectEnvVar{ProjectID: "a", Name: "TOKEN", Value: "s3cret"}); err != nil {
+		t.Fatalf("SetProjectEnv: %v", err)
+	}
+	if _, err := st.CreateProjectVolume(ctx, ProjectVolume{ID: "v1", ProjectID: "a", Name: "data", ContainerPath: "/data"}); err != nil {
+		t.Fatalf("CreateProjectVolume: %v", err)
+	}
+	src, _ := st.GetProject(ctx, "a")
+
+	p := src
+	p.ID, p.Name, p.GitRef = "b", "a-staging", "staging"
+	p.AutoRollback = true
+	p.Ports = []ProjectPort{{HostPort: 8080, ContainerPort: 80}}
+	if _, err := st.CloneProject(ctx, "a", p, CloneOptions{}); !errors.Is(err, ErrPortConflict) {
+		t.Fatalf("CloneProject with a taken port = %v, want ErrPortConflict", err)
+	}
+	if _, err := st.GetProject(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetProject after failed clone = %v, want ErrNotFound", err)
+	}
+
+	p.Ports = []ProjectPort{{HostPort: 8081, ContainerPort: 80}}
+	if _, err := st.CloneProject(ctx, "a", p, CloneOptions{}); err != nil {
+		t.Fatalf("CloneProject: %v", err)
+	}
+	got, err := st.GetProject(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	if got.Name != "a-staging" || got.GitRef != "staging" || !got.AutoRollback || got.HostPort != 8081 {
+		t.Errorf("clone = %+v", got)
+	}
+	env, _ := st.ListProjectEnv(ctx, "b")
+	if len(env) != 1 || env[0].Value != "s3cret" {
+		t.Errorf("env = %+v", env)
+	}
+	volumes, _ := st.ListProjectVolumes(ctx, "b")
+	if len(volumes) != 1 || volumes[0].Name != "data" || volumes[0].ID == "v1" {
+		t.Errorf("volumes = %+v", volumes)
+	}
+
+	p.ID, p.Name, p.Ports = "c", "a-untrusted", nil
+	if _, err := st.CloneProject(ctx, "a", p, CloneOptions{OmitEnvValues: true}); err != nil {
+		t.Fatalf("CloneProject without env values: %v", err)
+	}
+	env, _ = st.ListProjectEnv(ctx, "c")
+	if len(env) != 1 || env[0].Name != "TOKEN" || env[0].Value != "" {
+		t.Errorf("env without values = %+v", env)
+	}
+}
+
+func TestCreateAndUpdateProjectWith(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	if _, err := st.CreateProject(ctx, Project{ID: "a", Name: "a", GitURL: "u"}); err != nil {
+		t.Fatalf("CreateProject: %v", err)
+	}
+	if _, err := st.CreateProjectDomain(ctx, ProjectDomain{ID: "d1", ProjectID: "a", Hostname: "a.example.com"}); err != nil {
+		t.Fatalf("CreateProjectDomain: %v", err)
+	}
+
+	p := Project{
+		ID: "b", Name: "b", GitURL: "u",
+		HealthCheck: HealthCheck{Type: HealthCheckHTTP, Path: "/healthz"},
+		BuildArgs:   map[string]string{"VERSION": "1"},
+		Ports:       []ProjectPort{{HostPort: 8080, ContainerPort: 80}},
+	}
+	add := ProjectAdditions{
+		Volumes: []ProjectVolume{{ID: "v1", Name: "data", ContainerPath: "/data"}},
+		Domains: []ProjectDomain{{ID: "d2", Hostname: "a.example.com"}},
+		Env:     []ProjectEnvVar{{Name: "TOKEN", Value: "s3cret"}},
+	}
+	if _, err := st.CreateProjectWith(ctx, p, add); !errors.Is(err, ErrDomainConflict) {
+		t.Fatalf("CreateProjectWith with a taken hostname = %v, want ErrDomainConflict", err)
+	}
+	if _, err := st.GetProject(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetProject after failed create = %v, want ErrNotFound", err)
+	}
+
+	add.Domains[0].Hostname = "b.example.com"
+	if _, err := st.CreateProjectWith(ctx, p, add); err != nil {
+		t.Fatalf("CreateProjectWith: %v", err)
+	}
+	got, err := st.GetProject(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetProject: %v", err)
+	}
+	if got.HealthCheck.Path != "/healthz" || got.BuildArgs["VERSION"] != "1" || got.HostPort != 8080 || got.DeployStrategy != DeployStrategyRecreate {
+		t.Errorf("project = %+v", got)
+	}
+	env, _ := st.ListProjectEnv(ctx, "b")
+	volumes, _ := st.ListProjectVolumes(ctx, "b")
+	domains, _ := st.ListProjectDomains(ctx, "b")
+	if len(env) != 1 || len(volumes) != 1 || len(domains) != 1 {
+		t.Errorf("env = %+v, volumes = %+v, domains = %+v", env, volumes, domains)
+	}
+
+	// A failing addition leaves the update unapplied.
+	got.Name = "renamed"
+	got.Ports = nil
+	if err := st.UpdateProjectWith(ctx, got, ProjectAdditions{
+		Volumes: []ProjectVolume{{ID: "v2", Name: "data", ContainerPath: "/other"}},
+	}); !errors.Is(err, ErrVolumeConflict) {
+		t.Fatalf("UpdateProjectWith with a duplicate volume = %v, want ErrVolumeConflict", err)
+	}
+	if cur, _ := st.GetProject(ctx, "b"); cur.Name != "b" {
+		t.Errorf("name after failed update = %q, want b", cur.Name)
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Preview links a pull request of a template project's repository to the
+// project created for it. The project is removed once ExpiresAt passes.
+type Preview struct {
+	ProjectID  string `json:"project_id"`
+	TemplateID string `json:"template_id"`
+	PRNumber   int    `json:"pr_number"`
+	HeadRef    string `json:"head_ref"`
+	HeadSHA    string `json:"head_sha"`
+	ExpiresAt  int64  `json:"expires_at"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+const previewColumns = `project_id, template_id, pr_number, head_ref, head_sha, expires_at, created_at, updated_at`
+
+// ListPreviews returns the previews of a template project, newest PR first.
+func (s *Store) ListPreviews(ctx context.Context, templateID string) ([]Preview, error) {
+	return s.queryPreviews(ctx, `
+		SELECT `+previewColumns+`
+		FROM previews
+		WHERE template_id = ?
+		ORDER BY pr_number DESC`, templateID)
+}
+
+// ListExpiredPreviews returns the previews whose ExpiresAt is not after now.
+func (s *Store) ListExpiredPreviews(ctx context.Context, now int64) ([]Preview, error) {
+	return s.queryPreviews(ctx, `
+		SELECT `+previewColumns+`
+		FROM previews
+		WHERE expires_at <= ?
+		ORDER BY expires_at`, now)
+}
+
+func (s *Store) GetPreview(ctx context.Context, templateID string, prNumber int) (Preview, error) {
+	previews, err := s.queryPreviews(ctx, `
+		SELECT `+previewColumns+`
+		FROM previews
+		WHERE template_id = ? AND pr_number = ?`, templateID, prNumber)
+	if err != nil {
+		return Preview{}, err
+	}
+	if len(previews) == 0 {
+		return Preview{}, ErrNotFound
+	}
+	return previews[0], nil
+}
+
+// GetPreviewByProject returns the preview a project was created for.
+func (s *Store) GetPreviewByProject(ctx context.Context, projectID string) (Preview, error) {
+	previews, err := s.queryPreviews(ctx, `
+		SELECT `+previewColumns+`
+		FROM previews
+		WHERE project_id = ?`, projectID)
+	if err != nil {
+		return Preview{}, err
+	}
+	if len(previews) == 0 {
+		return Preview{}, ErrNotFound
+	}
+	return previews[0], nil
+}
+
+// SavePreview creates the preview or updates its head and expiry.
+func (s *Store) SavePreview(ctx context.Context, p Preview) (Preview, error) {
+	now := time.Now().Unix()
+	if p.CreatedAt == 0 {
+		p.CreatedAt = now
+	}
+	p.UpdatedAt = now
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO previews (`+previewColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (project_id) DO UPDATE SET
+		  head_ref = excluded.head_ref, head_sha = excluded.head_sha,
+		  expires_at = excluded.expires_at, updated_at = excluded.updated_at`,
+		p.ProjectID, p.TemplateID, p.PRNumber, p.HeadRef, p.HeadSHA, p.ExpiresAt, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		return Preview{}, err
+	}
+	return p, nil
+}
+
+func (s *Store) DeletePreview(ctx context.Context, projectID string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM previews
+		WHERE project_id = ?`, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) queryPreviews(ctx context.Context, query string, args ...any) ([]Preview, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Preview
+	for rows.Next() {
+		var p Preview
+		if err := rows.Scan(&p.ProjectID, &p.TemplateID, &p.PRNumber, &p.HeadRef, &p.HeadSHA,
+			&p.ExpiresAt, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPreviews(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	for _, id := range []string{"tpl", "pr1", "pr2"} {
+		if _, err := st.CreateProject(ctx, Project{ID: id, Name: id, GitURL: "u"}); err != nil {
+			t.Fatalf("CreateProject(%s): %v", id, err)
+		}
+	}
+	if _, err := st.SavePreview(ctx, Preview{ProjectID: "pr1", TemplateID: "tpl", PRNumber: 1, HeadSHA: "a", ExpiresAt: 100}); err != nil {
+		t.Fatalf("SavePreview: %v", err)
+	}
+	if _, err := st.SavePreview(ctx, Preview{ProjectID: "pr2", TemplateID: "tpl", PRNumber: 2, HeadSHA: "b", ExpiresAt: 300}); err != nil {
+		t.Fatalf("SavePreview: %v", err)
+	}
+	// A second project for the same PR is refused.
+	if _, err := st.SavePreview(ctx, Preview{ProjectID: "tpl", TemplateID: "tpl", PRNumber: 2, ExpiresAt: 300}); err == nil {
+		t.Fatal("SavePreview with a duplicate PR succeeded")
+	}
+
+	// Saving again moves the head and the expiry.
+	if _, err := st.SavePreview(ctx, Preview{ProjectID: "pr1", TemplateID: "tpl", PRNumber: 1, HeadSHA: "c", ExpiresAt: 200}); err != nil {
+		t.Fatalf("SavePreview update: %v", err)
+	}
+	got, err := st.GetPreview(ctx, "tpl", 1)
+	if err != nil {
+		t.Fatalf("GetPreview: %v", err)
+	}
+	if got.ProjectID != "pr1" || got.HeadSHA != "c" || got.ExpiresAt != 200 {
+		t.Fatalf("GetPreview = %+v", got)
+	}
+
+	expired, err := st.ListExpiredPreviews(ctx, 250)
+	if err != nil {
+		t.Fatalf("ListExpiredPreviews: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ProjectID != "pr1" {
+		t.Fatalf("ListExpiredPreviews = %+v", expired)
+	}
+
+	if err := st.DeletePreview(ctx, "pr1"); err != nil {
+		t.Fatalf("DeletePreview: %v", err)
+	}
+	if _, err := st.GetPreviewByProject(ctx, "pr1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetPreviewByProject after delete = %v, want ErrNotFound", err)
+	}
+	all, _ := st.ListPreviews(ctx, "tpl")
+	if len(all) != 1 || all[0].PRNumber != 2 {
+		t.Fatalf("ListPreviews = %+v", all)
+	}
+}
@@ -14,6 +14,25 @@ CREATE TABLE IF NOT EXISTS projects (
   compose_content TEXT NOT NULL DEFAULT '',
   host_port INTEGER NOT NULL DEFAULT 0,
   container_port INTEGER NOT NULL DEFAULT 0,
+  expose_mode TEXT NOT NULL DEFAULT 'loopback',
+  bind_ip TEXT NOT NULL DEFAULT '',
+  health_type TEXT NOT NULL DEFAULT '',
+  health_path TEXT NOT NULL DEFAULT '',
+  health_expected_status INTEGER NOT NULL DEFAULT 0,
+  health_service TEXT NOT NULL DEFAULT '',
+  health_port INTEGER NOT NULL DEFAULT 0,
+  health_timeout INTEGER NOT NULL DEFAULT 60,
+  deploy_strategy TEXT NOT NULL DEFAULT 'recreate',
+  auto_rollback INTEGER NOT NULL DEFAULT 0,
+  memory_mb INTEGER NOT NULL DEFAULT 0,
+  memory_swap_mb INTEGER NOT NULL DEFAULT 0,
+  cpu_shares INTEGER NOT NULL DEFAULT 0,
+  cpu_quota INTEGER NOT NULL DEFAULT 0,
+  pids_limit INTEGER NOT NULL DEFAULT 0,
+  managed_by TEXT NOT NULL DEFAULT '',
+  build_args TEXT NOT NULL DEFAULT '',
+  git_credential_id TEXT NOT NULL DEFAULT '',
+  sparse_checkout INTEGER NOT NULL DEFAULT 0,
   last_status TEXT NOT NULL DEFAULT 'unknown',
   last_status_at INTEGER,
   deleted_at INTEGER,
@@ -21,12 +40,11 @@ CREATE TABLE IF NOT EXISTS projects (
   updated_at INTEGER NOT NULL
 );
 
-CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_host_port_active ON projects(host_port) WHERE deleted_at IS NULL AND host_port > 0;
-
 CREATE TABLE IF NOT EXISTS jobs (
   id TEXT PRIMARY KEY,
   project_id TEXT NOT NULL REFERENCES projects(id),
   type TEXT NOT NULL,
+  target TEXT NOT NULL DEFAULT '',
   status TEXT NOT NULL,
   current_step TEXT NOT NULL DEFAULT '',
   log TEXT NOT NULL DEFAULT '',
@@ -49,9 +67,97 @@ CREATE TABLE IF NOT EXISTS project_drafts (
   compose_path TEXT NOT NULL DEFAULT '',
   compose_content TEXT NOT NULL DEFAULT '',
   services_json TEXT NOT NULL DEFAULT '[]',
+  repo_config TEXT NOT NULL DEFAULT '',
+  git_credential_id TEXT NOT NULL DEFAULT '',
   repo_dir TEXT NOT NULL,
   created_at INTEGER NOT NULL,
   expires_at INTEGER NOT NULL
 );
 
 CREATE INDEX IF NOT EXISTS idx_project_drafts_expires_at ON project_drafts(expires_at);
+
+CREATE TABLE IF NOT EXISTS project_ports (
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id),
+  service TEXT NOT NULL DEFAULT '',
+  host_ip TEXT NOT NULL DEFAULT '',
+  host_port INTEGER NOT NULL DEFAULT 0,
+  container_port INTEGER NOT NULL,
+  protocol TEXT NOT NULL DEFAULT 'tcp',
+  deleted_at INTEGER,
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_ports_project ON project_ports(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_ports_host_port_active ON project_ports(host_port, protocol) WHERE deleted_at IS NULL AND host_port > 0;
+
+CREATE TABLE IF NOT EXISTS project_domains (
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id),
+  hostname TEXT NOT NULL,
+  service TEXT NOT NULL DEFAULT '',
+  container_port INTEGER NOT NULL DEFAULT 0,
+  deleted_at INTEGER,
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_domains_project ON project_domains(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_domains_hostname_active ON project_domains(hostname) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS project_volumes (
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id),
+  name TEXT NOT NULL,
+  container_path TEXT NOT NULL,
+  read_only INTEGER NOT NULL DEFAULT 0,
+  deleted_at INTEGER,
+  created_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_volumes_name_active ON project_volumes(project_id, name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_project_volumes_path_active ON project_volumes(project_id, container_path) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS backups (
+  id TEXT PRIMARY KEY,
+  project_id TEXT NOT NULL REFERENCES projects(id),
+  file_name TEXT NOT NULL,
+  size_bytes INTEGER NOT NULL DEFAULT 0,
+  volumes TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_backups_project_created ON backups(project_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS project_env (
+  project_id TEXT NOT NULL REFERENCES projects(id),
+  name TEXT NOT NULL,
+  value TEXT NOT NULL DEFAULT '',
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (project_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS previews (
+  project_id TEXT PRIMARY KEY REFERENCES projects(id),
+  template_id TEXT NOT NULL REFERENCES projects(id),
+  pr_number INTEGER NOT NULL,
+  head_ref TEXT NOT NULL DEFAULT '',
+  head_sha TEXT NOT NULL DEFAULT '',
+  expires_at INTEGER NOT NULL,
+  created_at INTEGER NOT NULL,
+  updated_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_previews_template_pr ON previews(template_id, pr_number);
+CREATE INDEX IF NOT EXISTS idx_previews_expires ON previews(expires_at);
+
+CREATE TABLE IF NOT EXISTS git_credentials (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  type TEXT NOT NULL,
+  username TEXT NOT NULL DEFAULT '',
+  secret TEXT NOT NULL DEFAULT '',
+  public_key TEXT NOT NULL DEFAULT '',
+  known_hosts TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL,
+  updated_at INTEGER NOT NULL
+);
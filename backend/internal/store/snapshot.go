@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// BackupTo writes a consistent copy of the database to path while it stays
+// online. path must not exist yet.
+func (s *Store) BackupTo(ctx context.Context, path string) error {
+	_, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path)
+	return err
+}
+
+// RestoreFile replaces the database at dbPath with the copy at src. It must
+// run before the database is opened. The replaced database is kept as
+// dbPath + ".before-restore".
+func RestoreFile(ctx context.Context, src, dbPath string) error {
+	if err := checkSnapshot(ctx, src); err != nil {
+		return fmt.Errorf("check %s: %w", src, err)
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		// Fold the WAL into the main file so the kept copy is complete.
+		if err := checkpoint(ctx, dbPath); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("checkpoint current database: %w", err)
+		}
+		if err := os.Rename(dbPath, dbPath+".before-restore"); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, dbPath)
+}
+
+func checkSnapshot(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check: %s", result)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = 'projects'`).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("not a last-deploy database")
+	}
+	return nil
+}
+
+func checkpoint(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrVolumeConflict is returned when a project already has a volume with the
+// same name or mounted at the same container path.
+var ErrVolumeConflict = errors.New("volume already exists")
+
+// ProjectVolume mounts a project-scoped named volume into the container of a
+// Dockerfile project. The Docker volume outlives the mount definition and
+// redeploys; it is only removed when the project is purged.
+type ProjectVolume struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	Name          string `json:"name"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+func (s *Store) ListProjectVolumes(ctx context.Context, projectID string) ([]ProjectVolume, error) {
+	return s.queryVolumes(ctx, `
+		SELECT id, project_id, name, container_path, read_only, created_at
+		FROM project_volumes
+		WHERE project_id = ? AND deleted_at IS NULL
+		ORDER BY created_at, rowid`, projectID)
+}
+
+func (s *Store) GetProjectVolume(ctx context.Context, projectID, id string) (ProjectVolume, error) {
+	volumes, err := s.queryVolumes(ctx, `
+		SELECT id, project_id, name, container_path, read_only, created_at
+		FROM project_volumes
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	if err != nil {
+		return ProjectVolume{}, err
+	}
+	if len(volumes) == 0 {
+		return ProjectVolume{}, ErrNotFound
+	}
+	return volumes[0], nil
+}
+
+func (s *Store) CreateProjectVolume(ctx context.Context, v ProjectVolume) (ProjectVolume, error) {
+	if v.ID == "" {
+		return ProjectVolume{}, fmt.Errorf("volume id is required")
+	}
+	if v.ProjectID == "" {
+		return ProjectVolume{}, fmt.Errorf("project id is required")
+	}
+	if v.Name == "" || v.ContainerPath == "" {
+		return ProjectVolume{}, fmt.Errorf("name and container path are required")
+	}
+	if v.CreatedAt == 0 {
+		v.CreatedAt = time.Now().Unix()
+	}
+	if err := insertProjectVolume(ctx, s.db, v); err != nil {
+		return ProjectVolume{}, err
+	}
+	return v, nil
+}
+
+func insertProjectVolume(ctx context.Context, tx dbtx, v ProjectVolume) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO project_volumes (id, project_id, name, container_path, read_only, deleted_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		v.ID, v.ProjectID, v.Name, v.ContainerPath, v.ReadOnly, nil, v.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %s", ErrVolumeConflict, v.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) DeleteProjectVolume(ctx context.Context, projectID, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		DELETE FROM project_volumes
+		WHERE id = ? AND project_id = ? AND deleted_at IS NULL`, id, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) queryVolumes(ctx context.Context, query string, args ...any) ([]ProjectVolume, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ProjectVolume
+	for rows.Next() {
+		var v ProjectVolume
+		if err := rows.Scan(&v.ID, &v.ProjectID, &v.Name, &v.ContainerPath, &v.ReadOnly, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
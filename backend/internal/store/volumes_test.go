@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProjectVolumes_UniquePerProject(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := st.CreateProject(ctx, Project{ID: id, Name: id, GitURL: "u"}); err != nil {
+			t.Fatalf("CreateProject %s: %v", id, err)
+		}
+	}
+	if _, err := st.CreateProjectVolume(ctx, ProjectVolume{ID: "v1", ProjectID: "a", Name: "data", ContainerPath: "/data"}); err != nil {
+		t.Fatalf("CreateProjectVolume: %v", err)
+	}
+
+	tests := []struct {
+		v    ProjectVolume
+		want error
+	}{
+		{ProjectVolume{ID: "v2", ProjectID: "a", Name: "data", ContainerPath: "/other"}, ErrVolumeConflict},
+		{ProjectVolume{ID: "v3", ProjectID: "a", Name: "other", ContainerPath: "/data"}, ErrVolumeConflict},
+		{ProjectVolume{ID: "v4", ProjectID: "b", Name: "data", ContainerPath: "/data"}, nil},
+	}
+	for _, tt := range tests {
+		_, err := st.CreateProjectVolume(ctx, tt.v)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("CreateProjectVolume(%s) = %v, want %v", tt.v.ID, err, tt.want)
+		}
+	}
+
+	// Deleted mounts and deleted projects free their name and path.
+	if err := st.DeleteProjectVolume(ctx, "a", "v1"); err != nil {
+		t.Fatalf("DeleteProjectVolume: %v", err)
+	}
+	if _, err := st.CreateProjectVolume(ctx, ProjectVolume{ID: "v5", ProjectID: "a", Name: "data", ContainerPath: "/data", ReadOnly: true}); err != nil {
+		t.Fatalf("CreateProjectVolume after delete: %v", err)
+	}
+	if err := st.MarkProjectDeleted(ctx, "b"); err != nil {
+		t.Fatalf("MarkProjectDeleted: %v", err)
+	}
+	if vs, err := st.ListProjectVolumes(ctx, "b"); err != nil || len(vs) != 0 {
+		t.Fatalf("ListProjectVolumes(b) = %v, %v; want none", vs, err)
+	}
+
+	vs, err := st.ListProjectVolumes(ctx, "a")
+	if err != nil {
+		t.Fatalf("ListProjectVolumes: %v", err)
+	}
+	if len(vs) != 1 || vs[0].ID != "v5" || !vs[0].ReadOnly {
+		t.Fatalf("ListProjectVolumes(a) = %+v, want read-only v5", vs)
+	}
+}
@@ -1,6 +1,7 @@
 package workspace
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,23 @@ func RepoDir(cfg config.Config, projectID string) string {
 	return filepath.Join(cfg.ReposDir(), projectID)
 }
 
+// BackupPath is where the archive of a backup is stored.
+func BackupPath(cfg config.Config, b store.Backup) (string, error) {
+	return SafeJoin(cfg.BackupsDir(), b.FileName)
+}
+
+// RemoveBackup deletes the archive and the record of a backup.
+func RemoveBackup(ctx context.Context, cfg config.Config, st *store.Store, b store.Backup) error {
+	path, err := BackupPath(cfg, b)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return st.DeleteBackup(ctx, b.ProjectID, b.ID)
+}
+
 func SafeJoin(base, rel string) (string, error) {
 	if base == "" {
 		return "", fmt.Errorf("base is required")
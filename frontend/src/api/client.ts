@@ -1,5 +1,10 @@
+import type { ApiErrorBody } from './types'
+
 export class ApiError extends Error {
   readonly status: number
+  // code 是服务端返回的错误码，例如 project_not_found；非 JSON 响应时为空
+  readonly code: string
+  readonly field?: string
   readonly body: unknown
 
   constructor(message: string, status: number, body: unknown) {
@@ -7,9 +12,18 @@ export class ApiError extends Error {
     this.name = 'ApiError'
     this.status = status
     this.body = body
+    const err = isErrorBody(body) ? body.error : undefined
+    this.code = err?.code ?? ''
+    this.field = err?.field
   }
 }
 
+function isErrorBody(body: unknown): body is ApiErrorBody {
+  if (typeof body !== 'object' || body == null || !('error' in body)) return false
+  const err = (body as { error: unknown }).error
+  return typeof err === 'object' && err != null && 'code' in err
+}
+
 const API_BASE = (import.meta.env.VITE_API_BASE_URL ?? '/api').replace(/\/$/, '')
 
 function urlFor(path: string): string {
@@ -19,12 +33,32 @@ function urlFor(path: string): string {
   return `${API_BASE}${path}`
 }
 
+const TOKEN_KEY = 'last-deploy-token'
+
+// getToken 返回保存在浏览器中的 API 令牌，未设置时为空字符串
+export function getToken(): string {
+  return localStorage.getItem(TOKEN_KEY) ?? ''
+}
+
+// setToken 保存 API 令牌，传入空字符串时清除
+export function setToken(token: string) {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token)
+  } else {
+    localStorage.removeItem(TOKEN_KEY)
+  }
+}
+
 export async function request<T>(path: string, init: RequestInit = {}): Promise<T> {
   const headers = new Headers(init.headers)
   headers.set('accept', 'application/json')
   if (init.body != null && !headers.has('content-type')) {
     headers.set('content-type', 'application/json')
   }
+  const token = getToken()
+  if (token && !headers.has('authorization')) {
+    headers.set('authorization', `Bearer ${token}`)
+  }
 
   const res = await fetch(urlFor(path), { ...init, headers })
 
@@ -36,10 +70,7 @@ export async function request<T>(path: string, init: RequestInit = {}): Promise<
     : await res.text().catch(() => null)
 
   if (!res.ok) {
-    const message =
-      typeof body === 'object' && body && 'error' in body
-        ? String((body as { error: unknown }).error)
-        : `HTTP ${res.status}`
+    const message = isErrorBody(body) ? body.error.message : `HTTP ${res.status}`
     throw new ApiError(message, res.status, body)
   }
 
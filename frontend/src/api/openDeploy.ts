@@ -1,11 +1,18 @@
 import { request } from './client'
 import type {
+  CloneProjectRequest,
+  CreateGitCredentialRequest,
   CreateProjectFromDraftRequest,
   CreateProjectRequest,
   DetectProjectRequest,
   DetectProjectResponse,
+  GitCredential,
   Job,
+  Preview,
   Project,
+  UpdateGitCredentialRequest,
+  UpdateProjectRequest,
+  UpdateProjectResponse,
 } from './types'
 
 export function health(): Promise<{ ok: boolean }> {
@@ -62,6 +69,24 @@ export function getJob(id: string): Promise<{ job: Job }> {
   return request(`/jobs/${encodeURIComponent(id)}`)
 }
 
+export function updateProject(
+  id: string,
+  body: UpdateProjectRequest,
+): Promise<UpdateProjectResponse> {
+  return request(`/projects/${encodeURIComponent(id)}`, { method: 'PATCH', body: JSON.stringify(body) })
+}
+
+export function cloneProject(
+  id: string,
+  body: CloneProjectRequest,
+): Promise<{ project: Project; job?: Job }> {
+  return request(`/projects/${encodeURIComponent(id)}/clone`, { method: 'POST', body: JSON.stringify(body) })
+}
+
+export function listProjectPreviews(id: string): Promise<{ previews: Preview[] }> {
+  return request(`/projects/${encodeURIComponent(id)}/previews`)
+}
+
 export function getProjectLatestJob(id: string): Promise<{ job: Job }> {
   return request(`/projects/${encodeURIComponent(id)}/jobs/latest`)
 }
@@ -80,3 +105,23 @@ export function updateProjectConfig(
   })
 }
 
+export function listGitCredentials(): Promise<{ credentials: GitCredential[] }> {
+  return request('/git-credentials')
+}
+
+export function createGitCredential(
+  body: CreateGitCredentialRequest,
+): Promise<{ credential: GitCredential }> {
+  return request('/git-credentials', { method: 'POST', body: JSON.stringify(body) })
+}
+
+export function updateGitCredential(
+  id: string,
+  body: UpdateGitCredentialRequest,
+): Promise<{ credential: GitCredential }> {
+  return request(`/git-credentials/${encodeURIComponent(id)}`, { method: 'PATCH', body: JSON.stringify(body) })
+}
+
+export function deleteGitCredential(id: string): Promise<{ ok: boolean }> {
+  return request(`/git-credentials/${encodeURIComponent(id)}`, { method: 'DELETE' })
+}
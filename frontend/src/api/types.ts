@@ -6,16 +6,19 @@ export type ProjectStatus =
   | 'failed'
   | 'deleted'
   | 'deploying'
+  | 'degraded'
   | (string & {})
 
 export type JobStatus = 'queued' | 'running' | 'succeeded' | 'failed' | (string & {})
 
-export type JobType = 'deploy' | 'start' | 'stop' | 'pause' | 'unpause' | 'delete' | (string & {})
+export type JobType = 'deploy' | 'start' | 'stop' | 'pause' | 'unpause' | 'delete' | 'purge' | 'backup' | 'restore' | (string & {})
 
 export type DeployType = 'auto' | 'dockerfile' | 'compose'
 
 export type UnixSeconds = number
 
+export type ExposeMode = 'loopback' | 'all' | 'ip' | 'none'
+
 export interface Project {
   id: string
   name: string
@@ -30,6 +33,19 @@ export interface Project {
   compose_content: string
   host_port: number
   container_port: number
+  ports: ProjectPort[] | null
+  expose_mode: ExposeMode
+  bind_ip: string
+  health_check: HealthCheck
+  deploy_strategy: DeployStrategy
+  auto_rollback: boolean
+  resource_limits: ResourceLimits
+  managed_by: string
+  build_args?: Record<string, string>
+  /** credential used to clone a private repository */
+  git_credential_id?: string
+  /** only repo_subdir is checked out */
+  sparse_checkout: boolean
   last_status: ProjectStatus
   last_status_at?: UnixSeconds | null
   deleted_at?: UnixSeconds | null
@@ -37,10 +53,70 @@ export interface Project {
   updated_at: UnixSeconds
 }
 
+export interface ResourceLimits {
+  memory_mb: number
+  memory_swap_mb: number
+  cpu_shares: number
+  cpu_quota: number
+  pids_limit: number
+}
+
+export type DeployStrategy = 'recreate' | 'blue_green'
+
+export type HealthCheckType = '' | 'http' | 'tcp' | 'docker'
+
+export interface HealthCheck {
+  type: HealthCheckType
+  path: string
+  expected_status: number
+  service: string
+  port: number
+  timeout_seconds: number
+}
+
+export type PortProtocol = 'tcp' | 'udp' | 'sctp'
+
+export interface ProjectPort {
+  id: string
+  project_id: string
+  service: string
+  host_ip: string
+  host_port: number
+  container_port: number
+  protocol: PortProtocol
+  created_at: UnixSeconds
+}
+
+export interface ProjectDomain {
+  id: string
+  project_id: string
+  hostname: string
+  service: string
+  container_port: number
+  created_at: UnixSeconds
+}
+
+export type CertificateSource = 'custom' | 'acme'
+
+export interface CertificateInfo {
+  hostname: string
+  source: CertificateSource
+  issuer: string
+  dns_names: string[] | null
+  not_before: UnixSeconds
+  not_after: UnixSeconds
+}
+
+export interface ProjectDomainsResponse {
+  default_hostname: string
+  domains: ProjectDomain[] | null
+}
+
 export interface Job {
   id: string
   project_id: string
   type: JobType
+  target?: string
   status: JobStatus
   current_step: string
   log: string
@@ -53,21 +129,106 @@ export interface Job {
 export interface CreateProjectRequest {
   name: string
   git_url: string
+  git_credential_id?: string
+  /** 0 lets the server pick a free port */
   host_port: number
   container_port: number
   deploy?: boolean
 
   git_ref?: string
   repo_subdir?: string
+  /** check out only repo_subdir */
+  sparse_checkout?: boolean
   deploy_type?: DeployType
   compose_file?: string
   compose_service?: string
   dockerfile_path?: string
+  expose_mode?: ExposeMode
+  bind_ip?: string
+}
+
+/** Fields left out are not changed; ports replaces every mapping */
+export interface UpdateProjectRequest {
+  name?: string
+  git_url?: string
+  git_ref?: string
+  /** '' switches back to anonymous access */
+  git_credential_id?: string
+  repo_subdir?: string
+  /** check out only repo_subdir */
+  sparse_checkout?: boolean
+  deploy_type?: DeployType
+  compose_file?: string
+  compose_service?: string
+  dockerfile_path?: string
+  expose_mode?: ExposeMode
+  bind_ip?: string
+  ports?: BundlePort[]
+}
+
+export interface UpdateProjectResponse {
+  project: Project
+  /** the running containers keep the old settings until the next deploy */
+  requires_redeploy: boolean
+}
+
+export interface CloneProjectRequest {
+  name: string
+  /** defaults to the source project's ref */
+  git_ref?: string
+  /** host port of the first mapping, 0 lets the server pick a free port */
+  host_port?: number
+  deploy?: boolean
+}
+
+/** a pull request deployment created from a template project */
+export interface Preview {
+  project_id: string
+  template_id: string
+  pr_number: number
+  head_ref: string
+  head_sha: string
+  /** unix seconds; each push to the PR extends it */
+  expires_at: number
+  created_at: number
+  updated_at: number
 }
 
 export interface DetectProjectRequest {
   name: string
   git_url: string
+  git_credential_id?: string
+}
+
+export type GitCredentialType = 'ssh' | 'token' | 'basic'
+
+/** secrets are never returned; public_key is the deploy key to add to the repository */
+export interface GitCredential {
+  id: string
+  name: string
+  type: GitCredentialType
+  username: string
+  public_key?: string
+  known_hosts: string
+  created_at: UnixSeconds
+  updated_at: UnixSeconds
+}
+
+export interface CreateGitCredentialRequest {
+  name: string
+  type: GitCredentialType
+  username?: string
+  /** token or password; for ssh an existing private key, or empty to generate one */
+  secret?: string
+  known_hosts?: string
+}
+
+export interface UpdateGitCredentialRequest {
+  name?: string
+  username?: string
+  /** replaces the secret; '' generates a new ssh key */
+  secret?: string
+  known_hosts?: string
 }
 
 export interface DetectProjectResponse {
@@ -78,6 +239,20 @@ export interface DetectProjectResponse {
   compose_path: string
   compose_content: string
   services: string[]
+  compose_service: string
+  config_path: string
+  config: RepoConfig | null
+}
+
+export interface RepoConfig {
+  deploy_type?: DeployType
+  dockerfile_path?: string
+  compose_file?: string
+  compose_service?: string
+  ports?: BundlePort[]
+  health_check?: HealthCheck
+  env?: string[]
+  build_args?: Record<string, string>
 }
 
 export interface CreateProjectFromDraftRequest {
@@ -87,6 +262,154 @@ export interface CreateProjectFromDraftRequest {
   compose_service?: string
   git_ref?: string
   repo_subdir?: string
+  sparse_checkout?: boolean
+  host_port?: number
+  expose_mode?: ExposeMode
+  bind_ip?: string
   deploy?: boolean
+  env?: Record<string, string>
+}
+
+export interface ProjectVolume {
+  id: string
+  project_id: string
+  name: string
+  container_path: string
+  read_only: boolean
+  created_at: number
+}
+
+export interface ProjectVolumesResponse {
+  volumes: ProjectVolume[] | null
+}
+
+export interface ProjectEnvVar {
+  project_id: string
+  name: string
+  value: string
+  updated_at: number
 }
 
+export interface ProjectEnvResponse {
+  env: ProjectEnvVar[] | null
+}
+
+export interface Backup {
+  id: string
+  project_id: string
+  file_name: string
+  size_bytes: number
+  volumes: string[] | null
+  created_at: number
+}
+
+export interface BackupsResponse {
+  backups: Backup[] | null
+}
+
+export interface DBBackup {
+  name: string
+  size_bytes: number
+  created_at: number
+}
+
+export interface DBBackupsResponse {
+  backups: DBBackup[] | null
+}
+
+export interface BundlePort {
+  service?: string
+  host_ip?: string
+  host_port?: number
+  container_port: number
+  protocol?: string
+}
+
+export interface BundleVolume {
+  name: string
+  container_path: string
+  read_only?: boolean
+}
+
+export interface BundleDomain {
+  hostname: string
+  service?: string
+  container_port?: number
+}
+
+export interface BundleProject {
+  name: string
+  git_url: string
+  git_ref?: string
+  repo_subdir?: string
+  deploy_type?: DeployType
+  compose_file?: string
+  compose_service?: string
+  dockerfile_path?: string
+  dockerfile_content?: string
+  compose_content?: string
+  expose_mode?: ExposeMode
+  bind_ip?: string
+  ports?: BundlePort[]
+  health_check?: HealthCheck
+  deploy_strategy?: DeployStrategy
+  auto_rollback?: boolean
+  sparse_checkout?: boolean
+  resource_limits?: ResourceLimits
+  volumes?: BundleVolume[]
+  domains?: BundleDomain[]
+  build_args?: Record<string, string>
+  env?: string[]
+}
+
+export interface ProjectBundle {
+  version: number
+  exported_at?: number
+  projects: BundleProject[]
+}
+
+export type ImportAction = 'create' | 'update' | 'unchanged' | 'conflict' | 'delete'
+
+export interface ImportItem {
+  name: string
+  action: ImportAction
+  project_id?: string
+  changes?: string[]
+  conflicts?: string[]
+  env?: string[]
+  error?: string
+}
+
+export interface ImportResponse {
+  dry_run: boolean
+  items: ImportItem[]
+}
+
+export interface GitOpsPlan {
+  commit: string
+  items: ImportItem[] | null
+  destructive: boolean
+}
+
+export interface GitOpsStatus {
+  enabled: boolean
+  repo?: string
+  ref?: string
+  path?: string
+  allow_destructive: boolean
+  adopt: boolean
+  last_sync_at?: number
+  last_commit?: string
+  last_error?: string
+  last_plan?: GitOpsPlan
+}
+
+// 错误响应，code 为稳定的错误码，message 按 Accept-Language 本地化
+export interface ApiErrorBody {
+  error: {
+    code: string
+    message: string
+    field?: string
+    details?: unknown
+  }
+}
@@ -32,6 +32,7 @@ type Step2Values = {
   git_ref?: string
   repo_subdir?: string
   deploy: boolean
+  env?: Record<string, string>
 }
 
 function toErrorMessage(err: unknown): string {
@@ -108,6 +109,7 @@ export default function NewProjectWizardModal({ open, onCancel, onCreated }: Pro
         git_ref: values.git_ref?.trim() || undefined,
         repo_subdir: values.repo_subdir?.trim() || undefined,
         deploy: values.deploy,
+        env: values.env,
       })
       reset()
       onCreated(result)
@@ -210,6 +212,17 @@ export default function NewProjectWizardModal({ open, onCancel, onCreated }: Pro
             </Form.Item>
           )}
 
+          {(detectResult.config?.env?.length ?? 0) > 0 && (
+            <div style={{ marginBottom: 16 }}>
+              <div style={{ marginBottom: 8 }}>环境变量（last-deploy.yaml 声明，可稍后填写）:</div>
+              {(detectResult.config?.env ?? []).map((n) => (
+                <Form.Item key={n} label={n} name={['env', n]}>
+                  <Input.Password autoComplete="off" />
+                </Form.Item>
+              ))}
+            </div>
+          )}
+
           <Form.Item label="创建后立即部署" name="deploy" valuePropName="checked">
             <Switch />
           </Form.Item>
@@ -144,7 +144,7 @@ export default function ProjectTable({
       width: 140,
       render: (_: unknown, p: Project) => {
         const status = normalizeStatus(p.last_status)
-        if (status !== 'running') return '—'
+        if (status !== 'running' && status !== 'degraded') return '—'
 
         let hostPort = p.host_port
         if (p.deploy_type === 'compose' && p.host_port === 0) {
@@ -175,7 +175,7 @@ export default function ProjectTable({
       render: (_: unknown, p: Project) => {
         const status = normalizeStatus(p.last_status)
         const isBusy = status === 'deploying'
-        const isRunning = status === 'running'
+        const isRunning = status === 'running' || status === 'degraded'
         const isPaused = status === 'paused'
         const isStopped = status === 'stopped'
 
@@ -18,6 +18,8 @@ export function ProjectStatusTag({ status }: { status?: ProjectStatus }) {
       return <Tag color="#ef4444" style={{ borderColor: '#ef4444' }}>失败</Tag>
     case 'deploying':
       return <Tag color="#8b5cf6" style={{ borderColor: '#8b5cf6' }}>部署中</Tag>
+    case 'degraded':
+      return <Tag color="#f97316" style={{ borderColor: '#f97316' }}>已回滚</Tag>
     case 'deleted':
       return <Tag color="#4b5563" style={{ borderColor: '#4b5563' }}>已删除</Tag>
     case 'unknown':
@@ -0,0 +1,43 @@
+import { Input, Modal, Typography } from 'antd'
+import { useState } from 'react'
+import { getToken, setToken } from '../api/client'
+
+interface TokenModalProps {
+  open: boolean
+  onClose: () => void
+  onSaved: () => void
+}
+
+export default function TokenModal({ open, onClose, onSaved }: TokenModalProps) {
+  const [value, setValue] = useState('')
+
+  const handleSave = () => {
+    setToken(value.trim())
+    onSaved()
+    onClose()
+  }
+
+  return (
+    <Modal
+      title="API 令牌"
+      open={open}
+      onOk={handleSave}
+      onCancel={onClose}
+      okText="保存"
+      cancelText="取消"
+      afterOpenChange={(visible) => visible && setValue(getToken())}
+      destroyOnClose
+    >
+      <Typography.Paragraph type="secondary">
+        服务端启用令牌认证时，在此填写 API 令牌。令牌只保存在当前浏览器中，留空则清除。
+      </Typography.Paragraph>
+      <Input.Password
+        value={value}
+        onChange={(e) => setValue(e.target.value)}
+        onPressEnter={handleSave}
+        placeholder="API 令牌"
+        autoComplete="off"
+      />
+    </Modal>
+  )
+}
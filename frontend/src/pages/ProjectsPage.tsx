@@ -1,4 +1,4 @@
-import { PlusOutlined, ReloadOutlined, RocketOutlined } from '@ant-design/icons'
+import { KeyOutlined, PlusOutlined, ReloadOutlined, RocketOutlined } from '@ant-design/icons'
 import { Alert, Button, Layout, Space, message } from 'antd'
 import { useCallback, useEffect, useRef, useState } from 'react'
 import { ApiError } from '../api/client'
@@ -8,6 +8,7 @@ import ConfigEditorModal from '../components/ConfigEditorModal'
 import JobDrawer from '../components/JobDrawer'
 import NewProjectWizardModal from '../components/NewProjectWizardModal'
 import ProjectTable from '../components/ProjectTable'
+import TokenModal from '../components/TokenModal'
 
 const { Header, Content } = Layout
 
@@ -25,12 +26,14 @@ export default function ProjectsPage() {
   const [error, setError] = useState<string | null>(null)
   const loadedOnceRef = useRef(false)
   const inFlightRef = useRef(false)
+  const tokenPromptedRef = useRef(false)
 
   const [newModalOpen, setNewModalOpen] = useState(false)
   const [jobDrawerOpen, setJobDrawerOpen] = useState(false)
   const [activeJobId, setActiveJobId] = useState<string | undefined>(undefined)
   const [configEditorOpen, setConfigEditorOpen] = useState(false)
   const [editingProject, setEditingProject] = useState<Project | null>(null)
+  const [tokenModalOpen, setTokenModalOpen] = useState(false)
 
   const refreshProjects = useCallback(async () => {
     if (inFlightRef.current) return
@@ -43,6 +46,11 @@ export default function ProjectsPage() {
       loadedOnceRef.current = true
     } catch (err) {
       setError(toErrorMessage(err))
+      // 未授权时提示填写令牌，轮询中只提示一次
+      if (err instanceof ApiError && err.status === 401 && !tokenPromptedRef.current) {
+        tokenPromptedRef.current = true
+        setTokenModalOpen(true)
+      }
     } finally {
       setLoading(false)
       inFlightRef.current = false
@@ -159,6 +167,9 @@ export default function ProjectsPage() {
               </span>
             </div>
             <Space>
+              <Button icon={<KeyOutlined />} onClick={() => setTokenModalOpen(true)}>
+                令牌
+              </Button>
               <Button
                 icon={<ReloadOutlined />}
                 onClick={() => void refreshProjects()}
@@ -224,6 +235,12 @@ export default function ProjectsPage() {
         onClose={() => setConfigEditorOpen(false)}
         onSuccess={() => void refreshProjects()}
       />
+
+      <TokenModal
+        open={tokenModalOpen}
+        onClose={() => setTokenModalOpen(false)}
+        onSaved={() => void refreshProjects()}
+      />
     </>
   )
 }